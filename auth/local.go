@@ -0,0 +1,136 @@
+package auth
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/json"
+	"html/template"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/coreos/go-oidc"
+	"github.com/pkg/errors"
+	"golang.org/x/oauth2"
+	jose "gopkg.in/square/go-jose.v2"
+)
+
+/** Constant and Variable Definitions */
+
+// localTokenLifetime is how long ID tokens from the local provider are
+// valid for.
+const localTokenLifetime = time.Hour
+
+var localLoginTemplate = template.Must(template.New("local_login").Parse(`<!DOCTYPE html>
+<title>Local sign-in</title>
+<p>This is the local stand-in sign-in provider. Sign in as:</p>
+<form method="POST">
+  <input type="hidden" name="state" value="{{.}}">
+  <input type="email" name="email" placeholder="Email" required autofocus>
+  <button type="submit">Sign in</button>
+</form>
+`))
+
+/** Type Definitions */
+
+// LocalProvider is a stand-in OpenID Connect provider for dev and
+// tests. It lets anyone sign in as any email address, so it must never
+// be used in prod.
+//
+// Its sign-in form is served by its ServeHTTP method at
+// Config.LocalLoginURL. The code it redirects back with is the ID
+// token itself.
+type LocalProvider struct {
+	cfg      Config
+	signer   jose.Signer
+	verifier *oidc.IDTokenVerifier
+}
+
+// localKeySet verifies tokens signed by a LocalProvider.
+type localKeySet struct {
+	key *rsa.PublicKey
+}
+
+/** Functions and Methods */
+
+func NewLocalProvider(cfg Config) (*LocalProvider, error) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to generate local signing key")
+	}
+	signer, err := jose.NewSigner(jose.SigningKey{Algorithm: jose.RS256, Key: key}, nil)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to create local signer")
+	}
+	return &LocalProvider{
+		cfg:    cfg,
+		signer: signer,
+		verifier: oidc.NewVerifier(LocalIssuer, &localKeySet{key: &key.PublicKey}, &oidc.Config{
+			ClientID: cfg.ClientID,
+		}),
+	}, nil
+}
+
+// IssueIDToken returns a signed ID token for email, as if they had
+// signed in.
+func (p *LocalProvider) IssueIDToken(email string) (string, error) {
+	now := time.Now()
+	payload, err := json.Marshal(map[string]interface{}{
+		"iss":            LocalIssuer,
+		"aud":            p.cfg.ClientID,
+		"sub":            email,
+		"email":          email,
+		"email_verified": true,
+		"iat":            now.Unix(),
+		"exp":            now.Add(localTokenLifetime).Unix(),
+	})
+	if err != nil {
+		return "", errors.Wrap(err, "failed to encode ID token")
+	}
+	jws, err := p.signer.Sign(payload)
+	if err != nil {
+		return "", errors.Wrap(err, "failed to sign ID token")
+	}
+	return jws.CompactSerialize()
+}
+
+func (p *LocalProvider) AuthCodeURL(ctx context.Context, state string, opts ...oauth2.AuthCodeOption) (string, error) {
+	return p.cfg.LocalLoginURL + "?" + url.Values{"state": {state}}.Encode(), nil
+}
+
+func (p *LocalProvider) Exchange(ctx context.Context, code string) (string, error) {
+	return code, nil
+}
+
+func (p *LocalProvider) Verify(ctx context.Context, rawIDToken string) (Identity, error) {
+	return verifyIDToken(ctx, p.cfg, p.verifier, rawIDToken)
+}
+
+// ServeHTTP serves the sign-in form, and sends users back to
+// Config.RedirectURL once they've picked who to sign in as.
+func (p *LocalProvider) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		localLoginTemplate.Execute(w, r.FormValue("state"))
+		return
+	}
+
+	token, err := p.IssueIDToken(r.PostFormValue("email"))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	http.Redirect(w, r, p.cfg.RedirectURL+"?"+url.Values{
+		"state": {r.PostFormValue("state")},
+		"code":  {token},
+	}.Encode(), http.StatusFound)
+}
+
+func (s *localKeySet) VerifySignature(ctx context.Context, jwt string) ([]byte, error) {
+	jws, err := jose.ParseSigned(jwt)
+	if err != nil {
+		return nil, errors.Wrap(err, "malformed ID token")
+	}
+	return jws.Verify(s.key)
+}
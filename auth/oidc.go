@@ -0,0 +1,132 @@
+package auth
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/coreos/go-oidc"
+	"github.com/pkg/errors"
+	"golang.org/x/oauth2"
+)
+
+/** Constant and Variable Definitions */
+
+// discoveryAttempts is how many times discovery is tried before a
+// sign-in gives up. The next sign-in tries again.
+const discoveryAttempts = 3
+
+// discoveryBackoff is how long to wait after the first failed
+// discovery attempt. It doubles after each one.
+var discoveryBackoff = time.Second
+
+/** Type Definitions */
+
+// OIDCProvider signs users in with an OpenID Connect provider. The
+// provider's configuration is discovered the first time it's needed,
+// so ADB can start while the provider is unreachable.
+type OIDCProvider struct {
+	cfg Config
+
+	mu       sync.Mutex
+	oauth2   *oauth2.Config
+	verifier *oidc.IDTokenVerifier
+}
+
+/** Functions and Methods */
+
+func NewOIDCProvider(cfg Config) *OIDCProvider {
+	return &OIDCProvider{cfg: cfg}
+}
+
+// discover fetches the provider's configuration, unless that has
+// already been done.
+func (p *OIDCProvider) discover() (*oauth2.Config, *oidc.IDTokenVerifier, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.verifier != nil {
+		return p.oauth2, p.verifier, nil
+	}
+
+	var provider *oidc.Provider
+	var err error
+	backoff := discoveryBackoff
+	for attempt := 0; attempt < discoveryAttempts; attempt++ {
+		if attempt > 0 {
+			time.Sleep(backoff)
+			backoff *= 2
+		}
+		// The provider keeps this context to fetch signing keys
+		// later, so it can't be a request's context.
+		provider, err = oidc.NewProvider(context.Background(), p.cfg.Issuer)
+		if err == nil {
+			break
+		}
+	}
+	if err != nil {
+		return nil, nil, errors.Wrapf(err, "failed to discover auth provider %s", p.cfg.Issuer)
+	}
+
+	p.oauth2 = &oauth2.Config{
+		ClientID:     p.cfg.ClientID,
+		ClientSecret: p.cfg.ClientSecret,
+		Endpoint:     provider.Endpoint(),
+		RedirectURL:  p.cfg.RedirectURL,
+		Scopes:       []string{oidc.ScopeOpenID, "email"},
+	}
+	p.verifier = provider.Verifier(&oidc.Config{
+		ClientID: p.cfg.ClientID,
+	})
+	return p.oauth2, p.verifier, nil
+}
+
+func (p *OIDCProvider) AuthCodeURL(ctx context.Context, state string, opts ...oauth2.AuthCodeOption) (string, error) {
+	conf, _, err := p.discover()
+	if err != nil {
+		return "", err
+	}
+	return conf.AuthCodeURL(state, opts...), nil
+}
+
+func (p *OIDCProvider) Exchange(ctx context.Context, code string) (string, error) {
+	conf, _, err := p.discover()
+	if err != nil {
+		return "", err
+	}
+	token, err := conf.Exchange(ctx, code)
+	if err != nil {
+		return "", errors.Wrap(err, "failed to exchange auth code")
+	}
+	rawIDToken, ok := token.Extra("id_token").(string)
+	if !ok {
+		return "", errors.New("auth provider did not return an ID token")
+	}
+	return rawIDToken, nil
+}
+
+func (p *OIDCProvider) Verify(ctx context.Context, rawIDToken string) (Identity, error) {
+	_, verifier, err := p.discover()
+	if err != nil {
+		return Identity{}, err
+	}
+	return verifyIDToken(ctx, p.cfg, verifier, rawIDToken)
+}
+
+func verifyIDToken(ctx context.Context, cfg Config, verifier *oidc.IDTokenVerifier, rawIDToken string) (Identity, error) {
+	token, err := verifier.Verify(ctx, rawIDToken)
+	if err != nil {
+		return Identity{}, errors.Wrap(err, "failed to verify ID token")
+	}
+	var claims struct {
+		Email         string `json:"email"`
+		EmailVerified bool   `json:"email_verified"`
+	}
+	if err := token.Claims(&claims); err != nil {
+		return Identity{}, errors.Wrap(err, "failed to parse ID token claims")
+	}
+	identity := Identity{Email: claims.Email, EmailVerified: claims.EmailVerified}
+	if err := checkIdentity(cfg, identity); err != nil {
+		return Identity{}, err
+	}
+	return identity, nil
+}
@@ -0,0 +1,115 @@
+// Package auth signs users in with an OpenID Connect provider. Which
+// provider is used comes from config, so ADB isn't tied to Google, and
+// a local stand-in provider can be used in dev and tests.
+package auth
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"strings"
+
+	"github.com/dxe/adb/config"
+	"github.com/pkg/errors"
+	"golang.org/x/oauth2"
+)
+
+/** Constant and Variable Definitions */
+
+// LocalIssuer selects the local stand-in provider instead of a real
+// OpenID Connect provider.
+const LocalIssuer = "local"
+
+/** Type Definitions */
+
+type Config struct {
+	// Issuer is the OpenID Connect issuer URL, e.g.
+	// https://accounts.google.com, or LocalIssuer.
+	Issuer       string
+	ClientID     string
+	ClientSecret string
+	// RedirectURL is where the provider sends users back to after
+	// they sign in.
+	RedirectURL string
+	// AllowedDomains limits sign-ins to emails in these domains. Any
+	// domain is allowed if it's empty.
+	AllowedDomains []string
+	// LocalLoginURL is where the local provider's sign-in form is
+	// served. It's only used with LocalIssuer.
+	LocalLoginURL string
+}
+
+// Identity is who an ID token says the user is.
+type Identity struct {
+	Email         string
+	EmailVerified bool
+}
+
+type Provider interface {
+	// AuthCodeURL returns the URL to send users to to sign in.
+	AuthCodeURL(ctx context.Context, state string, opts ...oauth2.AuthCodeOption) (string, error)
+	// Exchange trades the code the provider redirected back with for
+	// a raw ID token.
+	Exchange(ctx context.Context, code string) (string, error)
+	// Verify checks a raw ID token, and returns who it's for.
+	Verify(ctx context.Context, rawIDToken string) (Identity, error)
+}
+
+/** Functions and Methods */
+
+// NewProvider returns the provider cfg.Issuer names. It doesn't
+// contact the provider; that happens when it's first used.
+func NewProvider(cfg Config) (Provider, error) {
+	if cfg.Issuer == "" {
+		return nil, errors.New("auth issuer cannot be empty")
+	}
+	if cfg.ClientID == "" {
+		return nil, errors.New("auth client ID cannot be empty")
+	}
+	if cfg.Issuer == LocalIssuer {
+		if config.IsProd {
+			return nil, errors.New("the local auth provider can't be used in prod")
+		}
+		return NewLocalProvider(cfg)
+	}
+	return NewOIDCProvider(cfg), nil
+}
+
+// NewState returns a random value for the OAuth2 state parameter.
+func NewState() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", errors.Wrap(err, "failed to generate state")
+	}
+	return hex.EncodeToString(b), nil
+}
+
+// SplitDomains parses a comma-separated list of email domains.
+func SplitDomains(s string) []string {
+	var domains []string
+	for _, d := range strings.Split(s, ",") {
+		if d = strings.TrimSpace(d); d != "" {
+			domains = append(domains, d)
+		}
+	}
+	return domains
+}
+
+// checkIdentity returns an error if the identity can't sign in with
+// cfg.
+func checkIdentity(cfg Config, identity Identity) error {
+	if identity.Email == "" {
+		return errors.New("ID token has no email")
+	}
+	if len(cfg.AllowedDomains) == 0 {
+		return nil
+	}
+	at := strings.LastIndex(identity.Email, "@")
+	domain := identity.Email[at+1:]
+	for _, d := range cfg.AllowedDomains {
+		if strings.EqualFold(domain, d) {
+			return nil
+		}
+	}
+	return errors.Errorf("%s is not in an allowed domain", identity.Email)
+}
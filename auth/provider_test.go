@@ -0,0 +1,101 @@
+package auth
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestLocalProvider(t *testing.T) {
+	provider, err := NewProvider(Config{
+		Issuer:         LocalIssuer,
+		ClientID:       "adb",
+		RedirectURL:    "http://localhost:8080/auth/callback",
+		AllowedDomains: SplitDomains(" example.org, dxe.io "),
+		LocalLoginURL:  "http://localhost:8080/auth/local",
+	})
+	require.NoError(t, err)
+	local := provider.(*LocalProvider)
+	ctx := context.Background()
+
+	authURL, err := provider.AuthCodeURL(ctx, "some-state")
+	require.NoError(t, err)
+	require.Equal(t, "http://localhost:8080/auth/local?state=some-state", authURL)
+
+	// Signing in through the form redirects back with a code that
+	// exchanges for a verifiable ID token.
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest("POST", authURL, strings.NewReader("state=some-state&email=test%40example.org"))
+	r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	local.ServeHTTP(w, r)
+	require.Equal(t, http.StatusFound, w.Code)
+	location, err := r.URL.Parse(w.Header().Get("Location"))
+	require.NoError(t, err)
+	require.Equal(t, "/auth/callback", location.Path)
+	require.Equal(t, "some-state", location.Query().Get("state"))
+
+	rawIDToken, err := provider.Exchange(ctx, location.Query().Get("code"))
+	require.NoError(t, err)
+	identity, err := provider.Verify(ctx, rawIDToken)
+	require.NoError(t, err)
+	require.Equal(t, Identity{Email: "test@example.org", EmailVerified: true}, identity)
+
+	// Emails outside the allowed domains can't sign in.
+	rawIDToken, err = local.IssueIDToken("test@gmail.com")
+	require.NoError(t, err)
+	_, err = provider.Verify(ctx, rawIDToken)
+	require.Error(t, err)
+
+	// Neither can tokens from another provider.
+	other, err := NewLocalProvider(Config{Issuer: LocalIssuer, ClientID: "adb"})
+	require.NoError(t, err)
+	rawIDToken, err = other.IssueIDToken("test@example.org")
+	require.NoError(t, err)
+	_, err = provider.Verify(ctx, rawIDToken)
+	require.Error(t, err)
+}
+
+func TestOIDCProviderDiscovery(t *testing.T) {
+	defer func(backoff time.Duration) { discoveryBackoff = backoff }(discoveryBackoff)
+	discoveryBackoff = time.Millisecond
+
+	requests := 0
+	var server *httptest.Server
+	server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		// Fail the first attempt, as if the provider was briefly
+		// unreachable.
+		if requests == 1 {
+			http.Error(w, "unavailable", http.StatusServiceUnavailable)
+			return
+		}
+		json.NewEncoder(w).Encode(map[string]string{
+			"issuer":                 server.URL,
+			"authorization_endpoint": server.URL + "/authorize",
+			"token_endpoint":         server.URL + "/token",
+			"jwks_uri":               server.URL + "/keys",
+		})
+	}))
+	defer server.Close()
+
+	// Creating the provider doesn't contact it.
+	provider, err := NewProvider(Config{Issuer: server.URL, ClientID: "adb"})
+	require.NoError(t, err)
+	require.Equal(t, 0, requests)
+
+	authURL, err := provider.AuthCodeURL(context.Background(), "some-state")
+	require.NoError(t, err)
+	require.True(t, strings.HasPrefix(authURL, server.URL+"/authorize?"), authURL)
+	require.Equal(t, 2, requests)
+
+	// Discovery only happens once.
+	_, err = provider.AuthCodeURL(context.Background(), "other-state")
+	require.NoError(t, err)
+	require.Equal(t, 2, requests)
+}
@@ -16,13 +16,25 @@ var (
 
 	Port = mustGetenv("PORT", "8080", true)
 
-	Route0 = mustGetenv("ROUTE_0", "/route0", true)
 	Route1 = mustGetenv("ROUTE_1", "/route1", true)
-	Route2 = mustGetenv("ROUTE_2", "/route2", true)
 
 	CookieSecret = mustGetenv("COOKIE_SECRET", "some-fake-secret", true)
 	CsrfAuthKey  = mustGetenv("CSRF_AUTH_KEY", "", true)
 
+	// The OpenID Connect provider users sign in with. In dev it
+	// defaults to "local", a stand-in provider that lets you sign
+	// in as anyone.
+	AuthIssuer       = mustGetenv("AUTH_ISSUER", "local", true)
+	AuthClientID     = mustGetenv("AUTH_CLIENT_ID", "adb", true)
+	AuthClientSecret = mustGetenv("AUTH_CLIENT_SECRET", "", true)
+	AuthRedirectURL  = mustGetenv("AUTH_REDIRECT_URL", "http://localhost:8080/auth/callback", true)
+	// Comma-separated email domains that can sign in. Any domain
+	// can if it's empty.
+	AuthAllowedDomains = mustGetenv("AUTH_ALLOWED_DOMAINS", "", false)
+
+	// Signs the public check-in links for events.
+	CheckInSecret = mustGetenv("CHECK_IN_SECRET", "some-fake-check-in-secret", true)
+
 	// Path to Google API oauth client_secrets.json file, with
 	// access to the following scope:
 	// https://www.googleapis.com/auth/admin.directory.group
@@ -41,8 +53,17 @@ var (
 	SurveyMissingEmail = mustGetenv("SURVEY_MISSING_EMAIL", "", false)
 	SurveyFromEmail    = mustGetenv("SURVEY_FROM_EMAIL", "", false)
 
-	// For members.dxesf.org
-	MembersClientID     = mustGetenv("MEMBERS_CLIENT_ID", "", false)
+	// Optional JSON file overriding the rules used to compute the
+	// MPI. See model.MPIRules for the format.
+	MPIRulesFile = mustGetenv("MPI_RULES_FILE", "", false)
+
+	// How many days deleted events stay in the trash before they're
+	// purged. Defaults to model.DefaultEventTrashDays.
+	EventTrashDays = mustGetenv("EVENT_TRASH_DAYS", "", false)
+
+	// For members.dxesf.org. Members sign in with the same provider
+	// as ADB, using their own client.
+	MembersClientID     = mustGetenv("MEMBERS_CLIENT_ID", "members", false)
 	MembersClientSecret = mustGetenv("MEMBERS_CLIENT_SECRET", "", false)
 )
 
@@ -0,0 +1,35 @@
+package duplicate_finder
+
+import (
+	"log"
+	"time"
+
+	"github.com/dxe/adb/model"
+	"github.com/jmoiron/sqlx"
+)
+
+func findDuplicatesWrapper(db *sqlx.DB) {
+	defer func() {
+		if r := recover(); r != nil {
+			log.Println("Recovered from panic in findDuplicates", r)
+		}
+	}()
+
+	count, err := model.RefreshActivistDuplicates(db)
+	if err != nil {
+		log.Println("Failed to find duplicate activists:", err)
+		return
+	}
+	log.Printf("Found %d possible duplicate activists", count)
+}
+
+// Rescores possible duplicate activists every hour. Should be run in
+// a goroutine.
+func StartDuplicateFinder(db *sqlx.DB) {
+	for {
+		log.Println("Starting duplicate finder")
+		findDuplicatesWrapper(db)
+		log.Println("Finished duplicate finder")
+		time.Sleep(60 * time.Minute)
+	}
+}
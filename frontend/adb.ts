@@ -1,18 +1,42 @@
 import Vue from 'vue';
+import ActivistDuplicates from './ActivistDuplicates.vue';
+import ActivistHistory from './ActivistHistory.vue';
 import ActivistList from './ActivistList.vue';
+import ApiKeyList from './ApiKeyList.vue';
+import CheckInList from './CheckInList.vue';
 import CirclesList from './CirclesList.vue';
+import ConnectionEdit from './ConnectionEdit.vue';
+import ConnectionList from './ConnectionList.vue';
 import EventEdit from './EventEdit.vue';
 import EventList from './EventList.vue';
+import EventSeriesList from './EventSeriesList.vue';
+import EventTrash from './EventTrash.vue';
+import EventTypeList from './EventTypeList.vue';
+import ImportData from './ImportData.vue';
+import RoleList from './RoleList.vue';
+import SessionList from './SessionList.vue';
 import UserList from './UserList.vue';
 import WorkingGroupList from './WorkingGroupList.vue';
 
 new Vue({
   el: '#app',
   components: {
+    ActivistDuplicates,
+    ActivistHistory,
     ActivistList,
+    ApiKeyList,
+    CheckInList,
     CirclesList,
+    ConnectionEdit,
+    ConnectionList,
     EventEdit,
     EventList,
+    EventSeriesList,
+    EventTrash,
+    EventTypeList,
+    ImportData,
+    RoleList,
+    SessionList,
     UserList,
     WorkingGroupList,
   },
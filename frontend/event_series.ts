@@ -0,0 +1,32 @@
+import { flashMessage } from './flash_message';
+
+export interface EventSeries {
+  id: number;
+  name: string;
+  event_type: string;
+  recurrence: string;
+  location: string;
+  start_date: string;
+  end_date: string;
+  events: number;
+  next_event: string;
+}
+
+// Fetches every event series, in name order.
+export function fetchEventSeries(onSuccess: (series: EventSeries[]) => void) {
+  $.ajax({
+    url: '/event_series/list',
+    success: (data) => {
+      var parsed = JSON.parse(data);
+      if (parsed.status === 'error') {
+        flashMessage('Error: ' + parsed.message, true);
+        return;
+      }
+      // status === "success"
+      onSuccess(parsed.event_series);
+    },
+    error: () => {
+      flashMessage('Error: could not load event series', true);
+    },
+  });
+}
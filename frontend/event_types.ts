@@ -0,0 +1,35 @@
+import { flashMessage } from './flash_message';
+
+export interface EventType {
+  id: number;
+  name: string;
+  category: string;
+  active: boolean;
+  display_order: number;
+  events: number;
+}
+
+export const eventCategories = [
+  { value: 'direct_action', label: 'Direct Action' },
+  { value: 'community', label: 'Community' },
+  { value: 'other', label: 'Other' },
+];
+
+// Fetches every event type, in display order.
+export function fetchEventTypes(onSuccess: (eventTypes: EventType[]) => void) {
+  $.ajax({
+    url: '/event_type/list',
+    success: (data) => {
+      var parsed = JSON.parse(data);
+      if (parsed.status === 'error') {
+        flashMessage('Error: ' + parsed.message, true);
+        return;
+      }
+      // status === "success"
+      onSuccess(parsed.event_types);
+    },
+    error: () => {
+      flashMessage('Error: could not load event types', true);
+    },
+  });
+}
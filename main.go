@@ -7,16 +7,21 @@ import (
 	"html/template"
 	"io"
 	"log"
+	"mime/multipart"
 	"net/http"
 	"net/http/pprof"
 	"strconv"
+	"strings"
 	"time"
 
-	oidc "github.com/coreos/go-oidc"
+	"github.com/dxe/adb/auth"
 	"github.com/dxe/adb/config"
+	"github.com/dxe/adb/duplicate_finder"
 	"github.com/dxe/adb/mailinglist_sync"
 	"github.com/dxe/adb/members"
+	"github.com/dxe/adb/migrations"
 	"github.com/dxe/adb/model"
+	"github.com/dxe/adb/scheduler"
 	"github.com/dxe/adb/survey_mailer"
 	"github.com/getsentry/sentry-go"
 	"github.com/gorilla/csrf"
@@ -25,6 +30,7 @@ import (
 	"github.com/jmoiron/sqlx"
 	"github.com/justinas/alice"
 	"github.com/pkg/errors"
+	"github.com/skip2/go-qrcode"
 	"github.com/urfave/negroni"
 )
 
@@ -49,30 +55,19 @@ func flashMesssageError(w http.ResponseWriter, message string) {
 var sessionStore = sessions.NewCookieStore([]byte(config.CookieSecret))
 
 func getAuthedADBUser(db *sqlx.DB, r *http.Request) (adbUser model.ADBUser, authed bool) {
-	// In dev, just return the test user.
-	if !config.IsProd {
-		return model.DevTestUser, true
-	}
-
 	// First, check the cookie.
-	authSession, err := sessionStore.New(r, "auth-session")
-	if err != nil {
-		// the cookie secret has changed
-		return model.ADBUser{}, false
-	}
-	authed, ok := authSession.Values["authed"].(bool)
-	// We should always set "authed" to true, see setAuthSession,
-	// but check it just in case.
-	if !ok || !authed {
-		return model.ADBUser{}, false
-	}
-	adbUserID, ok := authSession.Values["adbuserid"].(int)
+	token, ok := getAuthSessionToken(r)
 	if !ok {
 		return model.ADBUser{}, false
 	}
 
-	// Then, check that the user is still authed.
-	adbUser, err = model.GetADBUser(db, adbUserID, "")
+	// Then, check that the session hasn't been revoked and the user
+	// is still authed.
+	session, err := model.AuthenticateSession(db, token, r.RemoteAddr)
+	if err != nil {
+		return model.ADBUser{}, false
+	}
+	adbUser, err = model.GetADBUser(db, session.UserID, "")
 
 	if err != nil {
 		return model.ADBUser{}, false
@@ -84,7 +79,22 @@ func getAuthedADBUser(db *sqlx.DB, r *http.Request) (adbUser model.ADBUser, auth
 	return adbUser, true
 }
 
-func setAuthSession(w http.ResponseWriter, r *http.Request, adbUser model.ADBUser) error {
+// getAuthSessionToken returns the session token from the request's
+// session cookie.
+func getAuthSessionToken(r *http.Request) (string, bool) {
+	authSession, err := sessionStore.New(r, "auth-session")
+	if err != nil {
+		// the cookie secret has changed
+		return "", false
+	}
+	token, ok := authSession.Values["token"].(string)
+	if !ok || token == "" {
+		return "", false
+	}
+	return token, true
+}
+
+func setAuthSession(db *sqlx.DB, w http.ResponseWriter, r *http.Request, adbUser model.ADBUser) error {
 	if adbUser.Disabled {
 		return nil
 	}
@@ -93,17 +103,18 @@ func setAuthSession(w http.ResponseWriter, r *http.Request, adbUser model.ADBUse
 	if err != nil {
 		return err
 	}
+	token, err := model.CreateSession(db, adbUser.ID, r.RemoteAddr, r.UserAgent())
+	if err != nil {
+		return err
+	}
 	authSession.Options = &sessions.Options{
-		Path: "/",
-		// MaxAge is 30 days in seconds
-		MaxAge: 30 * // days
-			24 * // hours
-			60 * // minutes
-			60, // seconds
+		Path:     "/",
+		MaxAge:   int(model.SessionDuration / time.Second),
 		HttpOnly: true,
 	}
-	authSession.Values["authed"] = true
-	authSession.Values["adbuserid"] = adbUser.ID
+	authSession.Values = map[interface{}]interface{}{
+		"token": token,
+	}
 	return sessionStore.Save(r, w, authSession)
 }
 
@@ -116,7 +127,18 @@ func noCacheHandler(h http.Handler) http.Handler {
 
 func router() (*mux.Router, *sqlx.DB) {
 	db := model.NewDB(config.DBDataSource())
-	main := MainController{db: db}
+	authProvider, err := auth.NewProvider(auth.Config{
+		Issuer:         config.AuthIssuer,
+		ClientID:       config.AuthClientID,
+		ClientSecret:   config.AuthClientSecret,
+		RedirectURL:    config.AuthRedirectURL,
+		AllowedDomains: auth.SplitDomains(config.AuthAllowedDomains),
+		LocalLoginURL:  "/auth/local",
+	})
+	if err != nil {
+		log.Fatalf("%+v", err)
+	}
+	main := MainController{db: db, auth: authProvider}
 	csrfMiddleware := csrf.Protect(
 		[]byte(config.CsrfAuthKey),
 		csrf.Secure(config.IsProd), // disable secure flag in dev
@@ -131,74 +153,130 @@ func router() (*mux.Router, *sqlx.DB) {
 
 	// Unauthed pages
 	router.HandleFunc("/login", main.LoginHandler)
+	router.HandleFunc("/login/start", main.LoginStartHandler)
+	router.HandleFunc("/auth/callback", main.AuthCallbackHandler)
+	if local, ok := authProvider.(*auth.LocalProvider); ok {
+		router.Handle("/auth/local", local)
+	}
 	router.HandleFunc("/logout", main.LogoutHandler)
+	router.HandleFunc("/check_in/{token}", main.CheckInHandler)
 
 	// Error pages
 	router.HandleFunc("/403", main.ForbiddenHandler)
 
 	// Authed pages
-	router.Handle("/", alice.New(main.authAttendanceMiddleware).ThenFunc(main.UpdateEventHandler))
-	router.Handle("/update_event/{event_id:[0-9]+}", alice.New(main.authAttendanceMiddleware).ThenFunc(main.UpdateEventHandler))
-	router.Handle("/new_connection", alice.New(main.authOrganizerMiddleware).ThenFunc(main.UpdateConnectionHandler))
-	router.Handle("/update_connection/{event_id:[0-9]+}", alice.New(main.authOrganizerMiddleware).ThenFunc(main.UpdateConnectionHandler))
-	router.Handle("/update_event/{event_id:[0-9]+}", alice.New(main.authAttendanceMiddleware).ThenFunc(main.UpdateEventHandler))
-	router.Handle("/list_events", alice.New(main.authAttendanceMiddleware).ThenFunc(main.ListEventsHandler))
-	router.Handle("/list_connections", alice.New(main.authOrganizerMiddleware).ThenFunc(main.ListConnectionsHandler))
-	router.Handle("/list_activists", alice.New(main.authOrganizerMiddleware).ThenFunc(main.ListActivistsHandler))
-	router.Handle("/community_prospects", alice.New(main.authOrganizerMiddleware).ThenFunc(main.ListCommunityProspectsHandler))
-	router.Handle("/activist_pool", alice.New(main.authOrganizerMiddleware).ThenFunc(main.ListActivistsPoolHandler))
-	router.Handle("/activist_recruitment", alice.New(main.authOrganizerMiddleware).ThenFunc(main.ListActivistsRecruitmentHandler))
-	router.Handle("/activist_actionteam", alice.New(main.authOrganizerMiddleware).ThenFunc(main.ListActivistsActionTeamHandler))
-	router.Handle("/activist_development", alice.New(main.authOrganizerMiddleware).ThenFunc(main.ListActivistsDevelopmentHandler))
-	router.Handle("/organizer_prospects", alice.New(main.authOrganizerMiddleware).ThenFunc(main.ListOrganizerProspectsHandler))
-	router.Handle("/senior_organizer_prospects", alice.New(main.authOrganizerMiddleware).ThenFunc(main.ListSeniorOrganizerProspectsHandler))
-	router.Handle("/senior_organizer_development", alice.New(main.authOrganizerMiddleware).ThenFunc(main.ListSeniorOrganizerDevelopmentHandler))
-	router.Handle("/chapter_member_prospects", alice.New(main.authOrganizerMiddleware).ThenFunc(main.ListChapterMemberProspectsHandler))
-	router.Handle("/chapter_member_development", alice.New(main.authOrganizerMiddleware).ThenFunc(main.ListChapterMemberDevelopmentHandler))
-	router.Handle("/circle_member_prospects", alice.New(main.authOrganizerMiddleware).ThenFunc(main.ListCircleMemberProspectsHandler))
-	router.Handle("/circle_members", alice.New(main.authOrganizerMiddleware).ThenFunc(main.ListCircleMembersHandler))
-	router.Handle("/leaderboard", alice.New(main.authOrganizerMiddleware).ThenFunc(main.LeaderboardHandler))
-	router.Handle("/list_working_groups", alice.New(main.authOrganizerMiddleware).ThenFunc(main.ListWorkingGroupsHandler))
-	router.Handle("/list_circles", alice.New(main.authOrganizerMiddleware).ThenFunc(main.ListCirclesHandler))
+	router.Handle("/", alice.New(main.authPermission(model.PermissionEventWrite)).ThenFunc(main.UpdateEventHandler))
+	router.Handle("/update_event/{event_id:[0-9]+}", alice.New(main.authPermission(model.PermissionEventWrite)).ThenFunc(main.UpdateEventHandler))
+	router.Handle("/new_connection", alice.New(main.authPermission(model.PermissionConnectionWrite)).ThenFunc(main.UpdateConnectionHandler))
+	router.Handle("/update_connection/{connection_id:[0-9]+}", alice.New(main.authPermission(model.PermissionConnectionWrite)).ThenFunc(main.UpdateConnectionHandler))
+	router.Handle("/update_event/{event_id:[0-9]+}", alice.New(main.authPermission(model.PermissionEventWrite)).ThenFunc(main.UpdateEventHandler))
+	router.Handle("/list_events", alice.New(main.authPermission(model.PermissionEventRead)).ThenFunc(main.ListEventsHandler))
+	router.Handle("/list_event_series", alice.New(main.authPermission(model.PermissionEventSeriesWrite)).ThenFunc(main.ListEventSeriesHandler))
+	router.Handle("/check_ins", alice.New(main.authPermission(model.PermissionEventWrite)).ThenFunc(main.ListCheckInsHandler))
+	router.Handle("/list_connections", alice.New(main.authPermission(model.PermissionConnectionRead)).ThenFunc(main.ListConnectionsHandler))
+	router.Handle("/list_activists", alice.New(main.authPermission(model.PermissionActivistRead, model.PermissionActivistReadLed)).ThenFunc(main.ListActivistsHandler))
+	router.Handle("/community_prospects", alice.New(main.authPermission(model.PermissionActivistRead)).ThenFunc(main.ListCommunityProspectsHandler))
+	router.Handle("/activist_pool", alice.New(main.authPermission(model.PermissionActivistRead)).ThenFunc(main.ListActivistsPoolHandler))
+	router.Handle("/activist_recruitment", alice.New(main.authPermission(model.PermissionActivistRead)).ThenFunc(main.ListActivistsRecruitmentHandler))
+	router.Handle("/activist_actionteam", alice.New(main.authPermission(model.PermissionActivistRead)).ThenFunc(main.ListActivistsActionTeamHandler))
+	router.Handle("/activist_development", alice.New(main.authPermission(model.PermissionActivistRead)).ThenFunc(main.ListActivistsDevelopmentHandler))
+	router.Handle("/organizer_prospects", alice.New(main.authPermission(model.PermissionActivistRead)).ThenFunc(main.ListOrganizerProspectsHandler))
+	router.Handle("/senior_organizer_prospects", alice.New(main.authPermission(model.PermissionActivistRead)).ThenFunc(main.ListSeniorOrganizerProspectsHandler))
+	router.Handle("/senior_organizer_development", alice.New(main.authPermission(model.PermissionActivistRead)).ThenFunc(main.ListSeniorOrganizerDevelopmentHandler))
+	router.Handle("/chapter_member_prospects", alice.New(main.authPermission(model.PermissionActivistRead)).ThenFunc(main.ListChapterMemberProspectsHandler))
+	router.Handle("/chapter_member_development", alice.New(main.authPermission(model.PermissionActivistRead)).ThenFunc(main.ListChapterMemberDevelopmentHandler))
+	router.Handle("/circle_member_prospects", alice.New(main.authPermission(model.PermissionActivistRead)).ThenFunc(main.ListCircleMemberProspectsHandler))
+	router.Handle("/circle_members", alice.New(main.authPermission(model.PermissionActivistRead)).ThenFunc(main.ListCircleMembersHandler))
+	router.Handle("/leaderboard", alice.New(main.authPermission(model.PermissionActivistRead)).ThenFunc(main.LeaderboardHandler))
+	router.Handle("/list_working_groups", alice.New(main.authPermission(model.PermissionGroupRead)).ThenFunc(main.ListWorkingGroupsHandler))
+	router.Handle("/list_circles", alice.New(main.authPermission(model.PermissionGroupRead)).ThenFunc(main.ListCirclesHandler))
+	router.Handle("/activist_duplicates", alice.New(main.authPermission(model.PermissionActivistMerge)).ThenFunc(main.ListActivistDuplicatesHandler))
+	router.Handle("/import", alice.New(main.authPermission(model.PermissionDataImport)).ThenFunc(main.ImportPageHandler))
+	router.Handle("/activist_history/{activist_id:[0-9]+}", alice.New(main.authPermission(model.PermissionActivistRead)).ThenFunc(main.ActivistHistoryPageHandler))
 
 	// Authed Admin pages
-	admin.Handle("/admin/users", alice.New(main.authAdminMiddleware).ThenFunc(main.ListUsersHandler))
+	admin.Handle("/admin/users", alice.New(main.authPermission(model.PermissionUserManage)).ThenFunc(main.ListUsersHandler))
+	admin.Handle("/admin/event_types", alice.New(main.authPermission(model.PermissionEventTypeManage)).ThenFunc(main.ListEventTypesHandler))
+	admin.Handle("/admin/event_trash", alice.New(main.authPermission(model.PermissionEventPurge)).ThenFunc(main.ListEventTrashHandler))
+	admin.Handle("/admin/sessions", alice.New(main.authPermission(model.PermissionUserManage)).ThenFunc(main.ListSessionsHandler))
+	admin.Handle("/admin/roles", alice.New(main.authPermission(model.PermissionUserManage)).ThenFunc(main.ListRolesHandler))
 
 	// Unauthed API
-	router.HandleFunc("/tokensignin", main.TokenSignInHandler)
-	router.HandleFunc(config.Route0, main.TransposedEventsDataJsonHandler)
-	router.HandleFunc("/wallboard_mpi", main.newPowerWallboard)                    // new endpoint for arc tv to get mpi
-	router.HandleFunc("/wallboard_chaptermembers", main.newChapterMemberWallboard) // new endpoint for arc tv to get chapter members
-	router.HandleFunc(config.Route2, main.ActivistListHandler)                     // used for connections google sheet
 
 	// Authed API
-	router.Handle("/activist_names/get", alice.New(main.apiAttendanceAuthMiddleware).ThenFunc(main.AutocompleteActivistsHandler))
-	router.Handle("/activist_names/get_organizers", alice.New(main.apiAttendanceAuthMiddleware).ThenFunc(main.AutocompleteOrganizersHandler))
-	router.Handle("/event/get/{event_id:[0-9]+}", alice.New(main.apiAttendanceAuthMiddleware).ThenFunc(main.EventGetHandler))
-	router.Handle("/event/save", alice.New(main.apiAttendanceAuthMiddleware).ThenFunc(main.EventSaveHandler))
-	router.Handle("/connection/save", alice.New(main.apiOrganizerAuthMiddleware).ThenFunc(main.ConnectionSaveHandler))
-	router.Handle("/event/list", alice.New(main.apiAttendanceAuthMiddleware).ThenFunc(main.EventListHandler))
-	router.Handle("/event/delete", alice.New(main.apiAttendanceAuthMiddleware).ThenFunc(main.EventDeleteHandler))
-	router.Handle("/activist/list", alice.New(main.apiOrganizerAuthMiddleware).ThenFunc(main.ActivistListHandler))
-	router.Handle("/activist/list_basic", alice.New(main.apiAttendanceAuthMiddleware).ThenFunc(main.ActivistListBasicHandler))
-	router.Handle("/activist/list_range", alice.New(main.apiOrganizerAuthMiddleware).ThenFunc(main.ActivistInfiniteScrollHandler))
-	router.Handle("/activist/save", alice.New(main.apiOrganizerAuthMiddleware).ThenFunc(main.ActivistSaveHandler))
-	router.Handle("/activist/hide", alice.New(main.apiOrganizerAuthMiddleware).ThenFunc(main.ActivistHideHandler))
-	router.Handle("/activist/merge", alice.New(main.apiOrganizerAuthMiddleware).ThenFunc(main.ActivistMergeHandler))
-	router.Handle("/working_group/save", alice.New(main.apiOrganizerAuthMiddleware).ThenFunc(main.WorkingGroupSaveHandler))
-	router.Handle("/working_group/list", alice.New(main.apiOrganizerAuthMiddleware).ThenFunc(main.WorkingGroupListHandler))
-	router.Handle("/working_group/delete", alice.New(main.apiOrganizerAuthMiddleware).ThenFunc(main.WorkingGroupDeleteHandler))
-	router.Handle("/circle/save", alice.New(main.apiOrganizerAuthMiddleware).ThenFunc(main.CircleGroupSaveHandler))
-	router.Handle("/circle/list", alice.New(main.apiOrganizerAuthMiddleware).ThenFunc(main.CircleGroupListHandler))
-	router.Handle("/circle/delete", alice.New(main.apiOrganizerAuthMiddleware).ThenFunc(main.CircleGroupDeleteHandler))
+	router.Handle("/activist_names/get", alice.New(main.apiPermission(model.PermissionEventRead)).ThenFunc(main.AutocompleteActivistsHandler))
+	router.Handle("/activist_names/get_organizers", alice.New(main.apiPermission(model.PermissionEventRead)).ThenFunc(main.AutocompleteOrganizersHandler))
+	router.Handle("/event/get/{event_id:[0-9]+}", alice.New(main.apiPermission(model.PermissionEventRead)).ThenFunc(main.EventGetHandler))
+	router.Handle("/event/save", alice.New(main.apiPermission(model.PermissionEventWrite)).ThenFunc(main.EventSaveHandler))
+	router.Handle("/connection/get/{connection_id:[0-9]+}", alice.New(main.apiPermission(model.PermissionConnectionRead)).ThenFunc(main.ConnectionGetHandler))
+	router.Handle("/connection/save", alice.New(main.apiPermission(model.PermissionConnectionWrite)).ThenFunc(main.ConnectionSaveHandler))
+	router.Handle("/connection/list", alice.New(main.apiPermission(model.PermissionConnectionRead)).ThenFunc(main.ConnectionListHandler))
+	router.Handle("/connection/delete", alice.New(main.apiPermission(model.PermissionConnectionWrite)).ThenFunc(main.ConnectionDeleteHandler))
+	router.Handle("/event/list", alice.New(main.apiPermission(model.PermissionEventRead)).ThenFunc(main.EventListHandler))
+	router.Handle("/event/list_transposed", alice.New(main.apiPermission(model.PermissionEventRead)).ThenFunc(main.TransposedEventsDataJsonHandler)) // used for the events google sheet
+	router.Handle("/event/delete", alice.New(main.apiPermission(model.PermissionEventDelete)).ThenFunc(main.EventDeleteHandler))
+	router.Handle("/event/owners", alice.New(main.apiPermission(model.PermissionEventRead)).ThenFunc(main.EventOwnersHandler))
+	router.Handle("/event/check_in_link/{event_id:[0-9]+}", alice.New(main.apiPermission(model.PermissionEventWrite)).ThenFunc(main.EventCheckInLinkHandler))
+	router.Handle("/event/check_in_qr/{event_id:[0-9]+}", alice.New(main.apiPermission(model.PermissionEventWrite)).ThenFunc(main.EventCheckInQRHandler))
+	router.Handle("/check_ins/pending", alice.New(main.apiPermission(model.PermissionEventWrite)).ThenFunc(main.CheckInsPendingHandler))
+	router.Handle("/check_ins/approve", alice.New(main.apiPermission(model.PermissionEventWrite)).ThenFunc(main.CheckInApproveHandler))
+	router.Handle("/check_ins/reject", alice.New(main.apiPermission(model.PermissionEventWrite)).ThenFunc(main.CheckInRejectHandler))
+	router.Handle("/event_type/list", alice.New(main.apiPermission(model.PermissionEventRead)).ThenFunc(main.EventTypeListHandler))
+	router.Handle("/event_series/list", alice.New(main.apiPermission(model.PermissionEventRead)).ThenFunc(main.EventSeriesListHandler))
+	router.Handle("/event_series/save", alice.New(main.apiPermission(model.PermissionEventSeriesWrite)).ThenFunc(main.EventSeriesSaveHandler))
+	router.Handle("/event_series/delete", alice.New(main.apiPermission(model.PermissionEventSeriesWrite)).ThenFunc(main.EventSeriesDeleteHandler))
+	router.Handle("/event_series/attendance", alice.New(main.apiPermission(model.PermissionEventSeriesWrite)).ThenFunc(main.EventSeriesAttendanceHandler))
+	router.Handle("/activist/list", alice.New(main.apiPermission(model.PermissionActivistRead, model.PermissionActivistReadLed)).ThenFunc(main.ActivistListHandler))
+	router.Handle("/activist/search", alice.New(main.apiPermission(model.PermissionActivistRead)).ThenFunc(main.ActivistSearchHandler))
+	router.Handle("/activist/export.csv", alice.New(main.apiPermission(model.PermissionActivistRead)).ThenFunc(main.ActivistExportHandler))
+	router.Handle("/activist/import", alice.New(main.apiPermission(model.PermissionDataImport)).ThenFunc(main.ActivistImportHandler))
+	router.Handle("/event/import", alice.New(main.apiPermission(model.PermissionDataImport)).ThenFunc(main.EventImportHandler))
+	router.Handle("/activist/list_basic", alice.New(main.apiPermission(model.PermissionEventRead)).ThenFunc(main.ActivistListBasicHandler))
+	router.Handle("/activist/list_range", alice.New(main.apiPermission(model.PermissionActivistRead)).ThenFunc(main.ActivistInfiniteScrollHandler))
+	router.Handle("/activist/save", alice.New(main.apiPermission(model.PermissionActivistWrite)).ThenFunc(main.ActivistSaveHandler))
+	router.Handle("/activist/hide", alice.New(main.apiPermission(model.PermissionActivistWrite)).ThenFunc(main.ActivistHideHandler))
+	router.Handle("/activist/merge", alice.New(main.apiPermission(model.PermissionActivistMerge)).ThenFunc(main.ActivistMergeHandler))
+	router.Handle("/activist/unmerge", alice.New(main.apiPermission(model.PermissionActivistMerge)).ThenFunc(main.ActivistUnmergeHandler))
+	router.Handle("/activist/duplicates", alice.New(main.apiPermission(model.PermissionActivistMerge)).ThenFunc(main.ActivistDuplicatesHandler))
+	router.Handle("/activist/duplicates/merge", alice.New(main.apiPermission(model.PermissionActivistMerge)).ThenFunc(main.ActivistDuplicateMergeHandler))
+	router.Handle("/activist/duplicates/dismiss", alice.New(main.apiPermission(model.PermissionActivistMerge)).ThenFunc(main.ActivistDuplicateDismissHandler))
+	router.Handle("/activist/history", alice.New(main.apiPermission(model.PermissionActivistRead)).ThenFunc(main.ActivistHistoryHandler))
+	router.Handle("/activist/{activist_id:[0-9]+}/events", alice.New(main.apiPermission(model.PermissionActivistRead)).ThenFunc(main.ActivistEventsHandler))
+	router.Handle("/working_group/save", alice.New(main.apiPermission(model.PermissionGroupWrite, model.PermissionGroupWriteLed)).ThenFunc(main.WorkingGroupSaveHandler))
+	router.Handle("/working_group/list", alice.New(main.apiPermission(model.PermissionGroupRead)).ThenFunc(main.WorkingGroupListHandler))
+	router.Handle("/working_group/delete", alice.New(main.apiPermission(model.PermissionGroupWrite)).ThenFunc(main.WorkingGroupDeleteHandler))
+	router.Handle("/circle/save", alice.New(main.apiPermission(model.PermissionGroupWrite, model.PermissionGroupWriteLed)).ThenFunc(main.CircleGroupSaveHandler))
+	router.Handle("/circle/list", alice.New(main.apiPermission(model.PermissionGroupRead)).ThenFunc(main.CircleGroupListHandler))
+	router.Handle("/circle/delete", alice.New(main.apiPermission(model.PermissionGroupWrite)).ThenFunc(main.CircleGroupDeleteHandler))
+	router.Handle("/power/history", alice.New(main.apiPermission(model.PermissionActivistRead)).ThenFunc(main.PowerHistoryHandler))
+	router.Handle("/wallboard_mpi", alice.New(main.apiPermission(model.PermissionWallboardRead)).ThenFunc(main.newPowerWallboard))                    // for the arc tv to get mpi
+	router.Handle("/wallboard_chaptermembers", alice.New(main.apiPermission(model.PermissionWallboardRead)).ThenFunc(main.newChapterMemberWallboard)) // for the arc tv to get chapter members
 
 	// Authed Admin API
-	admin.Handle("/user/list", alice.New(main.apiAdminAuthMiddleware).ThenFunc(main.UserListHandler))
-	admin.Handle("/user/save", alice.New(main.apiAdminAuthMiddleware).ThenFunc(main.UserSaveHandler))
-	admin.Handle("/user/delete", alice.New(main.apiAdminAuthMiddleware).ThenFunc(main.UserDeleteHandler))
+	admin.Handle("/user/list", alice.New(main.apiPermission(model.PermissionUserManage)).ThenFunc(main.UserListHandler))
+	admin.Handle("/user/save", alice.New(main.apiPermission(model.PermissionUserManage)).ThenFunc(main.UserSaveHandler))
+	admin.Handle("/user/delete", alice.New(main.apiPermission(model.PermissionUserManage)).ThenFunc(main.UserDeleteHandler))
 	// Authed Admin API for managing Users Roles
-	admin.Handle("/users-roles/add", alice.New(main.apiAdminAuthMiddleware).ThenFunc(main.UsersRolesAddHandler))
-	admin.Handle("/users-roles/remove", alice.New(main.apiAdminAuthMiddleware).ThenFunc(main.UsersRolesRemoveHandler))
+	admin.Handle("/users-roles/add", alice.New(main.apiPermission(model.PermissionUserManage)).ThenFunc(main.UsersRolesAddHandler))
+	admin.Handle("/users-roles/remove", alice.New(main.apiPermission(model.PermissionUserManage)).ThenFunc(main.UsersRolesRemoveHandler))
+	// Authed Admin API for managing roles and their permissions
+	admin.Handle("/role/list", alice.New(main.apiPermission(model.PermissionUserManage)).ThenFunc(main.RoleListHandler))
+	admin.Handle("/role/save", alice.New(main.apiPermission(model.PermissionUserManage)).ThenFunc(main.RoleSaveHandler))
+	admin.Handle("/role/delete", alice.New(main.apiPermission(model.PermissionUserManage)).ThenFunc(main.RoleDeleteHandler))
+	// Authed Admin API for managing API keys
+	admin.Handle("/api_key/list", alice.New(main.apiPermission(model.PermissionUserManage)).ThenFunc(main.APIKeyListHandler))
+	admin.Handle("/api_key/create", alice.New(main.apiPermission(model.PermissionUserManage)).ThenFunc(main.APIKeyCreateHandler))
+	admin.Handle("/api_key/revoke", alice.New(main.apiPermission(model.PermissionUserManage)).ThenFunc(main.APIKeyRevokeHandler))
+	// Authed Admin API for managing sign-in sessions
+	admin.Handle("/session/list", alice.New(main.apiPermission(model.PermissionUserManage)).ThenFunc(main.SessionListHandler))
+	admin.Handle("/session/revoke", alice.New(main.apiPermission(model.PermissionUserManage)).ThenFunc(main.SessionRevokeHandler))
+	// Authed Admin API for managing event types
+	admin.Handle("/event_type/save", alice.New(main.apiPermission(model.PermissionEventTypeManage)).ThenFunc(main.EventTypeSaveHandler))
+	admin.Handle("/event_type/delete", alice.New(main.apiPermission(model.PermissionEventTypeManage)).ThenFunc(main.EventTypeDeleteHandler))
+	// Authed Admin API for the event trash
+	admin.Handle("/event/trash/list", alice.New(main.apiPermission(model.PermissionEventPurge)).ThenFunc(main.EventTrashListHandler))
+	admin.Handle("/event/trash/restore", alice.New(main.apiPermission(model.PermissionEventPurge)).ThenFunc(main.EventTrashRestoreHandler))
+	admin.Handle("/event/trash/purge", alice.New(main.apiPermission(model.PermissionEventPurge)).ThenFunc(main.EventTrashPurgeHandler))
 
 	// Pprof debug routes
 	router.HandleFunc("/debug/pprof/", pprof.Index)
@@ -218,84 +296,40 @@ func router() (*mux.Router, *sqlx.DB) {
 }
 
 type MainController struct {
-	db *sqlx.DB
-}
-
-func (c MainController) authRoleMiddleware(h http.Handler, allowedRoles []string) http.Handler {
-	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		user, authed := getAuthedADBUser(c.db, r)
-		if !authed {
-			// Delete the cookie if it doesn't auth.
-			c := &http.Cookie{
-				Name:     "auth-session",
-				Path:     "/",
-				MaxAge:   -1,
-				HttpOnly: true,
-				SameSite: http.SameSiteLaxMode,
+	db   *sqlx.DB
+	auth auth.Provider
+}
+
+// authPermission returns middleware for pages that need the user to
+// be signed in with any of permissions.
+func (c MainController) authPermission(permissions ...string) alice.Constructor {
+	return func(h http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			user, authed := getAuthedADBUser(c.db, r)
+			if !authed {
+				// Delete the cookie if it doesn't auth.
+				c := &http.Cookie{
+					Name:     "auth-session",
+					Path:     "/",
+					MaxAge:   -1,
+					HttpOnly: true,
+					SameSite: http.SameSiteLaxMode,
+				}
+				http.SetCookie(w, c)
+
+				http.Redirect(w, r, "/login", http.StatusFound)
+				return
 			}
-			http.SetCookie(w, c)
-
-			http.Redirect(w, r, "/login", http.StatusFound)
-			return
-		}
-
-		if !userIsAllowed(allowedRoles, user) {
-			http.Redirect(w, r.WithContext(setUserContext(r, user)), "/403", http.StatusFound)
-			return
-		}
-
-		// Request is authed at this point.
-		h.ServeHTTP(w, r.WithContext(setUserContext(r, user)))
-	})
-}
 
-func (c MainController) authAttendanceMiddleware(h http.Handler) http.Handler {
-	return c.authRoleMiddleware(h, []string{"admin", "organizer", "attendance"})
-}
-
-func (c MainController) authOrganizerMiddleware(h http.Handler) http.Handler {
-	return c.authRoleMiddleware(h, []string{"admin", "organizer"})
-}
-
-func (c MainController) authAdminMiddleware(h http.Handler) http.Handler {
-	return c.authRoleMiddleware(h, []string{"admin"})
-}
-
-func userIsAllowed(roles []string, user model.ADBUser) bool {
-
-	for i := 0; i < len(roles); i++ {
-		for _, r := range user.Roles {
-			if r.Role == roles[i] {
-				return true
+			if !user.HasAnyPermission(permissions...) {
+				http.Redirect(w, r.WithContext(setUserContext(r, user)), "/403", http.StatusFound)
+				return
 			}
-		}
-	}
-
-	return false
-}
-
-func getUserMainRole(user model.ADBUser) string {
-	if len(user.Roles) == 0 {
-		return ""
-	}
-
-	var mainRole string
-	for _, r := range user.Roles {
-		if r.Role == "admin" {
-			mainRole = "admin"
-			break
-		}
-
-		if r.Role == "organizer" {
-			mainRole = "organizer"
-		}
 
-		if r.Role == "attendance" && mainRole != "organizer" {
-			mainRole = "attendance"
-		}
+			// Request is authed at this point.
+			h.ServeHTTP(w, r.WithContext(setUserContext(r, user)))
+		})
 	}
-
-	return mainRole
 }
 
 func getUserName(user model.ADBUser) string {
@@ -316,34 +350,87 @@ func getUserEmail(user model.ADBUser) string {
 	return userEmail
 }
 
-func (c MainController) apiRoleMiddleware(h http.Handler, allowedRoles []string) http.Handler {
-	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		user, authed := getAuthedADBUser(c.db, r)
-		if !authed {
-			http.Error(w, http.StatusText(400), 400)
-			return
-		}
+// apiPermission returns middleware for API endpoints that need the
+// user or API key to have any of permissions.
+func (c MainController) apiPermission(permissions ...string) alice.Constructor {
+	return func(h http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			// Integrations authenticate with an API key instead of a
+			// session.
+			if key := getRequestAPIKey(r); key != "" {
+				c.serveAPIKeyRequest(h, w, r, key, permissions)
+				return
+			}
 
-		if !userIsAllowed(allowedRoles, user) {
-			http.Error(w, http.StatusText(403), 403)
-			return
-		}
+			user, authed := getAuthedADBUser(c.db, r)
+			if !authed {
+				http.Error(w, http.StatusText(400), 400)
+				return
+			}
 
-		// Request is authed at this point.
-		h.ServeHTTP(w, r)
-	})
+			if !user.HasAnyPermission(permissions...) {
+				http.Error(w, http.StatusText(403), 403)
+				return
+			}
+
+			// Request is authed at this point.
+			h.ServeHTTP(w, r.WithContext(setUserContext(r, user)))
+		})
+	}
+}
+
+// getRequestAPIKey returns the API key sent in the Authorization
+// header as a bearer token, or in the X-API-Key header.
+func getRequestAPIKey(r *http.Request) string {
+	if auth := r.Header.Get("Authorization"); strings.HasPrefix(auth, "Bearer ") {
+		return strings.TrimSpace(strings.TrimPrefix(auth, "Bearer "))
+	}
+	return strings.TrimSpace(r.Header.Get("X-API-Key"))
 }
 
-func (c MainController) apiAttendanceAuthMiddleware(h http.Handler) http.Handler {
-	return c.apiRoleMiddleware(h, []string{"admin", "organizer", "attendance"})
+// serveAPIKeyRequest serves a request authenticated with an API key,
+// and logs it.
+func (c MainController) serveAPIKeyRequest(h http.Handler, w http.ResponseWriter, r *http.Request, key string, permissions []string) {
+	apiKey, err := model.AuthenticateAPIKey(c.db, key)
+	if err != nil {
+		http.Error(w, http.StatusText(401), 401)
+		return
+	}
+	user := apiKey.ADBUser()
+	user.Permissions, err = model.GetPermissions(c.db, []string{apiKey.Role})
+	if err != nil {
+		sendErrorMessage(w, err)
+		return
+	}
+
+	recorder := &statusRecorder{ResponseWriter: w, status: http.StatusOK}
+	if user.HasAnyPermission(permissions...) {
+		h.ServeHTTP(recorder, r.WithContext(setUserContext(r, user)))
+	} else {
+		http.Error(recorder, http.StatusText(403), 403)
+	}
+
+	err = model.LogAPIKeyRequest(c.db, model.APIKeyRequest{
+		APIKeyID:   apiKey.ID,
+		Method:     r.Method,
+		Path:       r.URL.Path,
+		Status:     recorder.status,
+		RemoteAddr: r.RemoteAddr,
+	})
+	if err != nil {
+		log.Printf("ERROR: %+v", err)
+	}
 }
 
-func (c MainController) apiOrganizerAuthMiddleware(h http.Handler) http.Handler {
-	return c.apiRoleMiddleware(h, []string{"admin", "organizer"})
+// statusRecorder remembers the status code written to a response.
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
 }
 
-func (c MainController) apiAdminAuthMiddleware(h http.Handler) http.Handler {
-	return c.apiRoleMiddleware(h, []string{"admin"})
+func (r *statusRecorder) WriteHeader(status int) {
+	r.status = status
+	r.ResponseWriter.WriteHeader(status)
 }
 
 func setUserContext(r *http.Request, user model.ADBUser) context.Context {
@@ -361,53 +448,82 @@ func getUserFromContext(ctx context.Context) model.ADBUser {
 	return userctx.(model.ADBUser)
 }
 
-var verifier = func() *oidc.IDTokenVerifier {
-	provider, err := oidc.NewProvider(context.Background(), "https://accounts.google.com")
+func (c MainController) LoginHandler(w http.ResponseWriter, r *http.Request) {
+	renderPage(w, r, "login", PageData{PageName: "Login"})
+}
+
+// LoginStartHandler sends the user to the auth provider to sign in.
+func (c MainController) LoginStartHandler(w http.ResponseWriter, r *http.Request) {
+	state, err := auth.NewState()
+	if err != nil {
+		panic(err)
+	}
+	authURL, err := c.auth.AuthCodeURL(r.Context(), state)
 	if err != nil {
-		log.Fatal(err)
+		renderPage(w, r, "login", PageData{PageName: "Login", Data: "Could not reach the sign-in provider. Please try again."})
+		return
 	}
-	return provider.Verifier(&oidc.Config{
-		ClientID: "975059814880-lfffftbpt7fdl14cevtve8sjvh015udc.apps.googleusercontent.com",
+	http.SetCookie(w, &http.Cookie{
+		Name:     "auth-state",
+		Value:    state,
+		Path:     "/",
+		MaxAge:   3600,
+		HttpOnly: true,
+		SameSite: http.SameSiteLaxMode,
 	})
-}()
+	http.Redirect(w, r, authURL, http.StatusFound)
+}
 
-func (c MainController) TokenSignInHandler(w http.ResponseWriter, r *http.Request) {
-	if err := r.ParseForm(); err != nil {
-		panic(err)
+// AuthCallbackHandler signs the user in once the auth provider sends
+// them back.
+func (c MainController) AuthCallbackHandler(w http.ResponseWriter, r *http.Request) {
+	http.SetCookie(w, &http.Cookie{
+		Name:     "auth-state",
+		Path:     "/",
+		MaxAge:   -1,
+		HttpOnly: true,
+		SameSite: http.SameSiteLaxMode,
+	})
+	state, err := r.Cookie("auth-state")
+	if err != nil || state.Value == "" || state.Value != r.FormValue("state") {
+		renderPage(w, r, "login", PageData{PageName: "Login", Data: "Your sign-in expired. Please try again."})
+		return
 	}
 
-	idToken, err := verifier.Verify(r.Context(), r.PostFormValue("idtoken"))
+	rawIDToken, err := c.auth.Exchange(r.Context(), r.FormValue("code"))
 	if err != nil {
-		panic(err)
+		renderPage(w, r, "login", PageData{PageName: "Login", Data: "Could not sign you in: " + err.Error()})
+		return
 	}
-	var claims struct {
-		Email string `json:"email"`
+	identity, err := c.auth.Verify(r.Context(), rawIDToken)
+	if err != nil {
+		renderPage(w, r, "login", PageData{PageName: "Login", Data: "Could not sign you in: " + err.Error()})
+		return
 	}
-	if err := idToken.Claims(&claims); err != nil {
-		panic(err)
+	if !identity.EmailVerified {
+		renderPage(w, r, "login", PageData{PageName: "Login", Data: "Your email address has not been verified by the sign-in provider"})
+		return
 	}
 
-	adbUser, err := model.GetADBUser(c.db, 0, claims.Email)
+	adbUser, err := model.GetADBUser(c.db, 0, identity.Email)
 	if err != nil || adbUser.Disabled {
-		writeJSON(w, map[string]interface{}{
-			"redirect": false,
-			"message":  "Email is not valid",
-		})
+		renderPage(w, r, "login", PageData{PageName: "Login", Data: "Email is not valid"})
 		return
 	}
 
-	// Email is valid
-	setAuthSession(w, r, adbUser)
-	writeJSON(w, map[string]interface{}{
-		"redirect": true,
-	})
-}
-
-func (c MainController) LoginHandler(w http.ResponseWriter, r *http.Request) {
-	renderPage(w, r, "login", PageData{PageName: "Login"})
+	if err := setAuthSession(c.db, w, r, adbUser); err != nil {
+		panic(err)
+	}
+	http.Redirect(w, r, "/", http.StatusFound)
 }
 
 func (c MainController) LogoutHandler(w http.ResponseWriter, r *http.Request) {
+	if token, ok := getAuthSessionToken(r); ok {
+		if err := model.RevokeSessionToken(c.db, token); err != nil {
+			panic(err)
+		}
+	}
+
 	cookie := &http.Cookie{
 		Name:     "auth-session",
 		Value:    "",
@@ -600,10 +716,96 @@ func (c MainController) ListCirclesHandler(w http.ResponseWriter, r *http.Reques
 	renderPage(w, r, "circles_list", PageData{PageName: "CirclesList"})
 }
 
+func (c MainController) ListActivistDuplicatesHandler(w http.ResponseWriter, r *http.Request) {
+	renderPage(w, r, "activist_duplicates", PageData{PageName: "ActivistDuplicates"})
+}
+
+func (c MainController) ImportPageHandler(w http.ResponseWriter, r *http.Request) {
+	renderPage(w, r, "import", PageData{PageName: "Import"})
+}
+
+func (c MainController) ActivistHistoryPageHandler(w http.ResponseWriter, r *http.Request) {
+	activistID, err := strconv.Atoi(mux.Vars(r)["activist_id"])
+	if err != nil {
+		panic(err)
+	}
+
+	renderPage(w, r, "activist_history", PageData{
+		PageName: "ActivistHistory",
+		Data: map[string]interface{}{
+			"ActivistID": activistID,
+		},
+	})
+}
+
 func (c MainController) ListUsersHandler(w http.ResponseWriter, r *http.Request) {
 	renderPage(w, r, "user_list", PageData{PageName: "UserList"})
 }
 
+func (c MainController) ListEventTypesHandler(w http.ResponseWriter, r *http.Request) {
+	renderPage(w, r, "event_type_list", PageData{PageName: "EventTypeList"})
+}
+
+func (c MainController) ListEventTrashHandler(w http.ResponseWriter, r *http.Request) {
+	renderPage(w, r, "event_trash", PageData{PageName: "EventTrash"})
+}
+
+func (c MainController) ListSessionsHandler(w http.ResponseWriter, r *http.Request) {
+	renderPage(w, r, "session_list", PageData{PageName: "SessionList"})
+}
+
+func (c MainController) ListRolesHandler(w http.ResponseWriter, r *http.Request) {
+	renderPage(w, r, "role_list", PageData{PageName: "RoleList"})
+}
+
+func (c MainController) ListEventSeriesHandler(w http.ResponseWriter, r *http.Request) {
+	renderPage(w, r, "event_series_list", PageData{PageName: "EventSeriesList"})
+}
+
+func (c MainController) ListCheckInsHandler(w http.ResponseWriter, r *http.Request) {
+	renderPage(w, r, "check_in_list", PageData{PageName: "CheckInList"})
+}
+
+// CheckInHandler serves the public page attendees reach from an
+// event's check-in link or QR code. It doesn't need a login; the
+// signed token in the URL is what allows checking in to the event.
+func (c MainController) CheckInHandler(w http.ResponseWriter, r *http.Request) {
+	data := map[string]interface{}{}
+	pageData := PageData{PageName: "CheckIn", Data: data}
+
+	eventID, err := model.ParseCheckInToken([]byte(config.CheckInSecret), mux.Vars(r)["token"], time.Now())
+	if err != nil {
+		data["Error"] = err.Error()
+		renderPage(w, r, "check_in", pageData)
+		return
+	}
+	event, err := model.GetEvent(c.db, model.GetEventOptions{EventID: eventID})
+	if err != nil {
+		data["Error"] = "This event no longer exists"
+		renderPage(w, r, "check_in", pageData)
+		return
+	}
+	data["EventName"] = event.EventName
+	data["EventDate"] = event.EventDate
+
+	if r.Method == http.MethodPost {
+		checkIn, err := model.CleanCheckInData(r.PostFormValue("name"), r.PostFormValue("email"), r.PostFormValue("phone"))
+		if err == nil {
+			err = model.CreateCheckIn(c.db, eventID, checkIn)
+		}
+		if err != nil {
+			fmt.Printf("ERROR: %+v\n", err)
+			data["Error"] = err.Error()
+			data["Name"] = r.PostFormValue("name")
+			data["Email"] = r.PostFormValue("email")
+			data["Phone"] = r.PostFormValue("phone")
+		} else {
+			data["CheckedIn"] = checkIn.Name
+		}
+	}
+	renderPage(w, r, "check_in", pageData)
+}
+
 var templates = template.Must(template.New("").Funcs(
 	template.FuncMap{
 		"formatdate": func(date time.Time) string {
@@ -618,11 +820,17 @@ type PageData struct {
 	PageName  string
 	Data      interface{}
 	CsrfField string
-	MainRole  string
 	UserName  string
 	UserEmail string
 	// Filled in by renderPage.
 	StaticResourcesHash string
+	Permissions         map[string]bool
+}
+
+// Can reports whether the user viewing the page has permission. The
+// header uses it to only show the pages they can open.
+func (p PageData) Can(permission string) bool {
+	return p.Permissions[permission]
 }
 
 // Render a page. All templates that load a header expect a PageData
@@ -630,7 +838,7 @@ type PageData struct {
 func renderPage(w io.Writer, r *http.Request, name string, pageData PageData) {
 	pageData.CsrfField = csrf.Token(r)
 	pageData.StaticResourcesHash = config.StaticResourcesHash()
-	pageData.MainRole = getUserMainRole(getUserFromContext(r.Context()))
+	pageData.Permissions = getUserFromContext(r.Context()).Permissions
 	pageData.UserName = getUserName(getUserFromContext(r.Context()))
 	pageData.UserEmail = getUserEmail(getUserFromContext(r.Context()))
 	renderTemplate(w, name, pageData)
@@ -664,6 +872,29 @@ func sendErrorMessage(w io.Writer, err error) {
 	})
 }
 
+// sendUnknownAttendees tells the client which attendees need to be
+// confirmed as new activists or swapped for an existing one before a
+// strict save can go through.
+func sendUnknownAttendees(w io.Writer, err *model.UnknownAttendeesError) {
+	writeJSON(w, map[string]interface{}{
+		"status":            "error",
+		"message":           err.Error(),
+		"unknown_attendees": err.Attendees,
+	})
+}
+
+// sendConflict tells the client that what it tried to save was
+// changed by someone else since it was loaded, and what it looks like
+// now.
+func sendConflict(w io.Writer, err *model.ConflictError) {
+	writeJSON(w, map[string]interface{}{
+		"status":   "error",
+		"message":  err.Error(),
+		"conflict": true,
+		"current":  err.Current,
+	})
+}
+
 func (c MainController) UpdateEventHandler(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
 	var eventID int
@@ -685,10 +916,10 @@ func (c MainController) UpdateEventHandler(w http.ResponseWriter, r *http.Reques
 
 func (c MainController) UpdateConnectionHandler(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
-	var eventID int
-	if eventIDStr, ok := vars["event_id"]; ok {
+	var connectionID int
+	if connectionIDStr, ok := vars["connection_id"]; ok {
 		var err error
-		eventID, err = strconv.Atoi(eventIDStr)
+		connectionID, err = strconv.Atoi(connectionIDStr)
 		if err != nil {
 			panic(err)
 		}
@@ -697,7 +928,7 @@ func (c MainController) UpdateConnectionHandler(w http.ResponseWriter, r *http.R
 	renderPage(w, r, "connection_new", PageData{
 		PageName: "NewConnection",
 		Data: map[string]interface{}{
-			"EventID": eventID,
+			"ConnectionID": connectionID,
 		},
 	})
 }
@@ -737,7 +968,7 @@ func (c MainController) ActivistInfiniteScrollHandler(w http.ResponseWriter, r *
 		sendErrorMessage(w, err)
 		return
 	}
-	activists, err := model.GetActivistRangeJSON(c.db, activistOptions)
+	activists, err := model.GetActivistRangeJSON(c.db, activistOptions, getUserFromContext(r.Context()))
 	if err != nil {
 		sendErrorMessage(w, err)
 		return
@@ -756,13 +987,36 @@ func (c MainController) ActivistSaveHandler(w http.ResponseWriter, r *http.Reque
 		return
 	}
 
+	user := getUserFromContext(r.Context())
+	activistExtra, err = model.RestoreRedactedActivistFields(c.db, activistExtra, user)
+	if err != nil {
+		sendErrorMessage(w, err)
+		return
+	}
+
+	if !user.HasPermission(model.PermissionActivistWriteContact) {
+		changed, err := model.ActivistContactChanged(c.db, activistExtra)
+		if err != nil {
+			sendErrorMessage(w, err)
+			return
+		}
+		if changed {
+			sendErrorMessage(w, errors.New("You don't have permission to change contact details"))
+			return
+		}
+	}
+
 	// If the activist id is 0, that means they're creating a new
 	// activist.
 	var activistID int
 	if activistExtra.ID == 0 {
-		activistID, err = model.CreateActivist(c.db, activistExtra)
+		activistID, err = model.CreateActivist(c.db, activistExtra, getUserFromContext(r.Context()))
 	} else {
-		activistID, err = model.UpdateActivistData(c.db, activistExtra)
+		activistID, err = model.UpdateActivistData(c.db, activistExtra, getUserFromContext(r.Context()))
+	}
+	if conflictErr, ok := err.(*model.ConflictError); ok {
+		sendConflict(w, conflictErr)
+		return
 	}
 	if err != nil {
 		sendErrorMessage(w, err)
@@ -770,7 +1024,7 @@ func (c MainController) ActivistSaveHandler(w http.ResponseWriter, r *http.Reque
 	}
 
 	// Retrieve updated information from database and send in response body
-	activist, err := model.GetActivistJSON(c.db, model.GetActivistOptions{ID: activistID})
+	activist, err := model.GetActivistJSON(c.db, model.GetActivistOptions{ID: activistID}, user)
 	if err != nil {
 		sendErrorMessage(w, err)
 		return
@@ -793,7 +1047,7 @@ func (c MainController) ActivistHideHandler(w http.ResponseWriter, r *http.Reque
 		return
 	}
 
-	err = model.HideActivist(c.db, activistID.ID)
+	err = model.HideActivist(c.db, activistID.ID, getUserFromContext(r.Context()))
 	if err != nil {
 		sendErrorMessage(w, err)
 		return
@@ -824,7 +1078,7 @@ func (c MainController) ActivistMergeHandler(w http.ResponseWriter, r *http.Requ
 		return
 	}
 
-	err = model.MergeActivist(c.db, activistMergeData.CurrentActivistID, mergedActivist.ID)
+	err = model.MergeActivist(c.db, activistMergeData.CurrentActivistID, mergedActivist.ID, getUserFromContext(r.Context()))
 	if err != nil {
 		sendErrorMessage(w, err)
 		return
@@ -836,14 +1090,17 @@ func (c MainController) ActivistMergeHandler(w http.ResponseWriter, r *http.Requ
 	writeJSON(w, out)
 }
 
-func (c MainController) EventGetHandler(w http.ResponseWriter, r *http.Request) {
-	eventID, err := strconv.Atoi(mux.Vars(r)["event_id"])
+func (c MainController) ActivistUnmergeHandler(w http.ResponseWriter, r *http.Request) {
+	var requestData struct {
+		ActivistID int `json:"activist_id"`
+	}
+	err := json.NewDecoder(r.Body).Decode(&requestData)
 	if err != nil {
 		sendErrorMessage(w, err)
 		return
 	}
 
-	event, err := model.GetEvent(c.db, model.GetEventOptions{EventID: eventID})
+	err = model.UnmergeActivist(c.db, requestData.ActivistID, getUserFromContext(r.Context()))
 	if err != nil {
 		sendErrorMessage(w, err)
 		return
@@ -851,173 +1108,964 @@ func (c MainController) EventGetHandler(w http.ResponseWriter, r *http.Request)
 
 	out := map[string]interface{}{
 		"status": "success",
-		"event":  event.ToJSON(),
 	}
 	writeJSON(w, out)
 }
 
-func (c MainController) EventSaveHandler(w http.ResponseWriter, r *http.Request) {
-	event, err := model.CleanEventData(c.db, r.Body)
+func (c MainController) ActivistDuplicatesHandler(w http.ResponseWriter, r *http.Request) {
+	duplicates, err := model.GetActivistDuplicatesJSON(c.db, 200)
 	if err != nil {
 		sendErrorMessage(w, err)
 		return
 	}
 
-	// Events with no event ID are new events.
-	isNewEvent := event.ID == 0
+	writeJSON(w, map[string]interface{}{
+		"status":     "success",
+		"duplicates": duplicates,
+	})
+}
 
-	eventID, err := model.InsertUpdateEvent(c.db, event)
+func (c MainController) ActivistDuplicateMergeHandler(w http.ResponseWriter, r *http.Request) {
+	var requestData struct {
+		OriginalActivistID int `json:"original_activist_id"`
+		TargetActivistID   int `json:"target_activist_id"`
+	}
+	err := json.NewDecoder(r.Body).Decode(&requestData)
 	if err != nil {
 		sendErrorMessage(w, err)
 		return
 	}
 
-	attendees, err := model.GetEventAttendance(c.db, eventID)
+	err = model.MergeActivistDuplicate(c.db, requestData.OriginalActivistID, requestData.TargetActivistID, getUserFromContext(r.Context()))
 	if err != nil {
 		sendErrorMessage(w, err)
 		return
 	}
 
 	out := map[string]interface{}{
-		"status":    "success",
-		"redirect":  "",
-		"attendees": attendees,
-	}
-	if isNewEvent {
-		out["redirect"] = fmt.Sprintf("/update_event/%d", eventID)
+		"status": "success",
 	}
 	writeJSON(w, out)
 }
 
-func (c MainController) ConnectionSaveHandler(w http.ResponseWriter, r *http.Request) {
-	event, err := model.CleanEventData(c.db, r.Body)
+func (c MainController) ActivistDuplicateDismissHandler(w http.ResponseWriter, r *http.Request) {
+	var requestData struct {
+		ActivistIDA int `json:"activist_id_a"`
+		ActivistIDB int `json:"activist_id_b"`
+	}
+	err := json.NewDecoder(r.Body).Decode(&requestData)
 	if err != nil {
 		sendErrorMessage(w, err)
 		return
 	}
 
-	// Events with no event ID are new events.
-	isNewEvent := event.ID == 0
-
-	eventID, err := model.InsertUpdateEvent(c.db, event)
+	err = model.DismissActivistDuplicate(c.db, requestData.ActivistIDA, requestData.ActivistIDB, getUserFromContext(r.Context()))
 	if err != nil {
 		sendErrorMessage(w, err)
 		return
 	}
 
-	attendees, err := model.GetEventAttendance(c.db, eventID)
+	out := map[string]interface{}{
+		"status": "success",
+	}
+	writeJSON(w, out)
+}
+
+func (c MainController) ActivistHistoryHandler(w http.ResponseWriter, r *http.Request) {
+	var requestData struct {
+		ActivistID int `json:"activist_id"`
+	}
+	err := json.NewDecoder(r.Body).Decode(&requestData)
 	if err != nil {
 		sendErrorMessage(w, err)
 		return
 	}
 
-	out := map[string]interface{}{
-		"status":    "success",
-		"redirect":  "",
-		"attendees": attendees,
-	}
-	if isNewEvent {
-		out["redirect"] = fmt.Sprintf("/update_connection/%d", eventID)
+	changes, err := model.GetActivistChangesJSON(c.db, requestData.ActivistID, getUserFromContext(r.Context()))
+	if err != nil {
+		sendErrorMessage(w, err)
+		return
 	}
-	writeJSON(w, out)
+
+	writeJSON(w, map[string]interface{}{
+		"status":  "success",
+		"changes": changes,
+	})
 }
 
-func (c MainController) EventListHandler(w http.ResponseWriter, r *http.Request) {
-	err := r.ParseForm()
+// ActivistEventsHandler returns every event an activist attended and
+// which months they met the MPI requirements in.
+func (c MainController) ActivistEventsHandler(w http.ResponseWriter, r *http.Request) {
+	activistID, err := strconv.Atoi(mux.Vars(r)["activist_id"])
 	if err != nil {
 		sendErrorMessage(w, err)
 		return
 	}
 
-	eventName := r.PostFormValue("event_name")
-	eventActivist := r.PostFormValue("event_activist")
+	timeline, err := model.GetActivistTimelineJSON(c.db, activistID)
+	if err != nil {
+		sendErrorMessage(w, err)
+		return
+	}
+
+	writeJSON(w, map[string]interface{}{
+		"status":        "success",
+		"activist_id":   timeline.ActivistID,
+		"activist_name": timeline.ActivistName,
+		"events":        timeline.Events,
+		"months":        timeline.Months,
+	})
+}
+
+func (c MainController) EventGetHandler(w http.ResponseWriter, r *http.Request) {
+	eventID, err := strconv.Atoi(mux.Vars(r)["event_id"])
+	if err != nil {
+		sendErrorMessage(w, err)
+		return
+	}
+
+	event, err := model.GetEvent(c.db, model.GetEventOptions{EventID: eventID})
+	if err != nil {
+		sendErrorMessage(w, err)
+		return
+	}
+
+	out := map[string]interface{}{
+		"status": "success",
+		"event":  event.ToJSON(),
+	}
+	writeJSON(w, out)
+}
+
+func (c MainController) EventSaveHandler(w http.ResponseWriter, r *http.Request) {
+	event, err := model.CleanEventData(c.db, r.Body, getUserFromContext(r.Context()))
+	if unknownErr, ok := err.(*model.UnknownAttendeesError); ok {
+		sendUnknownAttendees(w, unknownErr)
+		return
+	}
+	if err != nil {
+		sendErrorMessage(w, err)
+		return
+	}
+
+	// Events with no event ID are new events.
+	isNewEvent := event.ID == 0
+
+	eventID, err := model.InsertUpdateEvent(c.db, event)
+	if conflictErr, ok := err.(*model.ConflictError); ok {
+		sendConflict(w, conflictErr)
+		return
+	}
+	if err != nil {
+		sendErrorMessage(w, err)
+		return
+	}
+
+	saved, err := model.GetEvent(c.db, model.GetEventOptions{EventID: eventID})
+	if err != nil {
+		sendErrorMessage(w, err)
+		return
+	}
+
+	out := map[string]interface{}{
+		"status":    "success",
+		"redirect":  "",
+		"attendees": saved.Attendees,
+		"version":   saved.Version,
+	}
+	if isNewEvent {
+		out["redirect"] = fmt.Sprintf("/update_event/%d", eventID)
+	}
+	writeJSON(w, out)
+}
+
+func (c MainController) ConnectionGetHandler(w http.ResponseWriter, r *http.Request) {
+	connectionID, err := strconv.Atoi(mux.Vars(r)["connection_id"])
+	if err != nil {
+		sendErrorMessage(w, err)
+		return
+	}
+
+	connection, err := model.GetConnection(c.db, connectionID)
+	if err != nil {
+		sendErrorMessage(w, err)
+		return
+	}
+
+	writeJSON(w, map[string]interface{}{
+		"status":     "success",
+		"connection": connection.ToJSON(),
+	})
+}
+
+func (c MainController) ConnectionSaveHandler(w http.ResponseWriter, r *http.Request) {
+	connection, err := model.CleanConnectionData(c.db, r.Body, getUserFromContext(r.Context()))
+	if err != nil {
+		sendErrorMessage(w, err)
+		return
+	}
+
+	// Connections with no ID are new connections.
+	isNewConnection := connection.ID == 0
+
+	connectionID, err := model.SaveConnection(c.db, connection)
+	if conflictErr, ok := err.(*model.ConflictError); ok {
+		sendConflict(w, conflictErr)
+		return
+	}
+	if err != nil {
+		sendErrorMessage(w, err)
+		return
+	}
+
+	saved, err := model.GetConnection(c.db, connectionID)
+	if err != nil {
+		sendErrorMessage(w, err)
+		return
+	}
+
+	out := map[string]interface{}{
+		"status":     "success",
+		"redirect":   "",
+		"connection": saved.ToJSON(),
+	}
+	if isNewConnection {
+		out["redirect"] = fmt.Sprintf("/update_connection/%d", connectionID)
+	}
+	writeJSON(w, out)
+}
+
+func (c MainController) ConnectionListHandler(w http.ResponseWriter, r *http.Request) {
+	err := r.ParseForm()
+	if err != nil {
+		sendErrorMessage(w, err)
+		return
+	}
+
+	connections, err := model.GetConnectionsJSON(c.db, model.GetConnectionOptions{
+		Connector:  r.PostFormValue("connector"),
+		Connectee:  r.PostFormValue("connectee"),
+		DateFrom:   r.PostFormValue("date_start"),
+		DateTo:     r.PostFormValue("date_end"),
+		FollowUpBy: r.PostFormValue("follow_up_by"),
+	})
+	if err != nil {
+		sendErrorMessage(w, err)
+		return
+	}
+
+	writeJSON(w, map[string]interface{}{
+		"status":      "success",
+		"connections": connections,
+	})
+}
+
+func (c MainController) ConnectionDeleteHandler(w http.ResponseWriter, r *http.Request) {
+	var requestData struct {
+		ConnectionID int `json:"connection_id"`
+	}
+	err := json.NewDecoder(r.Body).Decode(&requestData)
+	if err != nil {
+		sendErrorMessage(w, err)
+		return
+	}
+
+	if err := model.DeleteConnection(c.db, requestData.ConnectionID); err != nil {
+		sendErrorMessage(w, err)
+		return
+	}
+
+	writeJSON(w, map[string]interface{}{
+		"status": "success",
+	})
+}
+
+func (c MainController) EventListHandler(w http.ResponseWriter, r *http.Request) {
+	err := r.ParseForm()
+	if err != nil {
+		sendErrorMessage(w, err)
+		return
+	}
+
+	eventName := r.PostFormValue("event_name")
+	eventActivist := r.PostFormValue("event_activist")
 	dateStart := r.PostFormValue("event_date_start")
 	dateEnd := r.PostFormValue("event_date_end")
 	eventType := r.PostFormValue("event_type")
+	eventLocation := r.PostFormValue("event_location")
+	leadOrganizer := r.PostFormValue("event_lead_organizer")
+	var seriesID, workingGroupID, circleID int
+	for field, id := range map[string]*int{
+		"event_series_id":        &seriesID,
+		"event_working_group_id": &workingGroupID,
+		"event_circle_id":        &circleID,
+	} {
+		if s := r.PostFormValue(field); s != "" {
+			*id, err = strconv.Atoi(s)
+			if err != nil {
+				sendErrorMessage(w, err)
+				return
+			}
+		}
+	}
+
+	events, err := model.GetEventsJSON(c.db, model.GetEventOptions{
+		OrderBy:        "e.date DESC, e.id DESC",
+		DateFrom:       dateStart,
+		DateTo:         dateEnd,
+		EventType:      eventType,
+		EventNameQuery: eventName,
+		EventActivist:  eventActivist,
+		SeriesID:       seriesID,
+		EventLocation:  eventLocation,
+		LeadOrganizer:  leadOrganizer,
+		WorkingGroupID: workingGroupID,
+		CircleID:       circleID,
+	})
+
+	if err != nil {
+		sendErrorMessage(w, err)
+		return
+	}
+
+	writeJSON(w, events)
+}
+
+func (c MainController) EventOwnersHandler(w http.ResponseWriter, r *http.Request) {
+	workingGroups, circles, err := model.GetEventOwnersJSON(c.db)
+	if err != nil {
+		sendErrorMessage(w, err)
+		return
+	}
+
+	writeJSON(w, map[string]interface{}{
+		"status":         "success",
+		"working_groups": workingGroups,
+		"circles":        circles,
+	})
+}
+
+// checkInURL returns the public check-in link for an event, and when
+// it expires.
+func (c MainController) checkInURL(r *http.Request) (string, time.Time, error) {
+	eventID, err := strconv.Atoi(mux.Vars(r)["event_id"])
+	if err != nil {
+		return "", time.Time{}, err
+	}
+	token, expires, err := model.NewEventCheckInToken(c.db, []byte(config.CheckInSecret), eventID)
+	if err != nil {
+		return "", time.Time{}, err
+	}
+	scheme := "http"
+	if config.IsProd {
+		scheme = "https"
+	}
+	return scheme + "://" + r.Host + "/check_in/" + token, expires, nil
+}
+
+func (c MainController) EventCheckInLinkHandler(w http.ResponseWriter, r *http.Request) {
+	url, expires, err := c.checkInURL(r)
+	if err != nil {
+		sendErrorMessage(w, err)
+		return
+	}
+
+	writeJSON(w, map[string]interface{}{
+		"status":  "success",
+		"url":     url,
+		"expires": expires.Format(time.RFC3339),
+	})
+}
+
+func (c MainController) EventCheckInQRHandler(w http.ResponseWriter, r *http.Request) {
+	url, _, err := c.checkInURL(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	png, err := qrcode.Encode(url, qrcode.Medium, 512)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "image/png")
+	w.Write(png)
+}
+
+func (c MainController) CheckInsPendingHandler(w http.ResponseWriter, r *http.Request) {
+	var eventID int
+	if s := r.URL.Query().Get("event_id"); s != "" {
+		var err error
+		eventID, err = strconv.Atoi(s)
+		if err != nil {
+			sendErrorMessage(w, err)
+			return
+		}
+	}
+
+	checkIns, err := model.GetPendingCheckInsJSON(c.db, eventID)
+	if err != nil {
+		sendErrorMessage(w, err)
+		return
+	}
+
+	writeJSON(w, map[string]interface{}{
+		"status":    "success",
+		"check_ins": checkIns,
+	})
+}
+
+func (c MainController) CheckInApproveHandler(w http.ResponseWriter, r *http.Request) {
+	var requestData struct {
+		ID           int    `json:"id"`
+		ActivistName string `json:"activist_name"`
+	}
+	err := json.NewDecoder(r.Body).Decode(&requestData)
+	if err != nil {
+		sendErrorMessage(w, err)
+		return
+	}
+
+	err = model.ApproveCheckIn(c.db, requestData.ID, requestData.ActivistName, getUserFromContext(r.Context()))
+	if err != nil {
+		sendErrorMessage(w, err)
+		return
+	}
+
+	writeJSON(w, map[string]interface{}{
+		"status": "success",
+	})
+}
+
+func (c MainController) CheckInRejectHandler(w http.ResponseWriter, r *http.Request) {
+	var requestData struct {
+		ID int `json:"id"`
+	}
+	err := json.NewDecoder(r.Body).Decode(&requestData)
+	if err != nil {
+		sendErrorMessage(w, err)
+		return
+	}
+
+	if err := model.RejectCheckIn(c.db, requestData.ID, getUserFromContext(r.Context())); err != nil {
+		sendErrorMessage(w, err)
+		return
+	}
+
+	writeJSON(w, map[string]interface{}{
+		"status": "success",
+	})
+}
+
+func (c MainController) EventDeleteHandler(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil {
+		panic(err)
+	}
+	eventIDStr := r.PostFormValue("event_id")
+	eventID, err := strconv.Atoi(eventIDStr)
+	if err != nil {
+		panic(err)
+	}
+
+	if err := model.DeleteEvent(c.db, eventID, getUserFromContext(r.Context())); err != nil {
+		sendErrorMessage(w, err)
+		return
+	}
+
+	writeJSON(w, map[string]string{
+		"status": "success",
+	})
+}
+
+func (c MainController) WorkingGroupSaveHandler(w http.ResponseWriter, r *http.Request) {
+	wg, err := model.CleanWorkingGroupData(c.db, r.Body)
+	if err != nil {
+		sendErrorMessage(w, err)
+		return
+	}
+
+	// Users without group.write can only edit the groups they lead, and
+	// can't add members to them.
+	user := getUserFromContext(r.Context())
+	if !user.HasPermission(model.PermissionGroupWrite) {
+		leads, err := model.LeadsWorkingGroup(c.db, user.ActivistID, wg.ID)
+		if err != nil {
+			sendErrorMessage(w, err)
+			return
+		}
+		if !leads {
+			sendErrorMessage(w, errors.New("You can only edit working groups you are a point person of"))
+			return
+		}
+		if err := model.CheckWorkingGroupMembersNotAdded(c.db, wg); err != nil {
+			sendErrorMessage(w, err)
+			return
+		}
+	}
+
+	var wgID int
+	if wg.ID == 0 {
+		wgID, err = model.CreateWorkingGroup(c.db, wg)
+	} else {
+		wgID, err = model.UpdateWorkingGroup(c.db, wg)
+	}
+	if err != nil {
+		sendErrorMessage(w, err)
+		return
+	}
+
+	wgJSON, err := model.GetWorkingGroupJSON(c.db, wgID)
+	if err != nil {
+		sendErrorMessage(w, err)
+		return
+	}
+
+	writeJSON(w, map[string]interface{}{
+		"status":        "success",
+		"working_group": wgJSON,
+	})
+}
+
+func (c MainController) WorkingGroupListHandler(w http.ResponseWriter, r *http.Request) {
+	wgs, err := model.GetWorkingGroupsJSON(c.db)
+	if err != nil {
+		sendErrorMessage(w, err)
+		return
+	}
+
+	writeJSON(w, map[string]interface{}{
+		"status":         "success",
+		"working_groups": wgs,
+	})
+}
+
+func (c MainController) WorkingGroupDeleteHandler(w http.ResponseWriter, r *http.Request) {
+	var requestData struct {
+		ID int `json:"working_group_id"`
+	}
+	err := json.NewDecoder(r.Body).Decode(&requestData)
+	if err != nil {
+		sendErrorMessage(w, err)
+		return
+	}
+
+	err = model.DeleteWorkingGroup(c.db, requestData.ID)
+	if err != nil {
+		sendErrorMessage(w, err)
+		return
+	}
+
+	writeJSON(w, map[string]string{
+		"status": "success",
+	})
+}
+
+//start circle
+func (c MainController) CircleGroupSaveHandler(w http.ResponseWriter, r *http.Request) {
+	cir, err := model.CleanCircleGroupData(c.db, r.Body)
+	if err != nil {
+		sendErrorMessage(w, err)
+		return
+	}
+
+	// Users without group.write can only edit the circles they lead, and
+	// can't add members to them.
+	user := getUserFromContext(r.Context())
+	if !user.HasPermission(model.PermissionGroupWrite) {
+		leads, err := model.LeadsCircleGroup(c.db, user.ActivistID, cir.ID)
+		if err != nil {
+			sendErrorMessage(w, err)
+			return
+		}
+		if !leads {
+			sendErrorMessage(w, errors.New("You can only edit circles you are a point person of"))
+			return
+		}
+		if err := model.CheckCircleGroupMembersNotAdded(c.db, cir); err != nil {
+			sendErrorMessage(w, err)
+			return
+		}
+	}
+
+	var cirID int
+	if cir.ID == 0 {
+		cirID, err = model.CreateCircleGroup(c.db, cir)
+	} else {
+		cirID, err = model.UpdateCircleGroup(c.db, cir)
+	}
+	if err != nil {
+		sendErrorMessage(w, err)
+		return
+	}
+
+	cirJSON, err := model.GetCircleGroupJSON(c.db, cirID)
+	if err != nil {
+		sendErrorMessage(w, err)
+		return
+	}
+
+	writeJSON(w, map[string]interface{}{
+		"status": "success",
+		"circle": cirJSON,
+	})
+}
+
+func (c MainController) CircleGroupListHandler(w http.ResponseWriter, r *http.Request) {
+	cirs, err := model.GetCircleGroupsJSON(c.db)
+	if err != nil {
+		sendErrorMessage(w, err)
+		return
+	}
+
+	writeJSON(w, map[string]interface{}{
+		"status":         "success",
+		"working_groups": cirs,
+	})
+}
+
+func (c MainController) CircleGroupDeleteHandler(w http.ResponseWriter, r *http.Request) {
+	var requestData struct {
+		ID int `json:"circle_id"`
+	}
+	err := json.NewDecoder(r.Body).Decode(&requestData)
+	if err != nil {
+		sendErrorMessage(w, err)
+		return
+	}
+
+	err = model.DeleteCircleGroup(c.db, requestData.ID)
+	if err != nil {
+		sendErrorMessage(w, err)
+		return
+	}
+
+	writeJSON(w, map[string]string{
+		"status": "success",
+	})
+}
+
+//end circle
+
+func (c MainController) ActivistListHandler(w http.ResponseWriter, r *http.Request) {
+	options, err := model.CleanGetActivistOptions(r.Body)
+	if err != nil {
+		sendErrorMessage(w, err)
+		return
+	}
+	// Users without activist.read can only see the members of the
+	// groups they lead.
+	user := getUserFromContext(r.Context())
+	if !user.HasPermission(model.PermissionActivistRead) {
+		if user.ActivistID == 0 {
+			sendErrorMessage(w, errors.New("Your user isn't linked to an activist, so you can't see any group members"))
+			return
+		}
+		options.LedBy = user.ActivistID
+	}
+	activists, err := model.GetActivistsJSON(c.db, options, user)
+	if err != nil {
+		sendErrorMessage(w, err)
+		return
+	}
+
+	writeJSON(w, map[string]interface{}{
+		"status":        "success",
+		"activist_list": activists,
+	})
+}
+
+func (c MainController) ActivistSearchHandler(w http.ResponseWriter, r *http.Request) {
+	options, err := model.CleanActivistSearchOptions(r.Body)
+	if err != nil {
+		sendErrorMessage(w, err)
+		return
+	}
+	result, err := model.SearchActivists(c.db, options, getUserFromContext(r.Context()))
+	if err != nil {
+		sendErrorMessage(w, err)
+		return
+	}
+
+	writeJSON(w, map[string]interface{}{
+		"status":      "success",
+		"activists":   result.Activists,
+		"total":       result.Total,
+		"next_cursor": result.NextCursor,
+	})
+}
+
+func (c MainController) ActivistExportHandler(w http.ResponseWriter, r *http.Request) {
+	options, err := model.CleanActivistExportOptions(r.URL.Query())
+	if err != nil {
+		sendErrorMessage(w, err)
+		return
+	}
+
+	filename := "activists"
+	if options.Filter != "" {
+		filename = options.Filter
+	}
+	w.Header().Set("Content-Type", "text/csv; charset=utf-8")
+	w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="%s-%s.csv"`, filename, time.Now().Format(model.EventDateLayout)))
+
+	// The header has already been sent by the time most errors
+	// happen, so all we can do is log them.
+	if err := model.WriteActivistsCSV(c.db, w, options, getUserFromContext(r.Context())); err != nil {
+		log.Printf("ERROR: failed to export activists: %+v", err)
+	}
+}
+
+// maxImportSize is the largest CSV file that can be imported.
+const maxImportSize = 10 << 20
+
+func (c MainController) ActivistImportHandler(w http.ResponseWriter, r *http.Request) {
+	file, dryRun, err := readImportFile(r)
+	if err != nil {
+		sendErrorMessage(w, err)
+		return
+	}
+	defer file.Close()
+
+	result, err := model.ImportActivists(c.db, file, dryRun, getUserFromContext(r.Context()))
+	if err != nil {
+		sendErrorMessage(w, err)
+		return
+	}
+
+	writeJSON(w, map[string]interface{}{
+		"status": "success",
+		"import": result,
+	})
+}
+
+func (c MainController) EventImportHandler(w http.ResponseWriter, r *http.Request) {
+	file, dryRun, err := readImportFile(r)
+	if err != nil {
+		sendErrorMessage(w, err)
+		return
+	}
+	defer file.Close()
+
+	eventJSON := model.EventJSON{
+		EventName: r.FormValue("event_name"),
+		EventDate: r.FormValue("event_date"),
+		EventType: r.FormValue("event_type"),
+	}
+	result, err := model.ImportEventAttendance(c.db, eventJSON, file, dryRun, getUserFromContext(r.Context()))
+	if err != nil {
+		sendErrorMessage(w, err)
+		return
+	}
+
+	writeJSON(w, map[string]interface{}{
+		"status": "success",
+		"import": result,
+	})
+}
+
+// readImportFile returns the uploaded CSV file and whether the import
+// is a dry run. Imports are dry runs unless dry_run is "false".
+func readImportFile(r *http.Request) (multipart.File, bool, error) {
+	if err := r.ParseMultipartForm(maxImportSize); err != nil {
+		return nil, false, errors.Wrap(err, "failed to parse form")
+	}
+	file, _, err := r.FormFile("file")
+	if err != nil {
+		return nil, false, errors.Wrap(err, "failed to read file")
+	}
+	return file, r.FormValue("dry_run") != "false", nil
+}
+
+func (c MainController) ActivistListBasicHandler(w http.ResponseWriter, r *http.Request) {
+	activists := model.GetActivistListBasicJSON(c.db, getUserFromContext(r.Context()))
+
+	out := map[string]interface{}{
+		"status":    "success",
+		"activists": activists,
+	}
+
+	writeJSON(w, out)
+}
+
+func (c MainController) UserListHandler(w http.ResponseWriter, r *http.Request) {
+	users, err := model.GetUsersJSON(c.db)
+
+	if err != nil {
+		sendErrorMessage(w, err)
+		return
+	}
+
+	writeJSON(w, users)
+}
+
+func (c MainController) UserSaveHandler(w http.ResponseWriter, r *http.Request) {
+	user, err := model.CleanUserData(c.db, r.Body)
+
+	if err != nil {
+		sendErrorMessage(w, err)
+		return
+	}
+
+	// Check if we're updating an existing User or creating one
+
+	var userID int
+	if user.ID == 0 {
+		// new user
+		userID, err = model.CreateUser(c.db, user)
+	} else {
+		userID, err = model.UpdateUser(c.db, user)
+	}
+
+	if err != nil {
+		sendErrorMessage(w, err)
+		return
+	}
+
+	// Retrieve updated User Data and send back in response
+
+	userJSON, err := model.GetUserJSON(c.db, model.GetUserOptions{ID: userID})
+	if err != nil {
+		sendErrorMessage(w, err)
+		return
+	}
+
+	out := map[string]interface{}{
+		"status": "success",
+		"user":   userJSON,
+	}
+
+	writeJSON(w, out)
+}
 
-	events, err := model.GetEventsJSON(c.db, model.GetEventOptions{
-		OrderBy:        "e.date DESC, e.id DESC",
-		DateFrom:       dateStart,
-		DateTo:         dateEnd,
-		EventType:      eventType,
-		EventNameQuery: eventName,
-		EventActivist:  eventActivist,
-	})
+func (c MainController) UserDeleteHandler(w http.ResponseWriter, r *http.Request) {
+	user, err := model.CleanUserData(c.db, r.Body)
 
 	if err != nil {
 		sendErrorMessage(w, err)
 		return
 	}
 
-	writeJSON(w, events)
+	userID, err := model.RemoveUser(c.db, user.ID)
+
+	if err != nil {
+		sendErrorMessage(w, err)
+		return
+	}
+
+	out := map[string]interface{}{
+		"status": "success",
+		"userID": userID,
+	}
+
+	writeJSON(w, out)
 }
 
-func (c MainController) EventDeleteHandler(w http.ResponseWriter, r *http.Request) {
-	if err := r.ParseForm(); err != nil {
+func (c MainController) newPowerWallboard(w http.ResponseWriter, r *http.Request) {
+	power, err := model.GetPower(c.db)
+	if err != nil {
 		panic(err)
 	}
-	eventIDStr := r.PostFormValue("event_id")
-	eventID, err := strconv.Atoi(eventIDStr)
+
+	writeJSON(w, map[string]interface{}{
+		"status": "success",
+		"Power":  power,
+	})
+}
+
+func (c MainController) PowerHistoryHandler(w http.ResponseWriter, r *http.Request) {
+	history, err := model.GetPowerHistory(c.db, r.URL.Query().Get("from"), r.URL.Query().Get("to"))
+	if err != nil {
+		sendErrorMessage(w, err)
+		return
+	}
+
+	writeJSON(w, map[string]interface{}{
+		"status":  "success",
+		"history": history,
+	})
+}
+
+func (c MainController) newChapterMemberWallboard(w http.ResponseWriter, r *http.Request) {
+	members, err := model.GetActiveChapterMembers(c.db)
 	if err != nil {
 		panic(err)
 	}
 
-	if err := model.DeleteEvent(c.db, eventID); err != nil {
+	writeJSON(w, map[string]interface{}{
+		"status":  "success",
+		"Members": members,
+	})
+}
+
+func (c MainController) RoleListHandler(w http.ResponseWriter, r *http.Request) {
+	roles, err := model.GetRolesJSON(c.db)
+	if err != nil {
 		sendErrorMessage(w, err)
 		return
 	}
 
-	writeJSON(w, map[string]string{
-		"status": "success",
+	writeJSON(w, map[string]interface{}{
+		"status":      "success",
+		"roles":       roles,
+		"permissions": model.Permissions,
 	})
 }
 
-func (c MainController) WorkingGroupSaveHandler(w http.ResponseWriter, r *http.Request) {
-	wg, err := model.CleanWorkingGroupData(c.db, r.Body)
+func (c MainController) RoleSaveHandler(w http.ResponseWriter, r *http.Request) {
+	role, err := model.CleanRoleData(r.Body)
 	if err != nil {
 		sendErrorMessage(w, err)
 		return
 	}
 
-	var wgID int
-	if wg.ID == 0 {
-		wgID, err = model.CreateWorkingGroup(c.db, wg)
-	} else {
-		wgID, err = model.UpdateWorkingGroup(c.db, wg)
+	if err := model.SaveRole(c.db, role); err != nil {
+		sendErrorMessage(w, err)
+		return
+	}
+
+	writeJSON(w, map[string]interface{}{
+		"status": "success",
+	})
+}
+
+func (c MainController) RoleDeleteHandler(w http.ResponseWriter, r *http.Request) {
+	var requestData struct {
+		Name string `json:"name"`
 	}
+	err := json.NewDecoder(r.Body).Decode(&requestData)
 	if err != nil {
 		sendErrorMessage(w, err)
 		return
 	}
 
-	wgJSON, err := model.GetWorkingGroupJSON(c.db, wgID)
-	if err != nil {
+	if err := model.DeleteRole(c.db, requestData.Name); err != nil {
 		sendErrorMessage(w, err)
 		return
 	}
 
 	writeJSON(w, map[string]interface{}{
-		"status":        "success",
-		"working_group": wgJSON,
+		"status": "success",
 	})
 }
 
-func (c MainController) WorkingGroupListHandler(w http.ResponseWriter, r *http.Request) {
-	wgs, err := model.GetWorkingGroupsJSON(c.db)
+func (c MainController) APIKeyListHandler(w http.ResponseWriter, r *http.Request) {
+	keys, err := model.GetAPIKeysJSON(c.db)
 	if err != nil {
 		sendErrorMessage(w, err)
 		return
 	}
 
 	writeJSON(w, map[string]interface{}{
-		"status":         "success",
-		"working_groups": wgs,
+		"status":   "success",
+		"api_keys": keys,
 	})
 }
 
-func (c MainController) WorkingGroupDeleteHandler(w http.ResponseWriter, r *http.Request) {
+func (c MainController) APIKeyCreateHandler(w http.ResponseWriter, r *http.Request) {
 	var requestData struct {
-		ID int `json:"working_group_id"`
+		Name string `json:"name"`
+		Role string `json:"role"`
 	}
 	err := json.NewDecoder(r.Body).Decode(&requestData)
 	if err != nil {
@@ -1025,37 +2073,29 @@ func (c MainController) WorkingGroupDeleteHandler(w http.ResponseWriter, r *http
 		return
 	}
 
-	err = model.DeleteWorkingGroup(c.db, requestData.ID)
+	key, err := model.CreateAPIKey(c.db, requestData.Name, requestData.Role, getUserFromContext(r.Context()))
 	if err != nil {
 		sendErrorMessage(w, err)
 		return
 	}
 
-	writeJSON(w, map[string]string{
-		"status": "success",
+	writeJSON(w, map[string]interface{}{
+		"status":  "success",
+		"api_key": key,
 	})
 }
 
-//start circle
-func (c MainController) CircleGroupSaveHandler(w http.ResponseWriter, r *http.Request) {
-	cir, err := model.CleanCircleGroupData(c.db, r.Body)
-	if err != nil {
-		sendErrorMessage(w, err)
-		return
-	}
-
-	var cirID int
-	if cir.ID == 0 {
-		cirID, err = model.CreateCircleGroup(c.db, cir)
-	} else {
-		cirID, err = model.UpdateCircleGroup(c.db, cir)
+func (c MainController) APIKeyRevokeHandler(w http.ResponseWriter, r *http.Request) {
+	var requestData struct {
+		ID int `json:"id"`
 	}
+	err := json.NewDecoder(r.Body).Decode(&requestData)
 	if err != nil {
 		sendErrorMessage(w, err)
 		return
 	}
 
-	cirJSON, err := model.GetCircleGroupJSON(c.db, cirID)
+	err = model.RevokeAPIKey(c.db, requestData.ID, getUserFromContext(r.Context()))
 	if err != nil {
 		sendErrorMessage(w, err)
 		return
@@ -1063,26 +2103,25 @@ func (c MainController) CircleGroupSaveHandler(w http.ResponseWriter, r *http.Re
 
 	writeJSON(w, map[string]interface{}{
 		"status": "success",
-		"circle": cirJSON,
 	})
 }
 
-func (c MainController) CircleGroupListHandler(w http.ResponseWriter, r *http.Request) {
-	cirs, err := model.GetCircleGroupsJSON(c.db)
+func (c MainController) SessionListHandler(w http.ResponseWriter, r *http.Request) {
+	sessions, err := model.GetActiveSessionsJSON(c.db)
 	if err != nil {
 		sendErrorMessage(w, err)
 		return
 	}
 
 	writeJSON(w, map[string]interface{}{
-		"status":         "success",
-		"working_groups": cirs,
+		"status":   "success",
+		"sessions": sessions,
 	})
 }
 
-func (c MainController) CircleGroupDeleteHandler(w http.ResponseWriter, r *http.Request) {
+func (c MainController) SessionRevokeHandler(w http.ResponseWriter, r *http.Request) {
 	var requestData struct {
-		ID int `json:"circle_id"`
+		ID int `json:"id"`
 	}
 	err := json.NewDecoder(r.Body).Decode(&requestData)
 	if err != nil {
@@ -1090,142 +2129,185 @@ func (c MainController) CircleGroupDeleteHandler(w http.ResponseWriter, r *http.
 		return
 	}
 
-	err = model.DeleteCircleGroup(c.db, requestData.ID)
+	err = model.RevokeSession(c.db, requestData.ID)
 	if err != nil {
 		sendErrorMessage(w, err)
 		return
 	}
 
-	writeJSON(w, map[string]string{
+	writeJSON(w, map[string]interface{}{
 		"status": "success",
 	})
 }
 
-//end circle
+func (c MainController) EventTypeListHandler(w http.ResponseWriter, r *http.Request) {
+	eventTypes, err := model.GetEventTypesJSON(c.db)
+	if err != nil {
+		sendErrorMessage(w, err)
+		return
+	}
 
-func (c MainController) ActivistListHandler(w http.ResponseWriter, r *http.Request) {
-	options, err := model.CleanGetActivistOptions(r.Body)
+	writeJSON(w, map[string]interface{}{
+		"status":      "success",
+		"event_types": eventTypes,
+	})
+}
+
+func (c MainController) EventTypeSaveHandler(w http.ResponseWriter, r *http.Request) {
+	eventType, err := model.CleanEventTypeData(r.Body)
 	if err != nil {
 		sendErrorMessage(w, err)
 		return
 	}
-	activists, err := model.GetActivistsJSON(c.db, options)
+
+	eventType, err = model.SaveEventType(c.db, eventType)
 	if err != nil {
 		sendErrorMessage(w, err)
 		return
 	}
 
 	writeJSON(w, map[string]interface{}{
-		"status":        "success",
-		"activist_list": activists,
+		"status":     "success",
+		"event_type": eventType,
 	})
 }
 
-func (c MainController) ActivistListBasicHandler(w http.ResponseWriter, r *http.Request) {
-	activists := model.GetActivistListBasicJSON(c.db)
+func (c MainController) EventTypeDeleteHandler(w http.ResponseWriter, r *http.Request) {
+	var requestData struct {
+		ID int `json:"id"`
+	}
+	err := json.NewDecoder(r.Body).Decode(&requestData)
+	if err != nil {
+		sendErrorMessage(w, err)
+		return
+	}
 
-	out := map[string]interface{}{
-		"status":    "success",
-		"activists": activists,
+	if err := model.DeleteEventType(c.db, requestData.ID); err != nil {
+		sendErrorMessage(w, err)
+		return
 	}
 
-	writeJSON(w, out)
+	writeJSON(w, map[string]interface{}{
+		"status": "success",
+	})
 }
 
-func (c MainController) UserListHandler(w http.ResponseWriter, r *http.Request) {
-	users, err := model.GetUsersJSON(c.db)
-
+func (c MainController) EventTrashListHandler(w http.ResponseWriter, r *http.Request) {
+	events, err := model.GetTrashedEventsJSON(c.db)
 	if err != nil {
 		sendErrorMessage(w, err)
 		return
 	}
 
-	writeJSON(w, users)
+	writeJSON(w, map[string]interface{}{
+		"status":     "success",
+		"events":     events,
+		"trash_days": model.EventTrashDays(),
+	})
 }
 
-func (c MainController) UserSaveHandler(w http.ResponseWriter, r *http.Request) {
-	user, err := model.CleanUserData(r.Body)
-
+func (c MainController) EventTrashRestoreHandler(w http.ResponseWriter, r *http.Request) {
+	var requestData struct {
+		ID int `json:"id"`
+	}
+	err := json.NewDecoder(r.Body).Decode(&requestData)
 	if err != nil {
 		sendErrorMessage(w, err)
 		return
 	}
 
-	// Check if we're updating an existing User or creating one
-
-	var userID int
-	if user.ID == 0 {
-		// new user
-		userID, err = model.CreateUser(c.db, user)
-	} else {
-		userID, err = model.UpdateUser(c.db, user)
-	}
-
-	if err != nil {
+	if err := model.RestoreEvent(c.db, requestData.ID); err != nil {
 		sendErrorMessage(w, err)
 		return
 	}
 
-	// Retrieve updated User Data and send back in response
+	writeJSON(w, map[string]interface{}{
+		"status": "success",
+	})
+}
 
-	userJSON, err := model.GetUserJSON(c.db, model.GetUserOptions{ID: userID})
+func (c MainController) EventTrashPurgeHandler(w http.ResponseWriter, r *http.Request) {
+	var requestData struct {
+		ID int `json:"id"`
+	}
+	err := json.NewDecoder(r.Body).Decode(&requestData)
 	if err != nil {
 		sendErrorMessage(w, err)
 		return
 	}
 
-	out := map[string]interface{}{
-		"status": "success",
-		"user":   userJSON,
+	if err := model.PurgeEvent(c.db, requestData.ID); err != nil {
+		sendErrorMessage(w, err)
+		return
 	}
 
-	writeJSON(w, out)
+	writeJSON(w, map[string]interface{}{
+		"status": "success",
+	})
 }
 
-func (c MainController) UserDeleteHandler(w http.ResponseWriter, r *http.Request) {
-	user, err := model.CleanUserData(r.Body)
-
+func (c MainController) EventSeriesListHandler(w http.ResponseWriter, r *http.Request) {
+	series, err := model.GetEventSeriesJSON(c.db)
 	if err != nil {
 		sendErrorMessage(w, err)
 		return
 	}
 
-	userID, err := model.RemoveUser(c.db, user.ID)
+	writeJSON(w, map[string]interface{}{
+		"status":       "success",
+		"event_series": series,
+	})
+}
 
+func (c MainController) EventSeriesSaveHandler(w http.ResponseWriter, r *http.Request) {
+	series, err := model.CleanEventSeriesData(c.db, r.Body)
 	if err != nil {
 		sendErrorMessage(w, err)
 		return
 	}
 
-	out := map[string]interface{}{
-		"status": "success",
-		"userID": userID,
+	seriesJSON, err := model.SaveEventSeries(c.db, series, time.Now())
+	if err != nil {
+		sendErrorMessage(w, err)
+		return
 	}
 
-	writeJSON(w, out)
+	writeJSON(w, map[string]interface{}{
+		"status":       "success",
+		"event_series": seriesJSON,
+	})
 }
 
-func (c MainController) newPowerWallboard(w http.ResponseWriter, r *http.Request) {
-	power, err := model.GetPower(c.db)
+func (c MainController) EventSeriesDeleteHandler(w http.ResponseWriter, r *http.Request) {
+	var requestData struct {
+		ID int `json:"id"`
+	}
+	err := json.NewDecoder(r.Body).Decode(&requestData)
 	if err != nil {
-		panic(err)
+		sendErrorMessage(w, err)
+		return
+	}
+
+	if err := model.DeleteEventSeries(c.db, requestData.ID, time.Now()); err != nil {
+		sendErrorMessage(w, err)
+		return
 	}
 
 	writeJSON(w, map[string]interface{}{
 		"status": "success",
-		"Power":  power,
 	})
 }
 
-func (c MainController) newChapterMemberWallboard(w http.ResponseWriter, r *http.Request) {
-	members, err := model.GetActiveChapterMembers(c.db)
+func (c MainController) EventSeriesAttendanceHandler(w http.ResponseWriter, r *http.Request) {
+	attendance, err := model.GetEventSeriesAttendance(c.db, r.URL.Query().Get("from"), r.URL.Query().Get("to"), time.Now())
 	if err != nil {
-		panic(err)
+		sendErrorMessage(w, err)
+		return
 	}
 
 	writeJSON(w, map[string]interface{}{
-		"status":  "success",
-		"Members": members,
+		"status":     "success",
+		"attendance": attendance,
 	})
 }
 
@@ -1287,6 +2369,47 @@ func (c MainController) UsersRolesRemoveHandler(w http.ResponseWriter, r *http.R
 	})
 }
 
+// startHourlyJobs starts the background jobs that keep derived data
+// up to date.
+func startHourlyJobs(db *sqlx.DB) {
+	// Recomputes out of date activist metrics, so that points and MPP
+	// requirements roll over each day. Attendance changes update
+	// metrics as they happen.
+	go scheduler.Every("refresh activist metrics", time.Hour, func() error {
+		count, err := model.RefreshStaleActivistMetrics(db)
+		if err != nil {
+			return err
+		}
+		if count > 0 {
+			log.Printf("Refreshed metrics for %d activists", count)
+		}
+		return nil
+	})
+	// Retakes this month's power snapshot, so that each month's
+	// snapshot ends up reflecting the end of the month.
+	go scheduler.Every("record power snapshot", time.Hour, func() error {
+		return model.RecordPowerSnapshot(db, time.Now())
+	})
+	// Keeps each event series' events listed EventSeriesHorizonDays
+	// ahead.
+	go scheduler.Every("create upcoming series events", time.Hour, func() error {
+		_, err := model.CreateUpcomingSeriesEvents(db, time.Now())
+		return err
+	})
+	// Permanently deletes events that have been in the trash for
+	// longer than EventTrashDays.
+	go scheduler.Every("purge trashed events", time.Hour, func() error {
+		count, err := model.PurgeTrashedEvents(db, time.Now().AddDate(0, 0, -model.EventTrashDays()))
+		if err != nil {
+			return err
+		}
+		if count > 0 {
+			log.Printf("Purged %d events from the trash", count)
+		}
+		return nil
+	})
+}
+
 func main() {
 	sentry.Init(sentry.ClientOptions{
 		Dsn: "https://dc89e0cef6204791a1f199564aec911c@sentry.io/1820804",
@@ -1299,6 +2422,19 @@ func main() {
 
 	r, db := router()
 
+	// Warn loudly if someone forgot to run scripts/migrate.
+	if version, err := migrations.Version(db); err != nil {
+		log.Println("ERROR: could not get database schema version:", err)
+	} else if version != migrations.Latest() {
+		log.Printf("WARNING: database schema is at version %d but the latest migration is %d; run scripts/migrate", version, migrations.Latest())
+	}
+
+	if config.MPIRulesFile != "" {
+		if err := model.LoadMPIRules(config.MPIRulesFile); err != nil {
+			log.Fatalf("%+v", err)
+		}
+	}
+
 	// Start syncing mailing lists in the background if we have
 	// the environment set up.
 	if config.SyncMailingListsConfigFile != "" {
@@ -1311,6 +2447,9 @@ func main() {
 		go survey_mailer.StartSurveyMailer(db)
 	}
 
+	go duplicate_finder.StartDuplicateFinder(db)
+	startHourlyJobs(db)
+
 	// Set up server
 	n.UseHandler(r)
 
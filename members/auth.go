@@ -1,16 +1,10 @@
 package members
 
 import (
-	"context"
-	"crypto/rand"
-	"encoding/hex"
 	"errors"
-	"io"
-	"log"
 	"net/http"
 
-	"github.com/coreos/go-oidc"
-	"github.com/dxe/adb/config"
+	"github.com/dxe/adb/auth"
 	"golang.org/x/oauth2"
 )
 
@@ -20,64 +14,30 @@ const (
 	membersState   = "members_state"
 )
 
-var conf, verifier = func() (*oauth2.Config, *oidc.IDTokenVerifier) {
-	provider, err := oidc.NewProvider(context.Background(), "https://accounts.google.com")
-	if err != nil {
-		log.Fatal(err)
-	}
-	conf := &oauth2.Config{
-		ClientID:     config.MembersClientID,
-		ClientSecret: config.MembersClientSecret,
-		Endpoint:     provider.Endpoint(),
-		RedirectURL:  absURL("/auth"),
-		Scopes:       []string{"email"},
-	}
-	verifier := provider.Verifier(&oidc.Config{
-		ClientID: config.MembersClientID,
-	})
-	return conf, verifier
-}()
-
-func (s *server) googleEmail() (string, error) {
+func (s *server) signedInEmail() (string, error) {
 	c, err := s.r.Cookie(membersIDToken)
 	if err != nil {
 		return "", err
 	}
 
-	token, err := verifier.Verify(s.r.Context(), c.Value)
+	identity, err := s.provider.Verify(s.r.Context(), c.Value)
 	if err != nil {
 		return "", err
 	}
 
-	var claims struct {
-		Email         string `json:"email"`
-		EmailVerified bool   `json:"email_verified"`
-	}
-	err = token.Claims(&claims)
-	if err != nil {
-		return "", err
-	}
-
-	if !claims.EmailVerified {
+	if !identity.EmailVerified {
 		return "", errors.New("email not verified")
 	}
 
-	return claims.Email, nil
+	return identity.Email, nil
 }
 
 func (s *server) login() {
-	state, err := nonce()
+	state, err := auth.NewState()
 	if err != nil {
 		s.error(err)
 		return
 	}
-	http.SetCookie(s.w, &http.Cookie{
-		Name:     membersState,
-		Value:    state,
-		MaxAge:   3600,
-		SameSite: http.SameSiteLaxMode,
-		HttpOnly: true,
-	})
 
 	var opts []oauth2.AuthCodeOption
 	if s.r.URL.Query()["force"] != nil {
@@ -89,7 +49,20 @@ func (s *server) login() {
 		opts = append(opts, oauth2.SetAuthURLParam("prompt", "select_account"))
 	}
 
-	s.redirect(conf.AuthCodeURL(state, opts...))
+	authURL, err := s.provider.AuthCodeURL(s.r.Context(), state, opts...)
+	if err != nil {
+		s.error(err)
+		return
+	}
+
+	http.SetCookie(s.w, &http.Cookie{
+		Name:     membersState,
+		Value:    state,
+		MaxAge:   3600,
+		SameSite: http.SameSiteLaxMode,
+		HttpOnly: true,
+	})
+	s.redirect(authURL)
 }
 
 func (s *server) auth() {
@@ -103,13 +76,12 @@ func (s *server) auth() {
 		return
 	}
 
-	token, err := conf.Exchange(s.r.Context(), s.r.FormValue("code"))
+	idToken, err := s.provider.Exchange(s.r.Context(), s.r.FormValue("code"))
 	if err != nil {
 		s.error(err)
 		return
 	}
 
-	idToken := token.Extra("id_token").(string)
 	http.SetCookie(s.w, &http.Cookie{
 		Name:     membersIDToken,
 		Value:    idToken,
@@ -119,12 +91,3 @@ func (s *server) auth() {
 	})
 	s.redirect(absURL("/"))
 }
-
-// nonce returns a 256-bit random hex string.
-func nonce() (string, error) {
-	var buf [32]byte
-	if _, err := io.ReadFull(rand.Reader, buf[:]); err != nil {
-		return "", err
-	}
-	return hex.EncodeToString(buf[:]), nil
-}
@@ -5,6 +5,9 @@ import (
 	"fmt"
 	"html/template"
 	"sort"
+	"time"
+
+	"github.com/dxe/adb/model"
 )
 
 // TODO(mdempsky): Use adb_users instead?
@@ -13,7 +16,7 @@ var adminEmails = map[string]bool{
 }
 
 func (s *server) index() {
-	email, err := s.googleEmail()
+	email, err := s.signedInEmail()
 	if err != nil {
 		s.redirect(absURL("/login"))
 		return
@@ -47,16 +50,22 @@ func (s *server) index() {
 
 		Total      int
 		Attendance []struct {
-			Month        int // YYYYMM
-			MPI          int // boolean
-			Community    int // boolean
-			DirectAction int // boolean
-			Events       []struct {
-				Date         string // "YYYY-MM-DD"
-				Name         string
-				Community    int // boolean
-				DirectAction int //  boolean
+			Month  int // YYYYMM
+			Events []struct {
+				Date     string // "YYYY-MM-DD"
+				Name     string
+				Category string
+
+				// Computed from Category below.
+				Community    bool
+				DirectAction bool
 			}
+
+			// Computed from Events below.
+			MPI             bool
+			Community       bool
+			DirectAction    bool
+			CommunityWaived bool
 		}
 	}
 
@@ -78,9 +87,6 @@ select json_object(
   'Organizer', x.activist_level in ('Organizer', 'Senior Organizer'),
   'ChapterMember', x.activist_level in ('Chapter Member', 'Organizer', 'Senior Organizer'),
 
-  'FebVoter', sum(x.mpi and x.month >= 201911 and x.month < 202002) >= 2,
-  'MarVoter', sum(x.mpi and x.month >= 201912 and x.month < 202003) >= 2,
-
   'WorkingGroups', (
     select json_arrayagg(w.name)
     from working_groups w
@@ -92,32 +98,26 @@ select json_object(
   'Attendance', if(sum(x.subtotal) = 0, null,
     json_arrayagg(json_object(
       'Month', x.month,
-      'MPI', x.mpi,
-      'Community', x.community,
-      'DirectAction', x.direct_action,
       'Events', x.events
     )))
 )
 from (
   select a.id, a.name, a.email, a.phone, a.location, a.facebook, a.activist_level, a.dob, a.date_organizer,
     e.month, count(e.id) as subtotal,
-    max(e.community) as community, max(e.direct_action) as direct_action,
-    (max(e.direct_action) and (max(e.community) or (e.month >= 202001 and e.month < 202003))) as mpi,
     json_arrayagg(json_object(
       'Date', e.date,
       'Name', e.name,
-      'Community', e.community,
-      'DirectAction', e.direct_action
+      'Category', e.category
     )) as events
   from activists a
-  left join event_attendance ea on (a.id = ea.activist_id)
+  left join event_attendance ea on (a.id = ea.activist_id
+    and ea.event_id not in (select id from events where deleted_at is not null))
   left join (
-          select id, date,
-                 concat(name, if(event_type = 'Connection', ' (Connection)', '')) as name,
-                 extract(year_month from date) as month,
-                 event_type in ('Circle', 'Community', 'Training') as community,
-                 event_type in ('Action', 'Campaign Action', 'Frontline Surveillance', 'Outreach', 'Sanctuary') as direct_action
+          select events.id, date, category,
+                 events.name,
+                 extract(year_month from date) as month
           from events
+          join event_types on (event_types.name = events.event_type)
         ) e on (e.id = ea.event_id)
   where a.email = ?
     and not a.hidden
@@ -143,6 +143,36 @@ group by x.id
 		sort.Slice(events, func(i, j int) bool { return events[i].Date > events[j].Date })
 	}
 
+	// Use the same rules as the MPI itself to decide which months
+	// met its requirements.
+	rules := model.GetMPIRules()
+	var febMonths, marMonths int
+	for k := range data.Attendance {
+		month := &data.Attendance[k]
+		for j := range month.Events {
+			event := &month.Events[j]
+			event.Community = event.Category == model.EventCategoryCommunity
+			event.DirectAction = event.Category == model.EventCategoryDirectAction
+			month.Community = month.Community || event.Community
+			month.DirectAction = month.DirectAction || event.DirectAction
+		}
+		month.CommunityWaived = rules.CommunityWaived(time.Date(month.Month/100, time.Month(month.Month%100), 1, 0, 0, 0, 0, time.UTC))
+		month.MPI = model.MPIMonth{
+			Community:       month.Community,
+			DirectAction:    month.DirectAction,
+			CommunityWaived: month.CommunityWaived,
+		}.Met()
+
+		if month.MPI && month.Month >= 201911 && month.Month < 202002 {
+			febMonths++
+		}
+		if month.MPI && month.Month >= 201912 && month.Month < 202003 {
+			marMonths++
+		}
+	}
+	data.FebVoter = febMonths >= 2
+	data.MarVoter = marMonths >= 2
+
 	s.render(indexTmpl, &data)
 }
 
@@ -304,7 +334,7 @@ a <b class="gray">gray</b> bar indicates you did not.</p>
 <table class="attendance">
 {{range .Attendance}}
 <tr class="month {{if .MPI}}mpi{{end}}">
-  <td>{{if .Community}}🏙️{{else if and (ge .Month 202001) (le .Month 202002)}}🆓{{end}}</td>
+  <td>{{if .Community}}🏙️{{else if .CommunityWaived}}🆓{{end}}</td>
   <td>{{if .DirectAction}}📣{{end}}</td>
   <td colspan=2>{{monthfmt .Month}}</td>
 </tr>
@@ -5,17 +5,33 @@ import (
 	"encoding/json"
 	"fmt"
 	"html/template"
+	"log"
 	"net/http"
 
+	"github.com/dxe/adb/auth"
 	"github.com/dxe/adb/config"
 	"github.com/gorilla/mux"
 	"github.com/jmoiron/sqlx"
 )
 
 func Route(r *mux.Router, db *sqlx.DB) {
+	provider, err := auth.NewProvider(auth.Config{
+		Issuer:        config.AuthIssuer,
+		ClientID:      config.MembersClientID,
+		ClientSecret:  config.MembersClientSecret,
+		RedirectURL:   absURL("/auth"),
+		LocalLoginURL: absURL("/auth/local"),
+	})
+	if err != nil {
+		log.Fatalf("%+v", err)
+	}
+	if local, ok := provider.(*auth.LocalProvider); ok {
+		r.Handle("/auth/local", local)
+	}
+
 	handle := func(path string, method func(*server)) {
 		r.HandleFunc(path, func(w http.ResponseWriter, r *http.Request) {
-			method(&server{db, w, r})
+			method(&server{db, provider, w, r})
 		})
 	}
 
@@ -25,9 +41,10 @@ func Route(r *mux.Router, db *sqlx.DB) {
 }
 
 type server struct {
-	db *sqlx.DB
-	w  http.ResponseWriter
-	r  *http.Request
+	db       *sqlx.DB
+	provider auth.Provider
+	w        http.ResponseWriter
+	r        *http.Request
 }
 
 func (s *server) queryJSON(data interface{}, query string, args ...interface{}) error {
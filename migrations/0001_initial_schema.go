@@ -0,0 +1,178 @@
+package migrations
+
+// The schema as it was defined by model.WipeDatabase before we had
+// migrations.
+func init() {
+	register(Migration{
+		Version: 1,
+		Name:    "initial_schema",
+		Up: []string{`
+CREATE TABLE activists (
+  id INTEGER PRIMARY KEY AUTO_INCREMENT,
+  name VARCHAR(80) NOT NULL,
+  email VARCHAR(80) NOT NULL DEFAULT '',
+  phone VARCHAR(20) NOT NULL DEFAULT '',
+  location VARCHAR(200) DEFAULT '',
+  facebook VARCHAR(200) NOT NULL DEFAULT '',
+  activist_level VARCHAR(40) NOT NULL DEFAULT 'Supporter',
+  hidden TINYINT(1) NOT NULL DEFAULT '0',
+  connector VARCHAR(100) NOT NULL DEFAULT '',
+  source VARCHAR(255) NOT NULL DEFAULT '',
+  hiatus TINYINT(1) NOT NULL DEFAULT '0',
+  date_organizer DATE,
+  date_senior_organizer DATE,
+  dob TEXT,
+  training0 VARCHAR(20),
+  training1 VARCHAR(20),
+  training2 VARCHAR(20),
+  training3 VARCHAR(20),
+  training4 VARCHAR(20),
+  training5 VARCHAR(20),
+  training6 VARCHAR(20),
+  training_protest VARCHAR(20),
+  prospect_organizer TINYINT(1) NOT NULL DEFAULT '0',
+  prospect_chapter_member TINYINT NOT NULL DEFAULT '0',
+  circle_agreement TINYINT NOT NULL DEFAULT '0',
+  dev_manager VARCHAR(100) NOT NULL DEFAULT '',
+  dev_interest VARCHAR(200) NOT NULL DEFAULT '',
+  dev_auth VARCHAR(20),
+  dev_email_sent VARCHAR(20),
+  dev_vetted TINYINT(1) NOT NULL DEFAULT '0',
+  dev_interview VARCHAR(20),
+  dev_onboarding TINYINT(1) NOT NULL DEFAULT '0',
+  dev_application_date DATE,
+  dev_application_type VARCHAR(40) NOT NULL DEFAULT '',
+  dev_quiz VARCHAR(20),
+  cm_first_email VARCHAR(20),
+  cm_approval_email VARCHAR(20),
+  cm_warning_email VARCHAR(20),
+  cir_first_email VARCHAR(20),
+  prospect_senior_organizer tinyint(1) NOT NULL DEFAULT '0',
+  so_auth varchar(20),
+  so_core varchar(20),
+  so_agreement tinyint(1) NOT NULL DEFAULT '0',
+  so_training varchar(20),
+  so_quiz varchar(20),
+  so_connector varchar(100) NOT NULL DEFAULT '',
+  so_onboarding tinyint(1) NOT NULL DEFAULT '0',
+  referral_friends varchar(100) NOT NULL DEFAULT '',
+  referral_apply varchar(100) NOT NULL DEFAULT '',
+  referral_outlet varchar(100) NOT NULL DEFAULT '',
+  circle_interest tinyint(1) NOT NULL DEFAULT '0',
+  interest_date VARCHAR(20),
+  mpi tinyint(1) NOT NULL DEFAULT '0',
+  notes TEXT,
+  vision_wall varchar(10) NOT NULL DEFAULT '',
+  study_group varchar(40) NOT NULL DEFAULT '',
+  study_activator varchar(40) NOT NULL DEFAULT '',
+  study_conversation varchar(20),
+  survey_completion VARCHAR(20),
+  UNIQUE (name)
+)
+`, `
+CREATE TABLE event_attendance (
+  activist_id INTEGER NOT NULL,
+  event_id INTEGER NOT NULL,
+  UNIQUE (activist_id, event_id),
+  UNIQUE (event_id, activist_id)
+)
+`, `
+CREATE TABLE events (
+  id INTEGER PRIMARY KEY AUTO_INCREMENT,
+  name VARCHAR(60) NOT NULL,
+  date DATE NOT NULL,
+  event_type VARCHAR(60) NOT NULL,
+  survey_sent TINYINT(1) NOT NULL DEFAULT '0',
+  INDEX (date, name),
+  FULLTEXT (name)
+)
+`, `
+CREATE TABLE adb_users (
+  id INTEGER PRIMARY KEY AUTO_INCREMENT,
+  email VARCHAR(60) NOT NULL,
+  name VARCHAR(150) NOT NULL DEFAULT '',
+  admin TINYINT(1) NOT NULL DEFAULT '0',
+  disabled TINYINT(1) NOT NULL DEFAULT '0'
+)
+`, `
+CREATE TABLE merged_activist_attendance (
+  original_activist_id INTEGER NOT NULL,
+  target_activist_id INTEGER NOT NULL,
+  event_id INTEGER NOT NULL,
+  replaced_with_target_activist TINYINT(1) NOT NULL,
+  UNIQUE (original_activist_id, target_activist_id, event_id)
+)
+`, `
+CREATE TABLE working_groups (
+  id INTEGER PRIMARY KEY AUTO_INCREMENT,
+  name VARCHAR(60) NOT NULL,
+  type TINYINT(1) NOT NULL,
+  group_email VARCHAR(100) NOT NULL,
+  visible TINYINT(1) NOT NULL DEFAULT '0',
+  description TEXT NOT NULL,
+  meeting_time TEXT NOT NULL,
+  meeting_location TEXT NOT NULL,
+  coords TEXT NOT NULL,
+  UNIQUE (name)
+)
+`, `
+CREATE TABLE working_group_members (
+  working_group_id INTEGER NOT NULL,
+  activist_id INTEGER NOT NULL,
+  -- True if the activist is the point person of the working group.
+  -- There should be only one point person per working group, but we
+  -- don't restrict that on the backend.
+  point_person TINYINT NOT NULL DEFAULT '0',
+  -- Some activists need to be on the mailing list even though they
+  -- aren't in the workin group.
+  non_member_on_mailing_list TINYINT NOT NULL DEFAULT '0',
+  UNIQUE (working_group_id, activist_id),
+  INDEX (activist_id)
+)
+`, `
+CREATE TABLE circles (
+  id INTEGER PRIMARY KEY AUTO_INCREMENT,
+  name VARCHAR(60) NOT NULL,
+  type TINYINT(1) NOT NULL,
+  group_email VARCHAR(100) NOT NULL DEFAULT '1',
+  visible TINYINT(1) NOT NULL DEFAULT '1',
+  description TEXT NOT NULL,
+  meeting_time TEXT NOT NULL,
+  meeting_location TEXT NOT NULL,
+  coords TEXT NOT NULL,
+  UNIQUE (name)
+)
+`, `
+CREATE TABLE circle_members (
+  circle_id INTEGER NOT NULL,
+  activist_id INTEGER NOT NULL,
+  point_person TINYINT NOT NULL DEFAULT '0',
+  non_member_on_mailing_list TINYINT NOT NULL DEFAULT '0',
+  UNIQUE (circle_id, activist_id),
+  INDEX (activist_id)
+)
+`, `
+CREATE TABLE users_roles (
+  user_id INT NOT NULL,
+  role VARCHAR(45) NOT NULL,
+  PRIMARY KEY (user_id, role),
+  CONSTRAINT user_id
+    FOREIGN KEY (user_id)
+    REFERENCES adb_users (id)
+    ON DELETE NO ACTION
+    ON UPDATE NO ACTION)
+`},
+		Down: []string{
+			`DROP TABLE users_roles`,
+			`DROP TABLE circle_members`,
+			`DROP TABLE circles`,
+			`DROP TABLE working_group_members`,
+			`DROP TABLE working_groups`,
+			`DROP TABLE merged_activist_attendance`,
+			`DROP TABLE adb_users`,
+			`DROP TABLE events`,
+			`DROP TABLE event_attendance`,
+			`DROP TABLE activists`,
+		},
+	})
+}
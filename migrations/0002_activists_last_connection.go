@@ -0,0 +1,16 @@
+package migrations
+
+// CreateActivist writes last_connection, which was added to the
+// production activists table by hand.
+func init() {
+	register(Migration{
+		Version: 2,
+		Name:    "activists_last_connection",
+		Up: []string{
+			`ALTER TABLE activists ADD COLUMN last_connection DATE`,
+		},
+		Down: []string{
+			`ALTER TABLE activists DROP COLUMN last_connection`,
+		},
+	})
+}
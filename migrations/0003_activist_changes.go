@@ -0,0 +1,28 @@
+package migrations
+
+// Field-level history of every write to the activists table.
+func init() {
+	register(Migration{
+		Version: 3,
+		Name:    "activist_changes",
+		Up: []string{`
+CREATE TABLE activist_changes (
+  id INTEGER PRIMARY KEY AUTO_INCREMENT,
+  activist_id INTEGER NOT NULL,
+  -- The ADB user who made the change. The email is copied so the
+  -- history survives the user being deleted.
+  user_id INTEGER NOT NULL DEFAULT '0',
+  user_email VARCHAR(60) NOT NULL DEFAULT '',
+  changed_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+  action VARCHAR(20) NOT NULL,
+  field VARCHAR(60) NOT NULL,
+  old_value TEXT,
+  new_value TEXT,
+  INDEX (activist_id, changed_at)
+)
+`},
+		Down: []string{
+			`DROP TABLE activist_changes`,
+		},
+	})
+}
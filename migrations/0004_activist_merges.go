@@ -0,0 +1,29 @@
+package migrations
+
+// Snapshots of both activists taken before a merge, so that the merge
+// can be undone.
+func init() {
+	register(Migration{
+		Version: 4,
+		Name:    "activist_merges",
+		Up: []string{`
+CREATE TABLE activist_merges (
+  id INTEGER PRIMARY KEY AUTO_INCREMENT,
+  original_activist_id INTEGER NOT NULL,
+  target_activist_id INTEGER NOT NULL,
+  -- JSON encoded ActivistExtra of each activist before the merge.
+  original_snapshot MEDIUMTEXT NOT NULL,
+  target_snapshot MEDIUMTEXT NOT NULL,
+  merged_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+  merged_by INTEGER NOT NULL DEFAULT '0',
+  unmerged_at DATETIME,
+  unmerged_by INTEGER,
+  INDEX (original_activist_id),
+  INDEX (target_activist_id)
+)
+`},
+		Down: []string{
+			`DROP TABLE activist_merges`,
+		},
+	})
+}
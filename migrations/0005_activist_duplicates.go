@@ -0,0 +1,29 @@
+package migrations
+
+// Suggested duplicate activists, scored by the duplicate finder.
+func init() {
+	register(Migration{
+		Version: 5,
+		Name:    "activist_duplicates",
+		Up: []string{`
+CREATE TABLE activist_duplicates (
+  -- activist_id_a is always the smaller id.
+  activist_id_a INTEGER NOT NULL,
+  activist_id_b INTEGER NOT NULL,
+  score DOUBLE NOT NULL DEFAULT '0',
+  reasons VARCHAR(100) NOT NULL DEFAULT '',
+  updated_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+  -- Dismissed pairs are never suggested again.
+  dismissed TINYINT(1) NOT NULL DEFAULT '0',
+  dismissed_by INTEGER,
+  dismissed_at DATETIME,
+  PRIMARY KEY (activist_id_a, activist_id_b),
+  INDEX (activist_id_b),
+  INDEX (dismissed, score)
+)
+`},
+		Down: []string{
+			`DROP TABLE activist_duplicates`,
+		},
+	})
+}
@@ -0,0 +1,41 @@
+package migrations
+
+// API keys for integrations, and a log of the requests made with them.
+func init() {
+	register(Migration{
+		Version: 6,
+		Name:    "api_keys",
+		Up: []string{`
+CREATE TABLE api_keys (
+  id INTEGER PRIMARY KEY AUTO_INCREMENT,
+  name VARCHAR(80) NOT NULL,
+  -- The start of the key, so admins can tell keys apart.
+  key_prefix VARCHAR(12) NOT NULL,
+  -- Hex SHA-256 of the key. The key itself is never stored.
+  key_hash CHAR(64) NOT NULL,
+  role VARCHAR(45) NOT NULL,
+  created_by INTEGER NOT NULL DEFAULT '0',
+  created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+  last_used_at DATETIME,
+  revoked_by INTEGER,
+  revoked_at DATETIME,
+  UNIQUE (key_hash)
+)
+`, `
+CREATE TABLE api_key_requests (
+  id INTEGER PRIMARY KEY AUTO_INCREMENT,
+  api_key_id INTEGER NOT NULL,
+  method VARCHAR(10) NOT NULL,
+  path VARCHAR(255) NOT NULL,
+  status INTEGER NOT NULL,
+  remote_addr VARCHAR(64) NOT NULL DEFAULT '',
+  requested_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+  INDEX (api_key_id, requested_at)
+)
+`},
+		Down: []string{
+			`DROP TABLE api_key_requests`,
+			`DROP TABLE api_keys`,
+		},
+	})
+}
@@ -0,0 +1,33 @@
+package migrations
+
+// Attendance metrics for each activist, kept up to date as attendance
+// changes so that activist lists don't have to compute them per row.
+func init() {
+	register(Migration{
+		Version: 7,
+		Name:    "activist_metrics",
+		Up: []string{`
+CREATE TABLE activist_metrics (
+  activist_id INTEGER PRIMARY KEY,
+  first_event DATE,
+  last_event DATE,
+  last_circle DATE,
+  last_connection DATE,
+  first_event_name VARCHAR(200) NOT NULL DEFAULT '',
+  last_event_name VARCHAR(200) NOT NULL DEFAULT '',
+  total_events INTEGER NOT NULL DEFAULT '0',
+  -- Events attended in the 30 days before computed_on.
+  total_points INTEGER NOT NULL DEFAULT '0',
+  -- Whether the activist attended a protest or community event in the
+  -- month of computed_on.
+  mpp_protest TINYINT(1) NOT NULL DEFAULT '0',
+  mpp_community TINYINT(1) NOT NULL DEFAULT '0',
+  computed_on DATE NOT NULL,
+  INDEX (computed_on)
+)
+`},
+		Down: []string{
+			`DROP TABLE activist_metrics`,
+		},
+	})
+}
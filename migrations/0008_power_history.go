@@ -0,0 +1,34 @@
+package migrations
+
+// Monthly snapshots of the Movement Power Index and membership.
+func init() {
+	register(Migration{
+		Version: 8,
+		Name:    "power_history",
+		Up: []string{`
+CREATE TABLE power_history (
+  year INTEGER NOT NULL,
+  month INTEGER NOT NULL,
+  power INTEGER NOT NULL DEFAULT '0',
+  active_chapter_members INTEGER NOT NULL DEFAULT '0',
+  -- The snapshot is retaken until the month is over, so this is the
+  -- last time it was taken.
+  recorded_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+  PRIMARY KEY (year, month)
+)
+`, `
+CREATE TABLE power_history_levels (
+  year INTEGER NOT NULL,
+  month INTEGER NOT NULL,
+  activist_level VARCHAR(45) NOT NULL,
+  activists INTEGER NOT NULL DEFAULT '0',
+  mpi INTEGER NOT NULL DEFAULT '0',
+  PRIMARY KEY (year, month, activist_level)
+)
+`},
+		Down: []string{
+			`DROP TABLE power_history_levels`,
+			`DROP TABLE power_history`,
+		},
+	})
+}
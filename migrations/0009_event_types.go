@@ -0,0 +1,50 @@
+package migrations
+
+// Event types, and the category each one counts as, used to be
+// hard-coded. Events now reference them by name, so renaming a type
+// renames it on every event.
+func init() {
+	register(Migration{
+		Version: 9,
+		Name:    "event_types",
+		Up: []string{`
+CREATE TABLE event_types (
+  id INTEGER PRIMARY KEY AUTO_INCREMENT,
+  name VARCHAR(60) NOT NULL,
+  -- One of direct_action, community, connection, or other.
+  category VARCHAR(20) NOT NULL DEFAULT 'other',
+  -- Inactive types can't be given to new events.
+  active TINYINT(1) NOT NULL DEFAULT '1',
+  display_order INTEGER NOT NULL DEFAULT '0',
+  UNIQUE (name)
+)
+`, `
+INSERT INTO event_types (name, category, display_order) VALUES
+  ('Action', 'direct_action', 1),
+  ('Campaign Action', 'direct_action', 2),
+  ('Circle', 'community', 3),
+  ('Community', 'community', 4),
+  ('Frontline Surveillance', 'direct_action', 5),
+  ('Meeting', 'other', 6),
+  ('Outreach', 'direct_action', 7),
+  ('Sanctuary', 'direct_action', 8),
+  ('Training', 'community', 9),
+  ('Connection', 'connection', 10)
+`, `
+-- Keep any types that old events have but that weren't in the list.
+INSERT INTO event_types (name, category, active, display_order)
+SELECT DISTINCT event_type, 'other', 0, 100
+FROM events
+WHERE event_type NOT IN (SELECT name FROM event_types)
+`, `
+ALTER TABLE events
+  ADD CONSTRAINT events_event_type_fk
+  FOREIGN KEY (event_type) REFERENCES event_types (name)
+  ON UPDATE CASCADE
+`},
+		Down: []string{
+			`ALTER TABLE events DROP FOREIGN KEY events_event_type_fk, DROP INDEX events_event_type_fk`,
+			`DROP TABLE event_types`,
+		},
+	})
+}
@@ -0,0 +1,41 @@
+package migrations
+
+// Events that happen on a fixed cadence, like chapter meetings and
+// weekly outreach. Upcoming occurrences are created ahead of time and
+// link back to their series.
+func init() {
+	register(Migration{
+		Version: 10,
+		Name:    "event_series",
+		Up: []string{`
+CREATE TABLE event_series (
+  id INTEGER PRIMARY KEY AUTO_INCREMENT,
+  name VARCHAR(60) NOT NULL,
+  event_type VARCHAR(60) NOT NULL,
+  -- An iCalendar RRULE, like FREQ=WEEKLY;BYDAY=TU.
+  recurrence VARCHAR(200) NOT NULL,
+  location VARCHAR(200) NOT NULL DEFAULT '',
+  start_date DATE NOT NULL,
+  end_date DATE,
+  -- Occurrences up to this date have been created, so occurrences
+  -- that were deleted aren't created again.
+  generated_through DATE,
+  created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+  UNIQUE (name),
+  CONSTRAINT event_series_event_type_fk
+    FOREIGN KEY (event_type) REFERENCES event_types (name)
+    ON UPDATE CASCADE
+)
+`, `
+ALTER TABLE events
+  ADD COLUMN series_id INTEGER,
+  ADD CONSTRAINT events_series_id_fk
+    FOREIGN KEY (series_id) REFERENCES event_series (id)
+    ON DELETE SET NULL
+`},
+		Down: []string{
+			`ALTER TABLE events DROP FOREIGN KEY events_series_id_fk, DROP INDEX events_series_id_fk, DROP COLUMN series_id`,
+			`DROP TABLE event_series`,
+		},
+	})
+}
@@ -0,0 +1,45 @@
+package migrations
+
+// Who led an event, where and when it ran, and which working group or
+// circle it belongs to.
+func init() {
+	register(Migration{
+		Version: 11,
+		Name:    "event_details",
+		Up: []string{`
+ALTER TABLE events
+  ADD COLUMN start_time TIME,
+  ADD COLUMN end_time TIME,
+  ADD COLUMN location VARCHAR(200) NOT NULL DEFAULT '',
+  ADD COLUMN lead_organizer_id INTEGER,
+  ADD COLUMN working_group_id INTEGER,
+  ADD COLUMN circle_id INTEGER,
+  ADD COLUMN notes TEXT,
+  ADD CONSTRAINT events_lead_organizer_id_fk
+    FOREIGN KEY (lead_organizer_id) REFERENCES activists (id)
+    ON DELETE SET NULL,
+  ADD CONSTRAINT events_working_group_id_fk
+    FOREIGN KEY (working_group_id) REFERENCES working_groups (id)
+    ON DELETE SET NULL,
+  ADD CONSTRAINT events_circle_id_fk
+    FOREIGN KEY (circle_id) REFERENCES circles (id)
+    ON DELETE SET NULL
+`},
+		Down: []string{`
+ALTER TABLE events
+  DROP FOREIGN KEY events_lead_organizer_id_fk,
+  DROP FOREIGN KEY events_working_group_id_fk,
+  DROP FOREIGN KEY events_circle_id_fk,
+  DROP INDEX events_lead_organizer_id_fk,
+  DROP INDEX events_working_group_id_fk,
+  DROP INDEX events_circle_id_fk,
+  DROP COLUMN start_time,
+  DROP COLUMN end_time,
+  DROP COLUMN location,
+  DROP COLUMN lead_organizer_id,
+  DROP COLUMN working_group_id,
+  DROP COLUMN circle_id,
+  DROP COLUMN notes
+`},
+	})
+}
@@ -0,0 +1,38 @@
+package migrations
+
+// Attendees checking themselves in to an event from a public link.
+// Check-ins wait here until an attendance user approves them into
+// event_attendance.
+func init() {
+	register(Migration{
+		Version: 12,
+		Name:    "event_check_ins",
+		Up: []string{`
+CREATE TABLE event_check_ins (
+  id INTEGER PRIMARY KEY AUTO_INCREMENT,
+  event_id INTEGER NOT NULL,
+  name VARCHAR(80) NOT NULL,
+  email VARCHAR(100) NOT NULL DEFAULT '',
+  phone VARCHAR(20) NOT NULL DEFAULT '',
+  -- While pending, the existing activist the check-in matched, if
+  -- any. Once approved, the activist who was marked as attending.
+  activist_id INTEGER,
+  -- pending, approved or rejected
+  status VARCHAR(20) NOT NULL DEFAULT 'pending',
+  created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+  reviewed_at DATETIME,
+  reviewed_by INTEGER,
+  INDEX (status, event_id),
+  CONSTRAINT event_check_ins_event_id_fk
+    FOREIGN KEY (event_id) REFERENCES events (id)
+    ON DELETE CASCADE,
+  CONSTRAINT event_check_ins_activist_id_fk
+    FOREIGN KEY (activist_id) REFERENCES activists (id)
+    ON DELETE SET NULL
+)
+`},
+		Down: []string{
+			`DROP TABLE event_check_ins`,
+		},
+	})
+}
@@ -0,0 +1,19 @@
+package migrations
+
+// Row versions for events and activists, so that saves made from a
+// stale copy are rejected instead of overwriting someone else's
+// changes.
+func init() {
+	register(Migration{
+		Version: 13,
+		Name:    "row_versions",
+		Up: []string{
+			`ALTER TABLE events ADD COLUMN version INTEGER NOT NULL DEFAULT 1`,
+			`ALTER TABLE activists ADD COLUMN version INTEGER NOT NULL DEFAULT 1`,
+		},
+		Down: []string{
+			`ALTER TABLE activists DROP COLUMN version`,
+			`ALTER TABLE events DROP COLUMN version`,
+		},
+	})
+}
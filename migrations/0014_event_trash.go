@@ -0,0 +1,22 @@
+package migrations
+
+// Deleted events are kept in the trash, with who deleted them and
+// when, until they're restored or purged.
+func init() {
+	register(Migration{
+		Version: 14,
+		Name:    "event_trash",
+		Up: []string{`
+ALTER TABLE events
+  ADD COLUMN deleted_at DATETIME,
+  ADD COLUMN deleted_by INTEGER,
+  ADD INDEX events_deleted_at (deleted_at)
+`},
+		Down: []string{`
+ALTER TABLE events
+  DROP INDEX events_deleted_at,
+  DROP COLUMN deleted_by,
+  DROP COLUMN deleted_at
+`},
+	})
+}
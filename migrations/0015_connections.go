@@ -0,0 +1,116 @@
+package migrations
+
+// Maintenance connections used to be events of a "connection" type,
+// named after the connector and attended by the connectees. They're
+// converted to one connection per connectee. Connectors are matched to
+// activists by name; the name is kept in the notes of those that
+// don't match anyone. Connection events in the trash become deleted
+// connections, which are kept but not shown.
+//
+// Merges from before this migration can still be undone, but the
+// connections they moved stay with the target activist.
+func init() {
+	register(Migration{
+		Version: 15,
+		Name:    "connections",
+		Up: []string{`
+CREATE TABLE connections (
+  id INTEGER PRIMARY KEY AUTO_INCREMENT,
+  connector_id INTEGER,
+  connectee_id INTEGER NOT NULL,
+  date DATE NOT NULL,
+  notes TEXT,
+  follow_up_date DATE,
+  created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+  created_by INTEGER,
+  version INTEGER NOT NULL DEFAULT 1,
+  deleted_at DATETIME,
+  deleted_by INTEGER,
+  INDEX (connectee_id, date),
+  INDEX (connector_id, date),
+  INDEX (follow_up_date),
+  CONSTRAINT connections_connector_id_fk
+    FOREIGN KEY (connector_id) REFERENCES activists (id)
+    ON DELETE SET NULL,
+  CONSTRAINT connections_connectee_id_fk
+    FOREIGN KEY (connectee_id) REFERENCES activists (id)
+    ON DELETE CASCADE
+)
+`, `
+-- Which connections a merge moved from the original activist to the
+-- target, so that unmerging can move them back. role is connector or
+-- connectee.
+CREATE TABLE merged_activist_connections (
+  original_activist_id INTEGER NOT NULL,
+  target_activist_id INTEGER NOT NULL,
+  connection_id INTEGER NOT NULL,
+  role VARCHAR(20) NOT NULL,
+  UNIQUE (original_activist_id, target_activist_id, connection_id, role)
+)
+`, `
+INSERT INTO connections (connector_id, connectee_id, date, notes, deleted_at, deleted_by)
+SELECT c.id, ea.activist_id, e.date, IF(c.id IS NULL, CONCAT('Connector: ', e.name), NULL), e.deleted_at, e.deleted_by
+FROM events e
+JOIN event_types t ON t.name = e.event_type
+JOIN event_attendance ea ON ea.event_id = e.id
+LEFT JOIN activists c ON c.name = e.name
+WHERE t.category = 'connection'
+ORDER BY e.date, e.id, ea.activist_id
+`, `
+DELETE FROM merged_activist_attendance
+WHERE event_id IN (
+  SELECT e.id
+  FROM events e
+  JOIN event_types t ON t.name = e.event_type
+  WHERE t.category = 'connection')
+`, `
+DELETE ea
+FROM event_attendance ea
+JOIN events e ON e.id = ea.event_id
+JOIN event_types t ON t.name = e.event_type
+WHERE t.category = 'connection'
+`, `
+DELETE e
+FROM events e
+JOIN event_types t ON t.name = e.event_type
+WHERE t.category = 'connection'
+`, `
+DELETE s
+FROM event_series s
+JOIN event_types t ON t.name = s.event_type
+WHERE t.category = 'connection'
+`, `
+DELETE FROM event_types WHERE category = 'connection'
+`, `
+-- The rest of the metrics catch up when they're next refreshed.
+UPDATE activist_metrics m
+SET last_connection = (
+  SELECT MAX(c.date)
+  FROM connections c
+  WHERE c.connectee_id = m.activist_id AND c.deleted_at IS NULL)
+`},
+		Down: []string{`
+INSERT INTO event_types (name, category, display_order)
+VALUES ('Connection', 'connection', 10)
+`, `
+INSERT INTO events (name, date, event_type, deleted_at, deleted_by)
+SELECT DISTINCT IFNULL(a.name, 'Unknown connector'), c.date, 'Connection', c.deleted_at, c.deleted_by
+FROM connections c
+LEFT JOIN activists a ON a.id = c.connector_id
+`, `
+INSERT IGNORE INTO event_attendance (activist_id, event_id)
+SELECT c.connectee_id, e.id
+FROM connections c
+LEFT JOIN activists a ON a.id = c.connector_id
+JOIN events e
+  ON e.event_type = 'Connection'
+  AND e.date = c.date
+  AND e.name = IFNULL(a.name, 'Unknown connector')
+  AND e.deleted_at <=> c.deleted_at
+  AND e.deleted_by <=> c.deleted_by
+`,
+			`DROP TABLE merged_activist_connections`,
+			`DROP TABLE connections`,
+		},
+	})
+}
@@ -0,0 +1,30 @@
+package migrations
+
+// Login sessions, so they can be listed and revoked server-side. The
+// session cookie only holds a random token; existing cookies don't
+// have one, so everyone signs in again after this migration.
+func init() {
+	register(Migration{
+		Version: 16,
+		Name:    "sessions",
+		Up: []string{`
+CREATE TABLE sessions (
+  id INTEGER PRIMARY KEY AUTO_INCREMENT,
+  -- Hex SHA-256 of the token in the session cookie. The token itself
+  -- is never stored.
+  token_hash CHAR(64) NOT NULL,
+  user_id INTEGER NOT NULL,
+  created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+  last_seen_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+  expires_at DATETIME NOT NULL,
+  ip VARCHAR(64) NOT NULL DEFAULT '',
+  user_agent VARCHAR(255) NOT NULL DEFAULT '',
+  UNIQUE (token_hash),
+  INDEX (user_id)
+)
+`},
+		Down: []string{
+			`DROP TABLE sessions`,
+		},
+	})
+}
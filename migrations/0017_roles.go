@@ -0,0 +1,63 @@
+package migrations
+
+// Roles are sets of permissions, instead of being hard-coded. The
+// admin role isn't stored here because it always has every
+// permission. admin, organizer and attendance are seeded with the
+// access they had before.
+func init() {
+	register(Migration{
+		Version: 17,
+		Name:    "roles",
+		Up: []string{`
+CREATE TABLE roles (
+  name VARCHAR(45) PRIMARY KEY,
+  description VARCHAR(255) NOT NULL DEFAULT ''
+)
+`, `
+CREATE TABLE role_permissions (
+  role VARCHAR(45) NOT NULL,
+  permission VARCHAR(45) NOT NULL,
+  PRIMARY KEY (role, permission),
+  CONSTRAINT role_permissions_role_fk
+    FOREIGN KEY (role) REFERENCES roles (name)
+    ON DELETE CASCADE
+)
+`, `
+INSERT INTO roles (name, description) VALUES
+  ('admin', 'Can do everything, including managing users and roles.'),
+  ('organizer', 'Can see and edit activists, events, connections and groups.'),
+  ('attendance', 'Can take attendance at events.')
+`, `
+INSERT INTO role_permissions (role, permission) VALUES
+  ('organizer', 'activist.read'),
+  ('organizer', 'activist.write'),
+  ('organizer', 'activist.write_contact'),
+  ('organizer', 'activist.merge'),
+  ('organizer', 'connection.read'),
+  ('organizer', 'connection.write'),
+  ('organizer', 'data.import'),
+  ('organizer', 'event.read'),
+  ('organizer', 'event.write'),
+  ('organizer', 'event.delete'),
+  ('organizer', 'event_series.write'),
+  ('organizer', 'group.read'),
+  ('organizer', 'group.write'),
+  ('organizer', 'wallboard.read'),
+  ('attendance', 'event.read'),
+  ('attendance', 'event.write'),
+  ('attendance', 'event.delete'),
+  ('attendance', 'wallboard.read')
+`, `
+-- Keep any other roles that users or API keys already have, with no
+-- permissions until an admin defines them.
+INSERT IGNORE INTO roles (name)
+SELECT role FROM users_roles
+UNION
+SELECT role FROM api_keys
+`},
+		Down: []string{
+			`DROP TABLE role_permissions`,
+			`DROP TABLE roles`,
+		},
+	})
+}
@@ -0,0 +1,42 @@
+package migrations
+
+// Users can be linked to their own activist record. Point people of a
+// working group or circle can then be given the group.write_led and
+// activist.read_led permissions, which only cover the groups they lead,
+// instead of access to every group and activist. Organizers go from
+// editing every group to only the ones they lead.
+func init() {
+	register(Migration{
+		Version: 18,
+		Name:    "user_activists",
+		Up: []string{`
+ALTER TABLE adb_users
+  ADD COLUMN activist_id INTEGER,
+  ADD CONSTRAINT adb_users_activist_id_fk
+    FOREIGN KEY (activist_id) REFERENCES activists (id)
+    ON DELETE SET NULL
+`, `
+INSERT INTO roles (name, description) VALUES
+  ('point_person', 'Can edit the working groups and circles they lead, and see their members.')
+`, `
+INSERT INTO role_permissions (role, permission) VALUES
+  ('point_person', 'group.read'),
+  ('point_person', 'group.write_led'),
+  ('point_person', 'activist.read_led')
+`, `
+UPDATE role_permissions SET permission = 'group.write_led'
+WHERE role = 'organizer' AND permission = 'group.write'
+`},
+		Down: []string{`
+UPDATE role_permissions SET permission = 'group.write'
+WHERE role = 'organizer' AND permission = 'group.write_led'
+`, `
+DELETE FROM roles WHERE name = 'point_person'
+`, `
+ALTER TABLE adb_users
+  DROP FOREIGN KEY adb_users_activist_id_fk,
+  DROP INDEX adb_users_activist_id_fk,
+  DROP COLUMN activist_id
+`},
+	})
+}
@@ -0,0 +1,22 @@
+package migrations
+
+// Activists' full contact details and their date of birth and notes
+// need their own permissions. Organizers keep seeing everything, point
+// people see their members' contact details, and the attendance role
+// only gets masked email and phone.
+func init() {
+	register(Migration{
+		Version: 19,
+		Name:    "activist_redaction",
+		Up: []string{`
+INSERT INTO role_permissions (role, permission) VALUES
+  ('organizer', 'activist.read_contact'),
+  ('organizer', 'activist.read_private'),
+  ('point_person', 'activist.read_contact')
+`},
+		Down: []string{`
+DELETE FROM role_permissions
+WHERE permission IN ('activist.read_contact', 'activist.read_private')
+`},
+	})
+}
@@ -0,0 +1,34 @@
+package migrations
+
+// An audit log of who was sent activists' date of birth or notes, and
+// when. Each access lists the activists whose private fields were sent.
+func init() {
+	register(Migration{
+		Version: 20,
+		Name:    "activist_private_access",
+		Up: []string{`
+CREATE TABLE activist_private_accesses (
+  id INTEGER PRIMARY KEY AUTO_INCREMENT,
+  -- The email is copied so the log survives the user being deleted.
+  user_id INTEGER NOT NULL DEFAULT '0',
+  user_email VARCHAR(60) NOT NULL DEFAULT '',
+  accessed_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+  INDEX (user_id, accessed_at)
+)
+`, `
+CREATE TABLE activist_private_access_activists (
+  access_id INTEGER NOT NULL,
+  activist_id INTEGER NOT NULL,
+  PRIMARY KEY (access_id, activist_id),
+  INDEX (activist_id),
+  CONSTRAINT activist_private_access_activists_access_id_fk
+    FOREIGN KEY (access_id) REFERENCES activist_private_accesses (id)
+    ON DELETE CASCADE
+)
+`},
+		Down: []string{
+			`DROP TABLE activist_private_access_activists`,
+			`DROP TABLE activist_private_accesses`,
+		},
+	})
+}
@@ -0,0 +1,17 @@
+package migrations
+
+// The users that a merge moved from the original activist to the
+// target, so that unmerging can link them back.
+func init() {
+	register(Migration{
+		Version: 21,
+		Name:    "activist_merge_users",
+		Up: []string{
+			// JSON encoded list of adb_users IDs.
+			`ALTER TABLE activist_merges ADD COLUMN moved_user_ids TEXT`,
+		},
+		Down: []string{
+			`ALTER TABLE activist_merges DROP COLUMN moved_user_ids`,
+		},
+	})
+}
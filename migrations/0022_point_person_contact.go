@@ -0,0 +1,17 @@
+package migrations
+
+// Point people see their members' contact details through
+// activist.read_led, so activist.read_contact, which unmasks every
+// activist's, is taken back from them.
+func init() {
+	register(Migration{
+		Version: 22,
+		Name:    "point_person_contact",
+		Up: []string{
+			`DELETE FROM role_permissions WHERE role = 'point_person' AND permission = 'activist.read_contact'`,
+		},
+		Down: []string{
+			`INSERT INTO role_permissions (role, permission) VALUES ('point_person', 'activist.read_contact')`,
+		},
+	})
+}
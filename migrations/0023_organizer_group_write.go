@@ -0,0 +1,18 @@
+package migrations
+
+// Organizers go back to editing every working group and circle.
+// group.write_led is only meant for the point_person role.
+func init() {
+	register(Migration{
+		Version: 23,
+		Name:    "organizer_group_write",
+		Up: []string{`
+UPDATE role_permissions SET permission = 'group.write'
+WHERE role = 'organizer' AND permission = 'group.write_led'
+`},
+		Down: []string{`
+UPDATE role_permissions SET permission = 'group.write_led'
+WHERE role = 'organizer' AND permission = 'group.write'
+`},
+	})
+}
@@ -0,0 +1,285 @@
+// Package migrations owns the ADB database schema.
+//
+// The schema is described as an ordered list of versioned steps, each
+// with the SQL needed to apply it ("up") and to revert it ("down").
+// Applied steps are recorded in the schema_migrations table along
+// with a checksum of their SQL, so we can tell when a database was
+// migrated with a step that has since been edited.
+//
+// To change the schema, add a new file to this package that registers
+// the next version. Never edit a migration that has already been
+// applied to production; write a new one instead.
+package migrations
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/pkg/errors"
+)
+
+/** Type Definitions */
+
+type Migration struct {
+	Version int
+	Name    string
+	// Each statement is executed separately so that migrations
+	// don't depend on the multiStatements DSN option.
+	Up   []string
+	Down []string
+}
+
+type AppliedMigration struct {
+	Version   int       `db:"version"`
+	Name      string    `db:"name"`
+	Checksum  string    `db:"checksum"`
+	AppliedAt time.Time `db:"applied_at"`
+}
+
+type MigrationStatus struct {
+	Migration
+	Applied   bool
+	AppliedAt time.Time
+}
+
+/** Constant and Variable Definitions */
+
+const createSchemaMigrationsTable = `
+CREATE TABLE IF NOT EXISTS schema_migrations (
+  version INTEGER PRIMARY KEY,
+  name VARCHAR(100) NOT NULL,
+  checksum CHAR(64) NOT NULL,
+  applied_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP
+)
+`
+
+var registered []Migration
+
+/** Functions and Methods */
+
+// register is called from the init function of each migration file.
+func register(m Migration) {
+	registered = append(registered, m)
+}
+
+// All returns every known migration ordered by version.
+func All() []Migration {
+	all := make([]Migration, len(registered))
+	copy(all, registered)
+	sort.Slice(all, func(i, j int) bool { return all[i].Version < all[j].Version })
+	return all
+}
+
+// Latest returns the version the schema is at once every migration
+// has been applied.
+func Latest() int {
+	all := All()
+	if len(all) == 0 {
+		return 0
+	}
+	return all[len(all)-1].Version
+}
+
+// Checksum identifies the SQL of a migration. It changes whenever any
+// of the up or down statements are edited.
+func (m Migration) Checksum() string {
+	h := sha256.New()
+	for _, s := range m.Up {
+		fmt.Fprintf(h, "up:%s\n", strings.TrimSpace(s))
+	}
+	for _, s := range m.Down {
+		fmt.Fprintf(h, "down:%s\n", strings.TrimSpace(s))
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// validate checks that the registered migrations form a sequence
+// 1, 2, ..., n with names and up statements.
+func validate(all []Migration) error {
+	for i, m := range all {
+		if m.Version != i+1 {
+			return errors.Errorf("migration versions must be sequential starting at 1: expected %d, got %d (%s)", i+1, m.Version, m.Name)
+		}
+		if m.Name == "" {
+			return errors.Errorf("migration %d must have a name", m.Version)
+		}
+		if len(m.Up) == 0 {
+			return errors.Errorf("migration %d (%s) has no up statements", m.Version, m.Name)
+		}
+	}
+	return nil
+}
+
+func ensureSchemaMigrationsTable(db *sqlx.DB) error {
+	_, err := db.Exec(createSchemaMigrationsTable)
+	return errors.Wrap(err, "failed to create schema_migrations table")
+}
+
+// Applied returns the migrations recorded in schema_migrations,
+// ordered by version.
+func Applied(db *sqlx.DB) ([]AppliedMigration, error) {
+	if err := ensureSchemaMigrationsTable(db); err != nil {
+		return nil, err
+	}
+	var applied []AppliedMigration
+	err := db.Select(&applied, `
+SELECT version, name, checksum, applied_at
+FROM schema_migrations
+ORDER BY version`)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to select applied migrations")
+	}
+	return applied, nil
+}
+
+// Version returns the highest applied migration version, or 0 for an
+// empty database.
+func Version(db *sqlx.DB) (int, error) {
+	applied, err := Applied(db)
+	if err != nil {
+		return 0, err
+	}
+	if len(applied) == 0 {
+		return 0, nil
+	}
+	return applied[len(applied)-1].Version, nil
+}
+
+// Status returns every known migration along with whether it has
+// been applied to db.
+func Status(db *sqlx.DB) ([]MigrationStatus, error) {
+	applied, err := Applied(db)
+	if err != nil {
+		return nil, err
+	}
+	appliedByVersion := map[int]AppliedMigration{}
+	for _, a := range applied {
+		appliedByVersion[a.Version] = a
+	}
+
+	var statuses []MigrationStatus
+	for _, m := range All() {
+		a, ok := appliedByVersion[m.Version]
+		statuses = append(statuses, MigrationStatus{
+			Migration: m,
+			Applied:   ok,
+			AppliedAt: a.AppliedAt,
+		})
+	}
+	return statuses, nil
+}
+
+// Verify returns an error if db has applied migrations that we don't
+// know about, or whose SQL has changed since they were applied.
+func Verify(db *sqlx.DB) error {
+	all := All()
+	if err := validate(all); err != nil {
+		return err
+	}
+	applied, err := Applied(db)
+	if err != nil {
+		return err
+	}
+	for _, a := range applied {
+		if a.Version < 1 || a.Version > len(all) {
+			return errors.Errorf("database has unknown migration %d (%s) applied", a.Version, a.Name)
+		}
+		m := all[a.Version-1]
+		if a.Checksum != m.Checksum() {
+			return errors.Errorf("checksum mismatch for migration %d (%s): the migration was edited after it was applied", m.Version, m.Name)
+		}
+	}
+	return nil
+}
+
+// Up applies every migration that hasn't been applied yet.
+func Up(db *sqlx.DB) error {
+	return MigrateTo(db, Latest())
+}
+
+// MigrateTo applies or reverts migrations until db is at the target
+// version. A target of 0 reverts every migration.
+func MigrateTo(db *sqlx.DB, target int) error {
+	all := All()
+	if target < 0 || target > len(all) {
+		return errors.Errorf("invalid target version %d, must be between 0 and %d", target, len(all))
+	}
+	if err := Verify(db); err != nil {
+		return err
+	}
+	current, err := Version(db)
+	if err != nil {
+		return err
+	}
+
+	for v := current + 1; v <= target; v++ {
+		if err := apply(db, all[v-1]); err != nil {
+			return err
+		}
+	}
+	for v := current; v > target; v-- {
+		if err := revert(db, all[v-1]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Baseline records every migration up to and including version as
+// applied without running it. It's used to adopt databases whose
+// schema was created by hand before migrations existed.
+func Baseline(db *sqlx.DB, version int) error {
+	all := All()
+	if version < 1 || version > len(all) {
+		return errors.Errorf("invalid baseline version %d, must be between 1 and %d", version, len(all))
+	}
+	if err := Verify(db); err != nil {
+		return err
+	}
+	current, err := Version(db)
+	if err != nil {
+		return err
+	}
+	if current != 0 {
+		return errors.Errorf("cannot baseline a database that is already at version %d", current)
+	}
+	for _, m := range all[:version] {
+		if err := record(db, m); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func apply(db *sqlx.DB, m Migration) error {
+	// MySQL implicitly commits DDL statements, so running these
+	// in a transaction wouldn't buy us anything.
+	for i, stmt := range m.Up {
+		if _, err := db.Exec(stmt); err != nil {
+			return errors.Wrapf(err, "failed to apply migration %d (%s), statement %d", m.Version, m.Name, i+1)
+		}
+	}
+	return record(db, m)
+}
+
+func revert(db *sqlx.DB, m Migration) error {
+	for i, stmt := range m.Down {
+		if _, err := db.Exec(stmt); err != nil {
+			return errors.Wrapf(err, "failed to revert migration %d (%s), statement %d", m.Version, m.Name, i+1)
+		}
+	}
+	_, err := db.Exec(`DELETE FROM schema_migrations WHERE version = ?`, m.Version)
+	return errors.Wrapf(err, "failed to remove migration %d (%s) from schema_migrations", m.Version, m.Name)
+}
+
+func record(db *sqlx.DB, m Migration) error {
+	_, err := db.Exec(`
+INSERT INTO schema_migrations (version, name, checksum)
+VALUES (?, ?, ?)`, m.Version, m.Name, m.Checksum())
+	return errors.Wrapf(err, "failed to record migration %d (%s)", m.Version, m.Name)
+}
@@ -0,0 +1,49 @@
+package migrations
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestMigrationsAreValid(t *testing.T) {
+	require.NoError(t, validate(All()))
+	require.Equal(t, len(All()), Latest())
+}
+
+func TestValidate_rejectsGaps(t *testing.T) {
+	err := validate([]Migration{
+		{Version: 1, Name: "one", Up: []string{"SELECT 1"}},
+		{Version: 3, Name: "three", Up: []string{"SELECT 1"}},
+	})
+	require.Error(t, err)
+}
+
+func TestValidate_rejectsEmptyUp(t *testing.T) {
+	err := validate([]Migration{
+		{Version: 1, Name: "one"},
+	})
+	require.Error(t, err)
+}
+
+func TestChecksum(t *testing.T) {
+	m := Migration{
+		Version: 1,
+		Name:    "one",
+		Up:      []string{"CREATE TABLE a (id INTEGER)"},
+		Down:    []string{"DROP TABLE a"},
+	}
+
+	// Surrounding whitespace shouldn't matter.
+	same := m
+	same.Up = []string{"\n  CREATE TABLE a (id INTEGER)\n"}
+	require.Equal(t, m.Checksum(), same.Checksum())
+
+	changedUp := m
+	changedUp.Up = []string{"CREATE TABLE a (id BIGINT)"}
+	require.NotEqual(t, m.Checksum(), changedUp.Checksum())
+
+	changedDown := m
+	changedDown.Down = []string{"DROP TABLE IF EXISTS a"}
+	require.NotEqual(t, m.Checksum(), changedDown.Checksum())
+}
@@ -35,6 +35,8 @@ FROM activists
 //    how they effect performance
 //  - It seems like it's usually faster to use subqueries in the top
 //    part of the SELECT expression vs joining on a table.
+//  - Anything computed from attendance belongs in activist_metrics
+//    (see activist_metrics.go), not in a subquery here.
 const selectActivistExtraBaseQuery string = `
 SELECT
 
@@ -45,6 +47,7 @@ SELECT
   a.name,
   phone,
   dob,
+  a.version,
 
   activist_level,
   source,
@@ -79,59 +82,14 @@ SELECT
   circle_interest,
   interest_date,
 
-  @first_event := (
-      SELECT min(e.date) AS min_date
-      FROM event_attendance ea
-      JOIN activists inner_a ON inner_a.id = ea.activist_id
-      JOIN events e ON e.id = ea.event_id
-      WHERE inner_a.id = a.id
-  ) AS first_event,
-
-  @last_event := (
-    SELECT max(e.date) AS max_date
-    FROM event_attendance ea
-    JOIN activists inner_a ON inner_a.id = ea.activist_id
-    JOIN events e ON e.id = ea.event_id
-    WHERE inner_a.id = a.id
-  ) AS last_event,
-
-  @last_circle := (
-    SELECT max(e.date) AS max_date
-    FROM event_attendance ea
-    JOIN activists inner_a ON inner_a.id = ea.activist_id
-    JOIN events e ON e.id = ea.event_id
-    WHERE inner_a.id = a.id and e.event_type = 'Circle'
-  ) AS last_circle,
-
-  IFNULL(
-    concat(@first_event, ' ', (
-      SELECT name
-      FROM events e
-      JOIN event_attendance ea ON ea.event_id = e.id
-      WHERE
-	e.date = first_event
-        AND ea.activist_id = a.id
-      LIMIT 1)),
-    '') AS first_event_name,
-
-  IFNULL(
-    concat(@last_event, ' ', (
-      SELECT name
-      FROM events e
-      JOIN event_attendance ea ON ea.event_id = e.id
-      WHERE
-        e.date = last_event
-        AND ea.activist_id = a.id
-      LIMIT 1)),
-    '') AS last_event_name,
-
-  (SELECT COUNT(DISTINCT ea.event_id)
-    FROM event_attendance ea
-    WHERE
-      ea.activist_id = a.id) as total_events,
-
-  IFNULL(totalPoints, 0) as total_points,
-  IF(@last_event >= (now() - interval 30 day), 1, 0) as active,
+  metrics.first_event,
+  metrics.last_event,
+  metrics.last_circle,
+  IFNULL(metrics.first_event_name, '') AS first_event_name,
+  IFNULL(metrics.last_event_name, '') AS last_event_name,
+  IFNULL(metrics.total_events, 0) AS total_events,
+  IFNULL(metrics.total_points, 0) AS total_points,
+  IF(metrics.last_event >= (now() - interval 30 day), 1, 0) as active,
 
   IFNULL(
     (SELECT
@@ -150,54 +108,25 @@ SELECT
       circle_members.activist_id = a.id),
     '') AS circles_list,
 
-    IFNULL((
-    SELECT max(e.date) AS max_date
-    FROM event_attendance ea
-    JOIN activists inner_a ON inner_a.id = ea.activist_id
-    JOIN events e ON e.id = ea.event_id
-    WHERE inner_a.id = a.id and e.event_type = "Connection"
-  	),"") AS last_connection,
+    IFNULL(metrics.last_connection, "") AS last_connection,
 
     mpi,
     notes,
     vision_wall,
-    mpp_requirements
+    CASE
+      WHEN metrics.mpp_protest AND metrics.mpp_community THEN 'Fulfilling requirements'
+      WHEN metrics.mpp_protest THEN 'Missing Community event'
+      WHEN metrics.mpp_community THEN 'Missing DA event'
+      ELSE 'Missing Community & DA events'
+    END AS mpp_requirements
 
 FROM activists a
 
-LEFT JOIN (
-  SELECT
-      activist_id, count(ea.event_id) as totalPoints
-      FROM event_attendance ea
-      JOIN events e ON e.id = ea.event_id
-      WHERE
-        e.date BETWEEN (NOW() - INTERVAL 30 DAY) AND NOW()
-      GROUP BY activist_id
-) points
-  ON points.activist_id = a.id
-
-LEFT JOIN (
-
-  select
-    id as activist_id_mpp,
-      IF(is_protest = 1 and is_community = 1, 'Fulfilling requirements',IF(is_protest = 1 and is_community = 0, 'Missing Community event',IF(is_protest = 0 and is_community = 1, 'Missing DA event','Missing Community & DA events'))) as MPP_Requirements
-  from activists
-
-  left join (
-    select
-        activist_id,
-        max((CASE WHEN ((e.event_type = 'action') OR (e.event_type = 'outreach') OR (e.event_type = 'frontline surveillance') OR (e.event_type = 'sanctuary') OR (e.event_type = 'campaign action')) THEN '1' ELSE '0' END)) AS is_protest,
-        max((CASE WHEN ((e.event_type = 'community') OR (e.event_type = 'training') OR (e.event_type = 'circle')) THEN '1' ELSE '0' END)) AS is_community  
-    from
-        event_attendance ea
-    join events e on e.id = ea.event_id
-    where
-        YEAR(e.date) = YEAR(now()) AND (MONTH(e.date) = MONTH(now()))
-    group by ea.activist_id   
-  ) currentMonth on currentMonth.activist_id = activists.id
-) mpp_requirements on mpp_requirements.activist_id_mpp = a.id
+LEFT JOIN activist_metrics metrics ON metrics.activist_id = a.id
 `
 
+// updateActivistExtraBaseQuery doesn't write mpi, since it's computed
+// from attendance by updateActivistMPI rather than set.
 const updateActivistExtraBaseQuery string = `UPDATE activists
 SET
 
@@ -238,9 +167,9 @@ SET
   referral_outlet = :referral_outlet,
   circle_interest = :circle_interest,
   interest_date = :interest_date,
-  mpi = :mpi,
   notes = :notes,
-  vision_wall = :vision_wall
+  vision_wall = :vision_wall,
+  version = version + 1
 
 WHERE
   id = :id`
@@ -259,6 +188,7 @@ type Activist struct {
 	Name     string         `db:"name"`
 	Phone    string         `db:"phone"`
 	Birthday sql.NullString `db:"dob"`
+	Version  int            `db:"version"` // Incremented on every save
 }
 
 type ActivistEventData struct {
@@ -332,6 +262,9 @@ type ActivistJSON struct {
 	Name     string `json:"name"`
 	Phone    string `json:"phone"`
 	Birthday string `json:"dob"`
+	// Version must be sent back unchanged when saving, so that saves
+	// from a stale copy can be rejected.
+	Version int `json:"version"`
 
 	FirstEvent     string `json:"first_event"`
 	LastEvent      string `json:"last_event"`
@@ -392,6 +325,10 @@ type GetActivistOptions struct {
 	LastEventDateFrom string `json:"last_event_date_from"`
 	LastEventDateTo   string `json:"last_event_date_to"`
 	Filter            string `json:"filter"`
+	// LedBy limits the activists to members of the working groups
+	// and circles this activist is a point person of. It's set by the
+	// server for users who can only see their own groups.
+	LedBy int `json:"-"`
 }
 
 var validOrderFields = map[string]struct{}{
@@ -401,6 +338,26 @@ var validOrderFields = map[string]struct{}{
 	"interest_date": struct{}{},
 }
 
+// validActivistFilters are the views of the activist list pages, which
+// are passed as GetActivistOptions.Filter. Most of them filter the
+// activists; the rest only change which columns are shown.
+var validActivistFilters = map[string]struct{}{
+	"action_team":                  struct{}{},
+	"activist_pool":                struct{}{},
+	"activist_recruitment":         struct{}{},
+	"all_activists":                struct{}{},
+	"chapter_member_development":   struct{}{},
+	"chapter_member_prospects":     struct{}{},
+	"circle_member_prospects":      struct{}{},
+	"circle_members":               struct{}{},
+	"community_prospects":          struct{}{},
+	"development":                  struct{}{},
+	"leaderboard":                  struct{}{},
+	"organizer_prospects":          struct{}{},
+	"senior_organizer_development": struct{}{},
+	"senior_organizer_prospects":   struct{}{},
+}
+
 type ActivistRangeOptionsJSON struct {
 	Name  string `json:"name"`
 	Limit int    `json:"limit"`
@@ -409,19 +366,21 @@ type ActivistRangeOptionsJSON struct {
 
 /** Functions and Methods */
 
-func GetActivistsJSON(db *sqlx.DB, options GetActivistOptions) ([]ActivistJSON, error) {
+// GetActivistsJSON returns the activists selected by options, with the
+// sensitive fields viewer can't see withheld.
+func GetActivistsJSON(db *sqlx.DB, options GetActivistOptions, viewer ADBUser) ([]ActivistJSON, error) {
 	if options.ID != 0 {
 		return nil, errors.New("GetActivistsJSON: Cannot include ID in options")
 	}
-	return getActivistsJSON(db, options)
+	return getActivistsJSON(db, options, viewer)
 }
 
-func GetActivistJSON(db *sqlx.DB, options GetActivistOptions) (ActivistJSON, error) {
+func GetActivistJSON(db *sqlx.DB, options GetActivistOptions, viewer ADBUser) (ActivistJSON, error) {
 	if options.ID == 0 {
 		return ActivistJSON{}, errors.New("GetActivistJSON: Must include ID in options")
 	}
 
-	activists, err := getActivistsJSON(db, options)
+	activists, err := getActivistsJSON(db, options, viewer)
 	if err != nil {
 		return ActivistJSON{}, err
 	} else if len(activists) == 0 {
@@ -432,178 +391,188 @@ func GetActivistJSON(db *sqlx.DB, options GetActivistOptions) (ActivistJSON, err
 	return activists[0], nil
 }
 
-func getActivistsJSON(db *sqlx.DB, options GetActivistOptions) ([]ActivistJSON, error) {
+func getActivistsJSON(db *sqlx.DB, options GetActivistOptions, viewer ADBUser) ([]ActivistJSON, error) {
 	activists, err := GetActivistsExtra(db, options)
 	if err != nil {
 		return nil, err
 	}
-	return buildActivistJSONArray(activists), nil
+	return buildActivistJSONArray(db, activists, viewer, activistListRedactionFor(viewer, options))
 }
 
-func GetActivistRangeJSON(db *sqlx.DB, options ActivistRangeOptionsJSON) ([]ActivistJSON, error) {
+func GetActivistRangeJSON(db *sqlx.DB, options ActivistRangeOptionsJSON, viewer ADBUser) ([]ActivistJSON, error) {
 	activists, err := getActivistRange(db, options)
 	if err != nil {
 		return nil, err
 	}
-	return buildActivistJSONArray(activists), nil
+	return buildActivistJSONArray(db, activists, viewer, activistRedactionFor(viewer))
 }
 
-func buildActivistJSONArray(activists []ActivistExtra) []ActivistJSON {
+// buildActivistJSONArray builds activists for viewer with redaction,
+// and records it if they're being sent anyone's date of birth or notes.
+func buildActivistJSONArray(db *sqlx.DB, activists []ActivistExtra, viewer ADBUser, redaction activistRedaction) ([]ActivistJSON, error) {
 	var activistsJSON []ActivistJSON
 
 	for _, a := range activists {
-		firstEvent := ""
-		if a.ActivistEventData.FirstEvent.Valid {
-			firstEvent = a.ActivistEventData.FirstEvent.Time.Format(EventDateLayout)
-		}
-		lastEvent := ""
-		if a.ActivistEventData.LastEvent.Valid {
-			lastEvent = a.ActivistEventData.LastEvent.Time.Format(EventDateLayout)
-		}
-		lastCircle := ""
-		if a.ActivistEventData.LastCircle.Valid {
-			lastCircle = a.ActivistEventData.LastCircle.Time.Format(EventDateLayout)
-		}
-		applicationDate := ""
-		if a.ActivistConnectionData.ApplicationDate.Valid {
-			applicationDate = a.ActivistConnectionData.ApplicationDate.Time.Format(EventDateLayout)
-		}
+		activistsJSON = append(activistsJSON, buildActivistJSON(a, redaction))
+	}
+	if err := recordPrivateActivistAccess(db, viewer, privateActivistIDs(activistsJSON, redaction)); err != nil {
+		return nil, err
+	}
 
-		location := ""
-		if a.Activist.Location.Valid {
-			location = a.Activist.Location.String
-		}
-		dob := ""
-		if a.Activist.Birthday.Valid {
-			dob = a.Activist.Birthday.String
-		}
-		training0 := ""
-		if a.ActivistConnectionData.Training0.Valid {
-			training0 = a.ActivistConnectionData.Training0.String
-		}
-		training1 := ""
-		if a.ActivistConnectionData.Training1.Valid {
-			training1 = a.ActivistConnectionData.Training1.String
-		}
-		training2 := ""
-		if a.ActivistConnectionData.Training2.Valid {
-			training2 = a.ActivistConnectionData.Training2.String
-		}
-		training3 := ""
-		if a.ActivistConnectionData.Training3.Valid {
-			training3 = a.ActivistConnectionData.Training3.String
-		}
-		training4 := ""
-		if a.ActivistConnectionData.Training4.Valid {
-			training4 = a.ActivistConnectionData.Training4.String
-		}
-		training5 := ""
-		if a.ActivistConnectionData.Training5.Valid {
-			training5 = a.ActivistConnectionData.Training5.String
-		}
-		training6 := ""
-		if a.ActivistConnectionData.Training6.Valid {
-			training6 = a.ActivistConnectionData.Training6.String
-		}
-		training_protest := ""
-		if a.ActivistConnectionData.TrainingProtest.Valid {
-			training_protest = a.ActivistConnectionData.TrainingProtest.String
-		}
-		quiz := ""
-		if a.ActivistConnectionData.Quiz.Valid {
-			quiz = a.ActivistConnectionData.Quiz.String
-		}
-		last_connection := ""
-		if a.ActivistConnectionData.LastConnection.Valid {
-			last_connection = a.ActivistConnectionData.LastConnection.String
-		}
-		cm_first_email := ""
-		if a.ActivistConnectionData.CMFirstEmail.Valid {
-			cm_first_email = a.ActivistConnectionData.CMFirstEmail.String
-		}
-		cm_approval_email := ""
-		if a.ActivistConnectionData.CMApprovalEmail.Valid {
-			cm_approval_email = a.ActivistConnectionData.CMApprovalEmail.String
-		}
-		cm_warning_email := ""
-		if a.ActivistConnectionData.CMWarningEmail.Valid {
-			cm_warning_email = a.ActivistConnectionData.CMWarningEmail.String
-		}
-		cir_first_email := ""
-		if a.ActivistConnectionData.CirFirstEmail.Valid {
-			cir_first_email = a.ActivistConnectionData.CirFirstEmail.String
-		}
-		interest_date := ""
-		if a.ActivistConnectionData.InterestDate.Valid {
-			interest_date = a.ActivistConnectionData.InterestDate.String
-		}
-		notes := ""
-		if a.ActivistConnectionData.Notes.Valid {
-			notes = a.ActivistConnectionData.Notes.String
-		}
+	return activistsJSON, nil
+}
 
-		activistsJSON = append(activistsJSON, ActivistJSON{
-			Email:    a.Email,
-			Facebook: a.Facebook,
-			ID:       a.ID,
-			Location: location,
-			Name:     a.Name,
-			Phone:    a.Phone,
-			Birthday: dob,
-
-			FirstEvent:     firstEvent,
-			LastEvent:      lastEvent,
-			LastCircle:     lastCircle,
-			FirstEventName: a.FirstEventName,
-			LastEventName:  a.LastEventName,
-			Status:         a.Status,
-			TotalEvents:    a.TotalEvents,
-			TotalPoints:    a.TotalPoints,
-			Active:         a.Active,
-
-			ActivistLevel: a.ActivistLevel,
-			WorkingGroups: a.WorkingGroups,
-			Circles:       a.Circles,
-			Source:        a.Source,
-			Hiatus:        a.Hiatus,
-
-			Connector:       a.Connector,
-			Training0:       training0,
-			Training1:       training1,
-			Training2:       training2,
-			Training3:       training3,
-			Training4:       training4,
-			Training5:       training5,
-			Training6:       training6,
-			TrainingProtest: training_protest,
-			ApplicationDate: applicationDate,
-			ApplicationType: a.ApplicationType,
-			Quiz:            quiz,
-			DevManager:      a.DevManager,
-			DevInterest:     a.DevInterest,
-
-			ProspectSeniorOrganizer: a.ProspectSeniorOrganizer,
-
-			CMFirstEmail:          cm_first_email,
-			CMApprovalEmail:       cm_approval_email,
-			CMWarningEmail:        cm_warning_email,
-			CirFirstEmail:         cir_first_email,
-			ProspectOrganizer:     a.ProspectOrganizer,
-			ProspectChapterMember: a.ProspectChapterMember,
-			LastConnection:        last_connection,
-			ReferralFriends:       a.ReferralFriends,
-			ReferralApply:         a.ReferralApply,
-			ReferralOutlet:        a.ReferralOutlet,
-			CircleInterest:        a.CircleInterest,
-			InterestDate:          interest_date,
-			MPI:                   a.MPI,
-			Notes:                 notes,
-			VisionWall:            a.VisionWall,
-			MPPRequirements:       a.MPPRequirements,
-		})
+func buildActivistJSON(a ActivistExtra, redaction activistRedaction) ActivistJSON {
+	firstEvent := ""
+	if a.ActivistEventData.FirstEvent.Valid {
+		firstEvent = a.ActivistEventData.FirstEvent.Time.Format(EventDateLayout)
 	}
-
-	return activistsJSON
+	lastEvent := ""
+	if a.ActivistEventData.LastEvent.Valid {
+		lastEvent = a.ActivistEventData.LastEvent.Time.Format(EventDateLayout)
+	}
+	lastCircle := ""
+	if a.ActivistEventData.LastCircle.Valid {
+		lastCircle = a.ActivistEventData.LastCircle.Time.Format(EventDateLayout)
+	}
+	applicationDate := ""
+	if a.ActivistConnectionData.ApplicationDate.Valid {
+		applicationDate = a.ActivistConnectionData.ApplicationDate.Time.Format(EventDateLayout)
+	}
+
+	location := ""
+	if a.Activist.Location.Valid {
+		location = a.Activist.Location.String
+	}
+	dob := ""
+	if a.Activist.Birthday.Valid {
+		dob = a.Activist.Birthday.String
+	}
+	training0 := ""
+	if a.ActivistConnectionData.Training0.Valid {
+		training0 = a.ActivistConnectionData.Training0.String
+	}
+	training1 := ""
+	if a.ActivistConnectionData.Training1.Valid {
+		training1 = a.ActivistConnectionData.Training1.String
+	}
+	training2 := ""
+	if a.ActivistConnectionData.Training2.Valid {
+		training2 = a.ActivistConnectionData.Training2.String
+	}
+	training3 := ""
+	if a.ActivistConnectionData.Training3.Valid {
+		training3 = a.ActivistConnectionData.Training3.String
+	}
+	training4 := ""
+	if a.ActivistConnectionData.Training4.Valid {
+		training4 = a.ActivistConnectionData.Training4.String
+	}
+	training5 := ""
+	if a.ActivistConnectionData.Training5.Valid {
+		training5 = a.ActivistConnectionData.Training5.String
+	}
+	training6 := ""
+	if a.ActivistConnectionData.Training6.Valid {
+		training6 = a.ActivistConnectionData.Training6.String
+	}
+	training_protest := ""
+	if a.ActivistConnectionData.TrainingProtest.Valid {
+		training_protest = a.ActivistConnectionData.TrainingProtest.String
+	}
+	quiz := ""
+	if a.ActivistConnectionData.Quiz.Valid {
+		quiz = a.ActivistConnectionData.Quiz.String
+	}
+	last_connection := ""
+	if a.ActivistConnectionData.LastConnection.Valid {
+		last_connection = a.ActivistConnectionData.LastConnection.String
+	}
+	cm_first_email := ""
+	if a.ActivistConnectionData.CMFirstEmail.Valid {
+		cm_first_email = a.ActivistConnectionData.CMFirstEmail.String
+	}
+	cm_approval_email := ""
+	if a.ActivistConnectionData.CMApprovalEmail.Valid {
+		cm_approval_email = a.ActivistConnectionData.CMApprovalEmail.String
+	}
+	cm_warning_email := ""
+	if a.ActivistConnectionData.CMWarningEmail.Valid {
+		cm_warning_email = a.ActivistConnectionData.CMWarningEmail.String
+	}
+	cir_first_email := ""
+	if a.ActivistConnectionData.CirFirstEmail.Valid {
+		cir_first_email = a.ActivistConnectionData.CirFirstEmail.String
+	}
+	interest_date := ""
+	if a.ActivistConnectionData.InterestDate.Valid {
+		interest_date = a.ActivistConnectionData.InterestDate.String
+	}
+	notes := ""
+	if a.ActivistConnectionData.Notes.Valid {
+		notes = a.ActivistConnectionData.Notes.String
+	}
+
+	return redaction.apply(ActivistJSON{
+		Email:    a.Email,
+		Facebook: a.Facebook,
+		ID:       a.ID,
+		Location: location,
+		Name:     a.Name,
+		Phone:    a.Phone,
+		Birthday: dob,
+		Version:  a.Version,
+
+		FirstEvent:     firstEvent,
+		LastEvent:      lastEvent,
+		LastCircle:     lastCircle,
+		FirstEventName: a.FirstEventName,
+		LastEventName:  a.LastEventName,
+		Status:         a.Status,
+		TotalEvents:    a.TotalEvents,
+		TotalPoints:    a.TotalPoints,
+		Active:         a.Active,
+
+		ActivistLevel: a.ActivistLevel,
+		WorkingGroups: a.WorkingGroups,
+		Circles:       a.Circles,
+		Source:        a.Source,
+		Hiatus:        a.Hiatus,
+
+		Connector:       a.Connector,
+		Training0:       training0,
+		Training1:       training1,
+		Training2:       training2,
+		Training3:       training3,
+		Training4:       training4,
+		Training5:       training5,
+		Training6:       training6,
+		TrainingProtest: training_protest,
+		ApplicationDate: applicationDate,
+		ApplicationType: a.ApplicationType,
+		Quiz:            quiz,
+		DevManager:      a.DevManager,
+		DevInterest:     a.DevInterest,
+
+		ProspectSeniorOrganizer: a.ProspectSeniorOrganizer,
+
+		CMFirstEmail:          cm_first_email,
+		CMApprovalEmail:       cm_approval_email,
+		CMWarningEmail:        cm_warning_email,
+		CirFirstEmail:         cir_first_email,
+		ProspectOrganizer:     a.ProspectOrganizer,
+		ProspectChapterMember: a.ProspectChapterMember,
+		LastConnection:        last_connection,
+		ReferralFriends:       a.ReferralFriends,
+		ReferralApply:         a.ReferralApply,
+		ReferralOutlet:        a.ReferralOutlet,
+		CircleInterest:        a.CircleInterest,
+		InterestDate:          interest_date,
+		MPI:                   a.MPI,
+		Notes:                 notes,
+		VisionWall:            a.VisionWall,
+		MPPRequirements:       a.MPPRequirements,
+	})
 }
 
 func GetActivist(db *sqlx.DB, name string) (Activist, error) {
@@ -680,11 +649,72 @@ WHERE hidden = 0 AND activist_level IN('Organizer', 'Senior Organizer')
 }
 
 func GetActivistsExtra(db *sqlx.DB, options GetActivistOptions) ([]ActivistExtra, error) {
+	query, queryArgs, err := getActivistsExtraQuery(options)
+	if err != nil {
+		return nil, err
+	}
+
+	var activists []ActivistExtra
+	if err := db.Select(&activists, query, queryArgs...); err != nil {
+		return nil, errors.Wrapf(err, "failed to get activists extra for uid %d", options.ID)
+	}
+
+	for i := 0; i < len(activists); i++ {
+		a := activists[i]
+		activists[i].Status = getStatus(a.FirstEvent, a.LastEvent, a.TotalEvents)
+	}
+
+	return activists, nil
+}
+
+// StreamActivistsExtra is like GetActivistsExtra, but calls fn with
+// each activist as it's read instead of loading them all into memory.
+func StreamActivistsExtra(db *sqlx.DB, options GetActivistOptions, fn func(ActivistExtra) error) error {
+	query, queryArgs, err := getActivistsExtraQuery(options)
+	if err != nil {
+		return err
+	}
+
+	rows, err := db.Queryx(query, queryArgs...)
+	if err != nil {
+		return errors.Wrap(err, "failed to query activists extra")
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var a ActivistExtra
+		if err := rows.StructScan(&a); err != nil {
+			return errors.Wrap(err, "failed to scan activist extra")
+		}
+		a.Status = getStatus(a.FirstEvent, a.LastEvent, a.TotalEvents)
+		if err := fn(a); err != nil {
+			return err
+		}
+	}
+	return errors.Wrap(rows.Err(), "failed to read activists extra")
+}
+
+// ledGroupMembersClause matches members of the working groups and
+// circles an activist is a point person of. It takes the activist's ID
+// twice.
+const ledGroupMembersClause = `a.id IN (
+SELECT m.activist_id
+FROM working_group_members m
+JOIN working_group_members l ON l.working_group_id = m.working_group_id
+WHERE l.activist_id = ? AND l.point_person = 1
+UNION
+SELECT m.activist_id
+FROM circle_members m
+JOIN circle_members l ON l.circle_id = m.circle_id
+WHERE l.activist_id = ? AND l.point_person = 1
+)`
+
+func getActivistsExtraQuery(options GetActivistOptions) (string, []interface{}, error) {
 	// Redundant options validation
 	var err error
 	options, err = validateGetActivistOptions(options)
 	if err != nil {
-		return nil, err
+		return "", nil, err
 	}
 
 	query := selectActivistExtraBaseQuery
@@ -695,6 +725,10 @@ func GetActivistsExtra(db *sqlx.DB, options GetActivistOptions) ([]ActivistExtra
 		// retrieve specific activist rather than all activists
 		query += " WHERE a.id = ? "
 		queryArgs = append(queryArgs, options.ID)
+		if options.LedBy != 0 {
+			query += " AND " + ledGroupMembersClause
+			queryArgs = append(queryArgs, options.LedBy, options.LedBy)
+		}
 	} else {
 		whereClause := []string{}
 
@@ -735,7 +769,11 @@ func GetActivistsExtra(db *sqlx.DB, options GetActivistOptions) ([]ActivistExtra
 			whereClause = append(whereClause, "circle_interest = 1 AND a.id not in (select distinct activist_id from circle_members)")
 		}
 		if options.Filter == "leaderboard" {
-			whereClause = append(whereClause, "a.id in (select distinct activist_id  from event_attendance ea  where ea.event_id in (select id from events e where e.date >= (now() - interval 30 day)))")
+			whereClause = append(whereClause, "a.id in (select distinct activist_id  from event_attendance ea  where ea.event_id in (select id from events e where e.date >= (now() - interval 30 day) and e.deleted_at is null))")
+		}
+		if options.LedBy != 0 {
+			whereClause = append(whereClause, ledGroupMembersClause)
+			queryArgs = append(queryArgs, options.LedBy, options.LedBy)
 		}
 
 		if len(whereClause) != 0 {
@@ -767,7 +805,7 @@ func GetActivistsExtra(db *sqlx.DB, options GetActivistOptions) ([]ActivistExtra
 	// to be paranoid b/c this is a sql injection if we don't
 	// check it.
 	if _, ok := validOrderFields[orderField]; !ok {
-		return nil, errors.New("Invalid OrderField")
+		return "", nil, errors.New("Invalid OrderField")
 	}
 
 	query += " ORDER BY " + options.OrderField
@@ -775,17 +813,7 @@ func GetActivistsExtra(db *sqlx.DB, options GetActivistOptions) ([]ActivistExtra
 		query += " desc "
 	}
 
-	var activists []ActivistExtra
-	if err := db.Select(&activists, query, queryArgs...); err != nil {
-		return nil, errors.Wrapf(err, "failed to get activists extra for uid %d", options.ID)
-	}
-
-	for i := 0; i < len(activists); i++ {
-		a := activists[i]
-		activists[i].Status = getStatus(a.FirstEvent, a.LastEvent, a.TotalEvents)
-	}
-
-	return activists, nil
+	return query, queryArgs, nil
 }
 
 // TODO Make sure you only fetch non-hidden members
@@ -851,6 +879,7 @@ JOIN event_attendance
   ON event_attendance.event_id = e.id
 WHERE
   event_attendance.activist_id = ?
+  AND e.deleted_at IS NULL
 `
 	var data ActivistEventData
 	if err := db.Get(&data, query, a.ID); err != nil {
@@ -859,7 +888,7 @@ WHERE
 	return data, nil
 }
 
-func GetOrCreateActivist(db *sqlx.DB, name string) (Activist, error) {
+func GetOrCreateActivist(db *sqlx.DB, name string, user ADBUser) (Activist, error) {
 	activist, err := GetActivist(db, name)
 	if err == nil {
 		// We got a valid activist, return them.
@@ -892,6 +921,15 @@ func GetOrCreateActivist(db *sqlx.DB, name string) (Activist, error) {
 		return Activist{}, errors.Wrapf(err, "failed to get new activist %s", name)
 	}
 
+	err = recordActivistChange(tx, newActivist.ID, user, ActivistChangeCreate, activistFieldChange{
+		Field:    "name",
+		NewValue: sql.NullString{String: name, Valid: true},
+	})
+	if err != nil {
+		tx.Rollback()
+		return Activist{}, err
+	}
+
 	if err := tx.Commit(); err != nil {
 		tx.Rollback()
 		return Activist{}, errors.Wrapf(err, "failed to commit activist %s", name)
@@ -900,7 +938,26 @@ func GetOrCreateActivist(db *sqlx.DB, name string) (Activist, error) {
 	return newActivist, nil
 }
 
-func CreateActivist(db *sqlx.DB, activist ActivistExtra) (int, error) {
+func CreateActivist(db *sqlx.DB, activist ActivistExtra, user ADBUser) (int, error) {
+	tx, err := db.Beginx()
+	if err != nil {
+		return 0, errors.Wrap(err, "Failed to create transaction")
+	}
+
+	id, err := createActivistTx(tx, activist, user)
+	if err != nil {
+		tx.Rollback()
+		return 0, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		tx.Rollback()
+		return 0, errors.Wrapf(err, "failed to commit activist %s", activist.Name)
+	}
+	return id, nil
+}
+
+func createActivistTx(tx *sqlx.Tx, activist ActivistExtra, user ADBUser) (int, error) {
 	if activist.ID != 0 {
 		return 0, errors.New("Activist ID must be 0")
 	}
@@ -908,7 +965,7 @@ func CreateActivist(db *sqlx.DB, activist ActivistExtra) (int, error) {
 		return 0, errors.New("Name cannot be empty")
 	}
 
-	result, err := db.NamedExec(`
+	result, err := tx.NamedExec(`
 INSERT INTO activists (
 
   email,
@@ -947,7 +1004,6 @@ INSERT INTO activists (
   referral_outlet,
   circle_interest,
   interest_date,
-  mpi,
   notes,
   vision_wall
 
@@ -989,9 +1045,8 @@ INSERT INTO activists (
   :referral_outlet,
   :circle_interest,
   :interest_date,
-  :mpi,
   :notes,
-  :vision_wall,
+  :vision_wall
 
 )`, activist)
 	if err != nil {
@@ -1001,18 +1056,75 @@ INSERT INTO activists (
 	if err != nil {
 		return 0, errors.Wrapf(err, "Could not get LastInsertId for %s", activist.Name)
 	}
+
+	created, err := getActivistExtraTx(tx, int(id))
+	if err != nil {
+		return 0, err
+	}
+	if err := recordActivistChanges(tx, int(id), user, ActivistChangeCreate, ActivistExtra{}, created); err != nil {
+		return 0, err
+	}
 	return int(id), nil
 }
 
-func UpdateActivistData(db *sqlx.DB, activist ActivistExtra) (int, error) {
+func UpdateActivistData(db *sqlx.DB, activist ActivistExtra, user ADBUser) (int, error) {
+	tx, err := db.Beginx()
+	if err != nil {
+		return 0, errors.Wrap(err, "failed to create transaction")
+	}
+
+	if err := updateActivistDataTx(tx, activist, user); err != nil {
+		tx.Rollback()
+		if conflictErr, ok := err.(*ConflictError); ok {
+			current := []ActivistJSON{conflictErr.Current.(ActivistJSON)}
+			if err := recordPrivateActivistAccess(db, user, privateActivistIDs(current, activistRedactionFor(user))); err != nil {
+				return 0, err
+			}
+		}
+		return 0, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		tx.Rollback()
+		return 0, errors.Wrapf(err, "failed to commit activist %d", activist.ID)
+	}
+	return activist.ID, nil
+}
+
+// ActivistContactChanged reports whether saving activist would change
+// their email, phone, location or Facebook. Saving a new activist
+// changes them if any are set.
+func ActivistContactChanged(db *sqlx.DB, activist ActivistExtra) (bool, error) {
+	var before Activist
+	if activist.ID != 0 {
+		err := db.Get(&before, `
+SELECT email, facebook, location, phone
+FROM activists
+WHERE id = ?`, activist.ID)
+		if err != nil {
+			return false, errors.Wrapf(err, "failed to get activist %d", activist.ID)
+		}
+	}
+	return activist.Email != before.Email ||
+		activist.Facebook != before.Facebook ||
+		activist.Location.String != before.Location.String ||
+		activist.Phone != before.Phone, nil
+}
+
+func updateActivistDataTx(tx *sqlx.Tx, activist ActivistExtra, user ADBUser) error {
 	if activist.ID == 0 {
-		return 0, errors.New("activist ID cannot be 0")
+		return errors.New("activist ID cannot be 0")
 	}
 	if activist.Name == "" {
-		return 0, errors.New("Name cannot be empty")
+		return errors.New("Name cannot be empty")
+	}
+
+	before, err := getActivistExtraTx(tx, activist.ID)
+	if err != nil {
+		return err
 	}
 
-	_, err := db.NamedExec(`UPDATE activists
+	res, err := tx.NamedExec(`UPDATE activists
 SET
 
   email = :email,
@@ -1050,43 +1162,84 @@ SET
   referral_outlet = :referral_outlet,
   circle_interest = :circle_interest,
   interest_date = :interest_date,
-  mpi = :mpi,
   notes = :notes,
-  vision_wall = :vision_wall
-  
+  vision_wall = :vision_wall,
+  version = version + 1
+
 WHERE
-  id = :id`, activist)
+  id = :id AND version = :version`, activist)
 
 	if err != nil {
-		return 0, errors.Wrap(err, "failed to update activist data")
+		return errors.Wrap(err, "failed to update activist data")
 	}
-	return activist.ID, nil
+	if n, err := res.RowsAffected(); err != nil {
+		return errors.Wrap(err, "failed to update activist data")
+	} else if n == 0 {
+		// The current copy goes back to the user, so it's built
+		// for them. UpdateActivistData records it once the
+		// transaction is rolled back.
+		return &ConflictError{What: "activist", Current: buildActivistJSON(before, activistRedactionFor(user))}
+	}
+
+	// Diff against what's actually stored rather than the
+	// request, since not every field is writable here.
+	after, err := getActivistExtraTx(tx, activist.ID)
+	if err != nil {
+		return err
+	}
+	return recordActivistChanges(tx, activist.ID, user, ActivistChangeUpdate, before, after)
 }
 
-func HideActivist(db *sqlx.DB, activistID int) error {
+func HideActivist(db *sqlx.DB, activistID int, user ADBUser) error {
 	if activistID == 0 {
 		return errors.New("HideActivist: activistID cannot be 0")
 	}
-	var activistCount int
-	err := db.Get(&activistCount, `SELECT count(*) FROM activists WHERE id = ?`, activistID)
+
+	tx, err := db.Beginx()
 	if err != nil {
-		return errors.Wrap(err, "failed to get activist count")
+		return errors.Wrap(err, "failed to create transaction")
 	}
-	if activistCount == 0 {
+
+	var hidden []bool
+	err = tx.Select(&hidden, `SELECT hidden FROM activists WHERE id = ?`, activistID)
+	if err != nil {
+		tx.Rollback()
+		return errors.Wrap(err, "failed to get activist")
+	}
+	if len(hidden) == 0 {
+		tx.Rollback()
 		return errors.Errorf("Activist with id %d does not exist", activistID)
 	}
 
-	_, err = db.Exec(`UPDATE activists SET hidden = true WHERE id = ?`, activistID)
+	_, err = tx.Exec(`UPDATE activists SET hidden = true, version = version + 1 WHERE id = ?`, activistID)
 	if err != nil {
+		tx.Rollback()
 		return errors.Wrapf(err, "failed to update activist %d", activistID)
 	}
+
+	if !hidden[0] {
+		err = recordActivistChange(tx, activistID, user, ActivistChangeHide, activistFieldChange{
+			Field:    "hidden",
+			OldValue: formatActivistValue(false),
+			NewValue: formatActivistValue(true),
+		})
+		if err != nil {
+			tx.Rollback()
+			return err
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		tx.Rollback()
+		return errors.Wrapf(err, "failed to commit hiding activist %d", activistID)
+	}
 	return nil
 }
 
 // Merge activistID into targetActivistID.
 //  - The original activist is hidden
 //  - All of the original activist's event attendance is updated to be the target activist.
-func MergeActivist(db *sqlx.DB, originalActivistID, targetActivistID int) error {
+func MergeActivist(db *sqlx.DB, originalActivistID, targetActivistID int, user ADBUser) error {
 	if originalActivistID == 0 {
 		return errors.New("originalActivistID cannot be 0")
 	}
@@ -1102,12 +1255,60 @@ func MergeActivist(db *sqlx.DB, originalActivistID, targetActivistID int) error
 		return errors.Wrap(err, "could not create transaction")
 	}
 
-	_, err = tx.Exec(`UPDATE activists SET hidden = true, name = concat(name,' ', id) WHERE id = ?`, originalActivistID)
+	originalBefore, err := getActivistExtraTx(tx, originalActivistID)
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+	targetBefore, err := getActivistExtraTx(tx, targetActivistID)
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+	// Users linked to the original activist are linked to the target
+	// instead, so they keep access to the groups they lead.
+	var movedUserIDs []int
+	err = tx.Select(&movedUserIDs, `SELECT id FROM adb_users WHERE activist_id = ?`, originalActivistID)
+	if err != nil {
+		tx.Rollback()
+		return errors.Wrapf(err, "failed to get users linked to activist %d", originalActivistID)
+	}
+	err = insertActivistMergeSnapshot(tx, originalBefore, targetBefore, movedUserIDs, user)
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	_, err = tx.Exec(`UPDATE activists SET hidden = true, name = concat(name,' ', id), version = version + 1 WHERE id = ?`, originalActivistID)
 	if err != nil {
 		tx.Rollback()
 		return errors.Wrapf(err, "failed to hide original activist %d", originalActivistID)
 	}
 
+	originalAfter, err := getActivistExtraTx(tx, originalActivistID)
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+	originalChanges := diffActivists(originalBefore, originalAfter)
+	if !originalBefore.Hidden {
+		originalChanges = append(originalChanges, activistFieldChange{
+			Field:    "hidden",
+			OldValue: formatActivistValue(false),
+			NewValue: formatActivistValue(true),
+		})
+	}
+	originalChanges = append(originalChanges, activistFieldChange{
+		Field:    "merged_into",
+		NewValue: formatActivistValue(targetActivistID),
+	})
+	for _, c := range originalChanges {
+		if err := recordActivistChange(tx, originalActivistID, user, ActivistChangeMerge, c); err != nil {
+			tx.Rollback()
+			return err
+		}
+	}
+
 	err = updateMergedActivistData(tx, originalActivistID, targetActivistID, true)
 	if err != nil {
 		tx.Rollback()
@@ -1118,9 +1319,26 @@ func MergeActivist(db *sqlx.DB, originalActivistID, targetActivistID int) error
 		tx.Rollback()
 		return err
 	}
+	err = mergeActivistConnections(tx, originalActivistID, targetActivistID)
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	_, err = tx.Exec(`UPDATE adb_users SET activist_id = ? WHERE activist_id = ?`, targetActivistID, originalActivistID)
+	if err != nil {
+		tx.Rollback()
+		return errors.Wrapf(err, "failed to move users linked to activist %d", originalActivistID)
+	}
 
 	// Merge Activist data details
-	err = updateMergedActivistDataDetails(tx, originalActivistID, targetActivistID)
+	err = updateMergedActivistDataDetails(tx, originalActivistID, targetActivistID, user)
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	err = updateActivistMetrics(tx, []int{originalActivistID, targetActivistID})
 	if err != nil {
 		tx.Rollback()
 		return err
@@ -1136,6 +1354,246 @@ func MergeActivist(db *sqlx.DB, originalActivistID, targetActivistID int) error
 	return nil
 }
 
+func insertActivistMergeSnapshot(tx *sqlx.Tx, original, target ActivistExtra, movedUserIDs []int, user ADBUser) error {
+	originalSnapshot, err := json.Marshal(original)
+	if err != nil {
+		return errors.Wrapf(err, "failed to encode snapshot of activist %d", original.ID)
+	}
+	targetSnapshot, err := json.Marshal(target)
+	if err != nil {
+		return errors.Wrapf(err, "failed to encode snapshot of activist %d", target.ID)
+	}
+	if movedUserIDs == nil {
+		movedUserIDs = []int{}
+	}
+	movedUsers, err := json.Marshal(movedUserIDs)
+	if err != nil {
+		return errors.Wrapf(err, "failed to encode users linked to activist %d", original.ID)
+	}
+
+	_, err = tx.Exec(`
+INSERT INTO activist_merges (original_activist_id, target_activist_id, original_snapshot, target_snapshot, moved_user_ids, merged_by)
+VALUES (?, ?, ?, ?, ?, ?)`, original.ID, target.ID, string(originalSnapshot), string(targetSnapshot), string(movedUsers), user.ID)
+	return errors.Wrapf(err, "failed to save merge snapshot for activists %d and %d", original.ID, target.ID)
+}
+
+// UnmergeActivist undoes the most recent merge of originalActivistID.
+//  - The original activist is unhidden and gets its old name back
+//  - The event attendance, connections and users moved by the merge are moved back
+//  - The target activist's fields are restored to what they were before the merge
+func UnmergeActivist(db *sqlx.DB, originalActivistID int, user ADBUser) error {
+	if originalActivistID == 0 {
+		return errors.New("originalActivistID cannot be 0")
+	}
+
+	tx, err := db.Beginx()
+	if err != nil {
+		return errors.Wrap(err, "could not create transaction")
+	}
+
+	var merges []struct {
+		ID               int    `db:"id"`
+		TargetActivistID int    `db:"target_activist_id"`
+		OriginalSnapshot string `db:"original_snapshot"`
+		TargetSnapshot   string         `db:"target_snapshot"`
+		MovedUserIDs     sql.NullString `db:"moved_user_ids"`
+	}
+	err = tx.Select(&merges, `
+SELECT id, target_activist_id, original_snapshot, target_snapshot, moved_user_ids
+FROM activist_merges
+WHERE original_activist_id = ? AND unmerged_at IS NULL
+ORDER BY id DESC
+LIMIT 1
+FOR UPDATE`, originalActivistID)
+	if err != nil {
+		tx.Rollback()
+		return errors.Wrapf(err, "failed to get merge of activist %d", originalActivistID)
+	}
+	if len(merges) == 0 {
+		tx.Rollback()
+		return errors.Errorf("Activist %d has not been merged", originalActivistID)
+	}
+	merge := merges[0]
+	targetActivistID := merge.TargetActivistID
+
+	var originalSnapshot, targetSnapshot ActivistExtra
+	if err := json.Unmarshal([]byte(merge.OriginalSnapshot), &originalSnapshot); err != nil {
+		tx.Rollback()
+		return errors.Wrapf(err, "failed to decode snapshot of activist %d", originalActivistID)
+	}
+	if err := json.Unmarshal([]byte(merge.TargetSnapshot), &targetSnapshot); err != nil {
+		tx.Rollback()
+		return errors.Wrapf(err, "failed to decode snapshot of activist %d", targetActivistID)
+	}
+	// Merges from before moved users were recorded have none.
+	var movedUserIDs []int
+	if merge.MovedUserIDs.Valid {
+		if err := json.Unmarshal([]byte(merge.MovedUserIDs.String), &movedUserIDs); err != nil {
+			tx.Rollback()
+			return errors.Wrapf(err, "failed to decode users linked to activist %d", originalActivistID)
+		}
+	}
+
+	originalBefore, err := getActivistExtraTx(tx, originalActivistID)
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+	targetBefore, err := getActivistExtraTx(tx, targetActivistID)
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+	// The target's attendance may have moved on to yet another
+	// activist, so that merge has to be undone first.
+	if targetBefore.Hidden {
+		tx.Rollback()
+		return errors.Errorf("%s is hidden; unmerge it before unmerging %s", targetBefore.Name, originalSnapshot.Name)
+	}
+
+	_, err = tx.NamedExec(updateActivistExtraBaseQuery, originalSnapshot)
+	if err != nil {
+		tx.Rollback()
+		return errors.Wrapf(err, "failed to restore activist %d; is the name %s already taken?", originalActivistID, originalSnapshot.Name)
+	}
+	_, err = tx.Exec(`UPDATE activists SET hidden = false, version = version + 1 WHERE id = ?`, originalActivistID)
+	if err != nil {
+		tx.Rollback()
+		return errors.Wrapf(err, "failed to unhide activist %d", originalActivistID)
+	}
+
+	err = unmergeActivistConnections(tx, originalActivistID, targetActivistID)
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+	err = restoreMergedActivistAttendance(tx, originalActivistID, targetActivistID)
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+	err = restoreMergedActivistUsers(tx, movedUserIDs, originalActivistID, targetActivistID)
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	_, err = tx.NamedExec(updateActivistExtraBaseQuery, targetSnapshot)
+	if err != nil {
+		tx.Rollback()
+		return errors.Wrapf(err, "failed to restore activist %d", targetActivistID)
+	}
+
+	_, err = tx.Exec(`UPDATE activist_merges SET unmerged_at = NOW(), unmerged_by = ? WHERE id = ?`, user.ID, merge.ID)
+	if err != nil {
+		tx.Rollback()
+		return errors.Wrapf(err, "failed to mark merge %d as undone", merge.ID)
+	}
+
+	// Record the history of both activists.
+	originalAfter, err := getActivistExtraTx(tx, originalActivistID)
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+	originalChanges := append(diffActivists(originalBefore, originalAfter),
+		activistFieldChange{
+			Field:    "hidden",
+			OldValue: formatActivistValue(true),
+			NewValue: formatActivistValue(false),
+		},
+		activistFieldChange{
+			Field:    "merged_into",
+			OldValue: formatActivistValue(targetActivistID),
+		})
+	for _, c := range originalChanges {
+		if err := recordActivistChange(tx, originalActivistID, user, ActivistChangeUnmerge, c); err != nil {
+			tx.Rollback()
+			return err
+		}
+	}
+	targetAfter, err := getActivistExtraTx(tx, targetActivistID)
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+	err = recordActivistChanges(tx, targetActivistID, user, ActivistChangeUnmerge, targetBefore, targetAfter)
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		tx.Rollback()
+		return errors.Wrapf(err,
+			"failed to commit unmerge activist transaction. original activist id: %d, target activist id: %d",
+			originalActivistID, targetActivistID)
+	}
+
+	return nil
+}
+
+// restoreMergedActivistAttendance gives back the original activist the
+// attendance recorded in merged_activist_attendance. Events that only
+// the original attended are taken away from the target; events they
+// both attended are kept by both.
+func restoreMergedActivistAttendance(tx *sqlx.Tx, originalActivistID int, targetActivistID int) error {
+	var merged []struct {
+		EventID                    int  `db:"event_id"`
+		ReplacedWithTargetActivist bool `db:"replaced_with_target_activist"`
+	}
+	err := tx.Select(&merged, `
+SELECT event_id, replaced_with_target_activist
+FROM merged_activist_attendance
+WHERE original_activist_id = ? AND target_activist_id = ?`, originalActivistID, targetActivistID)
+	if err != nil {
+		return errors.Wrapf(err, "failed to get merged attendance for activist %d", originalActivistID)
+	}
+
+	for _, m := range merged {
+		if m.ReplacedWithTargetActivist {
+			_, err = tx.Exec(`DELETE FROM event_attendance WHERE activist_id = ? AND event_id = ?`, targetActivistID, m.EventID)
+			if err != nil {
+				return errors.Wrapf(err, "failed to remove activist %d from event %d", targetActivistID, m.EventID)
+			}
+		}
+		_, err = tx.Exec(`INSERT INTO event_attendance (activist_id, event_id)
+            VALUES(?,?) ON DUPLICATE KEY UPDATE activist_id = activist_id`, originalActivistID, m.EventID)
+		if err != nil {
+			return errors.Wrapf(err, "failed to add activist %d to event %d", originalActivistID, m.EventID)
+		}
+	}
+
+	_, err = tx.Exec(`
+DELETE FROM merged_activist_attendance
+WHERE original_activist_id = ? AND target_activist_id = ?`, originalActivistID, targetActivistID)
+	if err != nil {
+		return errors.Wrapf(err, "failed to delete merged attendance for activist %d", originalActivistID)
+	}
+	return updateActivistMetrics(tx, []int{originalActivistID, targetActivistID})
+}
+
+// restoreMergedActivistUsers links the users that a merge moved to the
+// target activist back to the original. Users that have since been
+// linked to someone else are left alone.
+func restoreMergedActivistUsers(tx *sqlx.Tx, userIDs []int, originalActivistID int, targetActivistID int) error {
+	if len(userIDs) == 0 {
+		return nil
+	}
+	query, args, err := sqlx.In(`
+UPDATE adb_users
+SET activist_id = ?
+WHERE id IN (?) AND activist_id = ?`, originalActivistID, userIDs, targetActivistID)
+	if err != nil {
+		return errors.Wrap(err, "failed to create query to restore users")
+	}
+	_, err = tx.Exec(tx.Rebind(query), args...)
+	if err != nil {
+		return errors.Wrapf(err, "failed to link users back to activist %d", originalActivistID)
+	}
+	return nil
+}
+
 func updateMergedActivistData(tx *sqlx.Tx, originalActivistID int, targetActivistID int, originalActivistOnly bool) error {
 	baseQuery := `
 SELECT event_id
@@ -1319,7 +1777,7 @@ func stringMergeSqlNullTime(original mysql.NullTime, target mysql.NullTime) mysq
 	return target
 }
 
-func updateMergedActivistDataDetails(tx *sqlx.Tx, originalActivistID int, targetActivistID int) error {
+func updateMergedActivistDataDetails(tx *sqlx.Tx, originalActivistID int, targetActivistID int, user ADBUser) error {
 	// Merge details of original activist into target activist
 	// Favor booleans that are set to TRUE, and pull in missing data from original activist to target; when both
 	// activists have data for the same field, we should use the target activist's data.
@@ -1346,7 +1804,11 @@ func updateMergedActivistDataDetails(tx *sqlx.Tx, originalActivistID int, target
 		return errors.Wrapf(err, "failed to update activist with id %d", targetActivistID)
 	}
 
-	return nil
+	targetAfter, err := getActivistExtraTx(tx, targetActivistID)
+	if err != nil {
+		return err
+	}
+	return recordActivistChanges(tx, targetActivistID, user, ActivistChangeMerge, *targetActivist, targetAfter)
 }
 
 func GetAutocompleteNames(db *sqlx.DB) []string {
@@ -1358,7 +1820,7 @@ func GetAutocompleteNames(db *sqlx.DB) []string {
 	err := db.Select(&names, `
 SELECT a.name FROM activists a
 LEFT OUTER JOIN event_attendance ea ON a.id = ea.activist_id
-LEFT OUTER JOIN events e ON e.id = ea.event_id
+LEFT OUTER JOIN events e ON e.id = ea.event_id AND e.deleted_at IS NULL
 WHERE a.hidden = 0
 GROUP BY a.name
 ORDER BY MAX(e.date) DESC`)
@@ -1384,7 +1846,7 @@ func GetAutocompleteOrganizerNames(db *sqlx.DB) []string {
 	err := db.Select(&names, `
 SELECT a.name FROM activists a
 LEFT OUTER JOIN event_attendance ea ON a.id = ea.activist_id
-LEFT OUTER JOIN events e ON e.id = ea.event_id
+LEFT OUTER JOIN events e ON e.id = ea.event_id AND e.deleted_at IS NULL
 WHERE a.hidden = 0 and (a.activist_level like '%organizer' or a.activist_level = 'non-local')
 GROUP BY a.name
 ORDER BY MAX(e.date) DESC`)
@@ -1420,14 +1882,16 @@ func (activist *ActivistBasicInfo) ToJSON() ActivistBasicInfoJSON {
 	}
 }
 
-func GetActivistListBasicJSON(db *sqlx.DB) []ActivistBasicInfoJSON {
+// GetActivistListBasicJSON returns every activist's name, email and
+// phone, with email and phone masked unless viewer can see them.
+func GetActivistListBasicJSON(db *sqlx.DB, viewer ADBUser) []ActivistBasicInfoJSON {
 	activists := []ActivistBasicInfo{}
 
 	// Order the activists by the last even they've been to.
 	err := db.Select(&activists, `
 SELECT a.name, a.email, a.phone FROM activists a
 LEFT OUTER JOIN event_attendance ea ON a.id = ea.activist_id
-LEFT OUTER JOIN events e ON e.id = ea.event_id
+LEFT OUTER JOIN events e ON e.id = ea.event_id AND e.deleted_at IS NULL
 WHERE a.hidden = 0
 GROUP BY a.name
 ORDER BY MAX(e.date) DESC`)
@@ -1438,8 +1902,14 @@ ORDER BY MAX(e.date) DESC`)
 
 	activistsJSON := make([]ActivistBasicInfoJSON, 0, len(activists))
 
+	redaction := activistRedactionFor(viewer)
 	for _, activist := range activists {
-		activistsJSON = append(activistsJSON, activist.ToJSON())
+		activistJSON := activist.ToJSON()
+		if redaction.Contact {
+			activistJSON.Email = maskEmail(activistJSON.Email)
+			activistJSON.Phone = maskPhone(activistJSON.Phone)
+		}
+		activistsJSON = append(activistsJSON, activistJSON)
 	}
 
 	return activistsJSON
@@ -1451,7 +1921,13 @@ func CleanActivistData(body io.Reader) (ActivistExtra, error) {
 	if err != nil {
 		return ActivistExtra{}, err
 	}
+	if activistJSON.ID != 0 && activistJSON.Version == 0 {
+		return ActivistExtra{}, errors.New("Missing activist version; reload the page and try again")
+	}
+	return cleanActivistJSON(activistJSON)
+}
 
+func cleanActivistJSON(activistJSON ActivistJSON) (ActivistExtra, error) {
 	// Check if name field contains dangerous input
 	if err := checkForDangerousChars(activistJSON.Name); err != nil {
 		return ActivistExtra{}, err
@@ -1552,6 +2028,7 @@ func CleanActivistData(body io.Reader) (ActivistExtra, error) {
 			Name:     strings.TrimSpace(activistJSON.Name),
 			Phone:    strings.TrimSpace(activistJSON.Phone),
 			Birthday: sql.NullString{String: strings.TrimSpace(activistJSON.Birthday), Valid: validBirthday},
+			Version:  activistJSON.Version,
 		},
 		ActivistMembershipData: ActivistMembershipData{
 			ActivistLevel: strings.TrimSpace(activistJSON.ActivistLevel),
@@ -0,0 +1,508 @@
+package model
+
+import (
+	"sort"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/pkg/errors"
+)
+
+/** Constant and Variable Definitions */
+
+// Pairs scoring below this aren't suggested.
+const DuplicateScoreThreshold = 0.5
+
+// Names less similar than this (by Jaro-Winkler) don't count as
+// evidence of a duplicate at all.
+const duplicateNameSimilarityFloor = 0.8
+
+// Candidate blocks larger than this are skipped, since a block that
+// big is keyed on something too common (e.g. a popular first name) to
+// be useful and would make finding duplicates quadratic.
+const maxDuplicateBlockSize = 1000
+
+const (
+	DuplicateReasonName       = "name"
+	DuplicateReasonEmail      = "email"
+	DuplicateReasonPhone      = "phone"
+	DuplicateReasonAttendance = "attendance"
+)
+
+/** Type Definitions */
+
+type ActivistDuplicate struct {
+	ActivistIDA   int     `db:"activist_id_a"`
+	ActivistNameA string  `db:"activist_name_a"`
+	ActivistIDB   int     `db:"activist_id_b"`
+	ActivistNameB string  `db:"activist_name_b"`
+	Score         float64 `db:"score"`
+	Reasons       string  `db:"reasons"`
+}
+
+type ActivistDuplicateJSON struct {
+	ActivistIDA   int      `json:"activist_id_a"`
+	ActivistNameA string   `json:"activist_name_a"`
+	ActivistIDB   int      `json:"activist_id_b"`
+	ActivistNameB string   `json:"activist_name_b"`
+	Score         float64  `json:"score"`
+	Reasons       []string `json:"reasons"`
+}
+
+// duplicateCandidate is everything about an activist that's used to
+// score them against other activists.
+type duplicateCandidate struct {
+	ID         int    `db:"id"`
+	Name       string `db:"name"`
+	Email      string `db:"email"`
+	Phone      string `db:"phone"`
+	normName   string
+	normEmail  string
+	normPhone  string
+	events     map[int]struct{}
+	eventNames map[string]struct{}
+}
+
+type duplicateScore struct {
+	Score   float64
+	Reasons []string
+}
+
+/** Functions and Methods */
+
+func GetActivistDuplicatesJSON(db *sqlx.DB, limit int) ([]ActivistDuplicateJSON, error) {
+	duplicates, err := GetActivistDuplicates(db, limit)
+	if err != nil {
+		return nil, err
+	}
+
+	duplicatesJSON := make([]ActivistDuplicateJSON, 0, len(duplicates))
+	for _, d := range duplicates {
+		var reasons []string
+		if d.Reasons != "" {
+			reasons = strings.Split(d.Reasons, ",")
+		}
+		duplicatesJSON = append(duplicatesJSON, ActivistDuplicateJSON{
+			ActivistIDA:   d.ActivistIDA,
+			ActivistNameA: d.ActivistNameA,
+			ActivistIDB:   d.ActivistIDB,
+			ActivistNameB: d.ActivistNameB,
+			Score:         d.Score,
+			Reasons:       reasons,
+		})
+	}
+	return duplicatesJSON, nil
+}
+
+// GetActivistDuplicates returns the suggested duplicates that haven't
+// been dismissed, best match first. Pairs where either activist has
+// since been hidden or merged are left out.
+func GetActivistDuplicates(db *sqlx.DB, limit int) ([]ActivistDuplicate, error) {
+	query := `
+SELECT
+  d.activist_id_a,
+  a.name AS activist_name_a,
+  d.activist_id_b,
+  b.name AS activist_name_b,
+  d.score,
+  d.reasons
+FROM activist_duplicates d
+JOIN activists a ON a.id = d.activist_id_a
+JOIN activists b ON b.id = d.activist_id_b
+WHERE
+  d.dismissed = 0
+  AND a.hidden = 0
+  AND b.hidden = 0
+ORDER BY d.score DESC, d.activist_id_a, d.activist_id_b`
+	var args []interface{}
+	if limit > 0 {
+		query += " LIMIT ?"
+		args = append(args, limit)
+	}
+
+	var duplicates []ActivistDuplicate
+	if err := db.Select(&duplicates, query, args...); err != nil {
+		return nil, errors.Wrap(err, "failed to select activist duplicates")
+	}
+	return duplicates, nil
+}
+
+// DismissActivistDuplicate marks a pair of activists as not being
+// duplicates so they're never suggested again.
+func DismissActivistDuplicate(db *sqlx.DB, activistID1, activistID2 int, user ADBUser) error {
+	if activistID1 == 0 || activistID2 == 0 {
+		return errors.New("activist IDs cannot be 0")
+	}
+	if activistID1 == activistID2 {
+		return errors.New("an activist cannot be a duplicate of themselves")
+	}
+	a, b := orderedActivistPair(activistID1, activistID2)
+	_, err := db.Exec(`
+INSERT INTO activist_duplicates (activist_id_a, activist_id_b, dismissed, dismissed_by, dismissed_at)
+VALUES (?, ?, 1, ?, NOW())
+ON DUPLICATE KEY UPDATE dismissed = 1, dismissed_by = VALUES(dismissed_by), dismissed_at = NOW()`,
+		a, b, user.ID)
+	return errors.Wrapf(err, "failed to dismiss duplicate activists %d and %d", a, b)
+}
+
+// MergeActivistDuplicate accepts a suggested duplicate by merging
+// originalActivistID into targetActivistID.
+func MergeActivistDuplicate(db *sqlx.DB, originalActivistID, targetActivistID int, user ADBUser) error {
+	if err := MergeActivist(db, originalActivistID, targetActivistID, user); err != nil {
+		return err
+	}
+	// The original is hidden now, so its suggestions are useless.
+	// Dismissed pairs are kept in case the merge is undone.
+	_, err := db.Exec(`
+DELETE FROM activist_duplicates
+WHERE
+  dismissed = 0
+  AND (activist_id_a = ? OR activist_id_b = ?)`, originalActivistID, originalActivistID)
+	return errors.Wrapf(err, "failed to delete duplicates of activist %d", originalActivistID)
+}
+
+// RefreshActivistDuplicates rescores every plausible pair of visible
+// activists and replaces the suggestions that haven't been dismissed.
+func RefreshActivistDuplicates(db *sqlx.DB) (int, error) {
+	candidates, err := getDuplicateCandidates(db)
+	if err != nil {
+		return 0, err
+	}
+	scores := findDuplicates(candidates)
+
+	tx, err := db.Beginx()
+	if err != nil {
+		return 0, errors.Wrap(err, "failed to create transaction")
+	}
+	_, err = tx.Exec(`DELETE FROM activist_duplicates WHERE dismissed = 0`)
+	if err != nil {
+		tx.Rollback()
+		return 0, errors.Wrap(err, "failed to delete old activist duplicates")
+	}
+	now := time.Now()
+	for pair, s := range scores {
+		// Dismissed pairs keep their dismissal, but get the new
+		// score for posterity.
+		_, err = tx.Exec(`
+INSERT INTO activist_duplicates (activist_id_a, activist_id_b, score, reasons, updated_at)
+VALUES (?, ?, ?, ?, ?)
+ON DUPLICATE KEY UPDATE score = VALUES(score), reasons = VALUES(reasons), updated_at = VALUES(updated_at)`,
+			pair[0], pair[1], s.Score, strings.Join(s.Reasons, ","), now)
+		if err != nil {
+			tx.Rollback()
+			return 0, errors.Wrapf(err, "failed to insert duplicate activists %d and %d", pair[0], pair[1])
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		tx.Rollback()
+		return 0, errors.Wrap(err, "failed to commit activist duplicates")
+	}
+	return len(scores), nil
+}
+
+func getDuplicateCandidates(db *sqlx.DB) ([]*duplicateCandidate, error) {
+	var candidates []*duplicateCandidate
+	err := db.Select(&candidates, `SELECT id, name, email, phone FROM activists WHERE hidden = 0`)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to select activists")
+	}
+	byID := map[int]*duplicateCandidate{}
+	for _, c := range candidates {
+		c.normName = normalizeDuplicateName(c.Name)
+		c.normEmail = strings.ToLower(strings.TrimSpace(c.Email))
+		c.normPhone = normalizeDuplicatePhone(c.Phone)
+		c.events = map[int]struct{}{}
+		c.eventNames = map[string]struct{}{}
+		byID[c.ID] = c
+	}
+
+	var attendance []struct {
+		ActivistID int    `db:"activist_id"`
+		EventID    int    `db:"event_id"`
+		EventName  string `db:"name"`
+	}
+	err = db.Select(&attendance, `
+SELECT ea.activist_id, ea.event_id, e.name
+FROM event_attendance ea
+JOIN events e ON e.id = ea.event_id
+WHERE e.deleted_at IS NULL`)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to select event attendance")
+	}
+	for _, a := range attendance {
+		c, ok := byID[a.ActivistID]
+		if !ok {
+			continue
+		}
+		c.events[a.EventID] = struct{}{}
+		c.eventNames[strings.ToLower(strings.TrimSpace(a.EventName))] = struct{}{}
+	}
+	return candidates, nil
+}
+
+// findDuplicates scores pairs of candidates that share a blocking key
+// (the soundex code of a name token, an email, or a phone number) and
+// returns the ones above DuplicateScoreThreshold keyed by ordered id
+// pair.
+func findDuplicates(candidates []*duplicateCandidate) map[[2]int]duplicateScore {
+	blocks := map[string][]*duplicateCandidate{}
+	for _, c := range candidates {
+		keys := map[string]struct{}{}
+		for _, token := range strings.Fields(c.normName) {
+			keys["name:"+soundex(token)] = struct{}{}
+		}
+		if c.normEmail != "" {
+			keys["email:"+c.normEmail] = struct{}{}
+		}
+		if c.normPhone != "" {
+			keys["phone:"+c.normPhone] = struct{}{}
+		}
+		for k := range keys {
+			blocks[k] = append(blocks[k], c)
+		}
+	}
+
+	scores := map[[2]int]duplicateScore{}
+	for _, block := range blocks {
+		if len(block) > maxDuplicateBlockSize {
+			continue
+		}
+		for i := 0; i < len(block); i++ {
+			for j := i + 1; j < len(block); j++ {
+				a, b := orderedActivistPair(block[i].ID, block[j].ID)
+				pair := [2]int{a, b}
+				if _, ok := scores[pair]; ok {
+					continue
+				}
+				scores[pair] = scoreDuplicate(block[i], block[j])
+			}
+		}
+	}
+
+	for pair, s := range scores {
+		if s.Score < DuplicateScoreThreshold {
+			delete(scores, pair)
+		}
+	}
+	return scores
+}
+
+// scoreDuplicate combines the evidence that a and b are the same
+// person with a noisy-OR, so that each signal on its own can make a
+// pair a candidate and every additional signal makes it more likely.
+// Having attended the same event is strong evidence that they're
+// different people.
+func scoreDuplicate(a, b *duplicateCandidate) duplicateScore {
+	var reasons []string
+	notDuplicate := 1.0
+
+	if similarity := nameSimilarity(a.normName, b.normName); similarity >= duplicateNameSimilarityFloor {
+		weight := (similarity - duplicateNameSimilarityFloor) / (1 - duplicateNameSimilarityFloor)
+		notDuplicate *= 1 - 0.8*weight
+		reasons = append(reasons, DuplicateReasonName)
+	}
+	if a.normEmail != "" && a.normEmail == b.normEmail {
+		notDuplicate *= 1 - 0.7
+		reasons = append(reasons, DuplicateReasonEmail)
+	}
+	if a.normPhone != "" && a.normPhone == b.normPhone {
+		notDuplicate *= 1 - 0.6
+		reasons = append(reasons, DuplicateReasonPhone)
+	}
+	if overlap := jaccard(a.eventNames, b.eventNames); overlap > 0 {
+		notDuplicate *= 1 - 0.3*overlap
+		reasons = append(reasons, DuplicateReasonAttendance)
+	}
+
+	score := 1 - notDuplicate
+	for e := range a.events {
+		if _, ok := b.events[e]; ok {
+			score *= 0.3
+			break
+		}
+	}
+	return duplicateScore{Score: score, Reasons: reasons}
+}
+
+func orderedActivistPair(id1, id2 int) (int, int) {
+	if id1 < id2 {
+		return id1, id2
+	}
+	return id2, id1
+}
+
+// normalizeDuplicateName lowercases a name and strips everything but
+// letters, digits and single spaces.
+func normalizeDuplicateName(name string) string {
+	var b strings.Builder
+	for _, r := range strings.ToLower(name) {
+		switch {
+		case unicode.IsLetter(r) || unicode.IsDigit(r):
+			b.WriteRune(r)
+		case unicode.IsSpace(r) || r == '-':
+			b.WriteRune(' ')
+		}
+	}
+	return strings.Join(strings.Fields(b.String()), " ")
+}
+
+// normalizeDuplicatePhone keeps only the digits of a phone number,
+// dropping the US country code.
+func normalizeDuplicatePhone(phone string) string {
+	var b strings.Builder
+	for _, r := range phone {
+		if r >= '0' && r <= '9' {
+			b.WriteRune(r)
+		}
+	}
+	digits := b.String()
+	if len(digits) == 11 && digits[0] == '1' {
+		digits = digits[1:]
+	}
+	// Anything shorter is probably a typo or an extension.
+	if len(digits) < 7 {
+		return ""
+	}
+	return digits
+}
+
+// nameSimilarity is the Jaro-Winkler similarity of two normalized
+// names, also comparing them with their tokens sorted so that "Smith
+// John" matches "John Smith".
+func nameSimilarity(a, b string) float64 {
+	similarity := jaroWinkler(a, b)
+	if sorted := jaroWinkler(sortTokens(a), sortTokens(b)); sorted > similarity {
+		similarity = sorted
+	}
+	return similarity
+}
+
+func sortTokens(s string) string {
+	tokens := strings.Fields(s)
+	sort.Strings(tokens)
+	return strings.Join(tokens, " ")
+}
+
+func jaroWinkler(s1, s2 string) float64 {
+	a, b := []rune(s1), []rune(s2)
+	if len(a) == 0 && len(b) == 0 {
+		return 1
+	}
+	if len(a) == 0 || len(b) == 0 {
+		return 0
+	}
+
+	window := len(a)
+	if len(b) > window {
+		window = len(b)
+	}
+	window = window/2 - 1
+	if window < 0 {
+		window = 0
+	}
+
+	aMatched := make([]bool, len(a))
+	bMatched := make([]bool, len(b))
+	matches := 0
+	for i := range a {
+		lo, hi := i-window, i+window+1
+		if lo < 0 {
+			lo = 0
+		}
+		if hi > len(b) {
+			hi = len(b)
+		}
+		for j := lo; j < hi; j++ {
+			if !bMatched[j] && a[i] == b[j] {
+				aMatched[i], bMatched[j] = true, true
+				matches++
+				break
+			}
+		}
+	}
+	if matches == 0 {
+		return 0
+	}
+
+	transpositions := 0
+	j := 0
+	for i := range a {
+		if !aMatched[i] {
+			continue
+		}
+		for !bMatched[j] {
+			j++
+		}
+		if a[i] != b[j] {
+			transpositions++
+		}
+		j++
+	}
+
+	m := float64(matches)
+	jaro := (m/float64(len(a)) + m/float64(len(b)) + (m-float64(transpositions)/2)/m) / 3
+
+	prefix := 0
+	for prefix < len(a) && prefix < len(b) && prefix < 4 && a[prefix] == b[prefix] {
+		prefix++
+	}
+	return jaro + float64(prefix)*0.1*(1-jaro)
+}
+
+func jaccard(a, b map[string]struct{}) float64 {
+	if len(a) == 0 || len(b) == 0 {
+		return 0
+	}
+	intersection := 0
+	for k := range a {
+		if _, ok := b[k]; ok {
+			intersection++
+		}
+	}
+	return float64(intersection) / float64(len(a)+len(b)-intersection)
+}
+
+// soundex returns the American Soundex code of a word, e.g. "R163"
+// for "Robert" and "Rupert".
+func soundex(word string) string {
+	codes := map[rune]byte{
+		'b': '1', 'f': '1', 'p': '1', 'v': '1',
+		'c': '2', 'g': '2', 'j': '2', 'k': '2', 'q': '2', 's': '2', 'x': '2', 'z': '2',
+		'd': '3', 't': '3',
+		'l': '4',
+		'm': '5', 'n': '5',
+		'r': '6',
+	}
+
+	var out []byte
+	var last byte
+	for i, r := range strings.ToLower(word) {
+		if r > unicode.MaxASCII || !unicode.IsLetter(r) {
+			// Non-ASCII names are compared in full instead.
+			return word
+		}
+		code := codes[r]
+		if i == 0 {
+			out = append(out, byte(unicode.ToUpper(r)))
+			last = code
+			continue
+		}
+		if code != 0 && code != last {
+			out = append(out, code)
+		}
+		// H and W don't separate letters with the same code,
+		// but vowels do.
+		if r != 'h' && r != 'w' {
+			last = code
+		}
+		if len(out) == 4 {
+			break
+		}
+	}
+	for len(out) < 4 {
+		out = append(out, '0')
+	}
+	return string(out)
+}
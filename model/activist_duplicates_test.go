@@ -0,0 +1,139 @@
+package model
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestSoundex(t *testing.T) {
+	for word, want := range map[string]string{
+		"robert":   "R163",
+		"rupert":   "R163",
+		"ashcraft": "A261",
+		"tymczak":  "T522",
+		"pfister":  "P236",
+		"smith":    "S530",
+		"smyth":    "S530",
+		"jon":      "J500",
+		"john":     "J500",
+	} {
+		require.Equal(t, want, soundex(word), word)
+	}
+}
+
+func TestJaroWinkler(t *testing.T) {
+	require.Equal(t, 1.0, jaroWinkler("john smith", "john smith"))
+	require.Equal(t, 0.0, jaroWinkler("abc", "xyz"))
+	require.InDelta(t, 0.961, jaroWinkler("martha", "marhta"), 0.001)
+	require.InDelta(t, 0.840, jaroWinkler("dwayne", "duane"), 0.001)
+}
+
+func TestNormalizeDuplicatePhone(t *testing.T) {
+	require.Equal(t, "5105551234", normalizeDuplicatePhone("+1 (510) 555-1234"))
+	require.Equal(t, "5105551234", normalizeDuplicatePhone("510.555.1234"))
+	require.Equal(t, "", normalizeDuplicatePhone("555"))
+}
+
+func TestFindDuplicates(t *testing.T) {
+	candidate := func(id int, name, email, phone string, events ...int) *duplicateCandidate {
+		c := &duplicateCandidate{
+			ID:         id,
+			normName:   normalizeDuplicateName(name),
+			normEmail:  email,
+			normPhone:  normalizeDuplicatePhone(phone),
+			events:     map[int]struct{}{},
+			eventNames: map[string]struct{}{},
+		}
+		for _, e := range events {
+			c.events[e] = struct{}{}
+		}
+		return c
+	}
+
+	scores := findDuplicates([]*duplicateCandidate{
+		candidate(1, "John Smith", "", ""),
+		candidate(2, "Jon Smith", "", ""),
+		// Same email, different name.
+		candidate(3, "Alex Doe", "alex@example.com", ""),
+		candidate(4, "Sam Doe", "alex@example.com", ""),
+		// Similar names, but they were at the same event.
+		candidate(5, "Jane Roe", "", "", 1),
+		candidate(6, "Jane Rowe", "", "", 1),
+		// Not similar at all.
+		candidate(7, "Pat Lee", "", ""),
+	})
+
+	require.Contains(t, scores, [2]int{1, 2})
+	require.Equal(t, []string{DuplicateReasonName}, scores[[2]int{1, 2}].Reasons)
+	require.Contains(t, scores, [2]int{3, 4})
+	require.Contains(t, scores[[2]int{3, 4}].Reasons, DuplicateReasonEmail)
+	require.NotContains(t, scores, [2]int{5, 6})
+	for pair := range scores {
+		require.NotEqual(t, 7, pair[0])
+		require.NotEqual(t, 7, pair[1])
+	}
+}
+
+func TestRefreshActivistDuplicates(t *testing.T) {
+	db := newTestDB()
+	defer db.Close()
+
+	a1, err := GetOrCreateActivist(db, "John Smith", ADBUser{})
+	require.NoError(t, err)
+	a2, err := GetOrCreateActivist(db, "Jon Smith", ADBUser{})
+	require.NoError(t, err)
+	_, err = GetOrCreateActivist(db, "Somebody Else", ADBUser{})
+	require.NoError(t, err)
+
+	count, err := RefreshActivistDuplicates(db)
+	require.NoError(t, err)
+	require.Equal(t, 1, count)
+
+	duplicates, err := GetActivistDuplicates(db, 0)
+	require.NoError(t, err)
+	require.Len(t, duplicates, 1)
+	require.Equal(t, a1.ID, duplicates[0].ActivistIDA)
+	require.Equal(t, a2.ID, duplicates[0].ActivistIDB)
+
+	// Dismissed pairs stay dismissed after refreshing.
+	require.NoError(t, DismissActivistDuplicate(db, a2.ID, a1.ID, ADBUser{}))
+	_, err = RefreshActivistDuplicates(db)
+	require.NoError(t, err)
+	duplicates, err = GetActivistDuplicates(db, 0)
+	require.NoError(t, err)
+	require.Len(t, duplicates, 0)
+}
+
+func TestMergeActivistDuplicate(t *testing.T) {
+	db := newTestDB()
+	defer db.Close()
+
+	a1, err := GetOrCreateActivist(db, "John Smith", ADBUser{})
+	require.NoError(t, err)
+	a2, err := GetOrCreateActivist(db, "Jon Smith", ADBUser{})
+	require.NoError(t, err)
+
+	d1, err := time.Parse("2006-01-02", "2017-04-15")
+	require.NoError(t, err)
+	mustInsertAllEvents(t, db, []Event{{
+		ID:             1,
+		EventName:      "event one",
+		EventDate:      d1,
+		EventType:      "Meeting",
+		AddedAttendees: []Activist{a2},
+	}})
+
+	_, err = RefreshActivistDuplicates(db)
+	require.NoError(t, err)
+	require.NoError(t, MergeActivistDuplicate(db, a2.ID, a1.ID, ADBUser{}))
+
+	duplicates, err := GetActivistDuplicates(db, 0)
+	require.NoError(t, err)
+	require.Len(t, duplicates, 0)
+
+	e1, err := GetEvent(db, GetEventOptions{EventID: 1})
+	require.NoError(t, err)
+	require.Equal(t, []string{a1.Name}, e1.Attendees)
+}
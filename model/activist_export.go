@@ -0,0 +1,155 @@
+package model
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"net/url"
+	"reflect"
+	"strconv"
+	"strings"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/pkg/errors"
+)
+
+/** Constant and Variable Definitions */
+
+// ActivistExportColumns lists every column that can be exported, by
+// the JSON name of the corresponding ActivistJSON field, in the order
+// they're exported by default.
+var ActivistExportColumns []string
+
+var activistExportFieldIndex = map[string]int{}
+
+// csvFormulaPrefixes are the first characters that make spreadsheet
+// programs treat a cell as a formula.
+const csvFormulaPrefixes = "=+-@\t\r"
+
+func init() {
+	t := reflect.TypeOf(ActivistJSON{})
+	for i := 0; i < t.NumField(); i++ {
+		column := strings.Split(t.Field(i).Tag.Get("json"), ",")[0]
+		if column == "" || column == "-" {
+			continue
+		}
+		ActivistExportColumns = append(ActivistExportColumns, column)
+		activistExportFieldIndex[column] = i
+	}
+}
+
+/** Type Definitions */
+
+type ActivistExportOptions struct {
+	GetActivistOptions
+	Columns []string
+}
+
+/** Functions and Methods */
+
+// CleanActivistExportOptions reads export options from URL query
+// parameters. It takes the same parameters as GetActivistOptions plus
+// a comma separated list of columns, which defaults to every column.
+func CleanActivistExportOptions(values url.Values) (ActivistExportOptions, error) {
+	var options ActivistExportOptions
+	options.OrderField = values.Get("order_field")
+	options.LastEventDateFrom = values.Get("last_event_date_from")
+	options.LastEventDateTo = values.Get("last_event_date_to")
+	options.Filter = values.Get("filter")
+	// The filter is also used to name the exported file.
+	if _, ok := validActivistFilters[options.Filter]; options.Filter != "" && !ok {
+		return ActivistExportOptions{}, errors.Errorf("invalid filter %s", options.Filter)
+	}
+
+	if order := values.Get("order"); order != "" {
+		o, err := strconv.Atoi(order)
+		if err != nil {
+			return ActivistExportOptions{}, errors.Wrapf(err, "invalid order %s", order)
+		}
+		options.Order = o
+	}
+	if hidden := values.Get("hidden"); hidden != "" {
+		h, err := strconv.ParseBool(hidden)
+		if err != nil {
+			return ActivistExportOptions{}, errors.Wrapf(err, "invalid hidden %s", hidden)
+		}
+		options.Hidden = h
+	}
+
+	getActivistOptions, err := validateGetActivistOptions(options.GetActivistOptions)
+	if err != nil {
+		return ActivistExportOptions{}, err
+	}
+	options.GetActivistOptions = getActivistOptions
+
+	if columns := values.Get("columns"); columns != "" {
+		for _, c := range strings.Split(columns, ",") {
+			c = strings.TrimSpace(c)
+			if _, ok := activistExportFieldIndex[c]; !ok {
+				return ActivistExportOptions{}, errors.Errorf("invalid column %s", c)
+			}
+			options.Columns = append(options.Columns, c)
+		}
+	} else {
+		options.Columns = ActivistExportColumns
+	}
+
+	return options, nil
+}
+
+// WriteActivistsCSV writes the activists selected by options to w as
+// CSV, with a header row of column names. The sensitive fields viewer
+// can't see are left blank or masked.
+func WriteActivistsCSV(db *sqlx.DB, w io.Writer, options ActivistExportOptions, viewer ADBUser) error {
+	if options.ID != 0 {
+		return errors.New("WriteActivistsCSV: Cannot include ID in options")
+	}
+
+	csvWriter := csv.NewWriter(w)
+	if err := csvWriter.Write(options.Columns); err != nil {
+		return errors.Wrap(err, "failed to write CSV header")
+	}
+
+	exportsPrivate := false
+	for _, c := range options.Columns {
+		if c == "dob" || c == "notes" {
+			exportsPrivate = true
+		}
+	}
+
+	record := make([]string, len(options.Columns))
+	redaction := activistRedactionFor(viewer)
+	var privateIDs []int
+	err := StreamActivistsExtra(db, options.GetActivistOptions, func(a ActivistExtra) error {
+		activistJSON := buildActivistJSON(a, redaction)
+		if exportsPrivate {
+			privateIDs = append(privateIDs, privateActivistIDs([]ActivistJSON{activistJSON}, redaction)...)
+		}
+		v := reflect.ValueOf(activistJSON)
+		for i, c := range options.Columns {
+			record[i] = escapeCSVFormula(fmt.Sprint(v.Field(activistExportFieldIndex[c]).Interface()))
+		}
+		return errors.Wrap(csvWriter.Write(record), "failed to write CSV record")
+	})
+	// Record whatever was written, even if the export failed part
+	// way through.
+	if recordErr := recordPrivateActivistAccess(db, viewer, privateIDs); recordErr != nil {
+		return recordErr
+	}
+	if err != nil {
+		return err
+	}
+
+	csvWriter.Flush()
+	return errors.Wrap(csvWriter.Error(), "failed to flush CSV")
+}
+
+// escapeCSVFormula prefixes value with a quote if a spreadsheet program
+// would otherwise run it as a formula, so that activists' data can't
+// be used to attack whoever opens the export.
+func escapeCSVFormula(value string) string {
+	if value != "" && strings.IndexByte(csvFormulaPrefixes, value[0]) >= 0 {
+		return "'" + value
+	}
+	return value
+}
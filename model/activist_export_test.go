@@ -0,0 +1,74 @@
+package model
+
+import (
+	"bytes"
+	"net/url"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestCleanActivistExportOptions(t *testing.T) {
+	options, err := CleanActivistExportOptions(url.Values{})
+	require.NoError(t, err)
+	require.Equal(t, ActivistExportColumns, options.Columns)
+	require.Equal(t, DescOrder, options.Order)
+	require.Equal(t, "a.name", options.OrderField)
+
+	options, err = CleanActivistExportOptions(url.Values{
+		"filter":      {"leaderboard"},
+		"order":       {"1"},
+		"order_field": {"total_points"},
+		"columns":     {"name,email,total_points"},
+	})
+	require.NoError(t, err)
+	require.Equal(t, "leaderboard", options.Filter)
+	require.Equal(t, AscOrder, options.Order)
+	require.Equal(t, []string{"name", "email", "total_points"}, options.Columns)
+
+	_, err = CleanActivistExportOptions(url.Values{"columns": {"name,password"}})
+	require.Error(t, err)
+
+	_, err = CleanActivistExportOptions(url.Values{"order_field": {"id; DROP TABLE activists"}})
+	require.Error(t, err)
+
+	// The filter names the exported file.
+	_, err = CleanActivistExportOptions(url.Values{"filter": {`x"; evil="`}})
+	require.Error(t, err)
+}
+
+func TestEscapeCSVFormula(t *testing.T) {
+	require.Equal(t, "'=HYPERLINK(\"http://example.com\")", escapeCSVFormula(`=HYPERLINK("http://example.com")`))
+	require.Equal(t, "'+1 555 1234", escapeCSVFormula("+1 555 1234"))
+	require.Equal(t, "'-2", escapeCSVFormula("-2"))
+	require.Equal(t, "'@SUM(A1)", escapeCSVFormula("@SUM(A1)"))
+	require.Equal(t, "'\tx", escapeCSVFormula("\tx"))
+	require.Equal(t, "Test Activist", escapeCSVFormula("Test Activist"))
+	require.Equal(t, "", escapeCSVFormula(""))
+}
+
+func TestWriteActivistsCSV(t *testing.T) {
+	db := newTestDB()
+	defer db.Close()
+
+	_, err := GetOrCreateActivist(db, "Test Activist", ADBUser{})
+	require.NoError(t, err)
+	_, err = GetOrCreateActivist(db, "Activist, With Comma", ADBUser{})
+	require.NoError(t, err)
+	_, err = GetOrCreateActivist(db, "=1+1", ADBUser{})
+	require.NoError(t, err)
+
+	options, err := CleanActivistExportOptions(url.Values{
+		"order":   {"1"},
+		"columns": {"name,total_events,hiatus"},
+	})
+	require.NoError(t, err)
+
+	var buf bytes.Buffer
+	require.NoError(t, WriteActivistsCSV(db, &buf, options, testViewer))
+	require.Equal(t, `name,total_events,hiatus
+'=1+1,0,false
+"Activist, With Comma",0,false
+Test Activist,0,false
+`, buf.String())
+}
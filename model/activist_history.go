@@ -0,0 +1,237 @@
+package model
+
+import (
+	"database/sql"
+	"fmt"
+	"reflect"
+	"strconv"
+	"time"
+
+	"github.com/go-sql-driver/mysql"
+	"github.com/jmoiron/sqlx"
+	"github.com/pkg/errors"
+)
+
+/** Constant and Variable Definitions */
+
+const (
+	ActivistChangeCreate  = "create"
+	ActivistChangeUpdate  = "update"
+	ActivistChangeHide    = "hide"
+	ActivistChangeMerge   = "merge"
+	ActivistChangeUnmerge = "unmerge"
+)
+
+const ActivistChangeTimeLayout = "2006-01-02 15:04:05"
+
+// Columns in ActivistExtra that diffActivists skips. Most are computed
+// by selectActivistExtraBaseQuery rather than stored on the activists
+// row; mpi is recomputed from attendance by updateActivistMPI; hidden
+// is recorded explicitly by HideActivist and MergeActivist; version
+// changes on every save.
+var activistComputedColumns = map[string]struct{}{
+	"hidden":             struct{}{},
+	"working_group_list": struct{}{},
+	"circles_list":       struct{}{},
+	"last_connection":    struct{}{},
+	"mpp_requirements":   struct{}{},
+	"mpi":                struct{}{},
+	"version":            struct{}{},
+}
+
+/** Type Definitions */
+
+type ActivistChange struct {
+	ID         int            `db:"id"`
+	ActivistID int            `db:"activist_id"`
+	UserID     int            `db:"user_id"`
+	UserEmail  string         `db:"user_email"`
+	ChangedAt  time.Time      `db:"changed_at"`
+	Action     string         `db:"action"`
+	Field      string         `db:"field"`
+	OldValue   sql.NullString `db:"old_value"`
+	NewValue   sql.NullString `db:"new_value"`
+}
+
+type ActivistChangeJSON struct {
+	ID         int    `json:"id"`
+	ActivistID int    `json:"activist_id"`
+	UserID     int    `json:"user_id"`
+	UserEmail  string `json:"user_email"`
+	ChangedAt  string `json:"changed_at"`
+	Action     string `json:"action"`
+	Field      string `json:"field"`
+	OldValue   string `json:"old_value"`
+	NewValue   string `json:"new_value"`
+}
+
+type activistFieldChange struct {
+	Field    string
+	OldValue sql.NullString
+	NewValue sql.NullString
+}
+
+/** Functions and Methods */
+
+// GetActivistChangesJSON returns the history of an activist as it's
+// sent to viewer. The sensitive fields viewer can't see are masked in
+// both the old and new values.
+func GetActivistChangesJSON(db *sqlx.DB, activistID int, viewer ADBUser) ([]ActivistChangeJSON, error) {
+	changes, err := GetActivistChanges(db, activistID)
+	if err != nil {
+		return nil, err
+	}
+
+	redaction := activistRedactionFor(viewer)
+	sentPrivate := false
+	changesJSON := make([]ActivistChangeJSON, 0, len(changes))
+	for _, c := range changes {
+		if !redaction.Private && (c.Field == "dob" || c.Field == "notes") && (c.OldValue.Valid || c.NewValue.Valid) {
+			sentPrivate = true
+		}
+		changesJSON = append(changesJSON, ActivistChangeJSON{
+			ID:         c.ID,
+			ActivistID: c.ActivistID,
+			UserID:     c.UserID,
+			UserEmail:  c.UserEmail,
+			ChangedAt:  c.ChangedAt.Format(ActivistChangeTimeLayout),
+			Action:     c.Action,
+			Field:      c.Field,
+			OldValue:   redaction.column(c.Field, c.OldValue.String),
+			NewValue:   redaction.column(c.Field, c.NewValue.String),
+		})
+	}
+
+	if sentPrivate {
+		if err := recordPrivateActivistAccess(db, viewer, []int{activistID}); err != nil {
+			return nil, err
+		}
+	}
+	return changesJSON, nil
+}
+
+// GetActivistChanges returns the history of an activist, newest
+// change first.
+func GetActivistChanges(db *sqlx.DB, activistID int) ([]ActivistChange, error) {
+	if activistID == 0 {
+		return nil, errors.New("GetActivistChanges: activistID cannot be 0")
+	}
+
+	var changes []ActivistChange
+	err := db.Select(&changes, `
+SELECT id, activist_id, user_id, user_email, changed_at, action, field, old_value, new_value
+FROM activist_changes
+WHERE activist_id = ?
+ORDER BY changed_at DESC, id DESC`, activistID)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to get changes for activist %d", activistID)
+	}
+	return changes, nil
+}
+
+// recordActivistChanges records every field that differs between
+// before and after. Pass a zero-value before for newly created
+// activists.
+func recordActivistChanges(tx *sqlx.Tx, activistID int, user ADBUser, action string, before, after ActivistExtra) error {
+	for _, c := range diffActivists(before, after) {
+		if err := recordActivistChange(tx, activistID, user, action, c); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func recordActivistChange(tx *sqlx.Tx, activistID int, user ADBUser, action string, change activistFieldChange) error {
+	_, err := tx.Exec(`
+INSERT INTO activist_changes (activist_id, user_id, user_email, action, field, old_value, new_value)
+VALUES (?, ?, ?, ?, ?, ?, ?)`,
+		activistID, user.ID, user.Email, action, change.Field, change.OldValue, change.NewValue)
+	return errors.Wrapf(err, "failed to record change to %s for activist %d", change.Field, activistID)
+}
+
+// diffActivists compares every stored column of two activists and
+// returns the ones that changed, in struct order.
+func diffActivists(before, after ActivistExtra) []activistFieldChange {
+	beforeValues := activistColumnValues(before)
+	afterValues := activistColumnValues(after)
+
+	var changes []activistFieldChange
+	for i := range afterValues {
+		if beforeValues[i].value != afterValues[i].value {
+			changes = append(changes, activistFieldChange{
+				Field:    afterValues[i].column,
+				OldValue: beforeValues[i].value,
+				NewValue: afterValues[i].value,
+			})
+		}
+	}
+	return changes
+}
+
+type activistColumnValue struct {
+	column string
+	value  sql.NullString
+}
+
+func activistColumnValues(a ActivistExtra) []activistColumnValue {
+	var values []activistColumnValue
+	for _, v := range []reflect.Value{
+		reflect.ValueOf(a.Activist),
+		reflect.ValueOf(a.ActivistMembershipData),
+		reflect.ValueOf(a.ActivistConnectionData),
+	} {
+		t := v.Type()
+		for i := 0; i < t.NumField(); i++ {
+			column := t.Field(i).Tag.Get("db")
+			if column == "" || column == "id" {
+				continue
+			}
+			if _, ok := activistComputedColumns[column]; ok {
+				continue
+			}
+			values = append(values, activistColumnValue{
+				column: column,
+				value:  formatActivistValue(v.Field(i).Interface()),
+			})
+		}
+	}
+	return values
+}
+
+// formatActivistValue converts a column value to the string stored in
+// activist_changes. Empty and NULL values are both stored as NULL so
+// that, e.g., clearing a NULL training date isn't recorded as a
+// change. Types without a format of their own are formatted with
+// fmt.Sprint.
+func formatActivistValue(value interface{}) sql.NullString {
+	var s string
+	switch v := value.(type) {
+	case nil:
+	case string:
+		s = v
+	case bool:
+		s = strconv.FormatBool(v)
+	case int:
+		s = strconv.Itoa(v)
+	case sql.NullString:
+		s = v.String
+	case mysql.NullTime:
+		if v.Valid {
+			s = v.Time.Format(EventDateLayout)
+		}
+	default:
+		s = fmt.Sprint(v)
+	}
+	if s == "" {
+		return sql.NullString{}
+	}
+	return sql.NullString{String: s, Valid: true}
+}
+
+func getActivistExtraTx(tx *sqlx.Tx, activistID int) (ActivistExtra, error) {
+	var activist ActivistExtra
+	if err := tx.Get(&activist, selectActivistExtraBaseQuery+" WHERE a.id = ?", activistID); err != nil {
+		return ActivistExtra{}, errors.Wrapf(err, "failed to get activist %d", activistID)
+	}
+	return activist, nil
+}
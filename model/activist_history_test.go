@@ -0,0 +1,153 @@
+package model
+
+import (
+	"database/sql"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestActivistChanges(t *testing.T) {
+	db := newTestDB()
+	defer db.Close()
+
+	user := ADBUser{ID: 1, Email: "test@test.com"}
+
+	a1, err := GetOrCreateActivist(db, "Test Activist", user)
+	require.NoError(t, err)
+
+	activist, err := GetActivistsExtra(db, GetActivistOptions{ID: a1.ID})
+	require.NoError(t, err)
+	require.Len(t, activist, 1)
+	activist[0].Email = "test@example.com"
+	activist[0].Location = sql.NullString{String: "Berkeley", Valid: true}
+	_, err = UpdateActivistData(db, activist[0], user)
+	require.NoError(t, err)
+
+	// Saving without changes shouldn't record anything.
+	activist[0].Version++
+	_, err = UpdateActivistData(db, activist[0], user)
+	require.NoError(t, err)
+
+	require.NoError(t, HideActivist(db, a1.ID, user))
+
+	changes, err := GetActivistChanges(db, a1.ID)
+	require.NoError(t, err)
+
+	type change struct {
+		action, field, old, new string
+	}
+	var got []change
+	for _, c := range changes {
+		require.Equal(t, 1, c.UserID)
+		require.Equal(t, "test@test.com", c.UserEmail)
+		got = append(got, change{c.Action, c.Field, c.OldValue.String, c.NewValue.String})
+	}
+
+	// Newest change first.
+	require.Equal(t, []change{
+		{ActivistChangeHide, "hidden", "false", "true"},
+		{ActivistChangeUpdate, "location", "", "Berkeley"},
+		{ActivistChangeUpdate, "email", "", "test@example.com"},
+		{ActivistChangeCreate, "name", "", "Test Activist"},
+	}, got)
+}
+
+func TestActivistChangesJSON_redaction(t *testing.T) {
+	db := newTestDB()
+	defer db.Close()
+
+	a, err := GetOrCreateActivist(db, "Test Activist", ADBUser{})
+	require.NoError(t, err)
+	activist, err := GetActivistsExtra(db, GetActivistOptions{ID: a.ID})
+	require.NoError(t, err)
+	activist[0].Email = "test@example.com"
+	activist[0].Notes = sql.NullString{String: "Some notes", Valid: true}
+	_, err = UpdateActivistData(db, activist[0], testViewer)
+	require.NoError(t, err)
+
+	values := func(changes []ActivistChangeJSON) map[string]string {
+		got := map[string]string{}
+		for _, c := range changes {
+			got[c.Field] = c.NewValue
+		}
+		return got
+	}
+	countAccesses := func(userEmail string) int {
+		var count int
+		err := db.Get(&count, `
+SELECT COUNT(*)
+FROM activist_private_accesses pa
+JOIN activist_private_access_activists paa ON paa.access_id = pa.id
+WHERE pa.user_email = ? AND paa.activist_id = ?`, userEmail, a.ID)
+		require.NoError(t, err)
+		return count
+	}
+
+	viewer := testViewer
+	viewer.Email = "viewer@example.com"
+	changes, err := GetActivistChangesJSON(db, a.ID, viewer)
+	require.NoError(t, err)
+	require.Equal(t, "test@example.com", values(changes)["email"])
+	require.Equal(t, "Some notes", values(changes)["notes"])
+	require.Equal(t, 1, countAccesses(viewer.Email))
+
+	// Users who can't see contact details or private fields get them
+	// masked, and nothing private is recorded as accessed.
+	changes, err = GetActivistChangesJSON(db, a.ID, ADBUser{Email: "other@example.com"})
+	require.NoError(t, err)
+	require.Equal(t, "t***@example.com", values(changes)["email"])
+	require.Equal(t, redactedPlaceholder, values(changes)["notes"])
+	require.Equal(t, 0, countAccesses("other@example.com"))
+}
+
+func TestActivistChanges_merge(t *testing.T) {
+	db := newTestDB()
+	defer db.Close()
+
+	a1, err := GetOrCreateActivist(db, "Original", ADBUser{})
+	require.NoError(t, err)
+	a2, err := GetOrCreateActivist(db, "Target", ADBUser{})
+	require.NoError(t, err)
+
+	require.NoError(t, MergeActivist(db, a1.ID, a2.ID, ADBUser{}))
+
+	changes, err := GetActivistChanges(db, a1.ID)
+	require.NoError(t, err)
+	fields := map[string]string{}
+	for _, c := range changes {
+		if c.Action == ActivistChangeMerge {
+			fields[c.Field] = c.NewValue.String
+		}
+	}
+	require.Equal(t, "true", fields["hidden"])
+	require.Equal(t, formatActivistValue(a2.ID).String, fields["merged_into"])
+	require.Contains(t, fields, "name")
+}
+
+func TestDiffActivists(t *testing.T) {
+	before := ActivistExtra{Activist: Activist{Name: "A", Email: "a@example.com"}}
+	after := before
+	after.Email = "b@example.com"
+	after.Phone = "555-5555"
+	after.Hidden = true                // recorded separately, so not diffed
+	after.Version = before.Version + 1 // bumped by every save, so not diffed
+
+	require.Equal(t, []activistFieldChange{{
+		Field:    "email",
+		OldValue: sql.NullString{String: "a@example.com", Valid: true},
+		NewValue: sql.NullString{String: "b@example.com", Valid: true},
+	}, {
+		Field:    "phone",
+		NewValue: sql.NullString{String: "555-5555", Valid: true},
+	}}, diffActivists(before, after))
+}
+
+func TestFormatActivistValue(t *testing.T) {
+	require.Equal(t, sql.NullString{String: "true", Valid: true}, formatActivistValue(true))
+	require.Equal(t, sql.NullString{String: "7", Valid: true}, formatActivistValue(7))
+	require.Equal(t, sql.NullString{}, formatActivistValue(""))
+	require.Equal(t, sql.NullString{}, formatActivistValue(nil))
+	// Other types don't panic.
+	require.Equal(t, sql.NullString{String: "1.5", Valid: true}, formatActivistValue(1.5))
+}
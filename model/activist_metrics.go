@@ -0,0 +1,180 @@
+package model
+
+import (
+	"fmt"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/pkg/errors"
+)
+
+/** Constant and Variable Definitions */
+
+// updateActivistMetricsQuery recomputes activist_metrics, except for
+// the MPP requirements, which are set by updateActivistMPI. Circles
+// are community events, so last_circle is the last of those. Both %s
+// are replaced with the same condition on activist ids, or nothing to
+// recompute every activist.
+const updateActivistMetricsQuery string = `
+INSERT INTO activist_metrics (
+  activist_id,
+  first_event,
+  last_event,
+  last_circle,
+  last_connection,
+  first_event_name,
+  last_event_name,
+  total_events,
+  total_points,
+  computed_on
+)
+SELECT
+  a.id,
+  m.first_event,
+  m.last_event,
+  m.last_circle,
+  (SELECT MAX(c.date) FROM connections c WHERE c.connectee_id = a.id AND c.deleted_at IS NULL) AS last_connection,
+  IFNULL(concat(m.first_event, ' ', (
+    SELECT e.name
+    FROM events e
+    JOIN event_attendance ea ON ea.event_id = e.id
+    WHERE e.date = m.first_event AND ea.activist_id = a.id AND e.deleted_at IS NULL
+    LIMIT 1)), '') AS first_event_name,
+  IFNULL(concat(m.last_event, ' ', (
+    SELECT e.name
+    FROM events e
+    JOIN event_attendance ea ON ea.event_id = e.id
+    WHERE e.date = m.last_event AND ea.activist_id = a.id AND e.deleted_at IS NULL
+    LIMIT 1)), '') AS last_event_name,
+  IFNULL(m.total_events, 0),
+  IFNULL(m.total_points, 0),
+  CURDATE()
+FROM activists a
+LEFT JOIN (
+  SELECT
+    ea.activist_id,
+    min(e.date) AS first_event,
+    max(e.date) AS last_event,
+    max(IF(t.category = 'community', e.date, NULL)) AS last_circle,
+    COUNT(DISTINCT ea.event_id) AS total_events,
+    SUM(e.date BETWEEN (NOW() - INTERVAL 30 DAY) AND NOW()) AS total_points
+  FROM event_attendance ea
+  JOIN events e ON e.id = ea.event_id AND e.deleted_at IS NULL
+  JOIN event_types t ON t.name = e.event_type
+  %s
+  GROUP BY ea.activist_id
+) m ON m.activist_id = a.id
+%s
+ON DUPLICATE KEY UPDATE
+  first_event = VALUES(first_event),
+  last_event = VALUES(last_event),
+  last_circle = VALUES(last_circle),
+  last_connection = VALUES(last_connection),
+  first_event_name = VALUES(first_event_name),
+  last_event_name = VALUES(last_event_name),
+  total_events = VALUES(total_events),
+  total_points = VALUES(total_points),
+  computed_on = VALUES(computed_on)
+`
+
+// activistMetricsBatchSize is how many activists are recomputed by a
+// single query when refreshing stale metrics.
+const activistMetricsBatchSize = 1000
+
+/** Functions and Methods */
+
+// updateActivistMetrics recomputes the metrics and MPI flag of the
+// given activists. It must be called in the same transaction as any
+// change to their attendance or connections.
+func updateActivistMetrics(e sqlx.Ext, activistIDs []int) error {
+	if len(activistIDs) == 0 {
+		return nil
+	}
+	query := fmt.Sprintf(updateActivistMetricsQuery, "WHERE ea.activist_id IN (?)", "WHERE a.id IN (?)")
+	query, args, err := sqlx.In(query, activistIDs, activistIDs)
+	if err != nil {
+		return errors.Wrap(err, "failed to build activist metrics query")
+	}
+	if _, err := e.Exec(query, args...); err != nil {
+		return errors.Wrapf(err, "failed to update metrics for activists %v", activistIDs)
+	}
+	return updateActivistMPI(e, activistIDs)
+}
+
+// updateEventActivistMetrics recomputes the metrics of everyone who
+// attends eventID, and of extraActivistIDs (for example, attendees who
+// were just removed from it).
+func updateEventActivistMetrics(tx *sqlx.Tx, eventID int, extraActivistIDs []int) error {
+	var activistIDs []int
+	err := tx.Select(&activistIDs, `SELECT activist_id FROM event_attendance WHERE event_id = ?`, eventID)
+	if err != nil {
+		return errors.Wrapf(err, "failed to get attendees of event %d", eventID)
+	}
+	return updateActivistMetrics(tx, append(activistIDs, extraActivistIDs...))
+}
+
+// RebuildActivistMetrics recomputes the metrics and MPI flag of every
+// activist from scratch.
+func RebuildActivistMetrics(db *sqlx.DB) error {
+	tx, err := db.Beginx()
+	if err != nil {
+		return errors.Wrap(err, "failed to create transaction")
+	}
+	_, err = tx.Exec(`DELETE FROM activist_metrics WHERE activist_id NOT IN (SELECT id FROM activists)`)
+	if err != nil {
+		tx.Rollback()
+		return errors.Wrap(err, "failed to delete old activist metrics")
+	}
+	_, err = tx.Exec(fmt.Sprintf(updateActivistMetricsQuery, "", ""))
+	if err != nil {
+		tx.Rollback()
+		return errors.Wrap(err, "failed to rebuild activist metrics")
+	}
+	var activistIDs []int
+	if err := tx.Select(&activistIDs, `SELECT id FROM activists`); err != nil {
+		tx.Rollback()
+		return errors.Wrap(err, "failed to get activists")
+	}
+	for start := 0; start < len(activistIDs); start += activistMetricsBatchSize {
+		end := start + activistMetricsBatchSize
+		if end > len(activistIDs) {
+			end = len(activistIDs)
+		}
+		if err := updateActivistMPI(tx, activistIDs[start:end]); err != nil {
+			tx.Rollback()
+			return err
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		tx.Rollback()
+		return errors.Wrap(err, "failed to commit activist metrics")
+	}
+	return nil
+}
+
+// RefreshStaleActivistMetrics recomputes the metrics of activists that
+// haven't been recomputed today. Points, MPP requirements, and the MPI
+// depend on the date, so they go stale even when attendance doesn't
+// change.
+// Returns the number of activists refreshed.
+func RefreshStaleActivistMetrics(db *sqlx.DB) (int, error) {
+	var activistIDs []int
+	err := db.Select(&activistIDs, `
+SELECT a.id
+FROM activists a
+LEFT JOIN activist_metrics m ON m.activist_id = a.id
+WHERE m.computed_on IS NULL OR m.computed_on < CURDATE()`)
+	if err != nil {
+		return 0, errors.Wrap(err, "failed to get stale activist metrics")
+	}
+
+	for start := 0; start < len(activistIDs); start += activistMetricsBatchSize {
+		end := start + activistMetricsBatchSize
+		if end > len(activistIDs) {
+			end = len(activistIDs)
+		}
+		if err := updateActivistMetrics(db, activistIDs[start:end]); err != nil {
+			return start, err
+		}
+	}
+	return len(activistIDs), nil
+}
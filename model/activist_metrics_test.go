@@ -0,0 +1,107 @@
+package model
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestActivistMetrics(t *testing.T) {
+	db := newTestDB()
+	defer db.Close()
+
+	a1, err := GetOrCreateActivist(db, "Test Activist", ADBUser{})
+	require.NoError(t, err)
+	a2, err := GetOrCreateActivist(db, "Other Activist", ADBUser{})
+	require.NoError(t, err)
+
+	now := time.Now()
+	yesterday := now.AddDate(0, 0, -1)
+	lastYear := now.AddDate(-1, 0, 0)
+	_, err = InsertUpdateEvent(db, Event{
+		EventName:      "Old Event",
+		EventDate:      lastYear,
+		EventType:      "Action",
+		AddedAttendees: []Activist{a1},
+	})
+	require.NoError(t, err)
+	eventID, err := InsertUpdateEvent(db, Event{
+		EventName:      "New Event",
+		EventDate:      yesterday,
+		EventType:      "Circle",
+		AddedAttendees: []Activist{a1, a2},
+	})
+	require.NoError(t, err)
+
+	a, err := GetActivistJSON(db, GetActivistOptions{ID: a1.ID}, testViewer)
+	require.NoError(t, err)
+	require.Equal(t, 2, a.TotalEvents)
+	require.Equal(t, 1, a.TotalPoints)
+	require.Equal(t, lastYear.Format(EventDateLayout), a.FirstEvent)
+	require.Equal(t, yesterday.Format(EventDateLayout), a.LastEvent)
+	require.Equal(t, yesterday.Format(EventDateLayout)+" New Event", a.LastEventName)
+	require.Equal(t, yesterday.Format(EventDateLayout), a.LastCircle)
+
+	// Removing an attendee updates their metrics.
+	_, err = InsertUpdateEvent(db, Event{
+		ID:               eventID,
+		Version:          1,
+		EventName:        "New Event",
+		EventDate:        yesterday,
+		EventType:        "Circle",
+		DeletedAttendees: []Activist{a2},
+	})
+	require.NoError(t, err)
+	a, err = GetActivistJSON(db, GetActivistOptions{ID: a2.ID}, testViewer)
+	require.NoError(t, err)
+	require.Equal(t, 0, a.TotalEvents)
+	require.Equal(t, "", a.LastEvent)
+
+	// So does deleting an event.
+	require.NoError(t, DeleteEvent(db, eventID, ADBUser{}))
+	a, err = GetActivistJSON(db, GetActivistOptions{ID: a1.ID}, testViewer)
+	require.NoError(t, err)
+	require.Equal(t, 1, a.TotalEvents)
+	require.Equal(t, 0, a.TotalPoints)
+	require.Equal(t, lastYear.Format(EventDateLayout), a.LastEvent)
+	require.Equal(t, "", a.LastCircle)
+
+	// A rebuild gives the same result.
+	require.NoError(t, RebuildActivistMetrics(db))
+	rebuilt, err := GetActivistJSON(db, GetActivistOptions{ID: a1.ID}, testViewer)
+	require.NoError(t, err)
+	require.Equal(t, a, rebuilt)
+
+	// Nothing is stale right after a rebuild.
+	count, err := RefreshStaleActivistMetrics(db)
+	require.NoError(t, err)
+	require.Equal(t, 0, count)
+}
+
+func TestActivistMetrics_merge(t *testing.T) {
+	db := newTestDB()
+	defer db.Close()
+
+	original, err := GetOrCreateActivist(db, "Original Activist", ADBUser{})
+	require.NoError(t, err)
+	target, err := GetOrCreateActivist(db, "Target Activist", ADBUser{})
+	require.NoError(t, err)
+	_, err = InsertUpdateEvent(db, Event{
+		EventName:      "Event",
+		EventDate:      time.Now(),
+		EventType:      "Action",
+		AddedAttendees: []Activist{original},
+	})
+	require.NoError(t, err)
+
+	require.NoError(t, MergeActivist(db, original.ID, target.ID, ADBUser{}))
+	a, err := GetActivistJSON(db, GetActivistOptions{ID: target.ID}, testViewer)
+	require.NoError(t, err)
+	require.Equal(t, 1, a.TotalEvents)
+
+	require.NoError(t, UnmergeActivist(db, original.ID, ADBUser{}))
+	a, err = GetActivistJSON(db, GetActivistOptions{ID: target.ID}, testViewer)
+	require.NoError(t, err)
+	require.Equal(t, 0, a.TotalEvents)
+}
@@ -0,0 +1,272 @@
+package model
+
+import (
+	"database/sql"
+	"strings"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/pkg/errors"
+)
+
+/** Constant and Variable Definitions */
+
+// redactedPlaceholder replaces the sensitive fields that are left out,
+// so that it's clear they aren't empty, and so that saving it back
+// can be told apart from clearing the field.
+const redactedPlaceholder = "[hidden]"
+
+// privateAccessBatchSize is how many activists are added to the
+// private access log per insert.
+const privateAccessBatchSize = 500
+
+/** Type Definitions */
+
+// activistRedaction is which sensitive activist fields are withheld
+// from whoever activists are being built for.
+type activistRedaction struct {
+	// Contact masks email and phone, and hides location and
+	// Facebook. Masked email and phone still show whether the
+	// activist has one.
+	Contact bool
+	// Private hides date of birth and notes.
+	Private bool
+}
+
+// storedSensitiveFields are the sensitive fields of an activist as
+// they're stored.
+type storedSensitiveFields struct {
+	Email    string         `db:"email"`
+	Phone    string         `db:"phone"`
+	Location sql.NullString `db:"location"`
+	Facebook string         `db:"facebook"`
+	Birthday sql.NullString `db:"dob"`
+	Notes    sql.NullString `db:"notes"`
+}
+
+/** Functions and Methods */
+
+func activistRedactionFor(viewer ADBUser) activistRedaction {
+	return activistRedaction{
+		Contact: !viewer.HasPermission(PermissionActivistReadContact),
+		Private: !viewer.HasPermission(PermissionActivistReadPrivate),
+	}
+}
+
+// activistListRedactionFor is activistRedactionFor for the activists
+// selected by options. Point people see the contact details of the
+// members of the groups they lead.
+func activistListRedactionFor(viewer ADBUser, options GetActivistOptions) activistRedaction {
+	redaction := activistRedactionFor(viewer)
+	if options.LedBy != 0 && options.LedBy == viewer.ActivistID && viewer.HasPermission(PermissionActivistReadLed) {
+		redaction.Contact = false
+	}
+	return redaction
+}
+
+func (r activistRedaction) apply(a ActivistJSON) ActivistJSON {
+	if r.Contact {
+		a.Email = maskEmail(a.Email)
+		a.Phone = maskPhone(a.Phone)
+		a.Location = hideValue(a.Location)
+		a.Facebook = hideValue(a.Facebook)
+	}
+	if r.Private {
+		a.Birthday = hideValue(a.Birthday)
+		a.Notes = hideValue(a.Notes)
+	}
+	return a
+}
+
+// column redacts value, as stored in the activists column of the same
+// name, such as an old or new value in an activist's history.
+func (r activistRedaction) column(column, value string) string {
+	switch column {
+	case "email":
+		if r.Contact {
+			return maskEmail(value)
+		}
+	case "phone":
+		if r.Contact {
+			return maskPhone(value)
+		}
+	case "location", "facebook":
+		if r.Contact {
+			return hideValue(value)
+		}
+	case "dob", "notes":
+		if r.Private {
+			return hideValue(value)
+		}
+	}
+	return value
+}
+
+// hideValue replaces a non-empty value with redactedPlaceholder.
+func hideValue(value string) string {
+	if value == "" {
+		return ""
+	}
+	return redactedPlaceholder
+}
+
+// maskEmail hides all of an email address but its first letter and
+// domain.
+func maskEmail(email string) string {
+	if email == "" {
+		return ""
+	}
+	at := strings.LastIndex(email, "@")
+	if at < 1 {
+		return "***"
+	}
+	return email[:1] + "***" + email[at:]
+}
+
+// maskPhone hides all of a phone number's digits but the last four.
+func maskPhone(phone string) string {
+	digits := 0
+	for _, c := range phone {
+		if c >= '0' && c <= '9' {
+			digits++
+		}
+	}
+	var masked strings.Builder
+	for _, c := range phone {
+		if c >= '0' && c <= '9' && digits > 4 {
+			masked.WriteRune('*')
+			digits--
+			continue
+		}
+		if c >= '0' && c <= '9' {
+			digits--
+		}
+		masked.WriteRune(c)
+	}
+	return masked.String()
+}
+
+// privateActivistIDs returns the IDs of the activists whose date of
+// birth or notes were sent without redaction.
+func privateActivistIDs(activists []ActivistJSON, redaction activistRedaction) []int {
+	if redaction.Private {
+		return nil
+	}
+	var ids []int
+	for _, a := range activists {
+		if a.Birthday != "" || a.Notes != "" {
+			ids = append(ids, a.ID)
+		}
+	}
+	return ids
+}
+
+// recordPrivateActivistAccess adds to the audit log that viewer was
+// sent the date of birth or notes of activistIDs.
+func recordPrivateActivistAccess(db *sqlx.DB, viewer ADBUser, activistIDs []int) error {
+	if len(activistIDs) == 0 {
+		return nil
+	}
+
+	tx, err := db.Beginx()
+	if err != nil {
+		return errors.Wrap(err, "failed to create transaction")
+	}
+	res, err := tx.Exec(`
+INSERT INTO activist_private_accesses (user_id, user_email) VALUES (?, ?)`, viewer.ID, viewer.Email)
+	if err != nil {
+		tx.Rollback()
+		return errors.Wrap(err, "failed to log private activist access")
+	}
+	accessID, err := res.LastInsertId()
+	if err != nil {
+		tx.Rollback()
+		return errors.Wrap(err, "failed to log private activist access")
+	}
+
+	for start := 0; start < len(activistIDs); start += privateAccessBatchSize {
+		end := start + privateAccessBatchSize
+		if end > len(activistIDs) {
+			end = len(activistIDs)
+		}
+		values := make([]string, 0, end-start)
+		args := make([]interface{}, 0, 2*(end-start))
+		for _, id := range activistIDs[start:end] {
+			values = append(values, "(?, ?)")
+			args = append(args, accessID, id)
+		}
+		_, err := tx.Exec(`
+INSERT IGNORE INTO activist_private_access_activists (access_id, activist_id)
+VALUES `+strings.Join(values, ", "), args...)
+		if err != nil {
+			tx.Rollback()
+			return errors.Wrap(err, "failed to log private activist access")
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		tx.Rollback()
+		return errors.Wrap(err, "failed to commit private activist access")
+	}
+	return nil
+}
+
+// RestoreRedactedActivistFields puts back the fields of an existing
+// activist that were withheld from viewer, so that saving the redacted
+// copy they were sent doesn't overwrite them. A withheld field can't be
+// changed: it's an error unless the viewer sent back exactly what they
+// were sent.
+func RestoreRedactedActivistFields(db *sqlx.DB, activist ActivistExtra, viewer ADBUser) (ActivistExtra, error) {
+	redaction := activistRedactionFor(viewer)
+	if activist.ID == 0 || (!redaction.Contact && !redaction.Private) {
+		return activist, nil
+	}
+
+	var stored storedSensitiveFields
+	err := db.Get(&stored, `
+SELECT email, phone, location, facebook, dob, notes
+FROM activists
+WHERE id = ?`, activist.ID)
+	if err != nil {
+		return ActivistExtra{}, errors.Wrapf(err, "failed to get activist %d", activist.ID)
+	}
+
+	if redaction.Contact {
+		if err := checkRedactedValue("email", activist.Email, stored.Email, maskEmail(stored.Email)); err != nil {
+			return ActivistExtra{}, err
+		}
+		if err := checkRedactedValue("phone", activist.Phone, stored.Phone, maskPhone(stored.Phone)); err != nil {
+			return ActivistExtra{}, err
+		}
+		if err := checkRedactedValue("location", activist.Location.String, stored.Location.String, hideValue(stored.Location.String)); err != nil {
+			return ActivistExtra{}, err
+		}
+		if err := checkRedactedValue("Facebook", activist.Facebook, stored.Facebook, hideValue(stored.Facebook)); err != nil {
+			return ActivistExtra{}, err
+		}
+		activist.Email = stored.Email
+		activist.Phone = stored.Phone
+		activist.Location = stored.Location
+		activist.Facebook = stored.Facebook
+	}
+	if redaction.Private {
+		if err := checkRedactedValue("date of birth", activist.Birthday.String, stored.Birthday.String, hideValue(stored.Birthday.String)); err != nil {
+			return ActivistExtra{}, err
+		}
+		if err := checkRedactedValue("notes", activist.Notes.String, stored.Notes.String, hideValue(stored.Notes.String)); err != nil {
+			return ActivistExtra{}, err
+		}
+		activist.Birthday = stored.Birthday
+		activist.Notes = stored.Notes
+	}
+	return activist, nil
+}
+
+// checkRedactedValue returns an error if a field that was withheld as
+// redacted was changed to anything other than redacted or its stored
+// value.
+func checkRedactedValue(field, sent, stored, redacted string) error {
+	if sent == redacted || sent == stored {
+		return nil
+	}
+	return errors.Errorf("You can't change %s, because you don't have permission to see it", field)
+}
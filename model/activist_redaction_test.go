@@ -0,0 +1,207 @@
+package model
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// testViewer can see every activist field.
+var testViewer = ADBUser{
+	Name: "Test Viewer",
+	Permissions: map[string]bool{
+		PermissionActivistReadContact: true,
+		PermissionActivistReadPrivate: true,
+	},
+}
+
+func TestMaskEmail(t *testing.T) {
+	require.Equal(t, "", maskEmail(""))
+	require.Equal(t, "j***@example.com", maskEmail("jane@example.com"))
+	require.Equal(t, "***", maskEmail("not an email"))
+	require.Equal(t, "***", maskEmail("@example.com"))
+}
+
+func TestMaskPhone(t *testing.T) {
+	require.Equal(t, "", maskPhone(""))
+	require.Equal(t, "(***) ***-1234", maskPhone("(510) 555-1234"))
+	require.Equal(t, "+*-***-***-1234", maskPhone("+1-510-555-1234"))
+	require.Equal(t, "123", maskPhone("123"))
+}
+
+func TestActivistRedaction(t *testing.T) {
+	a := ActivistJSON{
+		ID:       1,
+		Name:     "Jane",
+		Email:    "jane@example.com",
+		Phone:    "510-555-1234",
+		Location: "Berkeley",
+		Facebook: "jane.doe",
+		Birthday: "1990-01-01",
+		Notes:    "Some notes",
+	}
+
+	require.Equal(t, a, activistRedactionFor(testViewer).apply(a))
+
+	redaction := activistRedactionFor(ADBUser{})
+	redacted := redaction.apply(a)
+	require.Equal(t, ActivistJSON{
+		ID:       1,
+		Name:     "Jane",
+		Email:    "j***@example.com",
+		Phone:    "***-***-1234",
+		Location: redactedPlaceholder,
+		Facebook: redactedPlaceholder,
+		Birthday: redactedPlaceholder,
+		Notes:    redactedPlaceholder,
+	}, redacted)
+	// Empty fields stay empty.
+	require.Equal(t, "", redaction.apply(ActivistJSON{}).Notes)
+
+	require.Empty(t, privateActivistIDs([]ActivistJSON{redacted}, redaction))
+	require.Equal(t, []int{1}, privateActivistIDs([]ActivistJSON{a}, activistRedactionFor(testViewer)))
+}
+
+func TestActivistRedaction_column(t *testing.T) {
+	redaction := activistRedactionFor(ADBUser{})
+	require.Equal(t, "j***@example.com", redaction.column("email", "jane@example.com"))
+	require.Equal(t, "***-***-1234", redaction.column("phone", "510-555-1234"))
+	require.Equal(t, redactedPlaceholder, redaction.column("facebook", "jane.doe"))
+	require.Equal(t, redactedPlaceholder, redaction.column("dob", "1990-01-01"))
+	require.Equal(t, "", redaction.column("notes", ""))
+	require.Equal(t, "Jane", redaction.column("name", "Jane"))
+
+	require.Equal(t, "jane@example.com", activistRedactionFor(testViewer).column("email", "jane@example.com"))
+}
+
+func TestRestoreRedactedActivistFields(t *testing.T) {
+	db := newTestDB()
+	defer db.Close()
+
+	a, err := GetOrCreateActivist(db, "Redacted Activist", ADBUser{})
+	require.NoError(t, err)
+	stored, err := GetActivistsExtra(db, GetActivistOptions{ID: a.ID})
+	require.NoError(t, err)
+	stored[0].Email = "jane@example.com"
+	stored[0].Phone = "510-555-1234"
+	stored[0].Birthday.String, stored[0].Birthday.Valid = "1990-01-01", true
+	stored[0].Notes.String, stored[0].Notes.Valid = "Some notes", true
+	_, err = UpdateActivistData(db, stored[0], testViewer)
+	require.NoError(t, err)
+
+	// Saving the redacted copy someone was sent keeps what they
+	// couldn't see.
+	viewer := ADBUser{Permissions: map[string]bool{PermissionActivistWriteContact: true}}
+	sent, err := GetActivistJSON(db, GetActivistOptions{ID: a.ID}, viewer)
+	require.NoError(t, err)
+	require.Equal(t, "j***@example.com", sent.Email)
+	require.Equal(t, redactedPlaceholder, sent.Notes)
+	sent.ActivistLevel = "Organizer"
+	cleaned, err := cleanActivistJSON(sent)
+	require.NoError(t, err)
+	restored, err := RestoreRedactedActivistFields(db, cleaned, viewer)
+	require.NoError(t, err)
+	_, err = UpdateActivistData(db, restored, viewer)
+	require.NoError(t, err)
+
+	saved, err := GetActivistJSON(db, GetActivistOptions{ID: a.ID}, testViewer)
+	require.NoError(t, err)
+	require.Equal(t, "Organizer", saved.ActivistLevel)
+	require.Equal(t, "jane@example.com", saved.Email)
+	require.Equal(t, "510-555-1234", saved.Phone)
+	require.Equal(t, "1990-01-01", saved.Birthday)
+	require.Equal(t, "Some notes", saved.Notes)
+
+	// Fields they can't see can't be changed or cleared.
+	for _, change := range []func(*ActivistJSON){
+		func(a *ActivistJSON) { a.Phone = "415-555-0000" },
+		func(a *ActivistJSON) { a.Notes = "" },
+		func(a *ActivistJSON) { a.Birthday = "2000-01-01" },
+	} {
+		changed := sent
+		change(&changed)
+		cleaned, err := cleanActivistJSON(changed)
+		require.NoError(t, err)
+		_, err = RestoreRedactedActivistFields(db, cleaned, viewer)
+		require.Error(t, err)
+	}
+}
+
+func TestActivistListRedaction_ledGroups(t *testing.T) {
+	db := newTestDB()
+	defer db.Close()
+
+	members := insertActivists(t, db, []string{"Led Point Person", "Led Member"})
+	members[0].PointPerson = true
+	_, err := CreateWorkingGroup(db, WorkingGroup{
+		Name:    "Led Group",
+		Type:    working_group_db_value,
+		Members: members,
+	})
+	require.NoError(t, err)
+	stored, err := GetActivistsExtra(db, GetActivistOptions{ID: members[1].ActivistID})
+	require.NoError(t, err)
+	stored[0].Email = "member@example.com"
+	stored[0].Phone = "510-555-1234"
+	stored[0].Location.String, stored[0].Location.Valid = "Berkeley", true
+	_, err = UpdateActivistData(db, stored[0], testViewer)
+	require.NoError(t, err)
+
+	// A point person who can only see the groups they lead gets their
+	// members' contact details, but not their private fields.
+	viewer := ADBUser{
+		ActivistID:  members[0].ActivistID,
+		Permissions: map[string]bool{PermissionActivistReadLed: true},
+	}
+	activists, err := GetActivistsJSON(db, GetActivistOptions{LedBy: viewer.ActivistID}, viewer)
+	require.NoError(t, err)
+	var member ActivistJSON
+	for _, a := range activists {
+		if a.ID == members[1].ActivistID {
+			member = a
+		}
+	}
+	require.Equal(t, "member@example.com", member.Email)
+	require.Equal(t, "510-555-1234", member.Phone)
+	require.Equal(t, "Berkeley", member.Location)
+
+	// Outside of their groups, contact details are still masked.
+	member, err = GetActivistJSON(db, GetActivistOptions{ID: members[1].ActivistID}, viewer)
+	require.NoError(t, err)
+	require.Equal(t, "m***@example.com", member.Email)
+}
+
+func TestRecordPrivateActivistAccess(t *testing.T) {
+	db := newTestDB()
+	defer db.Close()
+
+	a, err := GetOrCreateActivist(db, "Private Activist", ADBUser{})
+	require.NoError(t, err)
+	stored, err := GetActivistsExtra(db, GetActivistOptions{ID: a.ID})
+	require.NoError(t, err)
+	stored[0].Notes.String, stored[0].Notes.Valid = "Some notes", true
+	_, err = UpdateActivistData(db, stored[0], testViewer)
+	require.NoError(t, err)
+
+	countAccesses := func(userEmail string) int {
+		var count int
+		err := db.Get(&count, `
+SELECT COUNT(*)
+FROM activist_private_accesses pa
+JOIN activist_private_access_activists paa ON paa.access_id = pa.id
+WHERE pa.user_email = ? AND paa.activist_id = ?`, userEmail, a.ID)
+		require.NoError(t, err)
+		return count
+	}
+
+	viewer := testViewer
+	viewer.Email = "viewer@example.com"
+	_, err = GetActivistJSON(db, GetActivistOptions{ID: a.ID}, viewer)
+	require.NoError(t, err)
+	require.Equal(t, 1, countAccesses(viewer.Email))
+
+	// Nothing is recorded when the notes were withheld.
+	_, err = GetActivistJSON(db, GetActivistOptions{ID: a.ID}, ADBUser{Email: "other@example.com"})
+	require.NoError(t, err)
+	require.Equal(t, 0, countAccesses("other@example.com"))
+}
@@ -0,0 +1,378 @@
+package model
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/pkg/errors"
+)
+
+/** Constant and Variable Definitions */
+
+const (
+	FilterEqual          = "eq"
+	FilterNotEqual       = "ne"
+	FilterLessThan       = "lt"
+	FilterLessOrEqual    = "lte"
+	FilterGreaterThan    = "gt"
+	FilterGreaterOrEqual = "gte"
+	FilterContains       = "contains"
+	FilterIn             = "in"
+)
+
+const (
+	filterString = iota
+	filterNumber
+	filterDate
+	filterBool
+	// filterMembership fields match activists who belong to a working
+	// group or circle with the given name.
+	filterMembership
+)
+
+const (
+	defaultActivistSearchLimit = 100
+	maxActivistSearchLimit     = 1000
+)
+
+// activistStatusExpression computes the same status as getStatus, so
+// that it can be filtered on.
+const activistStatusExpression = `
+CASE
+  WHEN first_event IS NULL OR last_event IS NULL THEN 'No attendance'
+  WHEN last_event < CURDATE() - INTERVAL 60 DAY THEN 'Former'
+  WHEN first_event > CURDATE() - INTERVAL 90 DAY AND total_events < 5 THEN 'New'
+  ELSE 'Current'
+END`
+
+type activistFilterField struct {
+	// expression is a column of selectActivistExtraBaseQuery, or
+	// an expression over its columns.
+	expression string
+	kind       int
+}
+
+// activistFilterFields are the fields that activists can be filtered
+// on, keyed by the name used in ActivistFilter.
+var activistFilterFields = map[string]activistFilterField{
+	"name":                      {"name", filterString},
+	"email":                     {"email", filterString},
+	"location":                  {"location", filterString},
+	"activist_level":            {"activist_level", filterString},
+	"source":                    {"source", filterString},
+	"status":                    {activistStatusExpression, filterString},
+	"dev_interest":              {"dev_interest", filterString},
+	"hiatus":                    {"hiatus", filterBool},
+	"mpi":                       {"mpi", filterBool},
+	"circle_interest":           {"circle_interest", filterBool},
+	"prospect_organizer":        {"prospect_organizer", filterBool},
+	"prospect_chapter_member":   {"prospect_chapter_member", filterBool},
+	"prospect_senior_organizer": {"prospect_senior_organizer", filterBool},
+	"first_event":               {"first_event", filterDate},
+	"last_event":                {"last_event", filterDate},
+	"last_connection":           {"last_connection", filterDate},
+	"interest_date":             {"interest_date", filterDate},
+	"total_events":              {"total_events", filterNumber},
+	"total_points":              {"total_points", filterNumber},
+	"working_group": {`id IN (
+  SELECT wgm.activist_id
+  FROM working_group_members wgm
+  JOIN working_groups wg ON wg.id = wgm.working_group_id
+  WHERE wgm.non_member_on_mailing_list = 0 AND wg.name IN (?))`, filterMembership},
+	"circle": {`id IN (
+  SELECT cm.activist_id
+  FROM circle_members cm
+  JOIN circles c ON c.id = cm.circle_id
+  WHERE c.name IN (?))`, filterMembership},
+}
+
+var filterOperators = map[string]string{
+	FilterEqual:          "=",
+	FilterNotEqual:       "<>",
+	FilterLessThan:       "<",
+	FilterLessOrEqual:    "<=",
+	FilterGreaterThan:    ">",
+	FilterGreaterOrEqual: ">=",
+}
+
+type activistOrderField struct {
+	// expression must never be NULL, so that it can be compared
+	// against a cursor.
+	expression string
+	value      func(a ActivistExtra) string
+}
+
+// activistOrderFields describes how to page through activists ordered
+// by each of validOrderFields.
+var activistOrderFields = map[string]activistOrderField{
+	"a.name": {"name", func(a ActivistExtra) string {
+		return a.Name
+	}},
+	"last_event": {"IFNULL(last_event, '')", func(a ActivistExtra) string {
+		if !a.LastEvent.Valid {
+			return ""
+		}
+		return a.LastEvent.Time.Format(EventDateLayout)
+	}},
+	"total_points": {"total_points", func(a ActivistExtra) string {
+		return strconv.Itoa(a.TotalPoints)
+	}},
+	"interest_date": {"IFNULL(interest_date, '')", func(a ActivistExtra) string {
+		return a.InterestDate.String
+	}},
+}
+
+/** Type Definitions */
+
+type ActivistFilter struct {
+	Field    string `json:"field"`
+	Operator string `json:"operator"`
+	Value    string `json:"value"`
+	// Values is used instead of Value by the "in" operator.
+	Values []string `json:"values"`
+}
+
+type ActivistSearchOptions struct {
+	Filters    []ActivistFilter `json:"filters"`
+	Hidden     bool             `json:"hidden"`
+	OrderField string           `json:"order_field"`
+	Order      int              `json:"order"`
+	Limit      int              `json:"limit"`
+	// Cursor is the NextCursor from the previous page, or empty
+	// for the first page.
+	Cursor string `json:"cursor"`
+}
+
+type ActivistSearchResultJSON struct {
+	Activists []ActivistJSON `json:"activists"`
+	// Total is the number of activists matching the filters, across
+	// all pages.
+	Total int `json:"total"`
+	// NextCursor is empty on the last page.
+	NextCursor string `json:"next_cursor"`
+}
+
+// activistCursor is the position of the last activist on a page.
+type activistCursor struct {
+	Value string `json:"v"`
+	ID    int    `json:"id"`
+}
+
+/** Functions and Methods */
+
+func CleanActivistSearchOptions(body io.Reader) (ActivistSearchOptions, error) {
+	var options ActivistSearchOptions
+	if err := json.NewDecoder(body).Decode(&options); err != nil {
+		return ActivistSearchOptions{}, err
+	}
+	return validateActivistSearchOptions(options)
+}
+
+func validateActivistSearchOptions(options ActivistSearchOptions) (ActivistSearchOptions, error) {
+	if options.Order == 0 {
+		options.Order = AscOrder
+	}
+	if options.Order != DescOrder && options.Order != AscOrder {
+		return ActivistSearchOptions{}, errors.New("Order must be ascending or descending")
+	}
+	if options.OrderField == "" {
+		options.OrderField = "a.name"
+	}
+	if _, ok := validOrderFields[options.OrderField]; !ok {
+		return ActivistSearchOptions{}, errors.New("OrderField is not valid")
+	}
+	if options.Limit <= 0 {
+		options.Limit = defaultActivistSearchLimit
+	}
+	if options.Limit > maxActivistSearchLimit {
+		options.Limit = maxActivistSearchLimit
+	}
+	return options, nil
+}
+
+// SearchActivists returns a page of the activists matching the
+// filters, and the total number that match.
+//
+// Pages are read with keyset pagination: pass the NextCursor of one
+// page as the Cursor for the next, and keep the filters and order the
+// same.
+func SearchActivists(db *sqlx.DB, options ActivistSearchOptions, viewer ADBUser) (ActivistSearchResultJSON, error) {
+	options, err := validateActivistSearchOptions(options)
+	if err != nil {
+		return ActivistSearchResultJSON{}, err
+	}
+
+	// Filter on the output of selectActivistExtraBaseQuery so that
+	// computed columns can be filtered on like any other.
+	from := "(" + selectActivistExtraBaseQuery + " WHERE a.hidden = ?) activist_rows"
+	fromArgs := []interface{}{options.Hidden}
+
+	where, whereArgs, err := buildActivistFilters(options.Filters)
+	if err != nil {
+		return ActivistSearchResultJSON{}, err
+	}
+
+	countQuery := "SELECT COUNT(*) FROM " + from
+	if len(where) > 0 {
+		countQuery += " WHERE " + strings.Join(where, " AND ")
+	}
+	countQuery, countArgs, err := sqlx.In(countQuery, append(fromArgs, whereArgs...)...)
+	if err != nil {
+		return ActivistSearchResultJSON{}, errors.Wrap(err, "failed to build activist count query")
+	}
+	var result ActivistSearchResultJSON
+	if err := db.Get(&result.Total, countQuery, countArgs...); err != nil {
+		return ActivistSearchResultJSON{}, errors.Wrap(err, "failed to count activists")
+	}
+
+	orderField := activistOrderFields[options.OrderField]
+	comparison, direction := ">", "ASC"
+	if options.Order == DescOrder {
+		comparison, direction = "<", "DESC"
+	}
+	if options.Cursor != "" {
+		cursor, err := decodeActivistCursor(options.Cursor)
+		if err != nil {
+			return ActivistSearchResultJSON{}, err
+		}
+		// Ties on the order field are broken by id.
+		where = append(where, "("+orderField.expression+" "+comparison+" ? OR ("+
+			orderField.expression+" = ? AND id "+comparison+" ?))")
+		whereArgs = append(whereArgs, cursor.Value, cursor.Value, cursor.ID)
+	}
+
+	query := "SELECT * FROM " + from
+	if len(where) > 0 {
+		query += " WHERE " + strings.Join(where, " AND ")
+	}
+	// Fetch an extra row to find out if there's another page.
+	query += " ORDER BY " + orderField.expression + " " + direction + ", id " + direction + " LIMIT ?"
+	query, queryArgs, err := sqlx.In(query, append(append(fromArgs, whereArgs...), options.Limit+1)...)
+	if err != nil {
+		return ActivistSearchResultJSON{}, errors.Wrap(err, "failed to build activist search query")
+	}
+
+	var activists []ActivistExtra
+	if err := db.Select(&activists, query, queryArgs...); err != nil {
+		return ActivistSearchResultJSON{}, errors.Wrap(err, "failed to search activists")
+	}
+	if len(activists) > options.Limit {
+		activists = activists[:options.Limit]
+		last := activists[len(activists)-1]
+		result.NextCursor = encodeActivistCursor(activistCursor{
+			Value: orderField.value(last),
+			ID:    last.ID,
+		})
+	}
+
+	result.Activists = []ActivistJSON{}
+	redaction := activistRedactionFor(viewer)
+	for _, a := range activists {
+		a.Status = getStatus(a.FirstEvent, a.LastEvent, a.TotalEvents)
+		result.Activists = append(result.Activists, buildActivistJSON(a, redaction))
+	}
+	if err := recordPrivateActivistAccess(db, viewer, privateActivistIDs(result.Activists, redaction)); err != nil {
+		return ActivistSearchResultJSON{}, err
+	}
+	return result, nil
+}
+
+// buildActivistFilters returns the conditions for filters. The
+// arguments may contain slices, so the query must be expanded with
+// sqlx.In.
+func buildActivistFilters(filters []ActivistFilter) ([]string, []interface{}, error) {
+	var where []string
+	var args []interface{}
+	for _, f := range filters {
+		field, ok := activistFilterFields[f.Field]
+		if !ok {
+			return nil, nil, errors.Errorf("Cannot filter on %s", f.Field)
+		}
+
+		values := f.Values
+		if f.Operator != FilterIn {
+			values = []string{f.Value}
+		}
+		if len(values) == 0 {
+			return nil, nil, errors.Errorf("Filter on %s has no values", f.Field)
+		}
+		var parsed []interface{}
+		for _, v := range values {
+			p, err := parseFilterValue(field.kind, v)
+			if err != nil {
+				return nil, nil, errors.Wrapf(err, "invalid value for %s", f.Field)
+			}
+			parsed = append(parsed, p)
+		}
+
+		switch {
+		case field.kind == filterMembership:
+			if f.Operator != FilterEqual && f.Operator != FilterIn {
+				return nil, nil, errors.Errorf("%s can only be filtered with eq or in", f.Field)
+			}
+			where = append(where, field.expression)
+			args = append(args, parsed)
+		case f.Operator == FilterIn:
+			where = append(where, "("+field.expression+") IN (?)")
+			args = append(args, parsed)
+		case f.Operator == FilterContains:
+			if field.kind != filterString {
+				return nil, nil, errors.Errorf("%s can't be filtered with contains", f.Field)
+			}
+			where = append(where, "("+field.expression+") LIKE ?")
+			args = append(args, "%"+escapeLike(f.Value)+"%")
+		default:
+			op, ok := filterOperators[f.Operator]
+			if !ok {
+				return nil, nil, errors.Errorf("Invalid filter operator %s", f.Operator)
+			}
+			if field.kind == filterBool && f.Operator != FilterEqual && f.Operator != FilterNotEqual {
+				return nil, nil, errors.Errorf("%s can only be filtered with eq or ne", f.Field)
+			}
+			where = append(where, "("+field.expression+") "+op+" ?")
+			args = append(args, parsed[0])
+		}
+	}
+	return where, args, nil
+}
+
+func parseFilterValue(kind int, value string) (interface{}, error) {
+	switch kind {
+	case filterNumber:
+		return strconv.Atoi(value)
+	case filterDate:
+		if _, err := time.Parse(EventDateLayout, value); err != nil {
+			return nil, err
+		}
+		return value, nil
+	case filterBool:
+		return strconv.ParseBool(value)
+	}
+	return value, nil
+}
+
+func escapeLike(s string) string {
+	return strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`).Replace(s)
+}
+
+func encodeActivistCursor(cursor activistCursor) string {
+	b, _ := json.Marshal(cursor)
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+func decodeActivistCursor(s string) (activistCursor, error) {
+	var cursor activistCursor
+	b, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return activistCursor{}, errors.Wrap(err, "invalid cursor")
+	}
+	if err := json.Unmarshal(b, &cursor); err != nil {
+		return activistCursor{}, errors.Wrap(err, "invalid cursor")
+	}
+	return cursor, nil
+}
@@ -0,0 +1,99 @@
+package model
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestBuildActivistFilters(t *testing.T) {
+	where, args, err := buildActivistFilters([]ActivistFilter{
+		{Field: "activist_level", Operator: FilterIn, Values: []string{"Organizer", "Senior Organizer"}},
+		{Field: "total_points", Operator: FilterGreaterOrEqual, Value: "3"},
+		{Field: "name", Operator: FilterContains, Value: "50%"},
+	})
+	require.NoError(t, err)
+	require.Equal(t, []string{
+		"(activist_level) IN (?)",
+		"(total_points) >= ?",
+		"(name) LIKE ?",
+	}, where)
+	require.Equal(t, []interface{}{
+		[]interface{}{"Organizer", "Senior Organizer"},
+		3,
+		`%50\%%`,
+	}, args)
+
+	for _, f := range []ActivistFilter{
+		{Field: "password", Operator: FilterEqual, Value: "x"},
+		{Field: "total_points", Operator: FilterEqual, Value: "lots"},
+		{Field: "last_event", Operator: FilterGreaterThan, Value: "yesterday"},
+		{Field: "hiatus", Operator: FilterLessThan, Value: "true"},
+		{Field: "total_points", Operator: FilterContains, Value: "1"},
+		{Field: "circle", Operator: FilterNotEqual, Value: "Circle"},
+		{Field: "name", Operator: "like", Value: "x"},
+		{Field: "name", Operator: FilterIn},
+	} {
+		_, _, err := buildActivistFilters([]ActivistFilter{f})
+		require.Error(t, err, "%+v", f)
+	}
+}
+
+func TestActivistCursor(t *testing.T) {
+	cursor := activistCursor{Value: "Jo, \"Smith\"", ID: 12}
+	decoded, err := decodeActivistCursor(encodeActivistCursor(cursor))
+	require.NoError(t, err)
+	require.Equal(t, cursor, decoded)
+
+	_, err = decodeActivistCursor("not a cursor")
+	require.Error(t, err)
+}
+
+func TestSearchActivists(t *testing.T) {
+	db := newTestDB()
+	defer db.Close()
+
+	for _, name := range []string{"Charlie", "Alice", "Eve", "Bob", "Dave"} {
+		_, err := GetOrCreateActivist(db, name, ADBUser{})
+		require.NoError(t, err)
+	}
+	_, err := CreateActivist(db, ActivistExtra{
+		Activist:               Activist{Name: "Frank"},
+		ActivistMembershipData: ActivistMembershipData{ActivistLevel: "Organizer"},
+	}, ADBUser{})
+	require.NoError(t, err)
+
+	options := ActivistSearchOptions{Limit: 2}
+	var names []string
+	for page := 0; ; page++ {
+		require.True(t, page < 5, "too many pages")
+		result, err := SearchActivists(db, options, testViewer)
+		require.NoError(t, err)
+		require.Equal(t, 6, result.Total)
+		for _, a := range result.Activists {
+			names = append(names, a.Name)
+		}
+		if result.NextCursor == "" {
+			break
+		}
+		options.Cursor = result.NextCursor
+	}
+	require.Equal(t, []string{"Alice", "Bob", "Charlie", "Dave", "Eve", "Frank"}, names)
+
+	result, err := SearchActivists(db, ActivistSearchOptions{
+		Filters: []ActivistFilter{{Field: "activist_level", Operator: FilterEqual, Value: "Organizer"}},
+	}, testViewer)
+	require.NoError(t, err)
+	require.Equal(t, 1, result.Total)
+	require.Equal(t, "Frank", result.Activists[0].Name)
+
+	result, err = SearchActivists(db, ActivistSearchOptions{
+		Filters: []ActivistFilter{{Field: "status", Operator: FilterEqual, Value: "No attendance"}},
+		Order:   DescOrder,
+		Limit:   1,
+	}, testViewer)
+	require.NoError(t, err)
+	require.Equal(t, 6, result.Total)
+	require.Equal(t, "Frank", result.Activists[0].Name)
+	require.NotEmpty(t, result.NextCursor)
+}
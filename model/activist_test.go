@@ -21,12 +21,12 @@ func TestAutocompleteActivistsHandler(t *testing.T) {
 	db := newTestDB()
 	defer db.Close()
 
-	_, err := GetOrCreateActivist(db, "Activist One")
+	_, err := GetOrCreateActivist(db, "Activist One", ADBUser{})
 	if err != nil {
 		t.Fatal(err)
 	}
 
-	_, err = GetOrCreateActivist(db, "Activist Two")
+	_, err = GetOrCreateActivist(db, "Activist Two", ADBUser{})
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -46,7 +46,7 @@ func TestGetActivistEventData(t *testing.T) {
 	db := newTestDB()
 	defer db.Close()
 
-	a1, err := GetOrCreateActivist(db, "Test Activist")
+	a1, err := GetOrCreateActivist(db, "Test Activist", ADBUser{})
 	require.NoError(t, err)
 
 	d1, err := time.Parse("2006-01-02", "2017-04-15")
@@ -61,25 +61,25 @@ func TestGetActivistEventData(t *testing.T) {
 		ID:             1,
 		EventName:      "event one",
 		EventDate:      d2,
-		EventType:      "Working Group",
+		EventType:      "Meeting",
 		AddedAttendees: []Activist{a1},
 	}, {
 		ID:             2,
 		EventName:      "event two",
 		EventDate:      d1,
-		EventType:      "Working Group",
+		EventType:      "Meeting",
 		AddedAttendees: []Activist{a1},
 	}, {
 		ID:             3,
 		EventName:      "event three",
 		EventDate:      d3,
-		EventType:      "Working Group",
+		EventType:      "Meeting",
 		AddedAttendees: []Activist{a1},
 	}, {
 		ID:             4,
 		EventName:      "event four",
 		EventDate:      d3,
-		EventType:      "Working Group",
+		EventType:      "Meeting",
 		AddedAttendees: []Activist{a1},
 	}}
 	mustInsertAllEvents(t, db, insertEvents)
@@ -98,7 +98,7 @@ func TestGetActivistEventData_noEvents(t *testing.T) {
 	db := newTestDB()
 	defer db.Close()
 
-	a1, err := GetOrCreateActivist(db, "Test Activist")
+	a1, err := GetOrCreateActivist(db, "Test Activist", ADBUser{})
 	require.NoError(t, err)
 
 	d, err := a1.GetActivistEventData(db)
@@ -129,13 +129,13 @@ func TestGetActivistsJSON_RestrictDates(t *testing.T) {
 	db := newTestDB()
 	defer db.Close()
 
-	a1, err := GetOrCreateActivist(db, "A")
+	a1, err := GetOrCreateActivist(db, "A", ADBUser{})
 	require.NoError(t, err)
 
-	a2, err := GetOrCreateActivist(db, "B")
+	a2, err := GetOrCreateActivist(db, "B", ADBUser{})
 	require.NoError(t, err)
 
-	a3, err := GetOrCreateActivist(db, "C")
+	a3, err := GetOrCreateActivist(db, "C", ADBUser{})
 	require.NoError(t, err)
 
 	d1, err := time.Parse("2006-01-02", "2017-04-15")
@@ -149,33 +149,33 @@ func TestGetActivistsJSON_RestrictDates(t *testing.T) {
 		ID:             1,
 		EventName:      "event one",
 		EventDate:      d1,
-		EventType:      "Working Group",
+		EventType:      "Meeting",
 		AddedAttendees: []Activist{a1, a3},
 	}, {
 		ID:             2,
 		EventName:      "event two",
 		EventDate:      d2,
-		EventType:      "Working Group",
+		EventType:      "Meeting",
 		AddedAttendees: []Activist{a2, a3},
 	}, {
 		ID:             3,
 		EventName:      "event three",
 		EventDate:      d3,
-		EventType:      "Working Group",
+		EventType:      "Meeting",
 		AddedAttendees: []Activist{a2},
 	}}
 	mustInsertAllEvents(t, db, insertEvents)
 
 	activists, err := GetActivistsJSON(db, GetActivistOptions{
 		Order: DescOrder,
-	})
+	}, testViewer)
 	require.NoError(t, err)
 	assertActivistJSONSliceContainsNames(t, activists, []string{"A", "B", "C"})
 
 	activists, err = GetActivistsJSON(db, GetActivistOptions{
 		Order:             DescOrder,
 		LastEventDateFrom: "2017-04-17",
-	})
+	}, testViewer)
 	require.NoError(t, err)
 	assertActivistJSONSliceContainsNames(t, activists, []string{"B"})
 
@@ -183,7 +183,7 @@ func TestGetActivistsJSON_RestrictDates(t *testing.T) {
 		Order:             DescOrder,
 		LastEventDateFrom: "2017-04-16",
 		LastEventDateTo:   "2017-04-17",
-	})
+	}, testViewer)
 	require.NoError(t, err)
 	assertActivistJSONSliceContainsNames(t, activists, []string{"B", "C"})
 }
@@ -192,13 +192,13 @@ func TestGetActivistsJSON_OrderField(t *testing.T) {
 	db := newTestDB()
 	defer db.Close()
 
-	a1, err := GetOrCreateActivist(db, "A")
+	a1, err := GetOrCreateActivist(db, "A", ADBUser{})
 	require.NoError(t, err)
 
-	a2, err := GetOrCreateActivist(db, "B")
+	a2, err := GetOrCreateActivist(db, "B", ADBUser{})
 	require.NoError(t, err)
 
-	a3, err := GetOrCreateActivist(db, "C")
+	a3, err := GetOrCreateActivist(db, "C", ADBUser{})
 	require.NoError(t, err)
 
 	d1, err := time.Parse("2006-01-02", "2017-04-15")
@@ -212,19 +212,19 @@ func TestGetActivistsJSON_OrderField(t *testing.T) {
 		ID:             1,
 		EventName:      "event one",
 		EventDate:      d1,
-		EventType:      "Working Group",
+		EventType:      "Meeting",
 		AddedAttendees: []Activist{a1, a3},
 	}, {
 		ID:             2,
 		EventName:      "event two",
 		EventDate:      d2,
-		EventType:      "Working Group",
+		EventType:      "Meeting",
 		AddedAttendees: []Activist{a2, a3},
 	}, {
 		ID:             3,
 		EventName:      "event three",
 		EventDate:      d3,
-		EventType:      "Working Group",
+		EventType:      "Meeting",
 		AddedAttendees: []Activist{a2},
 	}}
 	mustInsertAllEvents(t, db, insertEvents)
@@ -232,14 +232,14 @@ func TestGetActivistsJSON_OrderField(t *testing.T) {
 	activists, err := GetActivistsJSON(db, GetActivistOptions{
 		Order:      AscOrder,
 		OrderField: "a.name",
-	})
+	}, testViewer)
 	require.NoError(t, err)
 	assertActivistJSONSliceContainsOrderedNames(t, activists, []string{"A", "B", "C"})
 
 	activists, err = GetActivistsJSON(db, GetActivistOptions{
 		Order:      DescOrder,
 		OrderField: "last_event",
-	})
+	}, testViewer)
 	require.NoError(t, err)
 	assertActivistJSONSliceContainsOrderedNames(t, activists, []string{"B", "C", "A"})
 }
@@ -248,7 +248,7 @@ func TestGetActivistsJSON_FirstAndLastEvent(t *testing.T) {
 	db := newTestDB()
 	defer db.Close()
 
-	a1, err := GetOrCreateActivist(db, "A")
+	a1, err := GetOrCreateActivist(db, "A", ADBUser{})
 	require.NoError(t, err)
 
 	d1, err := time.Parse("2006-01-02", "2017-04-15")
@@ -261,32 +261,32 @@ func TestGetActivistsJSON_FirstAndLastEvent(t *testing.T) {
 	insertEvents := []Event{{
 		EventName:      "event one",
 		EventDate:      d2,
-		EventType:      "Working Group",
+		EventType:      "Meeting",
 		AddedAttendees: []Activist{a1},
 	}, {
 		EventName:      "yo yo yo",
 		EventDate:      d3,
-		EventType:      "Working Group",
+		EventType:      "Meeting",
 		AddedAttendees: []Activist{},
 	}, {
 		EventName:      "heyo",
 		EventDate:      d3,
-		EventType:      "Working Group",
+		EventType:      "Meeting",
 		AddedAttendees: []Activist{a1},
 	}, {
 		EventName:      "hello",
 		EventDate:      d1,
-		EventType:      "Working Group",
+		EventType:      "Meeting",
 		AddedAttendees: []Activist{},
 	}, {
 		EventName:      "hi there",
 		EventDate:      d1,
-		EventType:      "Working Group",
+		EventType:      "Meeting",
 		AddedAttendees: []Activist{a1},
 	}}
 	mustInsertAllEvents(t, db, insertEvents)
 
-	activists, err := GetActivistsJSON(db, GetActivistOptions{})
+	activists, err := GetActivistsJSON(db, GetActivistOptions{}, testViewer)
 	require.NoError(t, err)
 
 	gotActivist := activists[0]
@@ -302,10 +302,10 @@ func TestHideActivist(t *testing.T) {
 	defer db.Close()
 
 	// Test that deleting activists works
-	a1, err := GetOrCreateActivist(db, "Test Activist")
+	a1, err := GetOrCreateActivist(db, "Test Activist", ADBUser{})
 	require.NoError(t, err)
 
-	a2, err := GetOrCreateActivist(db, "Another Test Activist")
+	a2, err := GetOrCreateActivist(db, "Another Test Activist", ADBUser{})
 	require.NoError(t, err)
 
 	d1, err := time.Parse("2006-01-02", "2017-01-15")
@@ -314,11 +314,11 @@ func TestHideActivist(t *testing.T) {
 	eventID, err := InsertUpdateEvent(db, Event{
 		EventName:      "my event",
 		EventDate:      d1,
-		EventType:      "Working Group",
+		EventType:      "Meeting",
 		AddedAttendees: []Activist{a1, a2},
 	})
 
-	require.NoError(t, HideActivist(db, a1.ID))
+	require.NoError(t, HideActivist(db, a1.ID, ADBUser{}))
 
 	// Hidden activists should not show up in the autocompleted names
 	names := GetAutocompleteNames(db)
@@ -327,18 +327,18 @@ func TestHideActivist(t *testing.T) {
 
 	// Hidden activists should not show up in GetActivistsJSON unless
 	// Hidden = true.
-	unhiddenActivists, err := GetActivistsJSON(db, GetActivistOptions{})
+	unhiddenActivists, err := GetActivistsJSON(db, GetActivistOptions{}, testViewer)
 	require.NoError(t, err)
 	require.Equal(t, len(unhiddenActivists), 1)
 	require.Equal(t, unhiddenActivists[0].ID, a2.ID)
 
-	hiddenActivists, err := GetActivistsJSON(db, GetActivistOptions{Hidden: true})
+	hiddenActivists, err := GetActivistsJSON(db, GetActivistOptions{Hidden: true}, testViewer)
 	require.NoError(t, err)
 	require.Equal(t, len(hiddenActivists), 1)
 	require.Equal(t, hiddenActivists[0].ID, a1.ID)
 
 	// Hidden activists should show up in GetActivistJSON
-	a1JSON, err := GetActivistJSON(db, GetActivistOptions{ID: a1.ID})
+	a1JSON, err := GetActivistJSON(db, GetActivistOptions{ID: a1.ID}, testViewer)
 	require.NoError(t, err)
 	require.Equal(t, a1JSON.ID, a1.ID)
 
@@ -352,18 +352,48 @@ func TestHideActivist(t *testing.T) {
 	assertStringsSliceUnorderedEquals(t, attendanceNames, []string{a1.Name, a2.Name})
 }
 
+func TestUpdateActivistData_conflict(t *testing.T) {
+	db := newTestDB()
+	defer db.Close()
+
+	a, err := GetOrCreateActivist(db, "Test Activist", ADBUser{})
+	require.NoError(t, err)
+
+	first, err := GetActivistsExtra(db, GetActivistOptions{ID: a.ID})
+	require.NoError(t, err)
+	second, err := GetActivistsExtra(db, GetActivistOptions{ID: a.ID})
+	require.NoError(t, err)
+
+	first[0].Email = "first@example.com"
+	_, err = UpdateActivistData(db, first[0], ADBUser{})
+	require.NoError(t, err)
+
+	second[0].Phone = "555-1234"
+	_, err = UpdateActivistData(db, second[0], testViewer)
+	conflictErr, ok := err.(*ConflictError)
+	require.True(t, ok, "%v", err)
+	current := conflictErr.Current.(ActivistJSON)
+	require.Equal(t, "first@example.com", current.Email)
+	require.Equal(t, 2, current.Version)
+
+	saved, err := GetActivistJSON(db, GetActivistOptions{ID: a.ID}, testViewer)
+	require.NoError(t, err)
+	require.Equal(t, "first@example.com", saved.Email)
+	require.Equal(t, "", saved.Phone)
+}
+
 func TestMergeActivist(t *testing.T) {
 	db := newTestDB()
 	defer db.Close()
 
 	// Test that deleting activists works
-	a1, err := GetOrCreateActivist(db, "Test Activist")
+	a1, err := GetOrCreateActivist(db, "Test Activist", ADBUser{})
 	require.NoError(t, err)
 
-	a2, err := GetOrCreateActivist(db, "Another Test Activist")
+	a2, err := GetOrCreateActivist(db, "Another Test Activist", ADBUser{})
 	require.NoError(t, err)
 
-	a3, err := GetOrCreateActivist(db, "A Third Test Activist")
+	a3, err := GetOrCreateActivist(db, "A Third Test Activist", ADBUser{})
 	require.NoError(t, err)
 
 	d1, err := time.Parse("2006-01-02", "2017-04-15")
@@ -377,24 +407,24 @@ func TestMergeActivist(t *testing.T) {
 		ID:             1,
 		EventName:      "event one",
 		EventDate:      d1,
-		EventType:      "Working Group",
+		EventType:      "Meeting",
 		AddedAttendees: []Activist{a1, a3},
 	}, {
 		ID:             2,
 		EventName:      "event two",
 		EventDate:      d2,
-		EventType:      "Working Group",
+		EventType:      "Meeting",
 		AddedAttendees: []Activist{a1, a2, a3},
 	}, {
 		ID:             3,
 		EventName:      "event three",
 		EventDate:      d3,
-		EventType:      "Working Group",
+		EventType:      "Meeting",
 		AddedAttendees: []Activist{a2, a3},
 	}}
 	mustInsertAllEvents(t, db, insertEvents)
 
-	require.NoError(t, MergeActivist(db, a1.ID, a2.ID))
+	require.NoError(t, MergeActivist(db, a1.ID, a2.ID, ADBUser{}))
 
 	e1, err := GetEvent(db, GetEventOptions{EventID: 1})
 	require.NoError(t, err)
@@ -415,6 +445,87 @@ func TestMergeActivist(t *testing.T) {
 	require.Equal(t, e3.Attendees[1], a3.Name)
 }
 
+func TestUnmergeActivist(t *testing.T) {
+	db := newTestDB()
+	defer db.Close()
+
+	a1, err := GetOrCreateActivist(db, "Test Activist", ADBUser{})
+	require.NoError(t, err)
+	a2, err := GetOrCreateActivist(db, "Another Test Activist", ADBUser{})
+	require.NoError(t, err)
+
+	// Give the original a field that the target lacks so that the
+	// merge overwrites the target's value.
+	original, err := GetActivistsExtra(db, GetActivistOptions{ID: a1.ID})
+	require.NoError(t, err)
+	original[0].Email = "original@example.com"
+	_, err = UpdateActivistData(db, original[0], ADBUser{})
+	require.NoError(t, err)
+
+	d1, err := time.Parse("2006-01-02", "2017-04-15")
+	require.NoError(t, err)
+	d2, err := time.Parse("2006-01-02", "2017-04-16")
+	require.NoError(t, err)
+
+	mustInsertAllEvents(t, db, []Event{{
+		ID:             1,
+		EventName:      "event one",
+		EventDate:      d1,
+		EventType:      "Meeting",
+		AddedAttendees: []Activist{a1},
+	}, {
+		ID:             2,
+		EventName:      "event two",
+		EventDate:      d2,
+		EventType:      "Meeting",
+		AddedAttendees: []Activist{a1, a2},
+	}})
+
+	// A user linked to the original follows it through the merge and
+	// back, but the target's own user stays put.
+	originalUserID, err := CreateUser(db, ADBUser{Email: "original@example.com", Name: "Original", ActivistID: a1.ID})
+	require.NoError(t, err)
+	targetUserID, err := CreateUser(db, ADBUser{Email: "target@example.com", Name: "Target", ActivistID: a2.ID})
+	require.NoError(t, err)
+
+	require.NoError(t, MergeActivist(db, a1.ID, a2.ID, ADBUser{}))
+	merged, err := GetActivistsExtra(db, GetActivistOptions{ID: a2.ID})
+	require.NoError(t, err)
+	require.Equal(t, "original@example.com", merged[0].Email)
+	originalUser, err := GetADBUser(db, originalUserID, "")
+	require.NoError(t, err)
+	require.Equal(t, a2.ID, originalUser.ActivistID)
+
+	require.NoError(t, UnmergeActivist(db, a1.ID, ADBUser{}))
+
+	originalUser, err = GetADBUser(db, originalUserID, "")
+	require.NoError(t, err)
+	require.Equal(t, a1.ID, originalUser.ActivistID)
+	targetUser, err := GetADBUser(db, targetUserID, "")
+	require.NoError(t, err)
+	require.Equal(t, a2.ID, targetUser.ActivistID)
+
+	restored, err := GetActivistsExtra(db, GetActivistOptions{ID: a1.ID})
+	require.NoError(t, err)
+	require.Equal(t, "Test Activist", restored[0].Name)
+	require.False(t, restored[0].Hidden)
+
+	target, err := GetActivistsExtra(db, GetActivistOptions{ID: a2.ID})
+	require.NoError(t, err)
+	require.Equal(t, "", target[0].Email)
+
+	e1, err := GetEvent(db, GetEventOptions{EventID: 1})
+	require.NoError(t, err)
+	require.Equal(t, []string{a1.Name}, e1.Attendees)
+
+	e2, err := GetEvent(db, GetEventOptions{EventID: 2})
+	require.NoError(t, err)
+	assertStringsSliceUnorderedEquals(t, e2.Attendees, []string{a1.Name, a2.Name})
+
+	// There's nothing left to undo.
+	require.Error(t, UnmergeActivist(db, a1.ID, ADBUser{}))
+}
+
 // Not Specfiying a starting name with ascending order
 // and no limit, returns all activists
 func TestActivistRange_noNameOrLimitAscOrder_returnsAllActivists(t *testing.T) {
@@ -426,7 +537,7 @@ func TestActivistRange_noNameOrLimitAscOrder_returnsAllActivists(t *testing.T) {
 	activistOptions := ActivistRangeOptionsJSON{
 		Order: AscOrder,
 	}
-	fetchedActivists, err := GetActivistRangeJSON(db, activistOptions)
+	fetchedActivists, err := GetActivistRangeJSON(db, activistOptions, testViewer)
 
 	require.NoError(t, err)
 	require.Equal(t, len(activistsToInsert), len(fetchedActivists))
@@ -450,7 +561,7 @@ func TestActivistRange_noNameOrLimitDescOrder_returnsAllActivists(t *testing.T)
 	activistOptions := ActivistRangeOptionsJSON{
 		Order: DescOrder,
 	}
-	fetchedActivists, err := GetActivistRangeJSON(db, activistOptions)
+	fetchedActivists, err := GetActivistRangeJSON(db, activistOptions, testViewer)
 
 	require.NoError(t, err)
 	require.Equal(t, len(activistsToInsert), len(fetchedActivists))
@@ -476,7 +587,7 @@ func TestActivistRange_NameNoLimitAscOrder_returnsSubsetOfActivists(t *testing.T
 		Name:  "A",
 		Order: AscOrder,
 	}
-	fetchedActivists, err := GetActivistRangeJSON(db, activistOptions)
+	fetchedActivists, err := GetActivistRangeJSON(db, activistOptions, testViewer)
 
 	require.NoError(t, err)
 	require.Equal(t, 5, len(fetchedActivists))
@@ -488,7 +599,7 @@ func TestActivistRange_NameNoLimitAscOrder_returnsSubsetOfActivists(t *testing.T
 
 	// If specified name is last, then result should be nil
 	activistOptions.Name = "F"
-	fetchedActivists, err = GetActivistRangeJSON(db, activistOptions)
+	fetchedActivists, err = GetActivistRangeJSON(db, activistOptions, testViewer)
 	require.NoError(t, err)
 	require.Nil(t, fetchedActivists)
 
@@ -506,7 +617,7 @@ func TestActivistRange_NameNoLimitDescOrder_returnsSubsetOfActivists(t *testing.
 		Name:  "F",
 		Order: DescOrder,
 	}
-	fetchedActivists, err := GetActivistRangeJSON(db, activistOptions)
+	fetchedActivists, err := GetActivistRangeJSON(db, activistOptions, testViewer)
 
 	require.NoError(t, err)
 	require.Equal(t, 5, len(fetchedActivists))
@@ -519,7 +630,7 @@ func TestActivistRange_NameNoLimitDescOrder_returnsSubsetOfActivists(t *testing.
 
 	// If specified name is last, then result is nil
 	activistOptions.Name = "A"
-	fetchedActivists, err = GetActivistRangeJSON(db, activistOptions)
+	fetchedActivists, err = GetActivistRangeJSON(db, activistOptions, testViewer)
 	require.NoError(t, err)
 	require.Nil(t, fetchedActivists)
 
@@ -536,7 +647,7 @@ func TestActivistRange_nonPositiveLimit_behavesAsNoLimit(t *testing.T) {
 		Order: AscOrder,
 		Limit: -42,
 	}
-	fetchedActivists, err := GetActivistRangeJSON(db, activistOptions)
+	fetchedActivists, err := GetActivistRangeJSON(db, activistOptions, testViewer)
 
 	require.NoError(t, err)
 	require.Equal(t, len(activistsToInsert), len(fetchedActivists))
@@ -554,12 +665,12 @@ func TestActivistRange_NameAndLimitAscOrder_returnsSubsetOfActivists(t *testing.
 		Limit: 20,
 	}
 	// Should get all activists back since Limit > Number of activists
-	fetchedActivists, err := GetActivistRangeJSON(db, activistOptions)
+	fetchedActivists, err := GetActivistRangeJSON(db, activistOptions, testViewer)
 	require.NoError(t, err)
 	require.Equal(t, len(activistsToInsert), len(fetchedActivists))
 
 	activistOptions.Limit = 2
-	fetchedActivists, err = GetActivistRangeJSON(db, activistOptions)
+	fetchedActivists, err = GetActivistRangeJSON(db, activistOptions, testViewer)
 	require.NoError(t, err)
 	require.Equal(t, 2, len(fetchedActivists))
 
@@ -568,7 +679,7 @@ func TestActivistRange_NameAndLimitAscOrder_returnsSubsetOfActivists(t *testing.
 	}
 
 	activistOptions.Name = "F"
-	fetchedActivists, err = GetActivistRangeJSON(db, activistOptions)
+	fetchedActivists, err = GetActivistRangeJSON(db, activistOptions, testViewer)
 	require.NoError(t, err)
 	require.Nil(t, fetchedActivists)
 }
@@ -585,12 +696,12 @@ func TestActivistRange_NameAndLimitDescOrder_returnsSubsetofActivists(t *testing
 		Limit: 20,
 	}
 	// Should get all activists back since 20 > Number of activists
-	fetchedActivists, err := GetActivistRangeJSON(db, activistOptions)
+	fetchedActivists, err := GetActivistRangeJSON(db, activistOptions, testViewer)
 	require.NoError(t, err)
 	require.Equal(t, len(activistsToInsert), len(fetchedActivists))
 
 	activistOptions.Limit = 2
-	fetchedActivists, err = GetActivistRangeJSON(db, activistOptions)
+	fetchedActivists, err = GetActivistRangeJSON(db, activistOptions, testViewer)
 	require.NoError(t, err)
 	require.Equal(t, 2, len(fetchedActivists))
 
@@ -600,7 +711,7 @@ func TestActivistRange_NameAndLimitDescOrder_returnsSubsetofActivists(t *testing
 	}
 
 	activistOptions.Name = "A"
-	fetchedActivists, err = GetActivistRangeJSON(db, activistOptions)
+	fetchedActivists, err = GetActivistRangeJSON(db, activistOptions, testViewer)
 	require.NoError(t, err)
 	require.Nil(t, fetchedActivists)
 }
@@ -646,7 +757,7 @@ func assertActivistJSONSliceContainsOrderedNames(t *testing.T, activists []Activ
 func insertTestActivists(t *testing.T, db *sqlx.DB, names []string) []Activist {
 	var activists []Activist = make([]Activist, len(names))
 	for idx, name := range names {
-		activist, err := GetOrCreateActivist(db, name)
+		activist, err := GetOrCreateActivist(db, name, ADBUser{})
 		require.NoError(t, err)
 		activists[idx] = activist
 	}
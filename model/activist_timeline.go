@@ -0,0 +1,121 @@
+package model
+
+import (
+	"database/sql"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/pkg/errors"
+)
+
+/** Type Definitions */
+
+// ActivistTimelineEvent is one event in an activist's attendance
+// timeline.
+type ActivistTimelineEvent struct {
+	EventID   int       `db:"id"`
+	EventName string    `db:"name"`
+	EventDate time.Time `db:"date"`
+	EventType string    `db:"event_type"`
+	Category  string    `db:"category"`
+}
+
+type ActivistTimelineEventJSON struct {
+	EventID   int    `json:"event_id"`
+	EventName string `json:"event_name"`
+	EventDate string `json:"event_date"`
+	EventType string `json:"event_type"`
+	Category  string `json:"category"`
+	Month     string `json:"month"`
+}
+
+// ActivistTimelineMonthJSON is whether an activist met the MPI
+// requirements in a month they attended at least one event.
+type ActivistTimelineMonthJSON struct {
+	Month           string `json:"month"` // Formatted as MPIMonthLayout
+	Events          int    `json:"events"`
+	DirectAction    bool   `json:"direct_action"`
+	Community       bool   `json:"community"`
+	CommunityWaived bool   `json:"community_waived"`
+	MPI             bool   `json:"mpi"`
+}
+
+type ActivistTimelineJSON struct {
+	ActivistID   int                         `json:"activist_id"`
+	ActivistName string                      `json:"activist_name"`
+	Events       []ActivistTimelineEventJSON `json:"events"`
+	Months       []ActivistTimelineMonthJSON `json:"months"`
+}
+
+/** Functions and Methods */
+
+// GetActivistTimelineJSON returns every event an activist attended,
+// oldest first, along with whether they met the MPI requirements in
+// each month they attended anything.
+func GetActivistTimelineJSON(db *sqlx.DB, activistID int) (ActivistTimelineJSON, error) {
+	var name string
+	err := db.Get(&name, `SELECT name FROM activists WHERE id = ?`, activistID)
+	if err == sql.ErrNoRows {
+		return ActivistTimelineJSON{}, errors.Errorf("Activist %d does not exist", activistID)
+	} else if err != nil {
+		return ActivistTimelineJSON{}, errors.Wrapf(err, "failed to get activist %d", activistID)
+	}
+
+	var events []ActivistTimelineEvent
+	err = db.Select(&events, `
+SELECT e.id, e.name, e.date, e.event_type, t.category
+FROM event_attendance ea
+JOIN events e ON e.id = ea.event_id
+JOIN event_types t ON t.name = e.event_type
+WHERE ea.activist_id = ? AND e.deleted_at IS NULL
+ORDER BY e.date, e.id`, activistID)
+	if err != nil {
+		return ActivistTimelineJSON{}, errors.Wrapf(err, "failed to select events attended by activist %d", activistID)
+	}
+
+	timeline := buildActivistTimeline(GetMPIRules(), events)
+	timeline.ActivistID = activistID
+	timeline.ActivistName = name
+	return timeline, nil
+}
+
+// buildActivistTimeline groups events, which must be sorted by date,
+// into months, and decides which months met the MPI requirements using
+// the same rules as the MPI itself.
+func buildActivistTimeline(rules MPIRules, events []ActivistTimelineEvent) ActivistTimelineJSON {
+	timeline := ActivistTimelineJSON{
+		Events: []ActivistTimelineEventJSON{},
+		Months: []ActivistTimelineMonthJSON{},
+	}
+	var attendance []MPIAttendance
+	for _, e := range events {
+		timeline.Events = append(timeline.Events, ActivistTimelineEventJSON{
+			EventID:   e.EventID,
+			EventName: e.EventName,
+			EventDate: e.EventDate.Format(EventDateLayout),
+			EventType: e.EventType,
+			Category:  e.Category,
+			Month:     e.EventDate.Format(MPIMonthLayout),
+		})
+		attendance = append(attendance, MPIAttendance{Date: e.EventDate, Category: e.Category})
+	}
+
+	for i := 0; i < len(events); {
+		month := startOfMonth(events[i].EventDate)
+		j := i
+		for j < len(events) && startOfMonth(events[j].EventDate).Equal(month) {
+			j++
+		}
+		m := rules.Month(month, attendance[i:j])
+		timeline.Months = append(timeline.Months, ActivistTimelineMonthJSON{
+			Month:           month.Format(MPIMonthLayout),
+			Events:          j - i,
+			DirectAction:    m.DirectAction,
+			Community:       m.Community,
+			CommunityWaived: m.CommunityWaived,
+			MPI:             m.Met(),
+		})
+		i = j
+	}
+	return timeline
+}
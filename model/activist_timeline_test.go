@@ -0,0 +1,70 @@
+package model
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestBuildActivistTimeline(t *testing.T) {
+	events := []ActivistTimelineEvent{
+		{EventID: 1, EventName: "Protest", EventDate: mpiDate("2019-12-05"), EventType: "Action", Category: EventCategoryDirectAction},
+		{EventID: 2, EventName: "Potluck", EventDate: mpiDate("2019-12-20"), EventType: "Community", Category: EventCategoryCommunity},
+		{EventID: 3, EventName: "Protest", EventDate: mpiDate("2020-01-10"), EventType: "Action", Category: EventCategoryDirectAction},
+		{EventID: 4, EventName: "Meeting", EventDate: mpiDate("2020-03-01"), EventType: "Meeting", Category: EventCategoryOther},
+	}
+	timeline := buildActivistTimeline(DefaultMPIRules, events)
+
+	require.Len(t, timeline.Events, 4)
+	require.Equal(t, ActivistTimelineEventJSON{
+		EventID:   2,
+		EventName: "Potluck",
+		EventDate: "2019-12-20",
+		EventType: "Community",
+		Category:  EventCategoryCommunity,
+		Month:     "2019-12",
+	}, timeline.Events[1])
+
+	require.Equal(t, []ActivistTimelineMonthJSON{
+		{Month: "2019-12", Events: 2, DirectAction: true, Community: true, MPI: true},
+		// The community requirement was waived in January 2020.
+		{Month: "2020-01", Events: 1, DirectAction: true, CommunityWaived: true, MPI: true},
+		{Month: "2020-03", Events: 1},
+	}, timeline.Months)
+
+	empty := buildActivistTimeline(DefaultMPIRules, nil)
+	require.Empty(t, empty.Events)
+	require.NotNil(t, empty.Months)
+}
+
+func TestGetActivistTimelineJSON(t *testing.T) {
+	db := newTestDB()
+	defer db.Close()
+
+	a, err := GetOrCreateActivist(db, "Test Activist", ADBUser{})
+	require.NoError(t, err)
+	for _, e := range []Event{
+		{EventName: "Later", EventDate: mpiDate("2020-05-20"), EventType: "Action"},
+		{EventName: "Earlier", EventDate: mpiDate("2020-05-01"), EventType: "Community"},
+		{EventName: "Trashed", EventDate: mpiDate("2020-06-01"), EventType: "Action"},
+	} {
+		e.AddedAttendees = []Activist{a}
+		id, err := InsertUpdateEvent(db, e)
+		require.NoError(t, err)
+		if e.EventName == "Trashed" {
+			require.NoError(t, DeleteEvent(db, id, ADBUser{}))
+		}
+	}
+
+	timeline, err := GetActivistTimelineJSON(db, a.ID)
+	require.NoError(t, err)
+	require.Equal(t, "Test Activist", timeline.ActivistName)
+	require.Len(t, timeline.Events, 2)
+	require.Equal(t, "Earlier", timeline.Events[0].EventName)
+	require.Equal(t, "Later", timeline.Events[1].EventName)
+	require.Len(t, timeline.Months, 1)
+	require.True(t, timeline.Months[0].MPI)
+
+	_, err = GetActivistTimelineJSON(db, a.ID+1000)
+	require.Error(t, err)
+}
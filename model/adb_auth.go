@@ -4,6 +4,7 @@ import (
 	"encoding/json"
 	"fmt"
 	"io"
+	"strings"
 
 	"github.com/jmoiron/sqlx"
 	"github.com/pkg/errors"
@@ -17,7 +18,9 @@ SELECT
   email,
   name,
   admin,
-  disabled
+  disabled,
+  IFNULL(activist_id, 0) AS activist_id,
+  IFNULL((SELECT name FROM activists WHERE id = adb_users.activist_id), '') AS activist_name
 FROM adb_users
 `
 
@@ -36,16 +39,23 @@ type ADBUser struct {
 	Name     string `db:"name"`
 	Admin    bool   `db:"admin"`
 	Disabled bool   `db:"disabled"`
-	Roles    []UserRole
+	// ActivistID is the user's own activist record, or 0 if they
+	// haven't been linked to one.
+	ActivistID   int    `db:"activist_id"`
+	ActivistName string `db:"activist_name"`
+	Roles        []UserRole
+	// Permissions granted by any of Roles.
+	Permissions map[string]bool
 }
 
 type UserJSON struct {
-	ID       int      `json:"id"`
-	Email    string   `json:"email"`
-	Name     string   `json:"name"`
-	Admin    bool     `json:"admin"`
-	Disabled bool     `json:"disabled"`
-	Roles    []string `json:"roles"`
+	ID           int      `json:"id"`
+	Email        string   `json:"email"`
+	Name         string   `json:"name"`
+	Admin        bool     `json:"admin"`
+	Disabled     bool     `json:"disabled"`
+	ActivistName string   `json:"activist_name"`
+	Roles        []string `json:"roles"`
 }
 
 type GetUserOptions struct {
@@ -57,14 +67,6 @@ type GetUsersRolesOptions struct {
 	Roles []string
 }
 
-var DevTestUser = ADBUser{
-	ID:       1,
-	Email:    "test@test.com",
-	Name:     "Test User",
-	Disabled: false,
-	Roles:    []UserRole{{UserID: 1, Role: "admin"}},
-}
-
 type UserRole struct {
 	UserID int    `db:"user_id"`
 	Role   string `db:"role"`
@@ -84,7 +86,9 @@ SELECT
   email,
   name,
   admin,
-  disabled
+  disabled,
+  IFNULL(activist_id, 0) AS activist_id,
+  IFNULL((SELECT name FROM activists WHERE id = adb_users.activist_id), '') AS activist_name
 FROM adb_users
 `
 	var queryArgs []interface{}
@@ -113,12 +117,19 @@ FROM adb_users
 		return *adbUser, nil
 	}
 
+	var roles []string
 	for _, r := range usersRoles {
 		if r.UserID == adbUser.ID {
 			adbUser.Roles = append(adbUser.Roles, r)
+			roles = append(roles, r.Role)
 		}
 	}
 
+	adbUser.Permissions, err = GetPermissions(db, roles)
+	if err != nil {
+		return ADBUser{}, err
+	}
+
 	fmt.Println("[User access]", adbUser.Name, "-", adbUser.Email)
 
 	return *adbUser, nil
@@ -247,19 +258,20 @@ func buildUserJSONArray(users []ADBUser) []UserJSON {
 		}
 
 		usersJSON = append(usersJSON, UserJSON{
-			ID:       u.ID,
-			Email:    u.Email,
-			Name:     u.Name,
-			Admin:    u.Admin,
-			Disabled: u.Disabled,
-			Roles:    roles,
+			ID:           u.ID,
+			Email:        u.Email,
+			Name:         u.Name,
+			Admin:        u.Admin,
+			Disabled:     u.Disabled,
+			ActivistName: u.ActivistName,
+			Roles:        roles,
 		})
 	}
 
 	return usersJSON
 }
 
-func CleanUserData(body io.Reader) (ADBUser, error) {
+func CleanUserData(db *sqlx.DB, body io.Reader) (ADBUser, error) {
 	var userJSON UserJSON
 	err := json.NewDecoder(body).Decode(&userJSON)
 
@@ -275,6 +287,15 @@ func CleanUserData(body io.Reader) (ADBUser, error) {
 		Disabled: userJSON.Disabled,
 	}
 
+	if name := strings.TrimSpace(userJSON.ActivistName); name != "" {
+		activist, err := GetActivist(db, name)
+		if err != nil {
+			return ADBUser{}, err
+		}
+		user.ActivistID = activist.ID
+		user.ActivistName = activist.Name
+	}
+
 	return user, nil
 }
 
@@ -313,12 +334,14 @@ INSERT INTO adb_users (
   email,
   name,
   admin,
-  disabled
+  disabled,
+  activist_id
 ) VALUES (
   :email,
   :name,
   :admin,
-  :disabled
+  :disabled,
+  NULLIF(:activist_id, 0)
 )`, user)
 
 	if err != nil {
@@ -346,19 +369,40 @@ func UpdateUser(db *sqlx.DB, user ADBUser) (int, error) {
 		return 0, errors.New("User Name cannot be empty")
 	}
 
-	_, err := db.NamedExec(`UPDATE adb_users
+	tx, err := db.Beginx()
+	if err != nil {
+		return 0, errors.Wrap(err, "failed to create transaction")
+	}
+
+	_, err = tx.NamedExec(`UPDATE adb_users
 SET
   email = :email,
   name  = :name,
   admin = :admin,
-  disabled = :disabled
+  disabled = :disabled,
+  activist_id = NULLIF(:activist_id, 0)
 WHERE
 id = :id`, user)
 
 	if err != nil {
+		tx.Rollback()
 		return 0, errors.Wrap(err, "failed to update user data")
 	}
 
+	// Disabled users are signed out right away, rather than when
+	// their session expires.
+	if user.Disabled {
+		if err := revokeUserSessions(tx, user.ID); err != nil {
+			tx.Rollback()
+			return 0, err
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		tx.Rollback()
+		return 0, errors.Wrapf(err, "failed to commit update for user %d", user.ID)
+	}
+
 	return user.ID, nil
 }
 
@@ -389,6 +433,11 @@ func RemoveUser(db *sqlx.DB, userID int) (int, error) {
 		return 0, errors.Wrapf(err, "failed to delete user %d", userID)
 	}
 
+	if err := revokeUserSessions(tx, userID); err != nil {
+		tx.Rollback()
+		return 0, err
+	}
+
 	if err := tx.Commit(); err != nil {
 		tx.Rollback()
 		return 0, errors.Wrapf(err, "failed to commit delete transaction for user %d", userID)
@@ -406,7 +455,15 @@ func CreateUserRole(db *sqlx.DB, userRole UserRole) (int, error) {
 		return userRole.UserID, errors.New("Role cannot be empty")
 	}
 
-	_, err := db.Exec(`
+	exists, err := roleExists(db, userRole.Role)
+	if err != nil {
+		return userRole.UserID, err
+	}
+	if !exists {
+		return userRole.UserID, errors.Errorf("Not a valid role: %s", userRole.Role)
+	}
+
+	_, err = db.Exec(`
 INSERT INTO users_roles (user_id, role)
 VALUES (?, ?)
 `, userRole.UserID, userRole.Role)
@@ -0,0 +1,260 @@
+package model
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/go-sql-driver/mysql"
+	"github.com/jmoiron/sqlx"
+	"github.com/pkg/errors"
+)
+
+/** Constant and Variable Definitions */
+
+// apiKeyPrefix starts every API key, so leaked keys are easy to
+// recognize.
+const apiKeyPrefix = "adb_"
+
+// apiKeyPrefixLength is how much of a key is stored in the clear, to
+// tell keys apart in the admin UI.
+const apiKeyPrefixLength = 10
+
+const selectAPIKeyBaseQuery string = `
+SELECT
+  k.id,
+  k.name,
+  k.key_prefix,
+  k.role,
+  k.created_by,
+  k.created_at,
+  k.last_used_at,
+  k.revoked_at,
+  IFNULL(u.name, '') AS created_by_name
+FROM api_keys k
+LEFT JOIN adb_users u ON u.id = k.created_by
+`
+
+/** Type Definitions */
+
+type APIKey struct {
+	ID            int            `db:"id"`
+	Name          string         `db:"name"`
+	KeyPrefix     string         `db:"key_prefix"`
+	Role          string         `db:"role"`
+	CreatedBy     int            `db:"created_by"`
+	CreatedByName string         `db:"created_by_name"`
+	CreatedAt     time.Time      `db:"created_at"`
+	LastUsedAt    mysql.NullTime `db:"last_used_at"`
+	RevokedAt     mysql.NullTime `db:"revoked_at"`
+}
+
+type APIKeyJSON struct {
+	ID            int    `json:"id"`
+	Name          string `json:"name"`
+	KeyPrefix     string `json:"key_prefix"`
+	Role          string `json:"role"`
+	CreatedByName string `json:"created_by_name"`
+	CreatedAt     string `json:"created_at"`
+	LastUsedAt    string `json:"last_used_at"`
+	RevokedAt     string `json:"revoked_at"`
+	// Key is only set when the key is created. It can't be
+	// retrieved afterwards.
+	Key string `json:"key,omitempty"`
+}
+
+type APIKeyRequest struct {
+	APIKeyID   int    `db:"api_key_id"`
+	Method     string `db:"method"`
+	Path       string `db:"path"`
+	Status     int    `db:"status"`
+	RemoteAddr string `db:"remote_addr"`
+}
+
+/** Functions and Methods */
+
+// CreateAPIKey creates a new API key with the given role, and returns
+// it along with the key itself. Only a hash of the key is stored, so
+// this is the only chance to see it.
+func CreateAPIKey(db *sqlx.DB, name, role string, user ADBUser) (APIKeyJSON, error) {
+	name = strings.TrimSpace(name)
+	if name == "" {
+		return APIKeyJSON{}, errors.New("API key name cannot be empty")
+	}
+	// API keys can be given any of the roles users can.
+	exists, err := roleExists(db, role)
+	if err != nil {
+		return APIKeyJSON{}, err
+	}
+	if !exists {
+		return APIKeyJSON{}, errors.Errorf("Not a valid role: %s", role)
+	}
+
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return APIKeyJSON{}, errors.Wrap(err, "failed to generate API key")
+	}
+	key := apiKeyPrefix + base64.RawURLEncoding.EncodeToString(b)
+
+	res, err := db.Exec(`
+INSERT INTO api_keys (name, key_prefix, key_hash, role, created_by)
+VALUES (?, ?, ?, ?, ?)`, name, key[:apiKeyPrefixLength], hashAPIKey(key), role, user.ID)
+	if err != nil {
+		return APIKeyJSON{}, errors.Wrapf(err, "failed to create API key %s", name)
+	}
+	id, err := res.LastInsertId()
+	if err != nil {
+		return APIKeyJSON{}, errors.Wrap(err, "failed to get API key id")
+	}
+
+	keys, err := getAPIKeys(db, int(id))
+	if err != nil {
+		return APIKeyJSON{}, err
+	}
+	if len(keys) != 1 {
+		return APIKeyJSON{}, errors.Errorf("failed to find new API key %d", id)
+	}
+	keyJSON := buildAPIKeyJSON(keys[0])
+	keyJSON.Key = key
+	return keyJSON, nil
+}
+
+func GetAPIKeysJSON(db *sqlx.DB) ([]APIKeyJSON, error) {
+	keys, err := getAPIKeys(db, 0)
+	if err != nil {
+		return nil, err
+	}
+	keysJSON := []APIKeyJSON{}
+	for _, k := range keys {
+		keysJSON = append(keysJSON, buildAPIKeyJSON(k))
+	}
+	return keysJSON, nil
+}
+
+func getAPIKeys(db *sqlx.DB, id int) ([]APIKey, error) {
+	query := selectAPIKeyBaseQuery
+	var queryArgs []interface{}
+	if id != 0 {
+		query += " WHERE k.id = ? "
+		queryArgs = append(queryArgs, id)
+	}
+	query += " ORDER BY k.revoked_at IS NOT NULL, k.name "
+
+	var keys []APIKey
+	if err := db.Select(&keys, query, queryArgs...); err != nil {
+		return nil, errors.Wrap(err, "failed to select API keys")
+	}
+	return keys, nil
+}
+
+func buildAPIKeyJSON(k APIKey) APIKeyJSON {
+	keyJSON := APIKeyJSON{
+		ID:            k.ID,
+		Name:          k.Name,
+		KeyPrefix:     k.KeyPrefix,
+		Role:          k.Role,
+		CreatedByName: k.CreatedByName,
+		CreatedAt:     k.CreatedAt.Format(time.RFC3339),
+	}
+	if k.LastUsedAt.Valid {
+		keyJSON.LastUsedAt = k.LastUsedAt.Time.Format(time.RFC3339)
+	}
+	if k.RevokedAt.Valid {
+		keyJSON.RevokedAt = k.RevokedAt.Time.Format(time.RFC3339)
+	}
+	return keyJSON
+}
+
+// RevokeAPIKey stops an API key from authenticating. Revoked keys are
+// kept so their request log still makes sense.
+func RevokeAPIKey(db *sqlx.DB, id int, user ADBUser) error {
+	res, err := db.Exec(`
+UPDATE api_keys
+SET revoked_at = NOW(), revoked_by = ?
+WHERE id = ? AND revoked_at IS NULL`, user.ID, id)
+	if err != nil {
+		return errors.Wrapf(err, "failed to revoke API key %d", id)
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return errors.Wrapf(err, "failed to revoke API key %d", id)
+	}
+	if n == 0 {
+		return errors.Errorf("API key %d does not exist or is already revoked", id)
+	}
+	return nil
+}
+
+// AuthenticateAPIKey returns the API key matching key, or an error if
+// there isn't one or it has been revoked.
+func AuthenticateAPIKey(db *sqlx.DB, key string) (APIKey, error) {
+	if !strings.HasPrefix(key, apiKeyPrefix) {
+		return APIKey{}, errors.New("Invalid API key")
+	}
+
+	var keys []APIKey
+	err := db.Select(&keys, selectAPIKeyBaseQuery+`
+WHERE k.key_hash = ? AND k.revoked_at IS NULL`, hashAPIKey(key))
+	if err != nil {
+		return APIKey{}, errors.Wrap(err, "failed to select API key")
+	}
+	if len(keys) == 0 {
+		return APIKey{}, errors.New("Invalid API key")
+	}
+	return keys[0], nil
+}
+
+// ADBUser returns the user that requests made with the key act as. It
+// has no ID, so changes made with the key aren't attributed to whoever
+// created it; instead its email, which audit logs record, names the
+// key.
+func (k APIKey) ADBUser() ADBUser {
+	email := fmt.Sprintf("api-key:%d:%s", k.ID, k.Name)
+	// Audit logs store at most 60 characters of the email.
+	if len(email) > 60 {
+		email = email[:60]
+	}
+	return ADBUser{
+		Email: email,
+		Name:  "API key: " + k.Name,
+		Roles: []UserRole{{Role: k.Role}},
+	}
+}
+
+// LogAPIKeyRequest records a request made with an API key, and marks
+// the key as used.
+func LogAPIKeyRequest(db *sqlx.DB, request APIKeyRequest) error {
+	if len(request.Path) > 255 {
+		request.Path = request.Path[:255]
+	}
+	tx, err := db.Beginx()
+	if err != nil {
+		return errors.Wrap(err, "failed to create transaction")
+	}
+	_, err = tx.NamedExec(`
+INSERT INTO api_key_requests (api_key_id, method, path, status, remote_addr)
+VALUES (:api_key_id, :method, :path, :status, :remote_addr)`, request)
+	if err != nil {
+		tx.Rollback()
+		return errors.Wrap(err, "failed to log API key request")
+	}
+	_, err = tx.Exec(`UPDATE api_keys SET last_used_at = NOW() WHERE id = ?`, request.APIKeyID)
+	if err != nil {
+		tx.Rollback()
+		return errors.Wrap(err, "failed to update API key last use")
+	}
+	if err := tx.Commit(); err != nil {
+		tx.Rollback()
+		return errors.Wrap(err, "failed to commit API key request")
+	}
+	return nil
+}
+
+func hashAPIKey(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:])
+}
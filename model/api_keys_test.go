@@ -0,0 +1,51 @@
+package model
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestAPIKeys(t *testing.T) {
+	db := newTestDB()
+	defer db.Close()
+
+	_, err := CreateAPIKey(db, "Sheet Sync", "superuser", ADBUser{})
+	require.Error(t, err)
+
+	created, err := CreateAPIKey(db, "Sheet Sync", "organizer", ADBUser{})
+	require.NoError(t, err)
+	require.NotEmpty(t, created.Key)
+	require.Equal(t, created.Key[:apiKeyPrefixLength], created.KeyPrefix)
+
+	keys, err := GetAPIKeysJSON(db)
+	require.NoError(t, err)
+	require.Len(t, keys, 1)
+	// The key itself can't be retrieved after it's created.
+	require.Empty(t, keys[0].Key)
+
+	apiKey, err := AuthenticateAPIKey(db, created.Key)
+	require.NoError(t, err)
+	require.Equal(t, created.ID, apiKey.ID)
+	require.Equal(t, []UserRole{{Role: "organizer"}}, apiKey.ADBUser().Roles)
+	require.Equal(t, fmt.Sprintf("api-key:%d:Sheet Sync", created.ID), apiKey.ADBUser().Email)
+
+	_, err = AuthenticateAPIKey(db, created.Key+"x")
+	require.Error(t, err)
+
+	require.NoError(t, LogAPIKeyRequest(db, APIKeyRequest{
+		APIKeyID: apiKey.ID,
+		Method:   "GET",
+		Path:     "/activist/list",
+		Status:   200,
+	}))
+	keys, err = GetAPIKeysJSON(db)
+	require.NoError(t, err)
+	require.NotEmpty(t, keys[0].LastUsedAt)
+
+	require.NoError(t, RevokeAPIKey(db, created.ID, ADBUser{}))
+	require.Error(t, RevokeAPIKey(db, created.ID, ADBUser{}))
+	_, err = AuthenticateAPIKey(db, created.Key)
+	require.Error(t, err)
+}
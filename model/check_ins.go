@@ -0,0 +1,391 @@
+package model
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/pkg/errors"
+)
+
+/** Constant and Variable Definitions */
+
+const (
+	CheckInPending  = "pending"
+	CheckInApproved = "approved"
+	CheckInRejected = "rejected"
+)
+
+// CheckInDays is how many days after an event's date its check-in
+// link stops working, so people can still check in if the event runs
+// late or they forget until the next day.
+const CheckInDays = 2
+
+/** Type Definitions */
+
+type CheckIn struct {
+	ID         int       `db:"id"`
+	EventID    int       `db:"event_id"`
+	Name       string    `db:"name"`
+	Email      string    `db:"email"`
+	Phone      string    `db:"phone"`
+	ActivistID int       `db:"activist_id"` // 0 if it didn't match an activist
+	Status     string    `db:"status"`
+	CreatedAt  time.Time `db:"created_at"`
+}
+
+type CheckInJSON struct {
+	ID        int    `json:"id"`
+	EventID   int    `json:"event_id"`
+	EventName string `json:"event_name"`
+	EventDate string `json:"event_date"`
+	Name      string `json:"name"`
+	Email     string `json:"email"`
+	Phone     string `json:"phone"`
+	// The existing activist the check-in matched by email or name,
+	// if any.
+	ActivistID   int    `json:"activist_id"`
+	ActivistName string `json:"activist_name"`
+	// Activists with similar names, for check-ins that didn't match.
+	Suggestions []ActivistMatchJSON `json:"suggestions"`
+	CreatedAt   string              `json:"created_at"`
+}
+
+/** Functions and Methods */
+
+// CheckInExpiry is when the check-in link for an event on eventDate
+// expires.
+func CheckInExpiry(eventDate time.Time) time.Time {
+	return recurrenceDate(eventDate).AddDate(0, 0, CheckInDays)
+}
+
+// NewCheckInToken returns the token in an event's public check-in
+// link. It's signed with secret, so it can't be forged or changed to
+// another event, and it stops working after expires.
+func NewCheckInToken(secret []byte, eventID int, expires time.Time) string {
+	payload := strconv.Itoa(eventID) + "." + strconv.FormatInt(expires.Unix(), 10)
+	return payload + "." + signCheckInPayload(secret, payload)
+}
+
+// ParseCheckInToken returns the event a check-in token is for, or an
+// error if the token is invalid or expired.
+func ParseCheckInToken(secret []byte, token string, now time.Time) (int, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return 0, errors.New("Invalid check-in link")
+	}
+	payload := parts[0] + "." + parts[1]
+	if !hmac.Equal([]byte(parts[2]), []byte(signCheckInPayload(secret, payload))) {
+		return 0, errors.New("Invalid check-in link")
+	}
+	eventID, err := strconv.Atoi(parts[0])
+	if err != nil {
+		return 0, errors.New("Invalid check-in link")
+	}
+	expires, err := strconv.ParseInt(parts[1], 10, 64)
+	if err != nil {
+		return 0, errors.New("Invalid check-in link")
+	}
+	if !now.Before(time.Unix(expires, 0)) {
+		return 0, errors.New("Check-in for this event has closed")
+	}
+	return eventID, nil
+}
+
+// NewEventCheckInToken returns the check-in token for an event and
+// when it expires.
+func NewEventCheckInToken(db *sqlx.DB, secret []byte, eventID int) (string, time.Time, error) {
+	var dates []time.Time
+	err := db.Select(&dates, `SELECT date FROM events WHERE id = ? AND deleted_at IS NULL`, eventID)
+	if err != nil {
+		return "", time.Time{}, errors.Wrapf(err, "failed to get event %d", eventID)
+	}
+	if len(dates) == 0 {
+		return "", time.Time{}, errors.Errorf("Event %d does not exist", eventID)
+	}
+	expires := CheckInExpiry(dates[0])
+	return NewCheckInToken(secret, eventID, expires), expires, nil
+}
+
+func signCheckInPayload(secret []byte, payload string) string {
+	mac := hmac.New(sha256.New, secret)
+	mac.Write([]byte("check-in:" + payload))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+// CleanCheckInData validates what an attendee entered on the check-in
+// page.
+func CleanCheckInData(name, email, phone string) (CheckIn, error) {
+	c := CheckIn{
+		Name:  cleanAttendeeName(name),
+		Email: strings.ToLower(strings.TrimSpace(email)),
+		Phone: strings.TrimSpace(phone),
+	}
+	if c.Name == "" {
+		return CheckIn{}, errors.New("Please enter your name")
+	}
+	if len(c.Name) > 80 || len(c.Email) > 100 || len(c.Phone) > 20 {
+		return CheckIn{}, errors.New("Name, email or phone is too long")
+	}
+	for _, field := range []string{c.Name, c.Email, c.Phone} {
+		if err := checkForDangerousChars(field); err != nil {
+			return CheckIn{}, err
+		}
+	}
+	if c.Email != "" && !strings.Contains(c.Email, "@") {
+		return CheckIn{}, errors.New("Please enter a valid email address")
+	}
+	return c, nil
+}
+
+// CreateCheckIn adds a check-in to the event's pending queue, matched
+// against existing activists the same way imported attendees are: by
+// email if exactly one activist has it, and otherwise by name.
+// Submitting the same check-in twice only queues it once.
+func CreateCheckIn(db *sqlx.DB, eventID int, c CheckIn) error {
+	var count int
+	err := db.Get(&count, `
+SELECT COUNT(*)
+FROM event_check_ins
+WHERE event_id = ? AND status = ? AND name = ? AND email = ?`, eventID, CheckInPending, c.Name, c.Email)
+	if err != nil {
+		return errors.Wrap(err, "failed to check for duplicate check-ins")
+	}
+	if count > 0 {
+		return nil
+	}
+
+	activistID, err := matchCheckIn(db, c)
+	if err != nil {
+		return err
+	}
+	_, err = db.Exec(`
+INSERT INTO event_check_ins (event_id, name, email, phone, activist_id)
+VALUES (?, ?, ?, ?, NULLIF(?, 0))`, eventID, c.Name, c.Email, c.Phone, activistID)
+	if err != nil {
+		return errors.Wrapf(err, "failed to check in to event %d", eventID)
+	}
+	return nil
+}
+
+// matchCheckIn returns the ID of the visible activist the check-in
+// matches, or 0 if there isn't one.
+func matchCheckIn(db *sqlx.DB, c CheckIn) (int, error) {
+	if c.Email != "" {
+		var ids []int
+		err := db.Select(&ids, `SELECT id FROM activists WHERE hidden = 0 AND LOWER(TRIM(email)) = ?`, c.Email)
+		if err != nil {
+			return 0, errors.Wrap(err, "failed to match check-in email")
+		}
+		if len(ids) == 1 {
+			return ids[0], nil
+		}
+	}
+	var ids []int
+	err := db.Select(&ids, `SELECT id FROM activists WHERE hidden = 0 AND name = ?`, c.Name)
+	if err != nil {
+		return 0, errors.Wrap(err, "failed to match check-in name")
+	}
+	if len(ids) == 1 {
+		return ids[0], nil
+	}
+	return 0, nil
+}
+
+// GetPendingCheckInsJSON returns the check-ins waiting for approval,
+// oldest first, for one event or for every event if eventID is 0.
+func GetPendingCheckInsJSON(db *sqlx.DB, eventID int) ([]CheckInJSON, error) {
+	query := `
+SELECT
+  c.id, c.event_id, e.name AS event_name, e.date AS event_date,
+  c.name, c.email, c.phone,
+  IFNULL(c.activist_id, 0) AS activist_id, IFNULL(a.name, '') AS activist_name,
+  c.created_at
+FROM event_check_ins c
+JOIN events e ON e.id = c.event_id AND e.deleted_at IS NULL
+LEFT JOIN activists a ON a.id = c.activist_id
+WHERE c.status = ?`
+	queryArgs := []interface{}{CheckInPending}
+	if eventID != 0 {
+		query += " AND c.event_id = ?"
+		queryArgs = append(queryArgs, eventID)
+	}
+	query += " ORDER BY c.created_at, c.id"
+
+	var rows []struct {
+		CheckIn
+		EventName    string    `db:"event_name"`
+		EventDate    time.Time `db:"event_date"`
+		ActivistName string    `db:"activist_name"`
+	}
+	if err := db.Select(&rows, query, queryArgs...); err != nil {
+		return nil, errors.Wrap(err, "failed to select pending check-ins")
+	}
+
+	var activists []Activist
+	checkIns := []CheckInJSON{}
+	for _, row := range rows {
+		c := CheckInJSON{
+			ID:           row.ID,
+			EventID:      row.EventID,
+			EventName:    row.EventName,
+			EventDate:    row.EventDate.Format(EventDateLayout),
+			Name:         row.Name,
+			Email:        row.Email,
+			Phone:        row.Phone,
+			ActivistID:   row.ActivistID,
+			ActivistName: row.ActivistName,
+			Suggestions:  []ActivistMatchJSON{},
+			CreatedAt:    row.CreatedAt.Format(time.RFC3339),
+		}
+		if c.ActivistID == 0 {
+			if activists == nil {
+				err := db.Select(&activists, `SELECT id, name, email FROM activists WHERE hidden = 0`)
+				if err != nil {
+					return nil, errors.Wrap(err, "failed to select activists")
+				}
+			}
+			c.Suggestions = matchActivists(c.Name, activists)
+		}
+		checkIns = append(checkIns, c)
+	}
+	return checkIns, nil
+}
+
+// ApproveCheckIn marks a pending check-in's activist as attending its
+// event. The activist is the one named by activistName if it isn't
+// empty, and otherwise the one the check-in matched; if it matched no
+// one, a new activist is created from the check-in. Email and phone
+// numbers from the check-in are filled in on the activist if they
+// don't have one yet.
+func ApproveCheckIn(db *sqlx.DB, checkInID int, activistName string, user ADBUser) error {
+	tx, err := db.Beginx()
+	if err != nil {
+		return errors.Wrap(err, "failed to create transaction")
+	}
+	c, err := getPendingCheckInTx(tx, checkInID)
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	activistID := c.ActivistID
+	if activistName = strings.TrimSpace(activistName); activistName != "" {
+		var ids []int
+		err := tx.Select(&ids, `SELECT id FROM activists WHERE hidden = 0 AND name = ?`, activistName)
+		if err != nil {
+			tx.Rollback()
+			return errors.Wrapf(err, "failed to get activist %s", activistName)
+		}
+		if len(ids) != 1 {
+			tx.Rollback()
+			return errors.Errorf("Activist %s does not exist", activistName)
+		}
+		activistID = ids[0]
+	}
+
+	if activistID == 0 {
+		activistID, err = createActivistTx(tx, ActivistExtra{
+			Activist:               Activist{Name: c.Name, Email: c.Email, Phone: c.Phone},
+			ActivistMembershipData: ActivistMembershipData{ActivistLevel: "Supporter"},
+		}, user)
+		if err != nil {
+			tx.Rollback()
+			return err
+		}
+	} else if c.Email != "" || c.Phone != "" {
+		activist, err := getActivistExtraTx(tx, activistID)
+		if err != nil {
+			tx.Rollback()
+			return err
+		}
+		changed := false
+		if activist.Email == "" && c.Email != "" {
+			activist.Email, changed = c.Email, true
+		}
+		if activist.Phone == "" && c.Phone != "" {
+			activist.Phone, changed = c.Phone, true
+		}
+		if changed {
+			if err := updateActivistDataTx(tx, activist, user); err != nil {
+				tx.Rollback()
+				return err
+			}
+		}
+	}
+
+	err = insertEventAttendance(tx, Event{
+		ID:             c.EventID,
+		AddedAttendees: []Activist{{ID: activistID}},
+	})
+	if err != nil {
+		tx.Rollback()
+		return errors.Wrapf(err, "failed to add check-in %d to event %d", checkInID, c.EventID)
+	}
+	if err := reviewCheckInTx(tx, checkInID, CheckInApproved, activistID, user); err != nil {
+		tx.Rollback()
+		return err
+	}
+	if err := tx.Commit(); err != nil {
+		tx.Rollback()
+		return errors.Wrap(err, "failed to commit check-in approval")
+	}
+	return nil
+}
+
+// RejectCheckIn removes a pending check-in from the queue without
+// marking anyone as attending.
+func RejectCheckIn(db *sqlx.DB, checkInID int, user ADBUser) error {
+	tx, err := db.Beginx()
+	if err != nil {
+		return errors.Wrap(err, "failed to create transaction")
+	}
+	c, err := getPendingCheckInTx(tx, checkInID)
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+	if err := reviewCheckInTx(tx, checkInID, CheckInRejected, c.ActivistID, user); err != nil {
+		tx.Rollback()
+		return err
+	}
+	if err := tx.Commit(); err != nil {
+		tx.Rollback()
+		return errors.Wrap(err, "failed to commit check-in rejection")
+	}
+	return nil
+}
+
+func getPendingCheckInTx(tx *sqlx.Tx, checkInID int) (CheckIn, error) {
+	var checkIns []CheckIn
+	err := tx.Select(&checkIns, `
+SELECT id, event_id, name, email, phone, IFNULL(activist_id, 0) AS activist_id, status, created_at
+FROM event_check_ins
+WHERE id = ?
+FOR UPDATE`, checkInID)
+	if err != nil {
+		return CheckIn{}, errors.Wrapf(err, "failed to get check-in %d", checkInID)
+	}
+	if len(checkIns) == 0 {
+		return CheckIn{}, errors.Errorf("Check-in %d does not exist", checkInID)
+	}
+	if checkIns[0].Status != CheckInPending {
+		return CheckIn{}, errors.Errorf("Check-in %d was already %s", checkInID, checkIns[0].Status)
+	}
+	return checkIns[0], nil
+}
+
+func reviewCheckInTx(tx *sqlx.Tx, checkInID int, status string, activistID int, user ADBUser) error {
+	_, err := tx.Exec(`
+UPDATE event_check_ins
+SET status = ?, activist_id = NULLIF(?, 0), reviewed_at = NOW(), reviewed_by = NULLIF(?, 0)
+WHERE id = ?`, status, activistID, user.ID, checkInID)
+	if err != nil {
+		return errors.Wrapf(err, "failed to update check-in %d", checkInID)
+	}
+	return nil
+}
@@ -0,0 +1,95 @@
+package model
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestCheckInToken(t *testing.T) {
+	secret := []byte("secret")
+	now := time.Date(2020, 5, 1, 12, 0, 0, 0, time.UTC)
+	expires := CheckInExpiry(mpiDate("2020-05-01"))
+	require.Equal(t, mpiDate("2020-05-03"), expires)
+
+	token := NewCheckInToken(secret, 42, expires)
+	eventID, err := ParseCheckInToken(secret, token, now)
+	require.NoError(t, err)
+	require.Equal(t, 42, eventID)
+
+	// Tokens can't be used after they expire, with another secret, or
+	// for another event.
+	_, err = ParseCheckInToken(secret, token, expires)
+	require.Error(t, err)
+	_, err = ParseCheckInToken([]byte("other secret"), token, now)
+	require.Error(t, err)
+	forged := "43" + token[2:]
+	_, err = ParseCheckInToken(secret, forged, now)
+	require.Error(t, err)
+	_, err = ParseCheckInToken(secret, "garbage", now)
+	require.Error(t, err)
+}
+
+func TestCleanCheckInData(t *testing.T) {
+	c, err := CleanCheckInData("  jane doe ", " Jane@Example.com ", " 555-1234 ")
+	require.NoError(t, err)
+	require.Equal(t, CheckIn{Name: "Jane Doe", Email: "jane@example.com", Phone: "555-1234"}, c)
+
+	for _, test := range [][3]string{
+		{"", "jane@example.com", ""},
+		{"Jane <b>Doe</b>", "", ""},
+		{"Jane Doe", "not an email", ""},
+	} {
+		_, err := CleanCheckInData(test[0], test[1], test[2])
+		require.Error(t, err, test[0])
+	}
+}
+
+func TestCheckIns(t *testing.T) {
+	db := newTestDB()
+	defer db.Close()
+
+	known, err := GetOrCreateActivist(db, "Known Activist", ADBUser{})
+	require.NoError(t, err)
+	eventID, err := InsertUpdateEvent(db, Event{
+		EventName: "Chapter Meeting",
+		EventDate: mpiDate("2020-05-01"),
+		EventType: "Meeting",
+	})
+	require.NoError(t, err)
+
+	// One check-in matches an existing activist by name, and the other
+	// doesn't match anyone. Submitting a check-in twice queues it once.
+	for _, c := range []CheckIn{
+		{Name: "Known Activist", Email: "known@example.com"},
+		{Name: "New Person", Phone: "555-1234"},
+		{Name: "New Person", Phone: "555-1234"},
+	} {
+		require.NoError(t, CreateCheckIn(db, eventID, c))
+	}
+
+	pending, err := GetPendingCheckInsJSON(db, eventID)
+	require.NoError(t, err)
+	require.Len(t, pending, 2)
+	require.Equal(t, known.ID, pending[0].ActivistID)
+	require.Equal(t, "Chapter Meeting", pending[0].EventName)
+	require.Equal(t, 0, pending[1].ActivistID)
+
+	require.NoError(t, ApproveCheckIn(db, pending[0].ID, "", ADBUser{}))
+	require.NoError(t, ApproveCheckIn(db, pending[1].ID, "", ADBUser{}))
+	require.Error(t, ApproveCheckIn(db, pending[1].ID, "", ADBUser{}))
+
+	event, err := GetEvent(db, GetEventOptions{EventID: eventID})
+	require.NoError(t, err)
+	require.ElementsMatch(t, []string{"Known Activist", "New Person"}, event.Attendees)
+
+	// The known activist's missing email was filled in.
+	activist, err := GetActivist(db, "Known Activist")
+	require.NoError(t, err)
+	require.Equal(t, "known@example.com", activist.Email)
+
+	pending, err = GetPendingCheckInsJSON(db, 0)
+	require.NoError(t, err)
+	require.Empty(t, pending)
+}
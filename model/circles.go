@@ -286,6 +286,48 @@ WHERE id = ?`, circleGroupID)
 	return nil
 }
 
+// LeadsCircleGroup reports whether the activist is a point person of
+// the circle.
+func LeadsCircleGroup(db *sqlx.DB, activistID, circleGroupID int) (bool, error) {
+	if activistID == 0 || circleGroupID == 0 {
+		return false, nil
+	}
+	var count int
+	err := db.Get(&count, `
+SELECT COUNT(*)
+FROM circle_members
+WHERE circle_id = ? AND activist_id = ? AND point_person = 1`, circleGroupID, activistID)
+	if err != nil {
+		return false, errors.Wrapf(err, "failed to check if activist %d leads circle %d", activistID, circleGroupID)
+	}
+	return count != 0, nil
+}
+
+// CheckCircleGroupMembersNotAdded returns an error if circleGroup has members
+// or point people that the stored circle doesn't. Users who can only
+// edit the groups they lead may remove members, but adding them would
+// let them see more activists.
+func CheckCircleGroupMembersNotAdded(db *sqlx.DB, circleGroup CircleGroup) error {
+	stored, err := GetCircleGroup(db, CircleGroupQueryOptions{GroupID: circleGroup.ID})
+	if err != nil {
+		return err
+	}
+	pointPeople := map[int]bool{}
+	for _, m := range stored.Members {
+		pointPeople[m.ActivistID] = m.PointPerson
+	}
+	for _, m := range circleGroup.Members {
+		pointPerson, ok := pointPeople[m.ActivistID]
+		if !ok {
+			return errors.Errorf("You can't add %s to the circle", m.ActivistName)
+		}
+		if m.PointPerson && !pointPerson {
+			return errors.Errorf("You can't make %s a point person of the circle", m.ActivistName)
+		}
+	}
+	return nil
+}
+
 func GetCircleGroupJSON(db *sqlx.DB, circleGroupID int) (CircleGroupJSON, error) {
 	cirs, err := getCircleGroupsJSON(db, CircleGroupQueryOptions{
 		GroupID: circleGroupID,
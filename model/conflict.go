@@ -0,0 +1,16 @@
+package model
+
+/** Type Definitions */
+
+// ConflictError is returned when saving an event or activist whose
+// version doesn't match the database, because someone else saved it
+// since the client loaded it. Current is what's in the database now,
+// as the JSON the client loads it as.
+type ConflictError struct {
+	What    string
+	Current interface{}
+}
+
+func (e *ConflictError) Error() string {
+	return "This " + e.What + " was changed by someone else since you loaded it. Review their changes and save again."
+}
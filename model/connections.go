@@ -0,0 +1,368 @@
+package model
+
+import (
+	"database/sql"
+	"encoding/json"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/go-sql-driver/mysql"
+	"github.com/jmoiron/sqlx"
+	"github.com/pkg/errors"
+)
+
+/** Type Definitions */
+
+// Connection is a maintenance connection: a one-on-one conversation
+// between a connector and a connectee.
+type Connection struct {
+	ID            int            `db:"id"`
+	ConnectorID   int            `db:"connector_id"` // 0 if the connector isn't an activist
+	ConnectorName string         `db:"connector_name"`
+	ConnecteeID   int            `db:"connectee_id"`
+	ConnecteeName string         `db:"connectee_name"`
+	Date          time.Time      `db:"date"`
+	Notes         string         `db:"notes"`
+	FollowUpDate  mysql.NullTime `db:"follow_up_date"`
+	CreatedBy     int            `db:"created_by"`
+	Version       int            `db:"version"` // Incremented on every update
+}
+
+type ConnectionJSON struct {
+	ConnectionID int    `json:"connection_id"`
+	Connector    string `json:"connector"`    // Looked up by name when saving
+	ConnectorID  int    `json:"connector_id"` // Ignored when saving
+	Connectee    string `json:"connectee"`
+	ConnecteeID  int    `json:"connectee_id"`
+	Date         string `json:"date"`
+	Notes        string `json:"notes"`
+	FollowUpDate string `json:"follow_up_date"` // Empty if no follow-up is planned
+	Version      int    `json:"version"`        // Must be sent back unchanged when updating connections
+}
+
+type GetConnectionOptions struct {
+	ConnectionID int
+	Connector    string // The connector's name
+	Connectee    string // The connectee's name
+	DateFrom     string
+	DateTo       string
+	// FollowUpBy lists connections with a follow-up planned on or
+	// before it.
+	FollowUpBy string
+}
+
+/** Functions and Methods */
+
+func (c Connection) ToJSON() ConnectionJSON {
+	followUpDate := ""
+	if c.FollowUpDate.Valid {
+		followUpDate = c.FollowUpDate.Time.Format(EventDateLayout)
+	}
+	return ConnectionJSON{
+		ConnectionID: c.ID,
+		Connector:    c.ConnectorName,
+		ConnectorID:  c.ConnectorID,
+		Connectee:    c.ConnecteeName,
+		ConnecteeID:  c.ConnecteeID,
+		Date:         c.Date.Format(EventDateLayout),
+		Notes:        c.Notes,
+		FollowUpDate: followUpDate,
+		Version:      c.Version,
+	}
+}
+
+func GetConnectionsJSON(db *sqlx.DB, options GetConnectionOptions) ([]ConnectionJSON, error) {
+	connections, err := getConnections(db, options)
+	if err != nil {
+		return nil, err
+	}
+	connectionsJSON := make([]ConnectionJSON, 0, len(connections))
+	for _, c := range connections {
+		connectionsJSON = append(connectionsJSON, c.ToJSON())
+	}
+	return connectionsJSON, nil
+}
+
+func GetConnection(db *sqlx.DB, connectionID int) (Connection, error) {
+	if connectionID == 0 {
+		return Connection{}, errors.New("ConnectionID for GetConnection cannot be zero")
+	}
+	connections, err := getConnections(db, GetConnectionOptions{ConnectionID: connectionID})
+	if err != nil {
+		return Connection{}, err
+	}
+	if len(connections) == 0 {
+		return Connection{}, errors.Errorf("Connection %d does not exist", connectionID)
+	}
+	return connections[0], nil
+}
+
+func getConnections(db *sqlx.DB, options GetConnectionOptions) ([]Connection, error) {
+	query := `
+SELECT
+  c.id,
+  IFNULL(c.connector_id, 0) AS connector_id, IFNULL(r.name, '') AS connector_name,
+  c.connectee_id, e.name AS connectee_name,
+  c.date,
+  IFNULL(c.notes, '') AS notes,
+  c.follow_up_date,
+  IFNULL(c.created_by, 0) AS created_by,
+  c.version
+FROM connections c
+LEFT JOIN activists r ON r.id = c.connector_id
+JOIN activists e ON e.id = c.connectee_id
+`
+
+	// Deleted connections were converted from connection events that
+	// were in the trash.
+	whereClause := []string{"c.deleted_at IS NULL"}
+	var queryArgs []interface{}
+	where := func(clause string, args ...interface{}) {
+		whereClause = append(whereClause, clause)
+		queryArgs = append(queryArgs, args...)
+	}
+
+	if options.ConnectionID != 0 {
+		where("c.id = ?", options.ConnectionID)
+	}
+	if options.Connector != "" {
+		where("r.name = ?", options.Connector)
+	}
+	if options.Connectee != "" {
+		where("e.name = ?", options.Connectee)
+	}
+	if options.DateFrom != "" {
+		where("c.date >= ?", options.DateFrom)
+	}
+	if options.DateTo != "" {
+		where("c.date <= ?", options.DateTo)
+	}
+	if options.FollowUpBy != "" {
+		where("c.follow_up_date <= ?", options.FollowUpBy)
+	}
+
+	query += ` WHERE ` + strings.Join(whereClause, " AND ")
+	query += ` ORDER BY c.date DESC, c.id DESC`
+
+	var connections []Connection
+	if err := db.Select(&connections, query, queryArgs...); err != nil {
+		return nil, errors.Wrap(err, "failed to select connections")
+	}
+	return connections, nil
+}
+
+// CleanConnectionData decodes and validates a connection. The
+// connector and connectee must both be existing activists.
+func CleanConnectionData(db *sqlx.DB, body io.Reader, user ADBUser) (Connection, error) {
+	var connectionJSON ConnectionJSON
+	if err := json.NewDecoder(body).Decode(&connectionJSON); err != nil {
+		return Connection{}, err
+	}
+
+	c := Connection{
+		ID:        connectionJSON.ConnectionID,
+		Notes:     strings.TrimSpace(connectionJSON.Notes),
+		CreatedBy: user.ID,
+		Version:   connectionJSON.Version,
+	}
+	if c.ID != 0 && c.Version == 0 {
+		return Connection{}, errors.New("Missing connection version; reload the page and try again")
+	}
+
+	var err error
+	c.ConnectorID, err = getConnectionActivistID(db, "Connector", connectionJSON.Connector)
+	if err != nil {
+		return Connection{}, err
+	}
+	c.ConnecteeID, err = getConnectionActivistID(db, "Connectee", connectionJSON.Connectee)
+	if err != nil {
+		return Connection{}, err
+	}
+	if c.ConnectorID == c.ConnecteeID {
+		return Connection{}, errors.New("Connector and connectee must be different activists")
+	}
+
+	c.Date, err = time.Parse(EventDateLayout, connectionJSON.Date)
+	if err != nil {
+		return Connection{}, errors.Wrap(err, "invalid connection date")
+	}
+	if connectionJSON.FollowUpDate != "" {
+		followUp, err := time.Parse(EventDateLayout, connectionJSON.FollowUpDate)
+		if err != nil {
+			return Connection{}, errors.Wrap(err, "invalid follow-up date")
+		}
+		if followUp.Before(c.Date) {
+			return Connection{}, errors.New("Follow-up date must not be before the connection")
+		}
+		c.FollowUpDate = mysql.NullTime{Time: followUp, Valid: true}
+	}
+	return c, nil
+}
+
+func getConnectionActivistID(db *sqlx.DB, role, name string) (int, error) {
+	name = strings.TrimSpace(name)
+	if name == "" {
+		return 0, errors.Errorf("%s cannot be empty", role)
+	}
+	if err := checkForDangerousChars(name); err != nil {
+		return 0, err
+	}
+	activists, err := getActivists(db, name)
+	if err != nil {
+		return 0, err
+	}
+	if len(activists) != 1 {
+		return 0, errors.Errorf("%s %s is not an activist", role, name)
+	}
+	return activists[0].ID, nil
+}
+
+// SaveConnection creates a connection if it has no ID, or updates the
+// existing one unless someone else updated it since the client loaded
+// it. It returns the connection's ID.
+func SaveConnection(db *sqlx.DB, c Connection) (int, error) {
+	tx, err := db.Beginx()
+	if err != nil {
+		return 0, errors.Wrap(err, "failed to create transaction")
+	}
+
+	// The connectee's last connection changes, and so does the old
+	// connectee's if it's someone else now.
+	activistIDs := []int{c.ConnecteeID}
+	if c.ID == 0 {
+		res, err := tx.NamedExec(`
+INSERT INTO connections (connector_id, connectee_id, date, notes, follow_up_date, created_by)
+VALUES (:connector_id, :connectee_id, :date, :notes, :follow_up_date, NULLIF(:created_by, 0))`, c)
+		if err != nil {
+			tx.Rollback()
+			return 0, errors.Wrap(err, "failed to insert connection")
+		}
+		id, err := res.LastInsertId()
+		if err != nil {
+			tx.Rollback()
+			return 0, errors.Wrap(err, "failed to get inserted connection id")
+		}
+		c.ID = int(id)
+	} else {
+		var oldConnecteeID int
+		err := tx.Get(&oldConnecteeID, `SELECT connectee_id FROM connections WHERE id = ? AND deleted_at IS NULL`, c.ID)
+		if err == sql.ErrNoRows {
+			tx.Rollback()
+			return 0, errors.Errorf("Connection %d does not exist", c.ID)
+		} else if err != nil {
+			tx.Rollback()
+			return 0, errors.Wrapf(err, "failed to get connection %d", c.ID)
+		}
+		activistIDs = append(activistIDs, oldConnecteeID)
+
+		res, err := tx.NamedExec(`UPDATE connections
+SET
+  connector_id = :connector_id,
+  connectee_id = :connectee_id,
+  date = :date,
+  notes = :notes,
+  follow_up_date = :follow_up_date,
+  version = version + 1
+WHERE
+  id = :id AND version = :version`, c)
+		if err != nil {
+			tx.Rollback()
+			return 0, errors.Wrapf(err, "failed to update connection %d", c.ID)
+		}
+		if n, err := res.RowsAffected(); err != nil {
+			tx.Rollback()
+			return 0, errors.Wrapf(err, "failed to update connection %d", c.ID)
+		} else if n == 0 {
+			tx.Rollback()
+			current, err := GetConnection(db, c.ID)
+			if err != nil {
+				return 0, err
+			}
+			return 0, &ConflictError{What: "connection", Current: current.ToJSON()}
+		}
+	}
+
+	if err := updateActivistMetrics(tx, activistIDs); err != nil {
+		tx.Rollback()
+		return 0, err
+	}
+	if err := tx.Commit(); err != nil {
+		tx.Rollback()
+		return 0, errors.Wrap(err, "failed to commit connection")
+	}
+	return c.ID, nil
+}
+
+func DeleteConnection(db *sqlx.DB, connectionID int) error {
+	tx, err := db.Beginx()
+	if err != nil {
+		return errors.Wrap(err, "failed to create transaction")
+	}
+	var connecteeID int
+	err = tx.Get(&connecteeID, `SELECT connectee_id FROM connections WHERE id = ? AND deleted_at IS NULL`, connectionID)
+	if err == sql.ErrNoRows {
+		tx.Rollback()
+		return errors.Errorf("Connection %d does not exist", connectionID)
+	} else if err != nil {
+		tx.Rollback()
+		return errors.Wrapf(err, "failed to get connection %d", connectionID)
+	}
+	if _, err := tx.Exec(`DELETE FROM connections WHERE id = ?`, connectionID); err != nil {
+		tx.Rollback()
+		return errors.Wrapf(err, "failed to delete connection %d", connectionID)
+	}
+	if err := updateActivistMetrics(tx, []int{connecteeID}); err != nil {
+		tx.Rollback()
+		return err
+	}
+	if err := tx.Commit(); err != nil {
+		tx.Rollback()
+		return errors.Wrapf(err, "failed to commit deleting connection %d", connectionID)
+	}
+	return nil
+}
+
+// mergeActivistConnections moves the original activist's connections,
+// as either connector or connectee, to the target, and records which
+// ones it moved so that unmergeActivistConnections can move them back.
+func mergeActivistConnections(tx *sqlx.Tx, originalActivistID, targetActivistID int) error {
+	for _, role := range []string{"connector", "connectee"} {
+		_, err := tx.Exec(`
+INSERT INTO merged_activist_connections (original_activist_id, target_activist_id, connection_id, role)
+SELECT ?, ?, id, ?
+FROM connections
+WHERE `+role+`_id = ?`, originalActivistID, targetActivistID, role, originalActivistID)
+		if err != nil {
+			return errors.Wrapf(err, "failed to record merged connections of activist %d", originalActivistID)
+		}
+		_, err = tx.Exec(`UPDATE connections SET `+role+`_id = ? WHERE `+role+`_id = ?`, targetActivistID, originalActivistID)
+		if err != nil {
+			return errors.Wrapf(err, "failed to move connections of activist %d", originalActivistID)
+		}
+	}
+	return nil
+}
+
+// unmergeActivistConnections gives the original activist back the
+// connections that merging it moved to the target.
+func unmergeActivistConnections(tx *sqlx.Tx, originalActivistID, targetActivistID int) error {
+	for _, role := range []string{"connector", "connectee"} {
+		_, err := tx.Exec(`
+UPDATE connections c
+JOIN merged_activist_connections m ON m.connection_id = c.id
+SET c.`+role+`_id = m.original_activist_id
+WHERE m.original_activist_id = ? AND m.target_activist_id = ? AND m.role = ?
+  AND c.`+role+`_id = m.target_activist_id`, originalActivistID, targetActivistID, role)
+		if err != nil {
+			return errors.Wrapf(err, "failed to restore connections of activist %d", originalActivistID)
+		}
+	}
+	_, err := tx.Exec(`
+DELETE FROM merged_activist_connections
+WHERE original_activist_id = ? AND target_activist_id = ?`, originalActivistID, targetActivistID)
+	if err != nil {
+		return errors.Wrapf(err, "failed to delete merged connections of activist %d", originalActivistID)
+	}
+	return nil
+}
@@ -0,0 +1,145 @@
+package model
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/go-sql-driver/mysql"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCleanConnectionData(t *testing.T) {
+	db := newTestDB()
+	defer db.Close()
+
+	connector, err := GetOrCreateActivist(db, "Connector", ADBUser{})
+	require.NoError(t, err)
+	connectee, err := GetOrCreateActivist(db, "Connectee", ADBUser{})
+	require.NoError(t, err)
+
+	c, err := CleanConnectionData(db, strings.NewReader(`{
+  "connector": " Connector ",
+  "connectee": "Connectee",
+  "date": "2020-05-01",
+  "notes": " Talked about campaigns ",
+  "follow_up_date": "2020-06-01"
+}`), ADBUser{ID: 7})
+	require.NoError(t, err)
+	require.Equal(t, connector.ID, c.ConnectorID)
+	require.Equal(t, connectee.ID, c.ConnecteeID)
+	require.Equal(t, mpiDate("2020-05-01"), c.Date)
+	require.Equal(t, "Talked about campaigns", c.Notes)
+	require.Equal(t, mysql.NullTime{Time: mpiDate("2020-06-01"), Valid: true}, c.FollowUpDate)
+	require.Equal(t, 7, c.CreatedBy)
+
+	for _, body := range []string{
+		`{"connector": "Nobody", "connectee": "Connectee", "date": "2020-05-01"}`,
+		`{"connector": "Connector", "connectee": "Connector", "date": "2020-05-01"}`,
+		`{"connector": "Connector", "connectee": "Connectee", "date": ""}`,
+		`{"connector": "Connector", "connectee": "Connectee", "date": "2020-05-01", "follow_up_date": "2020-04-01"}`,
+		`{"connection_id": 1, "connector": "Connector", "connectee": "Connectee", "date": "2020-05-01"}`,
+	} {
+		_, err := CleanConnectionData(db, strings.NewReader(body), ADBUser{})
+		require.Error(t, err, body)
+	}
+}
+
+func TestSaveConnection(t *testing.T) {
+	db := newTestDB()
+	defer db.Close()
+
+	connector, err := GetOrCreateActivist(db, "Connector", ADBUser{})
+	require.NoError(t, err)
+	connectee, err := GetOrCreateActivist(db, "Connectee", ADBUser{})
+	require.NoError(t, err)
+
+	id, err := SaveConnection(db, Connection{
+		ConnectorID: connector.ID,
+		ConnecteeID: connectee.ID,
+		Date:        mpiDate("2020-05-01"),
+	})
+	require.NoError(t, err)
+
+	connections, err := GetConnectionsJSON(db, GetConnectionOptions{Connectee: "Connectee"})
+	require.NoError(t, err)
+	require.Equal(t, []ConnectionJSON{{
+		ConnectionID: id,
+		Connector:    "Connector",
+		ConnectorID:  connector.ID,
+		Connectee:    "Connectee",
+		ConnecteeID:  connectee.ID,
+		Date:         "2020-05-01",
+		Version:      1,
+	}}, connections)
+
+	activist, err := GetActivistJSON(db, GetActivistOptions{ID: connectee.ID}, testViewer)
+	require.NoError(t, err)
+	require.Equal(t, "2020-05-01", activist.LastConnection)
+
+	// Updating a stale version is rejected.
+	c, err := GetConnection(db, id)
+	require.NoError(t, err)
+	c.Date = mpiDate("2020-05-02")
+	_, err = SaveConnection(db, c)
+	require.NoError(t, err)
+	_, err = SaveConnection(db, c)
+	require.IsType(t, &ConflictError{}, err)
+
+	require.NoError(t, DeleteConnection(db, id))
+	require.Error(t, DeleteConnection(db, id))
+	activist, err = GetActivistJSON(db, GetActivistOptions{ID: connectee.ID}, testViewer)
+	require.NoError(t, err)
+	require.Equal(t, "", activist.LastConnection)
+}
+
+func TestDeletedConnections(t *testing.T) {
+	db := newTestDB()
+	defer db.Close()
+
+	connectee, err := GetOrCreateActivist(db, "Connectee", ADBUser{})
+	require.NoError(t, err)
+
+	// Connection events that were in the trash were converted to
+	// deleted connections.
+	_, err = db.Exec(`
+INSERT INTO connections (connectee_id, date, deleted_at, deleted_by)
+VALUES (?, '2020-05-01', NOW(), 1)`, connectee.ID)
+	require.NoError(t, err)
+	require.NoError(t, updateActivistMetrics(db, []int{connectee.ID}))
+
+	connections, err := GetConnectionsJSON(db, GetConnectionOptions{})
+	require.NoError(t, err)
+	require.Empty(t, connections)
+	activist, err := GetActivistJSON(db, GetActivistOptions{ID: connectee.ID}, testViewer)
+	require.NoError(t, err)
+	require.Equal(t, "", activist.LastConnection)
+}
+
+func TestMergeActivistConnections(t *testing.T) {
+	db := newTestDB()
+	defer db.Close()
+
+	connector, err := GetOrCreateActivist(db, "Connector", ADBUser{})
+	require.NoError(t, err)
+	original, err := GetOrCreateActivist(db, "Original", ADBUser{})
+	require.NoError(t, err)
+	target, err := GetOrCreateActivist(db, "Target", ADBUser{})
+	require.NoError(t, err)
+
+	id, err := SaveConnection(db, Connection{
+		ConnectorID: connector.ID,
+		ConnecteeID: original.ID,
+		Date:        mpiDate("2020-05-01"),
+	})
+	require.NoError(t, err)
+
+	require.NoError(t, MergeActivist(db, original.ID, target.ID, ADBUser{}))
+	c, err := GetConnection(db, id)
+	require.NoError(t, err)
+	require.Equal(t, target.ID, c.ConnecteeID)
+
+	require.NoError(t, UnmergeActivist(db, original.ID, ADBUser{}))
+	c, err = GetConnection(db, id)
+	require.NoError(t, err)
+	require.Equal(t, original.ID, c.ConnecteeID)
+}
@@ -1,7 +1,11 @@
 package model
 
 import (
+	"context"
+	"database/sql"
+
 	"github.com/dxe/adb/config"
+	"github.com/dxe/adb/migrations"
 	_ "github.com/go-sql-driver/mysql"
 	"github.com/jmoiron/sqlx"
 )
@@ -14,197 +18,45 @@ func NewDB(dataSourceName string) *sqlx.DB {
 	return db
 }
 
+// WipeDatabase drops every table in the database and rebuilds the
+// schema from the migrations package.
 func WipeDatabase(db *sqlx.DB) {
 	if config.IsProd {
 		panic("Cannot drop tables in prod")
 	}
 
-	db.MustExec(`DROP TABLE IF EXISTS activists`)
-	db.MustExec(`DROP TABLE IF EXISTS events`)
-	db.MustExec(`DROP TABLE IF EXISTS event_attendance`)
-	db.MustExec(`DROP TABLE IF EXISTS users_roles`)
-	db.MustExec(`DROP TABLE IF EXISTS adb_users`)
-	db.MustExec(`DROP TABLE IF EXISTS merged_activist_attendance`)
-	db.MustExec(`DROP TABLE IF EXISTS working_groups`)
-	db.MustExec(`DROP TABLE IF EXISTS working_group_members`)
-	db.MustExec(`DROP TABLE IF EXISTS circles`)
-	db.MustExec(`DROP TABLE IF EXISTS circle_members`)
-
-	db.MustExec(`
-CREATE TABLE activists (
-  id INTEGER PRIMARY KEY AUTO_INCREMENT,
-  name VARCHAR(80) NOT NULL,
-  email VARCHAR(80) NOT NULL DEFAULT '',
-  phone VARCHAR(20) NOT NULL DEFAULT '',
-  location VARCHAR(200) DEFAULT '',
-  facebook VARCHAR(200) NOT NULL DEFAULT '',
-  activist_level VARCHAR(40) NOT NULL DEFAULT 'Supporter',
-  hidden TINYINT(1) NOT NULL DEFAULT '0',
-  connector VARCHAR(100) NOT NULL DEFAULT '',
-  source VARCHAR(255) NOT NULL DEFAULT '',
-  hiatus TINYINT(1) NOT NULL DEFAULT '0',
-  date_organizer DATE,
-  date_senior_organizer DATE,
-  dob TEXT,
-  training0 VARCHAR(20),
-  training1 VARCHAR(20),
-  training2 VARCHAR(20),
-  training3 VARCHAR(20),
-  training4 VARCHAR(20),
-  training5 VARCHAR(20),
-  training6 VARCHAR(20),
-  training_protest VARCHAR(20),
-  prospect_organizer TINYINT(1) NOT NULL DEFAULT '0',
-  prospect_chapter_member TINYINT NOT NULL DEFAULT '0',
-  circle_agreement TINYINT NOT NULL DEFAULT '0',
-  dev_manager VARCHAR(100) NOT NULL DEFAULT '',
-  dev_interest VARCHAR(200) NOT NULL DEFAULT '',
-  dev_auth VARCHAR(20),
-  dev_email_sent VARCHAR(20),
-  dev_vetted TINYINT(1) NOT NULL DEFAULT '0',
-  dev_interview VARCHAR(20),
-  dev_onboarding TINYINT(1) NOT NULL DEFAULT '0',
-  dev_application_date DATE,
-  dev_application_type VARCHAR(40) NOT NULL DEFAULT '',
-  dev_quiz VARCHAR(20),
-  cm_first_email VARCHAR(20),
-  cm_approval_email VARCHAR(20),
-  cm_warning_email VARCHAR(20),
-  cir_first_email VARCHAR(20),
-  prospect_senior_organizer tinyint(1) NOT NULL DEFAULT '0',
-  so_auth varchar(20),
-  so_core varchar(20),
-  so_agreement tinyint(1) NOT NULL DEFAULT '0',
-  so_training varchar(20),
-  so_quiz varchar(20),
-  so_connector varchar(100) NOT NULL DEFAULT '',
-  so_onboarding tinyint(1) NOT NULL DEFAULT '0',
-  referral_friends varchar(100) NOT NULL DEFAULT '',
-  referral_apply varchar(100) NOT NULL DEFAULT '',
-  referral_outlet varchar(100) NOT NULL DEFAULT '',
-  circle_interest tinyint(1) NOT NULL DEFAULT '0',
-  interest_date VARCHAR(20),
-  mpi tinyint(1) NOT NULL DEFAULT '0',
-  notes TEXT,
-  vision_wall varchar(10) NOT NULL DEFAULT '',
-  study_group varchar(40) NOT NULL DEFAULT '',
-  study_activator varchar(40) NOT NULL DEFAULT '',
-  study_conversation varchar(20),
-  survey_completion VARCHAR(20),
-  UNIQUE (name)
-)
-`)
-
-	db.MustExec(`
-CREATE TABLE event_attendance (
-  activist_id INTEGER NOT NULL,
-  event_id INTEGER NOT NULL,
-  UNIQUE (activist_id, event_id),
-  UNIQUE (event_id, activist_id)
-)
-`)
-
-	db.MustExec(`
-CREATE TABLE events (
-  id INTEGER PRIMARY KEY AUTO_INCREMENT,
-  name VARCHAR(60) NOT NULL,
-  date DATE NOT NULL,
-  event_type VARCHAR(60) NOT NULL,
-  survey_sent TINYINT(1) NOT NULL DEFAULT '0',
-  INDEX (date, name),
-  FULLTEXT (name)
-)
-`)
-
-	db.MustExec(`
-CREATE TABLE adb_users (
-  id INTEGER PRIMARY KEY AUTO_INCREMENT,
-  email VARCHAR(60) NOT NULL,
-  name VARCHAR(150) NOT NULL DEFAULT '',
-  admin TINYINT(1) NOT NULL DEFAULT '0',
-  disabled TINYINT(1) NOT NULL DEFAULT '0'
-)
-`)
-
-	db.MustExec(`
-CREATE TABLE merged_activist_attendance (
-  original_activist_id INTEGER NOT NULL,
-  target_activist_id INTEGER NOT NULL,
-  event_id INTEGER NOT NULL,
-  replaced_with_target_activist TINYINT(1) NOT NULL,
-  UNIQUE (original_activist_id, target_activist_id, event_id)
-)
-`)
-
-	db.MustExec(`
-CREATE TABLE working_groups (
-  id INTEGER PRIMARY KEY AUTO_INCREMENT,
-  name VARCHAR(60) NOT NULL,
-  type TINYINT(1) NOT NULL,
-  group_email VARCHAR(100) NOT NULL,
-  visible TINYINT(1) NOT NULL DEFAULT '0',
-  description TEXT NOT NULL,
-  meeting_time TEXT NOT NULL,
-  meeting_location TEXT NOT NULL,
-  coords TEXT NOT NULL,
-  UNIQUE (name)
-)
-`)
-
-	db.MustExec(`
-CREATE TABLE working_group_members (
-  working_group_id INTEGER NOT NULL,
-  activist_id INTEGER NOT NULL,
-  -- True if the activist is the point person of the working group.
-  -- There should be only one point person per working group, but we
-  -- don't restrict that on the backend.
-  point_person TINYINT NOT NULL DEFAULT '0',
-  -- Some activists need to be on the mailing list even though they
-  -- aren't in the workin group.
-  non_member_on_mailing_list TINYINT NOT NULL DEFAULT '0',
-  UNIQUE (working_group_id, activist_id),
-  INDEX (activist_id)
-)
-`)
-
-	db.MustExec(`
-CREATE TABLE circles (
-  id INTEGER PRIMARY KEY AUTO_INCREMENT,
-  name VARCHAR(60) NOT NULL,
-  type TINYINT(1) NOT NULL,
-  group_email VARCHAR(100) NOT NULL DEFAULT '1',
-  visible TINYINT(1) NOT NULL DEFAULT '1',
-  description TEXT NOT NULL,
-  meeting_time TEXT NOT NULL,
-  meeting_location TEXT NOT NULL,
-  coords TEXT NOT NULL,
-  UNIQUE (name)
-)
-`)
+	var tables []string
+	err := db.Select(&tables, `
+SELECT table_name
+FROM information_schema.tables
+WHERE table_schema = DATABASE()`)
+	if err != nil {
+		panic(err)
+	}
 
-	db.MustExec(`
-CREATE TABLE circle_members (
-  circle_id INTEGER NOT NULL,
-  activist_id INTEGER NOT NULL,
-  point_person TINYINT NOT NULL DEFAULT '0',
-  non_member_on_mailing_list TINYINT NOT NULL DEFAULT '0',
-  UNIQUE (circle_id, activist_id),
-  INDEX (activist_id)
-)
-`)
+	// Foreign key checks are per-connection, so all of the drops
+	// need to happen on the same connection.
+	ctx := context.Background()
+	conn, err := db.Conn(ctx)
+	if err != nil {
+		panic(err)
+	}
+	defer conn.Close()
+	mustExecContext(ctx, conn, `SET FOREIGN_KEY_CHECKS = 0`)
+	for _, table := range tables {
+		mustExecContext(ctx, conn, "DROP TABLE IF EXISTS `"+table+"`")
+	}
+	mustExecContext(ctx, conn, `SET FOREIGN_KEY_CHECKS = 1`)
 
-	db.MustExec(`
-CREATE TABLE users_roles (
-  user_id INT NOT NULL,
-  role VARCHAR(45) NOT NULL,
-  PRIMARY KEY (user_id, role),
-  CONSTRAINT user_id
-    FOREIGN KEY (user_id)
-    REFERENCES adb_users (id)
-    ON DELETE NO ACTION
-    ON UPDATE NO ACTION);
-`)
+	if err := migrations.Up(db); err != nil {
+		panic(err)
+	}
+}
 
+func mustExecContext(ctx context.Context, conn *sql.Conn, query string) {
+	if _, err := conn.ExecContext(ctx, query); err != nil {
+		panic(err)
+	}
 }
 
 func newTestDB() *sqlx.DB {
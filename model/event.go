@@ -4,6 +4,7 @@ import (
 	"database/sql/driver"
 	"encoding/json"
 	"io"
+	"sort"
 	"strings"
 	"time"
 
@@ -15,18 +16,8 @@ import (
 
 const EventDateLayout string = "2006-01-02"
 
-var EventTypes map[string]bool = map[string]bool{
-	"Action":                 true,
-	"Campaign Action":        true,
-	"Circle":                 true,
-	"Community":              true,
-	"Frontline Surveillance": true,
-	"Meeting":                true,
-	"Outreach":               true,
-	"Sanctuary":              true,
-	"Training":               true,
-	"Connection":             true,
-}
+// EventTimeLayout is the layout of events' start and end times.
+const EventTimeLayout string = "15:04"
 
 /** Type Definitions */
 
@@ -38,11 +29,58 @@ type EventJSON struct {
 	EventName        string   `json:"event_name"`
 	EventDate        string   `json:"event_date"`
 	EventType        string   `json:"event_type"`
+	SeriesID         int      `json:"series_id"`
+	SeriesName       string   `json:"series_name"`
+	StartTime        string   `json:"start_time"` // Formatted as EventTimeLayout, or empty
+	EndTime          string   `json:"end_time"`
+	Location         string   `json:"location"`
+	LeadOrganizer    string   `json:"lead_organizer"`    // Looked up by name when updating events
+	LeadOrganizerID  int      `json:"lead_organizer_id"` // Ignored when updating events
+	WorkingGroupID   int      `json:"working_group_id"`  // Events belong to at most one working group or circle
+	WorkingGroupName string   `json:"working_group_name"`
+	CircleID         int      `json:"circle_id"`
+	CircleName       string   `json:"circle_name"`
+	Notes            string   `json:"notes"`
+	Version          int      `json:"version"`   // Must be sent back unchanged when updating events
 	Attendees        []string `json:"attendees"` // For displaying all event attendees
 	AttendeeEmails   []string `json:"attendee_emails"`
 	AttendeeIDs      []int    `json:"attendee_ids"`
 	AddedAttendees   []string `json:"added_attendees"`   // Used for Updating Events
 	DeletedAttendees []string `json:"deleted_attendees"` // Used for Updating Events
+	// In strict mode, added attendees that don't match an existing
+	// activist are rejected unless they're also listed in
+	// ConfirmedNewAttendees.
+	Strict                bool     `json:"strict"`
+	ConfirmedNewAttendees []string `json:"confirmed_new_attendees"`
+}
+
+// UnknownAttendeesError is returned by CleanEventData in strict mode
+// when some attendees don't match an existing activist.
+type UnknownAttendeesError struct {
+	Attendees []UnknownAttendeeJSON
+}
+
+func (e *UnknownAttendeesError) Error() string {
+	names := make([]string, len(e.Attendees))
+	for i, a := range e.Attendees {
+		names[i] = a.Name
+	}
+	return "Unknown attendees: " + strings.Join(names, ", ")
+}
+
+type UnknownAttendeeJSON struct {
+	// Input is the attendee as the client sent it, and Name is what
+	// the new activist would be called.
+	Input   string              `json:"input"`
+	Name    string              `json:"name"`
+	Matches []ActivistMatchJSON `json:"matches"`
+}
+
+type ActivistMatchJSON struct {
+	ID    int     `json:"id"`
+	Name  string  `json:"name"`
+	Email string  `json:"email"`
+	Score float64 `json:"score"`
 }
 
 /* TODO Restructure this Struct */
@@ -52,6 +90,19 @@ type Event struct {
 	EventDate             time.Time `db:"date"`
 	EventType             EventType `db:"event_type"`
 	SurveySent            int       `db:"survey_sent"` // Used for sending event surveys
+	SeriesID              int       `db:"series_id"`   // 0 if the event isn't part of a series
+	SeriesName            string    `db:"series_name"`
+	StartTime             string    `db:"start_time"` // Formatted as EventTimeLayout, or empty
+	EndTime               string    `db:"end_time"`
+	Location              string    `db:"location"`
+	LeadOrganizerID       int       `db:"lead_organizer_id"` // 0 if no one is recorded as leading it
+	LeadOrganizerName     string    `db:"lead_organizer_name"`
+	WorkingGroupID        int       `db:"working_group_id"`
+	WorkingGroupName      string    `db:"working_group_name"`
+	CircleID              int       `db:"circle_id"`
+	CircleName            string    `db:"circle_name"`
+	Notes                 string    `db:"notes"`
+	Version               int       `db:"version"` // Incremented on every update
 	Attendees             []string  // For retrieving all event attendees
 	AttendeeEmails        []string
 	AttendeeIDs           []int
@@ -62,13 +113,26 @@ type Event struct {
 
 func (event *Event) ToJSON() EventJSON {
 	return EventJSON{
-		EventID:        event.ID,
-		EventName:      event.EventName,
-		EventDate:      event.EventDate.Format(EventDateLayout),
-		EventType:      string(event.EventType),
-		Attendees:      event.Attendees,
-		AttendeeEmails: event.AttendeeEmails,
-		AttendeeIDs:    event.AttendeeIDs,
+		EventID:          event.ID,
+		EventName:        event.EventName,
+		EventDate:        event.EventDate.Format(EventDateLayout),
+		EventType:        string(event.EventType),
+		SeriesID:         event.SeriesID,
+		SeriesName:       event.SeriesName,
+		StartTime:        event.StartTime,
+		EndTime:          event.EndTime,
+		Location:         event.Location,
+		LeadOrganizer:    event.LeadOrganizerName,
+		LeadOrganizerID:  event.LeadOrganizerID,
+		WorkingGroupID:   event.WorkingGroupID,
+		WorkingGroupName: event.WorkingGroupName,
+		CircleID:         event.CircleID,
+		CircleName:       event.CircleName,
+		Notes:            event.Notes,
+		Version:          event.Version,
+		Attendees:        event.Attendees,
+		AttendeeEmails:   event.AttendeeEmails,
+		AttendeeIDs:      event.AttendeeIDs,
 	}
 }
 
@@ -83,6 +147,19 @@ type GetEventOptions struct {
 	EventNameQuery string
 	EventActivist  string
 	SurveySent     string
+	SeriesID       int
+	// EventLocation matches events whose location contains it.
+	EventLocation  string
+	LeadOrganizer  string // The lead organizer's name
+	WorkingGroupID int
+	CircleID       int
+}
+
+// EventOwnerJSON is a working group or circle that events can belong
+// to.
+type EventOwnerJSON struct {
+	ID   int    `db:"id" json:"id"`
+	Name string `db:"name" json:"name"`
 }
 
 /** Functions and Methods */
@@ -121,7 +198,24 @@ func GetEvent(db *sqlx.DB, options GetEventOptions) (Event, error) {
 }
 
 func getEvents(db *sqlx.DB, options GetEventOptions) ([]Event, error) {
-	query := `SELECT e.id, e.name, e.date, e.event_type, e.survey_sent FROM events e `
+	query := `
+SELECT
+  e.id, e.name, e.date, e.event_type, e.survey_sent,
+  IFNULL(e.series_id, 0) AS series_id, IFNULL(s.name, '') AS series_name,
+  IFNULL(TIME_FORMAT(e.start_time, '%H:%i'), '') AS start_time,
+  IFNULL(TIME_FORMAT(e.end_time, '%H:%i'), '') AS end_time,
+  e.location,
+  IFNULL(e.lead_organizer_id, 0) AS lead_organizer_id, IFNULL(o.name, '') AS lead_organizer_name,
+  IFNULL(e.working_group_id, 0) AS working_group_id, IFNULL(wg.name, '') AS working_group_name,
+  IFNULL(e.circle_id, 0) AS circle_id, IFNULL(c.name, '') AS circle_name,
+  IFNULL(e.notes, '') AS notes,
+  e.version
+FROM events e
+LEFT JOIN event_series s ON s.id = e.series_id
+LEFT JOIN activists o ON o.id = e.lead_organizer_id
+LEFT JOIN working_groups wg ON wg.id = e.working_group_id
+LEFT JOIN circles c ON c.id = e.circle_id
+`
 
 	// Items in whereClause are added to the query in order, separated by ' AND '.
 	var whereClause []string
@@ -132,6 +226,9 @@ func getEvents(db *sqlx.DB, options GetEventOptions) ([]Event, error) {
 		queryArgs = append(queryArgs, args...)
 	}
 
+	// Events in the trash are only listed by GetTrashedEventsJSON.
+	where("e.deleted_at IS NULL")
+
 	if options.EventActivist != "" {
 		// If we're filtering with an activist name, we need
 		// to join a couple tables which makes this slightly
@@ -152,15 +249,31 @@ ON (e.id = ea.event_id AND ea.activist_id = a.id)
 	if options.DateTo != "" {
 		where("e.date <= ?", options.DateTo)
 	}
+	if options.SeriesID != 0 {
+		where("e.series_id = ?", options.SeriesID)
+	}
+	if options.EventLocation != "" {
+		where("e.location LIKE ?", "%"+options.EventLocation+"%")
+	}
+	if options.LeadOrganizer != "" {
+		where("o.name = ?", options.LeadOrganizer)
+	}
+	if options.WorkingGroupID != 0 {
+		where("e.working_group_id = ?", options.WorkingGroupID)
+	}
+	if options.CircleID != 0 {
+		where("e.circle_id = ?", options.CircleID)
+	}
 	if options.SurveySent != "" {
 		where("e.survey_sent = ?", options.SurveySent)
 	}
-	if options.EventType == "noConnections" {
-		where("e.event_type <> 'Connection'")
-	} else if options.EventType == "mpiDA" {
-		where("e.event_type in ('Outreach', 'Action', 'Campaign Action', 'Sanctuary', 'Frontline Surveillance')")
+	whereCategory := func(op, category string) {
+		where("e.event_type IN (SELECT name FROM event_types WHERE category "+op+" ?)", category)
+	}
+	if options.EventType == "mpiDA" {
+		whereCategory("=", EventCategoryDirectAction)
 	} else if options.EventType == "mpiCOM" {
-		where("e.event_type in ('Community', 'Training', 'Circle')")
+		whereCategory("=", EventCategoryCommunity)
 	} else if options.EventType != "" {
 		where("e.event_type like ?", options.EventType)
 	}
@@ -249,23 +362,31 @@ func GetEventAttendance(db *sqlx.DB, eventID int) ([]string, error) {
 	return attendees, nil
 }
 
-func DeleteEvent(db *sqlx.DB, eventID int) error {
+// DeleteEvent moves an event to the trash. Its attendance is kept, but
+// no longer counts toward anything, until the event is restored with
+// RestoreEvent or purged with PurgeEvent.
+func DeleteEvent(db *sqlx.DB, eventID int, user ADBUser) error {
 	tx, err := db.Beginx()
 	if err != nil {
 		return errors.Wrap(err, "failed to create transaction")
 	}
-	_, err = tx.Exec(`DELETE FROM event_attendance
-WHERE event_id = ?`, eventID)
+	res, err := tx.Exec(`UPDATE events
+SET deleted_at = NOW(), deleted_by = NULLIF(?, 0)
+WHERE id = ? AND deleted_at IS NULL`, user.ID, eventID)
 	if err != nil {
 		tx.Rollback()
-		return errors.Wrapf(err, "failed to delete event attendance for event %d", eventID)
+		return errors.Wrapf(err, "failed to delete event %d", eventID)
 	}
-
-	_, err = tx.Exec(`DELETE FROM events
-WHERE id = ?`, eventID)
-	if err != nil {
+	if n, err := res.RowsAffected(); err != nil {
 		tx.Rollback()
 		return errors.Wrapf(err, "failed to delete event %d", eventID)
+	} else if n == 0 {
+		tx.Rollback()
+		return errors.Errorf("Event with id %d does not exist", eventID)
+	}
+	if err := updateEventActivistMetrics(tx, eventID, nil); err != nil {
+		tx.Rollback()
+		return err
 	}
 
 	if err := tx.Commit(); err != nil {
@@ -288,14 +409,6 @@ func (et *EventType) Scan(src interface{}) error {
 	return nil
 }
 
-func getEventType(rawEventType string) (EventType, error) {
-	rawEventType = strings.TrimSpace(rawEventType)
-	if EventTypes[rawEventType] {
-		return EventType(rawEventType), nil
-	}
-	return "", errors.New("Not a valid event type: " + rawEventType)
-}
-
 func InsertUpdateEvent(db *sqlx.DB, event Event) (eventID int, err error) {
 	if event.ID == 0 {
 		return insertEvent(db, event)
@@ -308,27 +421,40 @@ func insertEvent(db *sqlx.DB, event Event) (eventID int, err error) {
 	if err != nil {
 		return 0, errors.Wrap(err, "failed to create transaction")
 	}
-	res, err := tx.NamedExec(`INSERT INTO events (name, date, event_type)
-VALUES (:name, :date, :event_type)`, event)
+	id, err := insertEventTx(tx, event)
 	if err != nil {
 		tx.Rollback()
+		return 0, err
+	}
+	if err := tx.Commit(); err != nil {
+		tx.Rollback()
+		return 0, errors.Wrap(err, "failed insert event transaction")
+	}
+	return id, nil
+}
+
+func insertEventTx(tx *sqlx.Tx, event Event) (eventID int, err error) {
+	res, err := tx.NamedExec(`
+INSERT INTO events (
+  name, date, event_type, series_id, start_time, end_time, location,
+  lead_organizer_id, working_group_id, circle_id, notes
+)
+VALUES (
+  :name, :date, :event_type, NULLIF(:series_id, 0), NULLIF(:start_time, ''), NULLIF(:end_time, ''), :location,
+  NULLIF(:lead_organizer_id, 0), NULLIF(:working_group_id, 0), NULLIF(:circle_id, 0), :notes
+)`, event)
+	if err != nil {
 		return 0, errors.Wrap(err, "failed to insert event")
 	}
 	id, err := res.LastInsertId()
 	if err != nil {
-		tx.Rollback()
 		return 0, errors.Wrap(err, "failed to get inserted event id")
 	}
 	event.ID = int(id)
 
 	if err := insertEventAttendance(tx, event); err != nil {
-		tx.Rollback()
 		return 0, errors.Wrap(err, "failed to insert event attendance")
 	}
-	if err := tx.Commit(); err != nil {
-		tx.Rollback()
-		return 0, errors.Wrap(err, "failed insert event transaction")
-	}
 	return int(id), nil
 }
 
@@ -339,7 +465,7 @@ func updateEvent(db *sqlx.DB, event Event) (eventID int, err error) {
 	}
 	// Error out if the event doesn't exist.
 	var eventCount int
-	err = tx.Get(&eventCount, `SELECT count(*) FROM events WHERE id = ?`, event.ID)
+	err = tx.Get(&eventCount, `SELECT count(*) FROM events WHERE id = ? AND deleted_at IS NULL`, event.ID)
 	if err != nil {
 		tx.Rollback()
 		return 0, errors.Wrap(err, "failed to get event count")
@@ -349,18 +475,38 @@ func updateEvent(db *sqlx.DB, event Event) (eventID int, err error) {
 		return 0, errors.Errorf("Event with id %d does not exist", event.ID)
 	}
 
-	// Update the event
-	_, err = tx.NamedExec(`UPDATE events
+	// Update the event, unless someone else updated it since the
+	// client loaded it.
+	res, err := tx.NamedExec(`UPDATE events
 SET
   name = :name,
   date = :date,
-  event_type = :event_type
+  event_type = :event_type,
+  start_time = NULLIF(:start_time, ''),
+  end_time = NULLIF(:end_time, ''),
+  location = :location,
+  lead_organizer_id = NULLIF(:lead_organizer_id, 0),
+  working_group_id = NULLIF(:working_group_id, 0),
+  circle_id = NULLIF(:circle_id, 0),
+  notes = :notes,
+  version = version + 1
 WHERE
-  id = :id`, event)
+  id = :id AND version = :version`, event)
 	if err != nil {
 		tx.Rollback()
 		return 0, errors.Wrap(err, "failed to update event")
 	}
+	if n, err := res.RowsAffected(); err != nil {
+		tx.Rollback()
+		return 0, errors.Wrap(err, "failed to update event")
+	} else if n == 0 {
+		tx.Rollback()
+		current, err := GetEvent(db, GetEventOptions{EventID: event.ID})
+		if err != nil {
+			return 0, err
+		}
+		return 0, &ConflictError{What: "event", Current: current.ToJSON()}
+	}
 
 	if err := insertEventAttendance(tx, event); err != nil {
 		tx.Rollback()
@@ -427,19 +573,66 @@ func insertEventAttendance(tx *sqlx.Tx, event Event) error {
 			return errors.Wrap(err, "failed to insert attendees")
 		}
 	}
-	return nil
+
+	// Update everyone who attends, not just the added attendees, in
+	// case the event's date or type changed.
+	var deletedIDs []int
+	for _, u := range event.DeletedAttendees {
+		deletedIDs = append(deletedIDs, u.ID)
+	}
+	return updateEventActivistMetrics(tx, event.ID, deletedIDs)
 }
 
-func CleanEventData(db *sqlx.DB, body io.Reader) (Event, error) {
+func CleanEventData(db *sqlx.DB, body io.Reader, user ADBUser) (Event, error) {
 	var eventJSON EventJSON
 	err := json.NewDecoder(body).Decode(&eventJSON)
 	if err != nil {
 		return Event{}, err
 	}
 
+	e, err := cleanEventDetails(db, eventJSON)
+	if err != nil {
+		return Event{}, err
+	}
+
+	if eventJSON.Strict {
+		// Check before creating any activists so that nothing is
+		// written until the client has confirmed every new name.
+		unknown, err := findUnknownAttendees(db, eventJSON.AddedAttendees, eventJSON.ConfirmedNewAttendees)
+		if err != nil {
+			return Event{}, err
+		}
+		if len(unknown) > 0 {
+			return Event{}, &UnknownAttendeesError{Attendees: unknown}
+		}
+	}
+
+	addedAttendees, err := cleanEventAttendanceData(db, eventJSON.AddedAttendees, user)
+	if err != nil {
+		return Event{}, err
+	}
+
+	deletedAttendees, err := cleanEventAttendanceData(db, eventJSON.DeletedAttendees, user)
+	if err != nil {
+		return Event{}, err
+	}
+
+	e.AddedAttendees = addedAttendees
+	e.DeletedAttendees = deletedAttendees
+
+	return e, nil
+}
+
+// cleanEventDetails validates everything about an event except its
+// attendees.
+func cleanEventDetails(db *sqlx.DB, eventJSON EventJSON) (Event, error) {
 	// Strip spaces from front and back of all fields.
 	var e Event
 	e.ID = eventJSON.EventID
+	if e.ID != 0 && eventJSON.Version == 0 {
+		return Event{}, errors.New("Missing event version; reload the page and try again")
+	}
+	e.Version = eventJSON.Version
 
 	if err := checkForDangerousChars(eventJSON.EventName); err != nil {
 		return Event{}, err
@@ -451,37 +644,161 @@ func CleanEventData(db *sqlx.DB, body io.Reader) (Event, error) {
 		return Event{}, err
 	}
 	e.EventDate = t
-	eventType, err := getEventType(eventJSON.EventType)
+	eventType, err := getEventType(db, eventJSON.EventType, eventJSON.EventID)
 	if err != nil {
 		return Event{}, err
 	}
 	e.EventType = eventType
 
-	addedAttendees, err := cleanEventAttendanceData(db, eventJSON.AddedAttendees)
-	if err != nil {
-		return Event{}, err
+	if eventJSON.StartTime != "" {
+		start, err := time.Parse(EventTimeLayout, eventJSON.StartTime)
+		if err != nil {
+			return Event{}, errors.Wrap(err, "invalid event start time")
+		}
+		e.StartTime = start.Format(EventTimeLayout)
+	}
+	if eventJSON.EndTime != "" {
+		if e.StartTime == "" {
+			return Event{}, errors.New("Event must have a start time to have an end time")
+		}
+		end, err := time.Parse(EventTimeLayout, eventJSON.EndTime)
+		if err != nil {
+			return Event{}, errors.Wrap(err, "invalid event end time")
+		}
+		e.EndTime = end.Format(EventTimeLayout)
+		// Both are formatted the same way, so they sort as strings.
+		if e.EndTime <= e.StartTime {
+			return Event{}, errors.New("Event must end after it starts")
+		}
 	}
 
-	deletedAttendees, err := cleanEventAttendanceData(db, eventJSON.DeletedAttendees)
-	if err != nil {
+	e.Location = strings.TrimSpace(eventJSON.Location)
+	if err := checkForDangerousChars(e.Location); err != nil {
 		return Event{}, err
 	}
+	if len(e.Location) > 200 {
+		return Event{}, errors.New("Event location must be at most 200 characters")
+	}
 
-	e.AddedAttendees = addedAttendees
-	e.DeletedAttendees = deletedAttendees
+	if organizer := strings.TrimSpace(eventJSON.LeadOrganizer); organizer != "" {
+		activists, err := getActivists(db, organizer)
+		if err != nil {
+			return Event{}, err
+		}
+		if len(activists) != 1 {
+			return Event{}, errors.Errorf("Lead organizer %s is not an activist", organizer)
+		}
+		e.LeadOrganizerID = activists[0].ID
+	}
 
+	if eventJSON.WorkingGroupID != 0 && eventJSON.CircleID != 0 {
+		return Event{}, errors.New("Event can't belong to both a working group and a circle")
+	}
+	e.WorkingGroupID = eventJSON.WorkingGroupID
+	e.CircleID = eventJSON.CircleID
+	e.Notes = strings.TrimSpace(eventJSON.Notes)
 	return e, nil
 }
 
-func cleanEventAttendanceData(db *sqlx.DB, attendees []string) ([]Activist, error) {
+// GetEventOwnersJSON returns the working groups and circles that
+// events can belong to, without their members.
+func GetEventOwnersJSON(db *sqlx.DB) (workingGroups, circles []EventOwnerJSON, err error) {
+	workingGroups = []EventOwnerJSON{}
+	if err := db.Select(&workingGroups, `SELECT id, name FROM working_groups ORDER BY name`); err != nil {
+		return nil, nil, errors.Wrap(err, "failed to select working groups")
+	}
+	circles = []EventOwnerJSON{}
+	if err := db.Select(&circles, `SELECT id, name FROM circles ORDER BY name`); err != nil {
+		return nil, nil, errors.Wrap(err, "failed to select circles")
+	}
+	return workingGroups, circles, nil
+}
+
+// maxAttendeeMatches is how many existing activists are suggested for
+// each unknown attendee.
+const maxAttendeeMatches = 5
+
+// findUnknownAttendees returns the attendees that don't match an
+// existing activist and weren't confirmed as new, along with the
+// existing activists whose name or email is close to what was typed.
+func findUnknownAttendees(db *sqlx.DB, attendees []string, confirmed []string) ([]UnknownAttendeeJSON, error) {
+	confirmedNames := map[string]struct{}{}
+	for _, c := range confirmed {
+		confirmedNames[cleanAttendeeName(c)] = struct{}{}
+	}
+
+	var unknown []UnknownAttendeeJSON
+	for _, attendee := range attendees {
+		name := cleanAttendeeName(attendee)
+		if _, ok := confirmedNames[name]; ok {
+			continue
+		}
+		activists, err := getActivists(db, name)
+		if err != nil {
+			return nil, err
+		}
+		if len(activists) == 0 {
+			unknown = append(unknown, UnknownAttendeeJSON{Input: attendee, Name: name})
+		}
+	}
+	if len(unknown) == 0 {
+		return nil, nil
+	}
+
+	var activists []Activist
+	err := db.Select(&activists, `SELECT id, name, email FROM activists WHERE hidden = 0`)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to select activists")
+	}
+
+	for i := range unknown {
+		unknown[i].Matches = matchActivists(unknown[i].Name, activists)
+	}
+	return unknown, nil
+}
+
+// matchActivists returns the activists whose name is similar to
+// typed, or whose email is exactly typed, best match first.
+func matchActivists(typed string, activists []Activist) []ActivistMatchJSON {
+	typedName := normalizeDuplicateName(typed)
+	typedEmail := strings.ToLower(strings.TrimSpace(typed))
+
+	matches := []ActivistMatchJSON{}
+	for _, a := range activists {
+		score := nameSimilarity(typedName, normalizeDuplicateName(a.Name))
+		if a.Email != "" && strings.ToLower(strings.TrimSpace(a.Email)) == typedEmail {
+			score = 1
+		}
+		if score < duplicateNameSimilarityFloor {
+			continue
+		}
+		matches = append(matches, ActivistMatchJSON{
+			ID:    a.ID,
+			Name:  a.Name,
+			Email: a.Email,
+			Score: score,
+		})
+	}
+	sort.SliceStable(matches, func(i, j int) bool { return matches[i].Score > matches[j].Score })
+	if len(matches) > maxAttendeeMatches {
+		matches = matches[:maxAttendeeMatches]
+	}
+	return matches
+}
+
+func cleanAttendeeName(attendee string) string {
+	return strings.Title(strings.TrimSpace(attendee))
+}
+
+func cleanEventAttendanceData(db *sqlx.DB, attendees []string, user ADBUser) ([]Activist, error) {
 	activists := make([]Activist, len(attendees))
 
 	for idx, attendee := range attendees {
 		if err := checkForDangerousChars(attendee); err != nil {
 			return []Activist{}, err
 		}
-		cleanAttendee := strings.Title(strings.TrimSpace(attendee))
-		activist, err := GetOrCreateActivist(db, cleanAttendee)
+		cleanAttendee := cleanAttendeeName(attendee)
+		activist, err := GetOrCreateActivist(db, cleanAttendee, user)
 		if err != nil {
 			return []Activist{}, err
 		}
@@ -0,0 +1,467 @@
+package model
+
+import (
+	"database/sql"
+	"encoding/json"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/pkg/errors"
+)
+
+/** Constant and Variable Definitions */
+
+// EventSeriesHorizonDays is how far ahead occurrences of a series are
+// created, so they show up in the event list before they happen.
+const EventSeriesHorizonDays = 28
+
+const selectEventSeriesBaseQuery string = `
+SELECT
+  s.id,
+  s.name,
+  s.event_type,
+  s.recurrence,
+  s.location,
+  s.start_date,
+  s.end_date,
+  s.generated_through,
+  (SELECT COUNT(*) FROM events e WHERE e.series_id = s.id AND e.deleted_at IS NULL) AS events,
+  (SELECT MIN(e.date) FROM events e WHERE e.series_id = s.id AND e.date >= CURDATE() AND e.deleted_at IS NULL) AS next_event
+FROM event_series s
+`
+
+/** Type Definitions */
+
+type EventSeries struct {
+	ID               int            `db:"id"`
+	Name             string         `db:"name"`
+	EventType        EventType      `db:"event_type"`
+	Recurrence       string         `db:"recurrence"`
+	Location         string         `db:"location"`
+	StartDate        time.Time      `db:"start_date"`
+	EndDate          sql.NullString `db:"end_date"`
+	GeneratedThrough sql.NullString `db:"generated_through"`
+	Events           int            `db:"events"`
+	NextEvent        sql.NullString `db:"next_event"`
+}
+
+type EventSeriesJSON struct {
+	ID         int    `json:"id"`
+	Name       string `json:"name"`
+	EventType  string `json:"event_type"`
+	Recurrence string `json:"recurrence"`
+	Location   string `json:"location"`
+	StartDate  string `json:"start_date"`
+	// EndDate is empty for series that don't end.
+	EndDate string `json:"end_date"`
+	// Events and NextEvent are ignored when saving.
+	Events    int    `json:"events"`
+	NextEvent string `json:"next_event"`
+}
+
+// EventSeriesAttendanceJSON is the attendance of a series' events,
+// by month.
+type EventSeriesAttendanceJSON struct {
+	SeriesID int                              `json:"series_id"`
+	Name     string                           `json:"name"`
+	Months   []EventSeriesMonthAttendanceJSON `json:"months"`
+}
+
+type EventSeriesMonthAttendanceJSON struct {
+	// Month is formatted as PowerHistMonthLayout.
+	Month           string `db:"month" json:"month"`
+	Events          int    `db:"events" json:"events"`
+	Attendance      int    `db:"attendance" json:"attendance"`
+	UniqueAttendees int    `db:"unique_attendees" json:"unique_attendees"`
+}
+
+/** Functions and Methods */
+
+func GetEventSeriesJSON(db *sqlx.DB) ([]EventSeriesJSON, error) {
+	series, err := getEventSeries(db, 0)
+	if err != nil {
+		return nil, err
+	}
+	seriesJSON := []EventSeriesJSON{}
+	for _, s := range series {
+		seriesJSON = append(seriesJSON, s.ToJSON())
+	}
+	return seriesJSON, nil
+}
+
+func getEventSeries(q sqlx.Queryer, id int) ([]EventSeries, error) {
+	query := selectEventSeriesBaseQuery
+	var queryArgs []interface{}
+	if id != 0 {
+		query += " WHERE s.id = ? "
+		queryArgs = append(queryArgs, id)
+	}
+	query += " ORDER BY s.name "
+
+	var series []EventSeries
+	if err := sqlx.Select(q, &series, query, queryArgs...); err != nil {
+		return nil, errors.Wrap(err, "failed to select event series")
+	}
+	return series, nil
+}
+
+func (s EventSeries) ToJSON() EventSeriesJSON {
+	return EventSeriesJSON{
+		ID:         s.ID,
+		Name:       s.Name,
+		EventType:  string(s.EventType),
+		Recurrence: s.Recurrence,
+		Location:   s.Location,
+		StartDate:  s.StartDate.Format(EventDateLayout),
+		EndDate:    s.EndDate.String,
+		Events:     s.Events,
+		NextEvent:  s.NextEvent.String,
+	}
+}
+
+func CleanEventSeriesData(db *sqlx.DB, body io.Reader) (EventSeries, error) {
+	var seriesJSON EventSeriesJSON
+	if err := json.NewDecoder(body).Decode(&seriesJSON); err != nil {
+		return EventSeries{}, errors.Wrap(err, "failed to decode event series")
+	}
+
+	s := EventSeries{ID: seriesJSON.ID}
+	s.Name = strings.TrimSpace(seriesJSON.Name)
+	if s.Name == "" {
+		return EventSeries{}, errors.New("Series name cannot be empty")
+	}
+	if len(s.Name) > 60 {
+		return EventSeries{}, errors.New("Series name must be at most 60 characters")
+	}
+	s.Location = strings.TrimSpace(seriesJSON.Location)
+	for _, field := range []string{s.Name, s.Location} {
+		if err := checkForDangerousChars(field); err != nil {
+			return EventSeries{}, err
+		}
+	}
+
+	eventType, err := getEventType(db, seriesJSON.EventType, 0)
+	if err != nil {
+		return EventSeries{}, err
+	}
+	s.EventType = eventType
+
+	recurrence, err := ParseRecurrence(seriesJSON.Recurrence)
+	if err != nil {
+		return EventSeries{}, err
+	}
+	s.Recurrence = recurrence.String()
+
+	s.StartDate, err = time.Parse(EventDateLayout, seriesJSON.StartDate)
+	if err != nil {
+		return EventSeries{}, errors.Wrap(err, "invalid series start date")
+	}
+	if seriesJSON.EndDate != "" {
+		endDate, err := time.Parse(EventDateLayout, seriesJSON.EndDate)
+		if err != nil {
+			return EventSeries{}, errors.Wrap(err, "invalid series end date")
+		}
+		if endDate.Before(s.StartDate) {
+			return EventSeries{}, errors.New("Series can't end before it starts")
+		}
+		s.EndDate = sql.NullString{String: seriesJSON.EndDate, Valid: true}
+	}
+	return s, nil
+}
+
+// SaveEventSeries creates a series if it has no ID, or updates the
+// existing one, and then creates its upcoming events. Upcoming events
+// that nobody has attended yet are recreated when a series changes, so
+// they match its new name, type and recurrence.
+func SaveEventSeries(db *sqlx.DB, series EventSeries, now time.Time) (EventSeriesJSON, error) {
+	tx, err := db.Beginx()
+	if err != nil {
+		return EventSeriesJSON{}, errors.Wrap(err, "failed to create transaction")
+	}
+
+	id := series.ID
+	if id == 0 {
+		res, err := tx.NamedExec(`
+INSERT INTO event_series (name, event_type, recurrence, location, start_date, end_date)
+VALUES (:name, :event_type, :recurrence, :location, :start_date, :end_date)`, series)
+		if err != nil {
+			tx.Rollback()
+			return EventSeriesJSON{}, errors.Wrapf(err, "failed to create event series %s", series.Name)
+		}
+		newID, err := res.LastInsertId()
+		if err != nil {
+			tx.Rollback()
+			return EventSeriesJSON{}, errors.Wrap(err, "failed to get event series id")
+		}
+		id = int(newID)
+	} else {
+		var count int
+		err := tx.Get(&count, `SELECT COUNT(*) FROM event_series WHERE id = ? FOR UPDATE`, id)
+		if err != nil {
+			tx.Rollback()
+			return EventSeriesJSON{}, errors.Wrapf(err, "failed to get event series %d", id)
+		}
+		if count == 0 {
+			tx.Rollback()
+			return EventSeriesJSON{}, errors.Errorf("Event series %d does not exist", id)
+		}
+
+		_, err = tx.NamedExec(`
+UPDATE event_series
+SET
+  name = :name,
+  event_type = :event_type,
+  recurrence = :recurrence,
+  location = :location,
+  start_date = :start_date,
+  end_date = :end_date,
+  generated_through = NULL
+WHERE id = :id`, series)
+		if err != nil {
+			tx.Rollback()
+			return EventSeriesJSON{}, errors.Wrapf(err, "failed to update event series %d", id)
+		}
+		if err := deleteUpcomingSeriesEvents(tx, id, now); err != nil {
+			tx.Rollback()
+			return EventSeriesJSON{}, err
+		}
+	}
+
+	if _, err := createSeriesEvents(tx, id, now); err != nil {
+		tx.Rollback()
+		return EventSeriesJSON{}, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		tx.Rollback()
+		return EventSeriesJSON{}, errors.Wrap(err, "failed to commit event series")
+	}
+
+	saved, err := getEventSeries(db, id)
+	if err != nil {
+		return EventSeriesJSON{}, err
+	}
+	if len(saved) != 1 {
+		return EventSeriesJSON{}, errors.Errorf("failed to find event series %d", id)
+	}
+	return saved[0].ToJSON(), nil
+}
+
+// DeleteEventSeries deletes a series and its upcoming events that
+// nobody has attended. Its past events are kept, but are no longer
+// part of a series.
+func DeleteEventSeries(db *sqlx.DB, id int, now time.Time) error {
+	tx, err := db.Beginx()
+	if err != nil {
+		return errors.Wrap(err, "failed to create transaction")
+	}
+	if err := deleteUpcomingSeriesEvents(tx, id, now); err != nil {
+		tx.Rollback()
+		return err
+	}
+	res, err := tx.Exec(`DELETE FROM event_series WHERE id = ?`, id)
+	if err != nil {
+		tx.Rollback()
+		return errors.Wrapf(err, "failed to delete event series %d", id)
+	}
+	if n, err := res.RowsAffected(); err != nil || n == 0 {
+		tx.Rollback()
+		return errors.Errorf("Event series %d does not exist", id)
+	}
+	if err := tx.Commit(); err != nil {
+		tx.Rollback()
+		return errors.Wrap(err, "failed to commit event series")
+	}
+	return nil
+}
+
+// deleteUpcomingSeriesEvents deletes the series' events after now that
+// have no attendance.
+func deleteUpcomingSeriesEvents(tx *sqlx.Tx, seriesID int, now time.Time) error {
+	_, err := tx.Exec(`
+DELETE FROM events
+WHERE series_id = ?
+  AND date > ?
+  AND id NOT IN (SELECT event_id FROM event_attendance)`, seriesID, now.Format(EventDateLayout))
+	if err != nil {
+		return errors.Wrapf(err, "failed to delete upcoming events of series %d", seriesID)
+	}
+	return nil
+}
+
+// CreateUpcomingSeriesEvents creates the events of every series that
+// occur in the next EventSeriesHorizonDays days and haven't been
+// created yet. Returns the number of events created.
+func CreateUpcomingSeriesEvents(db *sqlx.DB, now time.Time) (int, error) {
+	var seriesIDs []int
+	err := db.Select(&seriesIDs, `
+SELECT id
+FROM event_series
+WHERE end_date IS NULL OR end_date >= ?`, now.Format(EventDateLayout))
+	if err != nil {
+		return 0, errors.Wrap(err, "failed to select event series")
+	}
+
+	created := 0
+	for _, id := range seriesIDs {
+		tx, err := db.Beginx()
+		if err != nil {
+			return created, errors.Wrap(err, "failed to create transaction")
+		}
+		n, err := createSeriesEvents(tx, id, now)
+		if err != nil {
+			tx.Rollback()
+			return created, err
+		}
+		if err := tx.Commit(); err != nil {
+			tx.Rollback()
+			return created, errors.Wrap(err, "failed to commit series events")
+		}
+		created += n
+	}
+	return created, nil
+}
+
+// createSeriesEvents creates the series' events from today through
+// EventSeriesHorizonDays from now, skipping any up to its
+// generated_through date, and returns how many were created.
+func createSeriesEvents(tx *sqlx.Tx, seriesID int, now time.Time) (int, error) {
+	series, err := getEventSeries(tx, seriesID)
+	if err != nil {
+		return 0, err
+	}
+	if len(series) != 1 {
+		return 0, errors.Errorf("Event series %d does not exist", seriesID)
+	}
+	s := series[0]
+
+	recurrence, err := ParseRecurrence(s.Recurrence)
+	if err != nil {
+		return 0, errors.Wrapf(err, "invalid recurrence for series %d", seriesID)
+	}
+	from := recurrenceDate(now)
+	if s.GeneratedThrough.Valid {
+		generatedThrough, err := time.Parse(EventDateLayout, s.GeneratedThrough.String)
+		if err != nil {
+			return 0, errors.Wrapf(err, "invalid generated_through for series %d", seriesID)
+		}
+		if !generatedThrough.Before(from) {
+			from = generatedThrough.AddDate(0, 0, 1)
+		}
+	}
+	to := recurrenceDate(now).AddDate(0, 0, EventSeriesHorizonDays)
+	if s.EndDate.Valid {
+		endDate, err := time.Parse(EventDateLayout, s.EndDate.String)
+		if err != nil {
+			return 0, errors.Wrapf(err, "invalid end date for series %d", seriesID)
+		}
+		if endDate.Before(to) {
+			to = endDate
+		}
+	}
+
+	// Events that already exist, for example from before the series
+	// was edited, aren't created twice. Neither are events in the
+	// trash, since deleting a series' event cancels it.
+	var existing []string
+	err = tx.Select(&existing, `
+SELECT DATE_FORMAT(date, '%Y-%m-%d')
+FROM events
+WHERE series_id = ? AND date BETWEEN ? AND ?`, seriesID, from.Format(EventDateLayout), to.Format(EventDateLayout))
+	if err != nil {
+		return 0, errors.Wrapf(err, "failed to select events of series %d", seriesID)
+	}
+	existingDates := map[string]bool{}
+	for _, d := range existing {
+		existingDates[d] = true
+	}
+
+	created := 0
+	for _, date := range recurrence.Occurrences(s.StartDate, from, to) {
+		if existingDates[date.Format(EventDateLayout)] {
+			continue
+		}
+		_, err := insertEventTx(tx, Event{
+			EventName: s.Name,
+			EventDate: date,
+			EventType: s.EventType,
+			SeriesID:  s.ID,
+			Location:  s.Location,
+		})
+		if err != nil {
+			return created, errors.Wrapf(err, "failed to create event of series %d", seriesID)
+		}
+		created++
+	}
+
+	if !to.Before(from) {
+		_, err = tx.Exec(`UPDATE event_series SET generated_through = ? WHERE id = ?`, to.Format(EventDateLayout), seriesID)
+		if err != nil {
+			return created, errors.Wrapf(err, "failed to update series %d", seriesID)
+		}
+	}
+	return created, nil
+}
+
+// GetEventSeriesAttendance returns the attendance of each series' past
+// events by month, between from and to, which are months formatted as
+// PowerHistMonthLayout. Either may be empty to leave that end of the
+// range open.
+func GetEventSeriesAttendance(db *sqlx.DB, from, to string, now time.Time) ([]EventSeriesAttendanceJSON, error) {
+	where := []string{"e.date <= ?"}
+	args := []interface{}{now.Format(EventDateLayout)}
+	if from != "" {
+		t, err := time.Parse(PowerHistMonthLayout, from)
+		if err != nil {
+			return nil, errors.Wrapf(err, "invalid month %s", from)
+		}
+		where = append(where, "e.date >= ?")
+		args = append(args, t.Format(EventDateLayout))
+	}
+	if to != "" {
+		t, err := time.Parse(PowerHistMonthLayout, to)
+		if err != nil {
+			return nil, errors.Wrapf(err, "invalid month %s", to)
+		}
+		where = append(where, "e.date < ?")
+		args = append(args, t.AddDate(0, 1, 0).Format(EventDateLayout))
+	}
+
+	var rows []struct {
+		SeriesID int    `db:"series_id"`
+		Name     string `db:"name"`
+		EventSeriesMonthAttendanceJSON
+	}
+	err := db.Select(&rows, `
+SELECT
+  s.id AS series_id,
+  s.name,
+  DATE_FORMAT(e.date, '%Y-%m') AS month,
+  COUNT(DISTINCT e.id) AS events,
+  COUNT(ea.activist_id) AS attendance,
+  COUNT(DISTINCT ea.activist_id) AS unique_attendees
+FROM event_series s
+JOIN events e ON e.series_id = s.id AND e.deleted_at IS NULL
+LEFT JOIN event_attendance ea ON ea.event_id = e.id
+WHERE `+strings.Join(where, " AND ")+`
+GROUP BY s.id, s.name, month
+ORDER BY s.name, month`, args...)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to select event series attendance")
+	}
+
+	attendance := []EventSeriesAttendanceJSON{}
+	for _, row := range rows {
+		if len(attendance) == 0 || attendance[len(attendance)-1].SeriesID != row.SeriesID {
+			attendance = append(attendance, EventSeriesAttendanceJSON{
+				SeriesID: row.SeriesID,
+				Name:     row.Name,
+			})
+		}
+		last := &attendance[len(attendance)-1]
+		last.Months = append(last.Months, row.EventSeriesMonthAttendanceJSON)
+	}
+	return attendance, nil
+}
@@ -0,0 +1,98 @@
+package model
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestEventSeries(t *testing.T) {
+	db := newTestDB()
+	defer db.Close()
+
+	now := mpiDate("2020-05-01") // Friday
+
+	series, err := CleanEventSeriesData(db, strings.NewReader(`{
+  "name": " Chapter Meeting ",
+  "event_type": "meeting",
+  "recurrence": "freq=weekly;byday=tu",
+  "location": "Berkeley Library",
+  "start_date": "2020-04-01"
+}`))
+	require.NoError(t, err)
+	require.Equal(t, "Chapter Meeting", series.Name)
+	require.Equal(t, EventType("Meeting"), series.EventType)
+	require.Equal(t, "FREQ=WEEKLY;BYDAY=TU", series.Recurrence)
+
+	// Creating a series creates its events for the next four weeks.
+	saved, err := SaveEventSeries(db, series, now)
+	require.NoError(t, err)
+	require.NotZero(t, saved.ID)
+	require.Equal(t, 4, saved.Events)
+
+	events, err := GetEventsJSON(db, GetEventOptions{SeriesID: saved.ID, OrderBy: "e.date"})
+	require.NoError(t, err)
+	require.Len(t, events, 4)
+	require.Equal(t, "2020-05-05", events[0].EventDate)
+	require.Equal(t, "Chapter Meeting", events[0].EventName)
+	require.Equal(t, "Chapter Meeting", events[0].SeriesName)
+	require.Equal(t, "Meeting", events[0].EventType)
+	require.Equal(t, "Berkeley Library", events[0].Location)
+
+	// Running again doesn't create them twice, and events that were
+	// deleted aren't created again.
+	require.NoError(t, DeleteEvent(db, events[1].EventID, ADBUser{}))
+	created, err := CreateUpcomingSeriesEvents(db, now)
+	require.NoError(t, err)
+	require.Equal(t, 0, created)
+
+	// A week later, one more event is created.
+	created, err = CreateUpcomingSeriesEvents(db, now.AddDate(0, 0, 7))
+	require.NoError(t, err)
+	require.Equal(t, 1, created)
+
+	// Attendance is reported by month.
+	a, err := GetOrCreateActivist(db, "Test Activist", ADBUser{})
+	require.NoError(t, err)
+	event, err := GetEvent(db, GetEventOptions{EventID: events[0].EventID})
+	require.NoError(t, err)
+	event.AddedAttendees = []Activist{a}
+	_, err = InsertUpdateEvent(db, event)
+	require.NoError(t, err)
+	attendance, err := GetEventSeriesAttendance(db, "2020-05", "2020-05", mpiDate("2020-05-31"))
+	require.NoError(t, err)
+	require.Equal(t, []EventSeriesAttendanceJSON{{
+		SeriesID: saved.ID,
+		Name:     "Chapter Meeting",
+		Months: []EventSeriesMonthAttendanceJSON{{
+			Month:           "2020-05",
+			Events:          3,
+			Attendance:      1,
+			UniqueAttendees: 1,
+		}},
+	}}, attendance)
+
+	// Editing the series recreates its upcoming events that nobody has
+	// attended.
+	series.ID = saved.ID
+	series.Recurrence = "FREQ=WEEKLY;BYDAY=TH"
+	saved, err = SaveEventSeries(db, series, now)
+	require.NoError(t, err)
+	events, err = GetEventsJSON(db, GetEventOptions{SeriesID: saved.ID, OrderBy: "e.date"})
+	require.NoError(t, err)
+	var dates []string
+	for _, e := range events {
+		dates = append(dates, e.EventDate)
+	}
+	require.Equal(t, []string{"2020-05-05", "2020-05-07", "2020-05-14", "2020-05-21", "2020-05-28"}, dates)
+
+	// Deleting the series keeps events that were attended.
+	require.NoError(t, DeleteEventSeries(db, saved.ID, now))
+	event, err = GetEvent(db, GetEventOptions{EventID: events[0].EventID})
+	require.NoError(t, err)
+	require.Equal(t, 0, event.SeriesID)
+	allSeries, err := GetEventSeriesJSON(db)
+	require.NoError(t, err)
+	require.Empty(t, allSeries)
+}
@@ -1,6 +1,8 @@
 package model
 
 import (
+	"strconv"
+	"strings"
 	"testing"
 	"time"
 
@@ -11,9 +13,9 @@ func TestGetEvents(t *testing.T) {
 	db := newTestDB()
 	defer db.Close()
 
-	a1, err := GetOrCreateActivist(db, "Hello")
+	a1, err := GetOrCreateActivist(db, "Hello", ADBUser{})
 	require.NoError(t, err)
-	a2, err := GetOrCreateActivist(db, "Hi")
+	a2, err := GetOrCreateActivist(db, "Hi", ADBUser{})
 	require.NoError(t, err)
 
 	d1, err := time.Parse("2006-01-02", "2017-01-15")
@@ -24,7 +26,7 @@ func TestGetEvents(t *testing.T) {
 		ID:             1,
 		EventName:      "event one",
 		EventDate:      d1,
-		EventType:      "Working Group",
+		EventType:      "Meeting",
 		Attendees:      []string{a1.Name},
 		AttendeeEmails: []string{a1.Email},
 		AttendeeIDs:    []int{a1.ID},
@@ -32,7 +34,7 @@ func TestGetEvents(t *testing.T) {
 		ID:             2,
 		EventName:      "event two",
 		EventDate:      d2,
-		EventType:      "Protest",
+		EventType:      "Action",
 		Attendees:      []string{a1.Name, a2.Name},
 		AttendeeEmails: []string{a1.Email, a2.Email},
 		AttendeeIDs:    []int{a1.ID, a2.ID},
@@ -77,7 +79,7 @@ func TestGetEvents_orderBy(t *testing.T) {
 	db := newTestDB()
 	defer db.Close()
 
-	a1, err := GetOrCreateActivist(db, "Hello")
+	a1, err := GetOrCreateActivist(db, "Hello", ADBUser{})
 	require.NoError(t, err)
 
 	d1, err := time.Parse("2006-01-02", "2017-01-15")
@@ -88,13 +90,13 @@ func TestGetEvents_orderBy(t *testing.T) {
 		ID:             1,
 		EventName:      "earlier event",
 		EventDate:      d1,
-		EventType:      "Working Group",
+		EventType:      "Meeting",
 		AddedAttendees: []Activist{a1},
 	}, {
 		ID:             2,
 		EventName:      "later event",
 		EventDate:      d2,
-		EventType:      "Protest",
+		EventType:      "Action",
 		AddedAttendees: []Activist{a1},
 	}}
 
@@ -124,15 +126,15 @@ func TestInsertUpdateEvent(t *testing.T) {
 	db := newTestDB()
 	defer db.Close()
 
-	a1, err := GetOrCreateActivist(db, "Hello")
+	a1, err := GetOrCreateActivist(db, "Hello", ADBUser{})
 	require.NoError(t, err)
-	a2, err := GetOrCreateActivist(db, "Hi")
+	a2, err := GetOrCreateActivist(db, "Hi", ADBUser{})
 	require.NoError(t, err)
 
 	event := Event{
 		EventName:      "event one",
 		EventDate:      time.Now(),
-		EventType:      "Working Group",
+		EventType:      "Meeting",
 		AddedAttendees: []Activist{a1},
 	}
 
@@ -140,9 +142,9 @@ func TestInsertUpdateEvent(t *testing.T) {
 	require.NoError(t, err)
 	require.Equal(t, eventID, 1)
 
-	var events []Event
+	var events []int
 	require.NoError(t,
-		db.Select(&events, "select * from events where name = 'event one'"))
+		db.Select(&events, "select id from events where name = 'event one'"))
 
 	require.Equal(t, len(events), 1)
 
@@ -152,6 +154,7 @@ func TestInsertUpdateEvent(t *testing.T) {
 	require.Equal(t, len(attendees), 1)
 
 	event.ID = 1
+	event.Version = 1
 	event.AddedAttendees = []Activist{a1, a2}
 
 	eventID, err = InsertUpdateEvent(db, event)
@@ -160,7 +163,7 @@ func TestInsertUpdateEvent(t *testing.T) {
 
 	events = nil
 	require.NoError(t,
-		db.Select(&events, "select * from events where name = 'event one'"))
+		db.Select(&events, "select id from events where name = 'event one'"))
 
 	require.Equal(t, len(events), 1)
 
@@ -174,13 +177,13 @@ func TestInsertUpdateEvent_noDuplicateAttendees(t *testing.T) {
 	db := newTestDB()
 	defer db.Close()
 
-	a1, err := GetOrCreateActivist(db, "Hello")
+	a1, err := GetOrCreateActivist(db, "Hello", ADBUser{})
 	require.NoError(t, err)
 
 	event := Event{
 		EventName:      "event one",
 		EventDate:      time.Now(),
-		EventType:      "Working Group",
+		EventType:      "Meeting",
 		AddedAttendees: []Activist{a1, a1},
 	}
 
@@ -194,14 +197,62 @@ func TestInsertUpdateEvent_noDuplicateAttendees(t *testing.T) {
 	require.Equal(t, len(attendees), 1)
 }
 
+func TestInsertUpdateEvent_conflict(t *testing.T) {
+	db := newTestDB()
+	defer db.Close()
+
+	a1, err := GetOrCreateActivist(db, "Hello", ADBUser{})
+	require.NoError(t, err)
+	eventID, err := InsertUpdateEvent(db, Event{
+		EventName:      "event one",
+		EventDate:      time.Now(),
+		EventType:      "Meeting",
+		AddedAttendees: []Activist{a1},
+	})
+	require.NoError(t, err)
+
+	// Two people load the event, and the first one saves it.
+	first, err := GetEvent(db, GetEventOptions{EventID: eventID})
+	require.NoError(t, err)
+	require.Equal(t, 1, first.Version)
+	second := first
+	first.EventName = "renamed by first"
+	_, err = InsertUpdateEvent(db, first)
+	require.NoError(t, err)
+
+	// The second save is rejected, and returns the first one's changes.
+	second.EventName = "renamed by second"
+	second.DeletedAttendees = []Activist{a1}
+	_, err = InsertUpdateEvent(db, second)
+	conflictErr, ok := err.(*ConflictError)
+	require.True(t, ok, "%v", err)
+	current := conflictErr.Current.(EventJSON)
+	require.Equal(t, "renamed by first", current.EventName)
+	require.Equal(t, 2, current.Version)
+
+	event, err := GetEvent(db, GetEventOptions{EventID: eventID})
+	require.NoError(t, err)
+	require.Equal(t, "renamed by first", event.EventName)
+	require.Equal(t, []string{"Hello"}, event.Attendees)
+
+	// Clients must send the version they loaded.
+	_, err = CleanEventData(db, strings.NewReader(`{
+  "event_id": `+strconv.Itoa(eventID)+`,
+  "event_name": "event one",
+  "event_date": "2017-04-15",
+  "event_type": "Meeting"
+}`), ADBUser{})
+	require.Error(t, err)
+}
+
 func TestDeleteEvents(t *testing.T) {
 	db := newTestDB()
 	defer db.Close()
 
 	// Set up two events
-	a1, err := GetOrCreateActivist(db, "Hello")
+	a1, err := GetOrCreateActivist(db, "Hello", ADBUser{})
 	require.NoError(t, err)
-	a2, err := GetOrCreateActivist(db, "Hi")
+	a2, err := GetOrCreateActivist(db, "Hi", ADBUser{})
 	require.NoError(t, err)
 
 	d1, err := time.Parse("2006-01-02", "2017-01-15")
@@ -212,13 +263,13 @@ func TestDeleteEvents(t *testing.T) {
 		ID:             1,
 		EventName:      "event one",
 		EventDate:      d1,
-		EventType:      "Working Group",
+		EventType:      "Meeting",
 		AddedAttendees: []Activist{a1},
 	}, {
 		ID:             2,
 		EventName:      "event two",
 		EventDate:      d2,
-		EventType:      "Protest",
+		EventType:      "Action",
 		AddedAttendees: []Activist{a1, a2},
 	}}
 
@@ -235,7 +286,7 @@ func TestDeleteEvents(t *testing.T) {
 	}
 
 	// Delete the first event
-	err = DeleteEvent(db, 1)
+	err = DeleteEvent(db, 1, ADBUser{})
 	require.NoError(t, err)
 
 	gotEvents, err := GetEvents(db, GetEventOptions{})
@@ -253,12 +304,41 @@ func TestDeleteEvents(t *testing.T) {
 	gotEvent.EventDate = time.Time{}
 	wantEvent.EventDate = time.Time{}
 
-	// Make sure that no attendance exists for the first event.
+	// The first event is in the trash, with its attendance.
+	trashed, err := GetTrashedEventsJSON(db)
+	require.NoError(t, err)
+	require.Len(t, trashed, 1)
+	require.Equal(t, 1, trashed[0].EventID)
+	require.Equal(t, 1, trashed[0].Attendees)
+
+	// Restoring it lists it again.
+	require.NoError(t, RestoreEvent(db, 1))
+	gotEvents, err = GetEvents(db, GetEventOptions{})
+	require.NoError(t, err)
+	require.Len(t, gotEvents, 2)
+
+	// Only events in the trash can be purged, and purging them
+	// deletes their attendance.
+	require.Error(t, PurgeEvent(db, 1))
+	require.NoError(t, DeleteEvent(db, 1, ADBUser{}))
+	require.NoError(t, PurgeEvent(db, 1))
+	trashed, err = GetTrashedEventsJSON(db)
+	require.NoError(t, err)
+	require.Empty(t, trashed)
+
 	var attendees []int
 	require.NoError(t,
 		db.Select(&attendees, "select activist_id from event_attendance where event_id = 1"))
-
 	require.Len(t, attendees, 0)
+
+	// Old events in the trash are purged automatically.
+	require.NoError(t, DeleteEvent(db, 2, ADBUser{}))
+	purged, err := PurgeTrashedEvents(db, time.Now().AddDate(0, 0, -1))
+	require.NoError(t, err)
+	require.Equal(t, 0, purged)
+	purged, err = PurgeTrashedEvents(db, time.Now().AddDate(0, 0, 1))
+	require.NoError(t, err)
+	require.Equal(t, 1, purged)
 }
 
 func TestCleanEventAttendanceData(t *testing.T) {
@@ -267,7 +347,7 @@ func TestCleanEventAttendanceData(t *testing.T) {
 
 	testAttendees := []string{"New Person", "Another person", "A third person"}
 
-	gotActivists, err := cleanEventAttendanceData(db, testAttendees)
+	gotActivists, err := cleanEventAttendanceData(db, testAttendees, ADBUser{})
 	require.NoError(t, err)
 
 	gotActivistNames := map[string]struct{}{}
@@ -282,3 +362,133 @@ func TestCleanEventAttendanceData(t *testing.T) {
 	}
 	require.Equal(t, gotActivistNames, wantActivistNames)
 }
+
+func TestCleanEventData_strict(t *testing.T) {
+	db := newTestDB()
+	defer db.Close()
+
+	_, err := GetOrCreateActivist(db, "John Smith", ADBUser{})
+	require.NoError(t, err)
+
+	body := `{
+  "event_name": "my event",
+  "event_date": "2017-04-15",
+  "event_type": "Action",
+  "added_attendees": ["John Smith", "Jon Smith", "brand new person"],
+  "strict": true
+}`
+	_, err = CleanEventData(db, strings.NewReader(body), ADBUser{})
+	unknownErr, ok := err.(*UnknownAttendeesError)
+	require.True(t, ok, "expected UnknownAttendeesError, got %v", err)
+	require.Len(t, unknownErr.Attendees, 2)
+	require.Equal(t, "Jon Smith", unknownErr.Attendees[0].Name)
+	require.Len(t, unknownErr.Attendees[0].Matches, 1)
+	require.Equal(t, "John Smith", unknownErr.Attendees[0].Matches[0].Name)
+	require.Equal(t, "brand new person", unknownErr.Attendees[1].Input)
+	require.Equal(t, "Brand New Person", unknownErr.Attendees[1].Name)
+	require.Len(t, unknownErr.Attendees[1].Matches, 0)
+
+	// Nothing should have been created.
+	names := GetAutocompleteNames(db)
+	require.Equal(t, []string{"John Smith"}, names)
+
+	// Confirming the new names lets the save through.
+	body = `{
+  "event_name": "my event",
+  "event_date": "2017-04-15",
+  "event_type": "Action",
+  "added_attendees": ["John Smith", "Jon Smith", "brand new person"],
+  "strict": true,
+  "confirmed_new_attendees": ["Jon Smith", "Brand New Person"]
+}`
+	event, err := CleanEventData(db, strings.NewReader(body), ADBUser{})
+	require.NoError(t, err)
+	require.Len(t, event.AddedAttendees, 3)
+}
+
+func TestMatchActivists(t *testing.T) {
+	activists := []Activist{
+		{ID: 1, Name: "John Smith", Email: "john@example.com"},
+		{ID: 2, Name: "Jane Doe", Email: "jane@example.com"},
+		{ID: 3, Name: "Johnny Smithers"},
+	}
+
+	matches := matchActivists("Jon Smith", activists)
+	require.NotEmpty(t, matches)
+	require.Equal(t, 1, matches[0].ID)
+	for _, m := range matches {
+		require.NotEqual(t, 2, m.ID)
+	}
+
+	matches = matchActivists("Jane@Example.com", activists)
+	require.Len(t, matches, 1)
+	require.Equal(t, 2, matches[0].ID)
+}
+
+func TestEventDetails(t *testing.T) {
+	db := newTestDB()
+	defer db.Close()
+
+	organizer, err := GetOrCreateActivist(db, "Jane Organizer", ADBUser{})
+	require.NoError(t, err)
+	wgID, err := CreateWorkingGroup(db, WorkingGroup{Name: "Outreach Team", Type: working_group_db_value})
+	require.NoError(t, err)
+
+	event, err := CleanEventData(db, strings.NewReader(`{
+  "event_name": "Tabling",
+  "event_date": "2017-04-15",
+  "event_type": "Outreach",
+  "start_time": "18:30",
+  "end_time": "20:00",
+  "location": " Berkeley BART ",
+  "lead_organizer": "Jane Organizer",
+  "working_group_id": `+strconv.Itoa(wgID)+`,
+  "notes": "Bring the banner."
+}`), ADBUser{})
+	require.NoError(t, err)
+	eventID, err := InsertUpdateEvent(db, event)
+	require.NoError(t, err)
+
+	got, err := GetEvent(db, GetEventOptions{EventID: eventID})
+	require.NoError(t, err)
+	gotJSON := got.ToJSON()
+	require.Equal(t, "18:30", gotJSON.StartTime)
+	require.Equal(t, "20:00", gotJSON.EndTime)
+	require.Equal(t, "Berkeley BART", gotJSON.Location)
+	require.Equal(t, "Jane Organizer", gotJSON.LeadOrganizer)
+	require.Equal(t, organizer.ID, gotJSON.LeadOrganizerID)
+	require.Equal(t, wgID, gotJSON.WorkingGroupID)
+	require.Equal(t, "Outreach Team", gotJSON.WorkingGroupName)
+	require.Equal(t, "Bring the banner.", gotJSON.Notes)
+
+	// Events can be searched by their details.
+	for _, options := range []GetEventOptions{
+		{EventLocation: "berkeley"},
+		{LeadOrganizer: "Jane Organizer"},
+		{WorkingGroupID: wgID},
+	} {
+		events, err := GetEvents(db, options)
+		require.NoError(t, err)
+		require.Len(t, events, 1, "%+v", options)
+	}
+	events, err := GetEvents(db, GetEventOptions{EventLocation: "Oakland"})
+	require.NoError(t, err)
+	require.Empty(t, events)
+
+	for _, details := range []string{
+		`"start_time": "6pm"`,
+		`"end_time": "20:00"`,
+		`"start_time": "20:00", "end_time": "18:30"`,
+		`"lead_organizer": "Nobody"`,
+		`"working_group_id": 1, "circle_id": 1`,
+		`"location": "<script>"`,
+	} {
+		_, err := CleanEventData(db, strings.NewReader(`{
+  "event_name": "Tabling",
+  "event_date": "2017-04-15",
+  "event_type": "Outreach",
+  `+details+`
+}`), ADBUser{})
+		require.Error(t, err, details)
+	}
+}
@@ -0,0 +1,192 @@
+package model
+
+import (
+	"log"
+	"strconv"
+	"time"
+
+	"github.com/dxe/adb/config"
+	"github.com/go-sql-driver/mysql"
+	"github.com/jmoiron/sqlx"
+	"github.com/pkg/errors"
+)
+
+/** Constant and Variable Definitions */
+
+// DefaultEventTrashDays is how long deleted events stay in the trash
+// before they're purged, unless EVENT_TRASH_DAYS is set.
+const DefaultEventTrashDays = 30
+
+/** Type Definitions */
+
+type TrashedEventJSON struct {
+	EventID   int    `json:"event_id"`
+	EventName string `json:"event_name"`
+	EventDate string `json:"event_date"`
+	EventType string `json:"event_type"`
+	Attendees int    `json:"attendees"`
+	DeletedAt string `json:"deleted_at"`
+	DeletedBy string `json:"deleted_by"` // Empty if the user was deleted
+}
+
+/** Functions and Methods */
+
+// EventTrashDays returns how many days deleted events stay in the
+// trash before they're purged.
+func EventTrashDays() int {
+	if config.EventTrashDays == "" {
+		return DefaultEventTrashDays
+	}
+	days, err := strconv.Atoi(config.EventTrashDays)
+	if err != nil || days < 1 {
+		log.Printf("Invalid EVENT_TRASH_DAYS %q; using %d", config.EventTrashDays, DefaultEventTrashDays)
+		return DefaultEventTrashDays
+	}
+	return days
+}
+
+// GetTrashedEventsJSON returns the events in the trash, most recently
+// deleted first.
+func GetTrashedEventsJSON(db *sqlx.DB) ([]TrashedEventJSON, error) {
+	var rows []struct {
+		ID        int            `db:"id"`
+		Name      string         `db:"name"`
+		Date      time.Time      `db:"date"`
+		EventType string         `db:"event_type"`
+		Attendees int            `db:"attendees"`
+		DeletedAt mysql.NullTime `db:"deleted_at"`
+		DeletedBy string         `db:"deleted_by"`
+	}
+	err := db.Select(&rows, `
+SELECT
+  e.id, e.name, e.date, e.event_type,
+  (SELECT COUNT(*) FROM event_attendance ea WHERE ea.event_id = e.id) AS attendees,
+  e.deleted_at,
+  IFNULL(IF(u.name = '', u.email, u.name), '') AS deleted_by
+FROM events e
+LEFT JOIN adb_users u ON u.id = e.deleted_by
+WHERE e.deleted_at IS NOT NULL
+ORDER BY e.deleted_at DESC, e.id DESC`)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to select trashed events")
+	}
+
+	events := []TrashedEventJSON{}
+	for _, r := range rows {
+		events = append(events, TrashedEventJSON{
+			EventID:   r.ID,
+			EventName: r.Name,
+			EventDate: r.Date.Format(EventDateLayout),
+			EventType: r.EventType,
+			Attendees: r.Attendees,
+			DeletedAt: r.DeletedAt.Time.Format(time.RFC3339),
+			DeletedBy: r.DeletedBy,
+		})
+	}
+	return events, nil
+}
+
+// RestoreEvent takes an event out of the trash, so that it and its
+// attendance count again.
+func RestoreEvent(db *sqlx.DB, eventID int) error {
+	tx, err := db.Beginx()
+	if err != nil {
+		return errors.Wrap(err, "failed to create transaction")
+	}
+	res, err := tx.Exec(`UPDATE events
+SET deleted_at = NULL, deleted_by = NULL
+WHERE id = ? AND deleted_at IS NOT NULL`, eventID)
+	if err != nil {
+		tx.Rollback()
+		return errors.Wrapf(err, "failed to restore event %d", eventID)
+	}
+	if n, err := res.RowsAffected(); err != nil {
+		tx.Rollback()
+		return errors.Wrapf(err, "failed to restore event %d", eventID)
+	} else if n == 0 {
+		tx.Rollback()
+		return errors.Errorf("Event %d is not in the trash", eventID)
+	}
+	if err := updateEventActivistMetrics(tx, eventID, nil); err != nil {
+		tx.Rollback()
+		return err
+	}
+	if err := tx.Commit(); err != nil {
+		tx.Rollback()
+		return errors.Wrapf(err, "failed to commit restoring event %d", eventID)
+	}
+	return nil
+}
+
+// PurgeEvent permanently deletes an event in the trash, along with its
+// attendance.
+func PurgeEvent(db *sqlx.DB, eventID int) error {
+	tx, err := db.Beginx()
+	if err != nil {
+		return errors.Wrap(err, "failed to create transaction")
+	}
+	var count int
+	err = tx.Get(&count, `SELECT COUNT(*) FROM events WHERE id = ? AND deleted_at IS NOT NULL`, eventID)
+	if err != nil {
+		tx.Rollback()
+		return errors.Wrapf(err, "failed to get event %d", eventID)
+	}
+	if count == 0 {
+		tx.Rollback()
+		return errors.Errorf("Event %d is not in the trash", eventID)
+	}
+	if err := purgeEventsTx(tx, []int{eventID}); err != nil {
+		tx.Rollback()
+		return err
+	}
+	if err := tx.Commit(); err != nil {
+		tx.Rollback()
+		return errors.Wrapf(err, "failed to commit purging event %d", eventID)
+	}
+	return nil
+}
+
+// PurgeTrashedEvents permanently deletes the events that were moved to
+// the trash before cutoff, and returns how many there were.
+func PurgeTrashedEvents(db *sqlx.DB, cutoff time.Time) (int, error) {
+	tx, err := db.Beginx()
+	if err != nil {
+		return 0, errors.Wrap(err, "failed to create transaction")
+	}
+	var eventIDs []int
+	err = tx.Select(&eventIDs, `SELECT id FROM events WHERE deleted_at < ?`, cutoff)
+	if err != nil {
+		tx.Rollback()
+		return 0, errors.Wrap(err, "failed to select trashed events")
+	}
+	if err := purgeEventsTx(tx, eventIDs); err != nil {
+		tx.Rollback()
+		return 0, err
+	}
+	if err := tx.Commit(); err != nil {
+		tx.Rollback()
+		return 0, errors.Wrap(err, "failed to commit purging trashed events")
+	}
+	return len(eventIDs), nil
+}
+
+// purgeEventsTx deletes events and their attendance. Trashed events
+// don't count toward anyone's metrics, so there are none to update.
+func purgeEventsTx(tx *sqlx.Tx, eventIDs []int) error {
+	if len(eventIDs) == 0 {
+		return nil
+	}
+	for _, query := range []string{
+		`DELETE FROM event_attendance WHERE event_id IN (?)`,
+		`DELETE FROM events WHERE id IN (?)`,
+	} {
+		query, args, err := sqlx.In(query, eventIDs)
+		if err != nil {
+			return errors.Wrap(err, "failed to build purge query")
+		}
+		if _, err := tx.Exec(query, args...); err != nil {
+			return errors.Wrapf(err, "failed to purge events %v", eventIDs)
+		}
+	}
+	return nil
+}
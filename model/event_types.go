@@ -0,0 +1,206 @@
+package model
+
+import (
+	"encoding/json"
+	"io"
+	"strings"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/pkg/errors"
+)
+
+/** Constant and Variable Definitions */
+
+// Event type categories. Direct action and community events count
+// toward the MPI.
+const (
+	EventCategoryDirectAction = "direct_action"
+	EventCategoryCommunity    = "community"
+	EventCategoryOther        = "other"
+)
+
+var EventCategories = map[string]bool{
+	EventCategoryDirectAction: true,
+	EventCategoryCommunity:    true,
+	EventCategoryOther:        true,
+}
+
+/** Type Definitions */
+
+type EventTypeJSON struct {
+	ID           int    `db:"id" json:"id"`
+	Name         string `db:"name" json:"name"`
+	Category     string `db:"category" json:"category"`
+	Active       bool   `db:"active" json:"active"`
+	DisplayOrder int    `db:"display_order" json:"display_order"`
+	// Events is the number of events with this type. It's ignored
+	// when saving.
+	Events int `db:"events" json:"events"`
+}
+
+/** Functions and Methods */
+
+// GetEventTypesJSON returns every event type, in display order.
+func GetEventTypesJSON(db *sqlx.DB) ([]EventTypeJSON, error) {
+	return getEventTypes(db, 0)
+}
+
+func getEventTypes(q sqlx.Queryer, id int) ([]EventTypeJSON, error) {
+	query := `
+SELECT
+  t.id,
+  t.name,
+  t.category,
+  t.active,
+  t.display_order,
+  (SELECT COUNT(*) FROM events e WHERE e.event_type = t.name) AS events
+FROM event_types t
+`
+	var queryArgs []interface{}
+	if id != 0 {
+		query += " WHERE t.id = ? "
+		queryArgs = append(queryArgs, id)
+	}
+	query += " ORDER BY t.display_order, t.name "
+
+	eventTypes := []EventTypeJSON{}
+	if err := sqlx.Select(q, &eventTypes, query, queryArgs...); err != nil {
+		return nil, errors.Wrap(err, "failed to select event types")
+	}
+	return eventTypes, nil
+}
+
+func CleanEventTypeData(body io.Reader) (EventTypeJSON, error) {
+	var eventType EventTypeJSON
+	if err := json.NewDecoder(body).Decode(&eventType); err != nil {
+		return EventTypeJSON{}, errors.Wrap(err, "failed to decode event type")
+	}
+	eventType.Name = strings.TrimSpace(eventType.Name)
+	if eventType.Name == "" {
+		return EventTypeJSON{}, errors.New("Event type name cannot be empty")
+	}
+	if len(eventType.Name) > 60 {
+		return EventTypeJSON{}, errors.New("Event type name must be at most 60 characters")
+	}
+	if err := checkForDangerousChars(eventType.Name); err != nil {
+		return EventTypeJSON{}, err
+	}
+	// The event list uses these as special filters.
+	if eventType.Name == "mpiDA" || eventType.Name == "mpiCOM" {
+		return EventTypeJSON{}, errors.Errorf("Event type name is reserved: %s", eventType.Name)
+	}
+	if !EventCategories[eventType.Category] {
+		return EventTypeJSON{}, errors.Errorf("Not a valid event category: %s", eventType.Category)
+	}
+	return eventType, nil
+}
+
+// SaveEventType creates an event type if it has no ID, or updates the
+// existing one. Renaming a type renames it on all of its events.
+// Changing its category changes which events count toward the MPI, so
+// every activist's metrics are rebuilt.
+func SaveEventType(db *sqlx.DB, eventType EventTypeJSON) (EventTypeJSON, error) {
+	tx, err := db.Beginx()
+	if err != nil {
+		return EventTypeJSON{}, errors.Wrap(err, "failed to create transaction")
+	}
+
+	id := eventType.ID
+	categoryChanged := false
+	if id == 0 {
+		res, err := tx.NamedExec(`
+INSERT INTO event_types (name, category, active, display_order)
+VALUES (:name, :category, :active, :display_order)`, eventType)
+		if err != nil {
+			tx.Rollback()
+			return EventTypeJSON{}, errors.Wrapf(err, "failed to create event type %s", eventType.Name)
+		}
+		newID, err := res.LastInsertId()
+		if err != nil {
+			tx.Rollback()
+			return EventTypeJSON{}, errors.Wrap(err, "failed to get event type id")
+		}
+		id = int(newID)
+	} else {
+		var oldCategory string
+		err := tx.Get(&oldCategory, `SELECT category FROM event_types WHERE id = ? FOR UPDATE`, id)
+		if err != nil {
+			tx.Rollback()
+			return EventTypeJSON{}, errors.Wrapf(err, "failed to get event type %d", id)
+		}
+		categoryChanged = oldCategory != eventType.Category
+
+		_, err = tx.NamedExec(`
+UPDATE event_types
+SET
+  name = :name,
+  category = :category,
+  active = :active,
+  display_order = :display_order
+WHERE id = :id`, eventType)
+		if err != nil {
+			tx.Rollback()
+			return EventTypeJSON{}, errors.Wrapf(err, "failed to update event type %d", id)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		tx.Rollback()
+		return EventTypeJSON{}, errors.Wrap(err, "failed to commit event type")
+	}
+
+	if categoryChanged {
+		if err := RebuildActivistMetrics(db); err != nil {
+			return EventTypeJSON{}, err
+		}
+	}
+
+	eventTypes, err := getEventTypes(db, id)
+	if err != nil {
+		return EventTypeJSON{}, err
+	}
+	if len(eventTypes) != 1 {
+		return EventTypeJSON{}, errors.Errorf("failed to find event type %d", id)
+	}
+	return eventTypes[0], nil
+}
+
+// DeleteEventType deletes an event type that no events have. Types
+// that are in use can be made inactive instead.
+func DeleteEventType(db *sqlx.DB, id int) error {
+	eventTypes, err := getEventTypes(db, id)
+	if err != nil {
+		return err
+	}
+	if len(eventTypes) != 1 {
+		return errors.Errorf("Event type %d does not exist", id)
+	}
+	if eventTypes[0].Events != 0 {
+		return errors.Errorf("%s is used by %d events; make it inactive instead", eventTypes[0].Name, eventTypes[0].Events)
+	}
+
+	if _, err := db.Exec(`DELETE FROM event_types WHERE id = ?`, id); err != nil {
+		return errors.Wrapf(err, "failed to delete event type %d", id)
+	}
+	return nil
+}
+
+// getEventType returns the event type named rawEventType. Inactive
+// types are only allowed if the event already has them, so old events
+// can still be edited.
+func getEventType(q sqlx.Queryer, rawEventType string, eventID int) (EventType, error) {
+	rawEventType = strings.TrimSpace(rawEventType)
+	var names []string
+	err := sqlx.Select(q, &names, `
+SELECT name
+FROM event_types
+WHERE name = ?
+  AND (active OR name = (SELECT event_type FROM events WHERE id = ?))`, rawEventType, eventID)
+	if err != nil {
+		return "", errors.Wrap(err, "failed to select event type")
+	}
+	if len(names) == 0 {
+		return "", errors.New("Not a valid event type: " + rawEventType)
+	}
+	return EventType(names[0]), nil
+}
@@ -0,0 +1,102 @@
+package model
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestCleanEventTypeData(t *testing.T) {
+	eventType, err := CleanEventTypeData(strings.NewReader(`{"name": " Potluck ", "category": "community", "active": true}`))
+	require.NoError(t, err)
+	require.Equal(t, EventTypeJSON{Name: "Potluck", Category: EventCategoryCommunity, Active: true}, eventType)
+
+	for _, body := range []string{
+		`{"name": "", "category": "community"}`,
+		`{"name": "Potluck", "category": "food"}`,
+		`{"name": "mpiDA", "category": "other"}`,
+		`{"name": "<Potluck>", "category": "community"}`,
+	} {
+		_, err := CleanEventTypeData(strings.NewReader(body))
+		require.Error(t, err, body)
+	}
+}
+
+func TestEventTypes(t *testing.T) {
+	db := newTestDB()
+	defer db.Close()
+
+	potluck, err := SaveEventType(db, EventTypeJSON{
+		Name:         "Potluck",
+		Category:     EventCategoryOther,
+		Active:       true,
+		DisplayOrder: 20,
+	})
+	require.NoError(t, err)
+	require.NotZero(t, potluck.ID)
+
+	a, err := GetOrCreateActivist(db, "Test Activist", ADBUser{})
+	require.NoError(t, err)
+	now := time.Now()
+	_, err = InsertUpdateEvent(db, Event{
+		EventName:      "Protest",
+		EventDate:      now,
+		EventType:      "Action",
+		AddedAttendees: []Activist{a},
+	})
+	require.NoError(t, err)
+	eventID, err := InsertUpdateEvent(db, Event{
+		EventName:      "Dinner",
+		EventDate:      now,
+		EventType:      "Potluck",
+		AddedAttendees: []Activist{a},
+	})
+	require.NoError(t, err)
+
+	activist, err := GetActivistJSON(db, GetActivistOptions{ID: a.ID}, testViewer)
+	require.NoError(t, err)
+	require.False(t, activist.MPI)
+
+	// Making potlucks community events puts the activist in the MPI.
+	potluck.Category = EventCategoryCommunity
+	potluck, err = SaveEventType(db, potluck)
+	require.NoError(t, err)
+	require.Equal(t, 1, potluck.Events)
+	activist, err = GetActivistJSON(db, GetActivistOptions{ID: a.ID}, testViewer)
+	require.NoError(t, err)
+	require.True(t, activist.MPI)
+
+	// Renaming a type renames it on its events.
+	potluck.Name = "Community Dinner"
+	potluck, err = SaveEventType(db, potluck)
+	require.NoError(t, err)
+	event, err := GetEvent(db, GetEventOptions{EventID: eventID})
+	require.NoError(t, err)
+	require.Equal(t, EventType("Community Dinner"), event.EventType)
+
+	// Types in use can't be deleted.
+	require.Error(t, DeleteEventType(db, potluck.ID))
+
+	// Inactive types can't be given to new events, but events that
+	// already have them can keep them.
+	potluck.Active = false
+	potluck, err = SaveEventType(db, potluck)
+	require.NoError(t, err)
+	_, err = getEventType(db, "Community Dinner", 0)
+	require.Error(t, err)
+	eventType, err := getEventType(db, "community dinner", eventID)
+	require.NoError(t, err)
+	require.Equal(t, EventType("Community Dinner"), eventType)
+
+	// Unused types can be deleted.
+	unused, err := SaveEventType(db, EventTypeJSON{Name: "Unused", Category: EventCategoryOther})
+	require.NoError(t, err)
+	require.NoError(t, DeleteEventType(db, unused.ID))
+	eventTypes, err := GetEventTypesJSON(db)
+	require.NoError(t, err)
+	for _, et := range eventTypes {
+		require.NotEqual(t, "Unused", et.Name)
+	}
+}
@@ -0,0 +1,523 @@
+package model
+
+import (
+	"encoding/csv"
+	"io"
+	"reflect"
+	"strconv"
+	"strings"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/pkg/errors"
+)
+
+/** Constant and Variable Definitions */
+
+const (
+	ImportCreate = "create"
+	ImportUpdate = "update"
+	ImportMatch  = "match"
+	ImportError  = "error"
+)
+
+// activistImportColumns are the ActivistJSON columns that can be set
+// by an activist import. Every other ActivistExport column is computed
+// and is ignored, so that an export can be edited and imported again.
+var activistImportColumns = map[string]struct{}{
+	"email": struct{}{}, "facebook": struct{}{}, "location": struct{}{},
+	"name": struct{}{}, "phone": struct{}{}, "dob": struct{}{},
+
+	"activist_level": struct{}{}, "source": struct{}{}, "hiatus": struct{}{},
+
+	"connector": struct{}{}, "training0": struct{}{}, "training1": struct{}{},
+	"training2": struct{}{}, "training3": struct{}{}, "training4": struct{}{},
+	"training5": struct{}{}, "training6": struct{}{}, "training_protest": struct{}{},
+	"dev_quiz": struct{}{}, "dev_manager": struct{}{}, "dev_interest": struct{}{},
+	"prospect_senior_organizer": struct{}{},
+	"cm_first_email":            struct{}{}, "cm_approval_email": struct{}{},
+	"cm_warning_email": struct{}{}, "cir_first_email": struct{}{},
+	"prospect_organizer": struct{}{}, "prospect_chapter_member": struct{}{},
+	"referral_friends": struct{}{}, "referral_apply": struct{}{},
+	"referral_outlet": struct{}{}, "circle_interest": struct{}{},
+	"interest_date": struct{}{}, "notes": struct{}{},
+	"vision_wall": struct{}{},
+}
+
+// activistImportColumnPermissions are the permissions needed to import
+// the sensitive activistImportColumns.
+var activistImportColumnPermissions = map[string]string{
+	"email": PermissionActivistWriteContact, "phone": PermissionActivistWriteContact,
+	"location": PermissionActivistWriteContact, "facebook": PermissionActivistWriteContact,
+	"dob": PermissionActivistReadPrivate, "notes": PermissionActivistReadPrivate,
+}
+
+/** Type Definitions */
+
+type ImportFieldChangeJSON struct {
+	Field    string `json:"field"`
+	OldValue string `json:"old_value"`
+	NewValue string `json:"new_value"`
+}
+
+type ImportRowJSON struct {
+	// Row is the line number in the CSV file, counting the header.
+	Row        int                     `json:"row"`
+	Action     string                  `json:"action"`
+	ActivistID int                     `json:"activist_id"`
+	Name       string                  `json:"name"`
+	Changes    []ImportFieldChangeJSON `json:"changes"`
+	Error      string                  `json:"error"`
+}
+
+type ImportResultJSON struct {
+	DryRun         bool            `json:"dry_run"`
+	Committed      bool            `json:"committed"`
+	Rows           []ImportRowJSON `json:"rows"`
+	IgnoredColumns []string        `json:"ignored_columns"`
+	Creates        int             `json:"creates"`
+	Updates        int             `json:"updates"`
+	Matches        int             `json:"matches"`
+	Errors         int             `json:"errors"`
+	EventID        int             `json:"event_id"`
+}
+
+// importActivist is the plan for a single row of an import.
+type importActivist struct {
+	row      ImportRowJSON
+	activist ActivistExtra
+}
+
+/** Functions and Methods */
+
+// ImportActivists creates or updates an activist for each row of a
+// CSV file. The header row names the ActivistJSON columns in the
+// file, and must include name or email. Each row is matched to an
+// existing activist by email, then by name; blank cells leave the
+// existing value alone. Contact and private columns can only be
+// imported by users allowed to write them.
+//
+// Nothing is written if dryRun is set or if any row has an error.
+// Otherwise every row is written in a single transaction.
+func ImportActivists(db *sqlx.DB, r io.Reader, dryRun bool, user ADBUser) (ImportResultJSON, error) {
+	header, records, err := readImportCSV(r)
+	if err != nil {
+		return ImportResultJSON{}, err
+	}
+
+	result := ImportResultJSON{DryRun: dryRun}
+	hasKey := false
+	for _, column := range header {
+		if _, ok := activistImportColumns[column]; ok {
+			if permission, ok := activistImportColumnPermissions[column]; ok && !user.HasPermission(permission) {
+				return ImportResultJSON{}, errors.Errorf("You don't have permission to import %s", column)
+			}
+			if column == "name" || column == "email" {
+				hasKey = true
+			}
+			continue
+		}
+		if _, ok := activistExportFieldIndex[column]; ok {
+			result.IgnoredColumns = append(result.IgnoredColumns, column)
+			continue
+		}
+		return ImportResultJSON{}, errors.Errorf("Unknown column %s", column)
+	}
+	if !hasKey {
+		return ImportResultJSON{}, errors.New("CSV must have a name or email column")
+	}
+
+	existing, err := GetActivistsExtra(db, GetActivistOptions{})
+	if err != nil {
+		return ImportResultJSON{}, err
+	}
+	byEmail := map[string][]ActivistExtra{}
+	byName := map[string]ActivistExtra{}
+	hidden, err := getHiddenActivistNames(db)
+	if err != nil {
+		return ImportResultJSON{}, err
+	}
+	for _, a := range existing {
+		if email := strings.ToLower(strings.TrimSpace(a.Email)); email != "" {
+			byEmail[email] = append(byEmail[email], a)
+		}
+		byName[strings.ToLower(a.Name)] = a
+	}
+
+	var plan []importActivist
+	seen := map[string]int{}
+	for i, record := range records {
+		p := planActivistImport(header, record, byEmail, byName, hidden, activistRedactionFor(user))
+		p.row.Row = i + 2
+		if p.row.Error == "" {
+			key := strings.ToLower(p.activist.Name)
+			if row, ok := seen[key]; ok {
+				p.row.Action = ImportError
+				p.row.Error = "Same activist as row " + strconv.Itoa(row)
+			}
+			seen[key] = p.row.Row
+		}
+		plan = append(plan, p)
+	}
+
+	for _, p := range plan {
+		result.Rows = append(result.Rows, p.row)
+		countImportRow(&result, p.row)
+	}
+	if dryRun || result.Errors > 0 {
+		return result, nil
+	}
+
+	tx, err := db.Beginx()
+	if err != nil {
+		return ImportResultJSON{}, errors.Wrap(err, "failed to create transaction")
+	}
+	for i, p := range plan {
+		switch p.row.Action {
+		case ImportCreate:
+			id, err := createActivistTx(tx, p.activist, user)
+			if err != nil {
+				tx.Rollback()
+				return ImportResultJSON{}, errors.Wrapf(err, "row %d", p.row.Row)
+			}
+			result.Rows[i].ActivistID = id
+		case ImportUpdate:
+			if err := updateActivistDataTx(tx, p.activist, user); err != nil {
+				tx.Rollback()
+				return ImportResultJSON{}, errors.Wrapf(err, "row %d", p.row.Row)
+			}
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		tx.Rollback()
+		return ImportResultJSON{}, errors.Wrap(err, "failed to commit import")
+	}
+	result.Committed = true
+	return result, nil
+}
+
+// planActivistImport plans a single row of an activist import. The
+// changes are redacted, since they're sent back to the importer.
+func planActivistImport(header, record []string, byEmail map[string][]ActivistExtra, byName map[string]ActivistExtra, hidden map[string]struct{}, redaction activistRedaction) importActivist {
+	fail := func(name string, err error) importActivist {
+		return importActivist{row: ImportRowJSON{Action: ImportError, Name: name, Error: err.Error()}}
+	}
+
+	values := map[string]string{}
+	for i, column := range header {
+		if _, ok := activistImportColumns[column]; ok {
+			values[column] = strings.TrimSpace(record[i])
+		}
+	}
+	name := values["name"]
+	email := strings.ToLower(values["email"])
+	if name == "" && email == "" {
+		return fail(name, errors.New("Row must have a name or email"))
+	}
+
+	var match ActivistExtra
+	found := false
+	if email != "" {
+		matches := byEmail[email]
+		if len(matches) > 1 {
+			return fail(name, errors.Errorf("%d activists have the email %s", len(matches), values["email"]))
+		}
+		if len(matches) == 1 {
+			match, found = matches[0], true
+		}
+	}
+	if !found && name != "" {
+		match, found = byName[strings.ToLower(name)]
+	}
+
+	var activistJSON ActivistJSON
+	if found {
+		// Nothing is withheld, since this is what's saved. Only
+		// the changes are redacted.
+		activistJSON = buildActivistJSON(match, activistRedaction{})
+	} else {
+		if name == "" {
+			return fail(name, errors.Errorf("No activist has the email %s, and the row has no name", values["email"]))
+		}
+		if hiddenName(hidden, name) {
+			return fail(name, errors.Errorf("%s is a hidden activist", name))
+		}
+		activistJSON.ActivistLevel = "Supporter"
+	}
+	if err := setImportValues(&activistJSON, values); err != nil {
+		return fail(name, err)
+	}
+	activist, err := cleanActivistJSON(activistJSON)
+	if err != nil {
+		return fail(name, err)
+	}
+	if activist.Name == "" {
+		return fail(name, errors.New("Name cannot be empty"))
+	}
+
+	p := importActivist{
+		row: ImportRowJSON{
+			ActivistID: activist.ID,
+			Name:       activist.Name,
+		},
+		activist: activist,
+	}
+	before := ActivistExtra{}
+	if found {
+		before = match
+	}
+	for _, c := range diffActivists(before, activist) {
+		p.row.Changes = append(p.row.Changes, ImportFieldChangeJSON{
+			Field:    c.Field,
+			OldValue: redaction.column(c.Field, c.OldValue.String),
+			NewValue: redaction.column(c.Field, c.NewValue.String),
+		})
+	}
+	switch {
+	case !found:
+		p.row.Action = ImportCreate
+	case len(p.row.Changes) > 0:
+		p.row.Action = ImportUpdate
+	default:
+		p.row.Action = ImportMatch
+	}
+	return p
+}
+
+// setImportValues sets the non-blank values on the matching fields of
+// activistJSON.
+func setImportValues(activistJSON *ActivistJSON, values map[string]string) error {
+	v := reflect.ValueOf(activistJSON).Elem()
+	for column, value := range values {
+		if value == "" {
+			continue
+		}
+		field := v.Field(activistExportFieldIndex[column])
+		switch field.Kind() {
+		case reflect.String:
+			field.SetString(value)
+		case reflect.Bool:
+			b, err := parseImportBool(value)
+			if err != nil {
+				return errors.Errorf("Invalid %s: %s", column, value)
+			}
+			field.SetBool(b)
+		default:
+			return errors.Errorf("Column %s can't be imported", column)
+		}
+	}
+	return nil
+}
+
+func parseImportBool(value string) (bool, error) {
+	switch strings.ToLower(value) {
+	case "yes", "y", "x":
+		return true, nil
+	case "no", "n":
+		return false, nil
+	}
+	return strconv.ParseBool(value)
+}
+
+// ImportEventAttendance creates an event with an attendee for each row
+// of a CSV file. The header row must include a name column, and may
+// include an email column which is used to match existing activists
+// before their name. Attendees that don't match anyone are created.
+//
+// Nothing is written if dryRun is set or if any row has an error.
+// Otherwise the event, its attendance and any new activists are
+// written in a single transaction.
+func ImportEventAttendance(db *sqlx.DB, eventJSON EventJSON, r io.Reader, dryRun bool, user ADBUser) (ImportResultJSON, error) {
+	event, err := cleanEventDetails(db, eventJSON)
+	if err != nil {
+		return ImportResultJSON{}, err
+	}
+	if event.ID != 0 {
+		return ImportResultJSON{}, errors.New("Attendance can only be imported into a new event")
+	}
+
+	header, records, err := readImportCSV(r)
+	if err != nil {
+		return ImportResultJSON{}, err
+	}
+	nameIndex, emailIndex := -1, -1
+	for i, column := range header {
+		switch column {
+		case "name":
+			nameIndex = i
+		case "email":
+			emailIndex = i
+		}
+	}
+	if nameIndex == -1 {
+		return ImportResultJSON{}, errors.New("CSV must have a name column")
+	}
+
+	var activists []Activist
+	err = db.Select(&activists, `SELECT id, name, email FROM activists WHERE hidden = 0`)
+	if err != nil {
+		return ImportResultJSON{}, errors.Wrap(err, "failed to select activists")
+	}
+	byEmail := map[string][]Activist{}
+	byName := map[string]Activist{}
+	hidden, err := getHiddenActivistNames(db)
+	if err != nil {
+		return ImportResultJSON{}, err
+	}
+	for _, a := range activists {
+		if email := strings.ToLower(strings.TrimSpace(a.Email)); email != "" {
+			byEmail[email] = append(byEmail[email], a)
+		}
+		byName[strings.ToLower(a.Name)] = a
+	}
+
+	result := ImportResultJSON{DryRun: dryRun}
+	var newActivists []ActivistExtra
+	seen := map[string]int{}
+	for i, record := range records {
+		row := ImportRowJSON{Row: i + 2}
+		name := cleanAttendeeName(record[nameIndex])
+		email := ""
+		if emailIndex != -1 {
+			email = strings.TrimSpace(record[emailIndex])
+		}
+		row.Name = name
+
+		var match Activist
+		found := false
+		if matches := byEmail[strings.ToLower(email)]; email != "" && len(matches) == 1 {
+			match, found = matches[0], true
+		} else if email != "" && len(matches) > 1 {
+			row.Action, row.Error = ImportError, strconv.Itoa(len(matches))+" activists have the email "+email
+		}
+		if !found && row.Error == "" {
+			match, found = byName[strings.ToLower(name)]
+		}
+
+		switch {
+		case row.Error != "":
+		case found:
+			row.Action, row.ActivistID, row.Name = ImportMatch, match.ID, match.Name
+		case name == "":
+			row.Action, row.Error = ImportError, "Row must have a name"
+		case hiddenName(hidden, name):
+			row.Action, row.Error = ImportError, name+" is a hidden activist"
+		default:
+			if err := checkForDangerousChars(name); err != nil {
+				row.Action, row.Error = ImportError, err.Error()
+				break
+			}
+			row.Action = ImportCreate
+			row.Changes = []ImportFieldChangeJSON{{Field: "name", NewValue: name}}
+			if email != "" {
+				row.Changes = append(row.Changes, ImportFieldChangeJSON{Field: "email", NewValue: email})
+			}
+		}
+
+		if row.Error == "" {
+			key := strings.ToLower(row.Name)
+			if prev, ok := seen[key]; ok {
+				row.Action, row.Error = ImportError, "Same activist as row "+strconv.Itoa(prev)
+			}
+			seen[key] = row.Row
+		}
+		if row.Action == ImportCreate {
+			newActivists = append(newActivists, ActivistExtra{
+				Activist:               Activist{Name: name, Email: email},
+				ActivistMembershipData: ActivistMembershipData{ActivistLevel: "Supporter"},
+			})
+		}
+		result.Rows = append(result.Rows, row)
+		countImportRow(&result, row)
+	}
+	if dryRun || result.Errors > 0 {
+		return result, nil
+	}
+
+	tx, err := db.Beginx()
+	if err != nil {
+		return ImportResultJSON{}, errors.Wrap(err, "failed to create transaction")
+	}
+	created := 0
+	for i, row := range result.Rows {
+		if row.Action == ImportCreate {
+			id, err := createActivistTx(tx, newActivists[created], user)
+			if err != nil {
+				tx.Rollback()
+				return ImportResultJSON{}, errors.Wrapf(err, "row %d", row.Row)
+			}
+			created++
+			result.Rows[i].ActivistID = id
+		}
+		event.AddedAttendees = append(event.AddedAttendees, Activist{ID: result.Rows[i].ActivistID})
+	}
+	eventID, err := insertEventTx(tx, event)
+	if err != nil {
+		tx.Rollback()
+		return ImportResultJSON{}, err
+	}
+	if err := tx.Commit(); err != nil {
+		tx.Rollback()
+		return ImportResultJSON{}, errors.Wrap(err, "failed to commit import")
+	}
+	result.EventID = eventID
+	result.Committed = true
+	return result, nil
+}
+
+// getHiddenActivistNames returns the lowercased names of hidden
+// activists. Names are unique across all activists, so an import can't
+// create an activist with one of these names.
+func getHiddenActivistNames(db *sqlx.DB) (map[string]struct{}, error) {
+	var names []string
+	if err := db.Select(&names, `SELECT name FROM activists WHERE hidden = 1`); err != nil {
+		return nil, errors.Wrap(err, "failed to select hidden activists")
+	}
+	hidden := map[string]struct{}{}
+	for _, name := range names {
+		hidden[strings.ToLower(name)] = struct{}{}
+	}
+	return hidden, nil
+}
+
+func hiddenName(hidden map[string]struct{}, name string) bool {
+	_, ok := hidden[strings.ToLower(name)]
+	return ok
+}
+
+func readImportCSV(r io.Reader) (header []string, records [][]string, err error) {
+	csvReader := csv.NewReader(r)
+	csvReader.TrimLeadingSpace = true
+	all, err := csvReader.ReadAll()
+	if err != nil {
+		return nil, nil, errors.Wrap(err, "failed to read CSV")
+	}
+	if len(all) == 0 {
+		return nil, nil, errors.New("CSV is empty")
+	}
+
+	header = all[0]
+	for i, column := range header {
+		// Be lenient about headers typed by hand.
+		header[i] = strings.ToLower(strings.Replace(strings.TrimSpace(column), " ", "_", -1))
+	}
+	// Skip blank lines, which spreadsheets like to leave at the end.
+	for _, record := range all[1:] {
+		if strings.TrimSpace(strings.Join(record, "")) != "" {
+			records = append(records, record)
+		}
+	}
+	return header, records, nil
+}
+
+func countImportRow(result *ImportResultJSON, row ImportRowJSON) {
+	switch row.Action {
+	case ImportCreate:
+		result.Creates++
+	case ImportUpdate:
+		result.Updates++
+	case ImportMatch:
+		result.Matches++
+	case ImportError:
+		result.Errors++
+	}
+}
@@ -0,0 +1,147 @@
+package model
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// testImporter can import every activist column.
+var testImporter = ADBUser{
+	Name: "Test Importer",
+	Permissions: map[string]bool{
+		PermissionActivistWriteContact: true,
+		PermissionActivistReadPrivate:  true,
+	},
+}
+
+func TestReadImportCSV(t *testing.T) {
+	header, records, err := readImportCSV(strings.NewReader("Name, Activist Level\nJo,Supporter\n,\n"))
+	require.NoError(t, err)
+	require.Equal(t, []string{"name", "activist_level"}, header)
+	require.Equal(t, [][]string{{"Jo", "Supporter"}}, records)
+
+	_, _, err = readImportCSV(strings.NewReader(""))
+	require.Error(t, err)
+}
+
+func TestSetImportValues(t *testing.T) {
+	var activistJSON ActivistJSON
+	activistJSON.Email = "old@example.com"
+	require.NoError(t, setImportValues(&activistJSON, map[string]string{
+		"email":  "",
+		"phone":  "555-1234",
+		"hiatus": "yes",
+	}))
+	require.Equal(t, "old@example.com", activistJSON.Email)
+	require.Equal(t, "555-1234", activistJSON.Phone)
+	require.True(t, activistJSON.Hiatus)
+
+	require.Error(t, setImportValues(&activistJSON, map[string]string{"hiatus": "maybe"}))
+}
+
+func TestPlanActivistImport_redaction(t *testing.T) {
+	existing := ActivistExtra{
+		Activist:               Activist{ID: 1, Name: "Existing Activist", Email: "existing@example.com"},
+		ActivistMembershipData: ActivistMembershipData{ActivistLevel: "Supporter"},
+	}
+	byEmail := map[string][]ActivistExtra{"existing@example.com": {existing}}
+	byName := map[string]ActivistExtra{"existing activist": existing}
+	header := []string{"email", "phone"}
+	record := []string{"existing@example.com", "555-1234"}
+
+	p := planActivistImport(header, record, byEmail, byName, nil, activistRedaction{})
+	require.Equal(t, ImportUpdate, p.row.Action)
+	require.Equal(t, []ImportFieldChangeJSON{{Field: "phone", NewValue: "555-1234"}}, p.row.Changes)
+
+	// The change is masked for importers who can't see contact
+	// details, but the full phone number is still saved.
+	p = planActivistImport(header, record, byEmail, byName, nil, activistRedactionFor(ADBUser{}))
+	require.Equal(t, ImportUpdate, p.row.Action)
+	require.Equal(t, []ImportFieldChangeJSON{{Field: "phone", NewValue: "***-1234"}}, p.row.Changes)
+	require.Equal(t, "555-1234", p.activist.Phone)
+}
+
+func TestImportActivists(t *testing.T) {
+	db := newTestDB()
+	defer db.Close()
+
+	_, err := CreateActivist(db, ActivistExtra{
+		Activist:               Activist{Name: "Existing Activist", Email: "existing@example.com"},
+		ActivistMembershipData: ActivistMembershipData{ActivistLevel: "Supporter"},
+	}, ADBUser{})
+	require.NoError(t, err)
+
+	// Contact and private columns need permission to import.
+	for _, csv := range []string{"name,phone\nX,555-1234\n", "name,notes\nX,Y\n"} {
+		_, err = ImportActivists(db, strings.NewReader(csv), true, ADBUser{})
+		require.Error(t, err)
+	}
+
+	csv := `name,email,phone,total_events
+New Activist,new@example.com,,3
+Renamed Activist,EXISTING@example.com,555-1234,
+`
+	result, err := ImportActivists(db, strings.NewReader(csv), true, testImporter)
+	require.NoError(t, err)
+	require.False(t, result.Committed)
+	require.Equal(t, []string{"total_events"}, result.IgnoredColumns)
+	require.Equal(t, 1, result.Creates)
+	require.Equal(t, 1, result.Updates)
+	require.Equal(t, ImportUpdate, result.Rows[1].Action)
+
+	activists, err := GetActivistsJSON(db, GetActivistOptions{}, testViewer)
+	require.NoError(t, err)
+	require.Len(t, activists, 1)
+
+	result, err = ImportActivists(db, strings.NewReader(csv), false, testImporter)
+	require.NoError(t, err)
+	require.True(t, result.Committed)
+
+	activists, err = GetActivistsJSON(db, GetActivistOptions{}, testViewer)
+	require.NoError(t, err)
+	require.Len(t, activists, 2)
+	require.Equal(t, "New Activist", activists[0].Name)
+	require.Equal(t, "Renamed Activist", activists[1].Name)
+	require.Equal(t, "555-1234", activists[1].Phone)
+
+	// Importing the same file again changes nothing.
+	result, err = ImportActivists(db, strings.NewReader(csv), true, testImporter)
+	require.NoError(t, err)
+	require.Equal(t, 2, result.Matches)
+
+	// A bad row stops the whole import.
+	result, err = ImportActivists(db, strings.NewReader("name,activist_level\nAnother Activist,\nBad Activist,Not A Level\n"), false, ADBUser{})
+	require.NoError(t, err)
+	require.False(t, result.Committed)
+	require.Equal(t, 1, result.Errors)
+	activists, err = GetActivistsJSON(db, GetActivistOptions{}, testViewer)
+	require.NoError(t, err)
+	require.Len(t, activists, 2)
+
+	_, err = ImportActivists(db, strings.NewReader("name,password\nX,Y\n"), true, ADBUser{})
+	require.Error(t, err)
+}
+
+func TestImportEventAttendance(t *testing.T) {
+	db := newTestDB()
+	defer db.Close()
+
+	existing, err := GetOrCreateActivist(db, "Existing Activist", ADBUser{})
+	require.NoError(t, err)
+
+	eventJSON := EventJSON{EventName: "Imported Event", EventDate: "2020-01-02", EventType: "Outreach"}
+	csv := "name\nexisting activist\nNew Attendee\n"
+	result, err := ImportEventAttendance(db, eventJSON, strings.NewReader(csv), false, ADBUser{})
+	require.NoError(t, err)
+	require.True(t, result.Committed)
+	require.Equal(t, 1, result.Matches)
+	require.Equal(t, 1, result.Creates)
+	require.Equal(t, existing.ID, result.Rows[0].ActivistID)
+
+	events, err := GetEventsJSON(db, GetEventOptions{EventID: result.EventID})
+	require.NoError(t, err)
+	require.Len(t, events, 1)
+	require.ElementsMatch(t, []string{"Existing Activist", "New Attendee"}, events[0].Attendees)
+}
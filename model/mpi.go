@@ -0,0 +1,225 @@
+package model
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/pkg/errors"
+)
+
+/** Constant and Variable Definitions */
+
+// MPIMonthLayout is the format of months in MPIRules.
+const MPIMonthLayout string = "2006-01"
+
+// DefaultMPIRules are the rules the MPI has always been computed with.
+var DefaultMPIRules = MPIRules{
+	// The community requirement was waived for January and
+	// February 2020.
+	CommunityWaivers: []string{"2020-01", "2020-02"},
+	Months:           2,
+}
+
+// mpiRules are the rules in use. They're only changed at startup, by
+// SetMPIRules.
+var mpiRules = DefaultMPIRules
+
+/** Type Definitions */
+
+// MPIRules decide who counts toward the Movement Power Index. An
+// activist meets the requirements in a month if they attended a direct
+// action and a community event that month, and they're counted in the
+// MPI if they met the requirements in any of the last Months months.
+// Which events are direct actions or community events is decided by
+// the category of their event type.
+type MPIRules struct {
+	// CommunityWaivers are months, formatted as MPIMonthLayout, in
+	// which a direct action alone meets the requirements.
+	CommunityWaivers []string `json:"community_waivers"`
+	// Months is how many calendar months, counting back from and
+	// including the current month, an activist stays in the MPI for
+	// after meeting the requirements. The default of 2 keeps the MPI
+	// from dropping to zero at the start of every month.
+	Months int `json:"months"`
+}
+
+// MPIMonth is an activist's attendance in one calendar month.
+type MPIMonth struct {
+	DirectAction    bool
+	Community       bool
+	CommunityWaived bool
+}
+
+// MPIAttendance is one event attended by an activist.
+type MPIAttendance struct {
+	ActivistID int       `db:"activist_id"`
+	Date       time.Time `db:"date"`
+	// Category is the category of the event's type.
+	Category string `db:"category"`
+}
+
+/** Functions and Methods */
+
+// LoadMPIRules replaces the rules used to compute the MPI with ones
+// read from a JSON file. Fields missing from the file keep their
+// default values. It should only be called at startup.
+func LoadMPIRules(path string) error {
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		return errors.Wrapf(err, "failed to read MPI rules from %s", path)
+	}
+	rules := DefaultMPIRules
+	if err := json.Unmarshal(b, &rules); err != nil {
+		return errors.Wrapf(err, "failed to parse MPI rules from %s", path)
+	}
+	return SetMPIRules(rules)
+}
+
+// SetMPIRules replaces the rules used to compute the MPI. It should
+// only be called at startup.
+func SetMPIRules(rules MPIRules) error {
+	if err := rules.validate(); err != nil {
+		return err
+	}
+	mpiRules = rules
+	return nil
+}
+
+// GetMPIRules returns the rules in use.
+func GetMPIRules() MPIRules {
+	return mpiRules
+}
+
+func (r MPIRules) validate() error {
+	for _, m := range r.CommunityWaivers {
+		if _, err := time.Parse(MPIMonthLayout, m); err != nil {
+			return errors.Wrapf(err, "invalid MPI community waiver %s", m)
+		}
+	}
+	if r.Months < 1 {
+		return errors.New("MPI rules must count at least one month")
+	}
+	return nil
+}
+
+// CommunityWaived returns whether the community requirement is waived
+// in the month containing month.
+func (r MPIRules) CommunityWaived(month time.Time) bool {
+	return containsString(r.CommunityWaivers, month.Format(MPIMonthLayout))
+}
+
+// Month summarizes the events attended in the month containing month.
+func (r MPIRules) Month(month time.Time, attendance []MPIAttendance) MPIMonth {
+	m := MPIMonth{CommunityWaived: r.CommunityWaived(month)}
+	for _, a := range attendance {
+		if a.Date.Year() != month.Year() || a.Date.Month() != month.Month() {
+			continue
+		}
+		m.DirectAction = m.DirectAction || a.Category == EventCategoryDirectAction
+		m.Community = m.Community || a.Category == EventCategoryCommunity
+	}
+	return m
+}
+
+// Met returns whether the month meets the MPI requirements.
+func (m MPIMonth) Met() bool {
+	return m.DirectAction && (m.Community || m.CommunityWaived)
+}
+
+// IsMPI returns whether an activist with the given attendance counts
+// toward the MPI at now.
+func (r MPIRules) IsMPI(now time.Time, attendance []MPIAttendance) bool {
+	month := startOfMonth(now)
+	for i := 0; i < r.Months; i++ {
+		if r.Month(month.AddDate(0, -i, 0), attendance).Met() {
+			return true
+		}
+	}
+	return false
+}
+
+// windowStart is the first day that can affect IsMPI at now.
+func (r MPIRules) windowStart(now time.Time) time.Time {
+	return startOfMonth(now).AddDate(0, 1-r.Months, 0)
+}
+
+func startOfMonth(t time.Time) time.Time {
+	return time.Date(t.Year(), t.Month(), 1, 0, 0, 0, 0, t.Location())
+}
+
+// updateActivistMPI recomputes the mpi flag of the given activists, and
+// their MPP requirements for the current month in activist_metrics.
+// It's called by updateActivistMetrics, so it runs whenever attendance
+// changes and when metrics are refreshed each day.
+func updateActivistMPI(e sqlx.Ext, activistIDs []int) error {
+	if len(activistIDs) == 0 {
+		return nil
+	}
+	rules := GetMPIRules()
+	now := time.Now()
+
+	query, args, err := sqlx.In(`
+SELECT ea.activist_id, e.date, t.category
+FROM event_attendance ea
+JOIN events e ON e.id = ea.event_id
+JOIN event_types t ON t.name = e.event_type
+WHERE ea.activist_id IN (?) AND e.date >= ? AND e.deleted_at IS NULL`, activistIDs, rules.windowStart(now).Format(EventDateLayout))
+	if err != nil {
+		return errors.Wrap(err, "failed to build MPI attendance query")
+	}
+	var attendance []MPIAttendance
+	if err := sqlx.Select(e, &attendance, query, args...); err != nil {
+		return errors.Wrap(err, "failed to select MPI attendance")
+	}
+	byActivist := map[int][]MPIAttendance{}
+	for _, a := range attendance {
+		byActivist[a.ActivistID] = append(byActivist[a.ActivistID], a)
+	}
+
+	// Group activists by outcome so there are at most a few updates
+	// no matter how many activists there are.
+	mpi := map[bool][]int{}
+	mpp := map[[2]bool][]int{}
+	for _, id := range activistIDs {
+		a := byActivist[id]
+		isMPI := rules.IsMPI(now, a)
+		mpi[isMPI] = append(mpi[isMPI], id)
+		month := rules.Month(now, a)
+		key := [2]bool{month.DirectAction, month.Community || month.CommunityWaived}
+		mpp[key] = append(mpp[key], id)
+	}
+
+	for isMPI, ids := range mpi {
+		query, args, err := sqlx.In(`UPDATE activists SET mpi = ? WHERE id IN (?)`, isMPI, ids)
+		if err != nil {
+			return errors.Wrap(err, "failed to build MPI update")
+		}
+		if _, err := e.Exec(query, args...); err != nil {
+			return errors.Wrap(err, "failed to update MPI")
+		}
+	}
+	for key, ids := range mpp {
+		query, args, err := sqlx.In(`
+UPDATE activist_metrics
+SET mpp_protest = ?, mpp_community = ?
+WHERE activist_id IN (?)`, key[0], key[1], ids)
+		if err != nil {
+			return errors.Wrap(err, "failed to build MPP requirements update")
+		}
+		if _, err := e.Exec(query, args...); err != nil {
+			return errors.Wrap(err, "failed to update MPP requirements")
+		}
+	}
+	return nil
+}
+
+func containsString(list []string, s string) bool {
+	for _, l := range list {
+		if l == s {
+			return true
+		}
+	}
+	return false
+}
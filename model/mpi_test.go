@@ -0,0 +1,211 @@
+package model
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func mpiDate(s string) time.Time {
+	t, err := time.Parse(EventDateLayout, s)
+	if err != nil {
+		panic(err)
+	}
+	return t
+}
+
+func TestMPIRules_IsMPI(t *testing.T) {
+	rules := DefaultMPIRules
+	now := mpiDate("2020-05-15")
+
+	tests := []struct {
+		name       string
+		attendance []MPIAttendance
+		want       bool
+	}{{
+		name: "no attendance",
+		want: false,
+	}, {
+		name: "direct action and community this month",
+		attendance: []MPIAttendance{
+			{Date: mpiDate("2020-05-01"), Category: EventCategoryDirectAction},
+			{Date: mpiDate("2020-05-10"), Category: EventCategoryCommunity},
+		},
+		want: true,
+	}, {
+		name: "direct action and community last month",
+		attendance: []MPIAttendance{
+			{Date: mpiDate("2020-04-01"), Category: EventCategoryDirectAction},
+			{Date: mpiDate("2020-04-30"), Category: EventCategoryCommunity},
+		},
+		want: true,
+	}, {
+		name: "too long ago",
+		attendance: []MPIAttendance{
+			{Date: mpiDate("2020-03-01"), Category: EventCategoryDirectAction},
+			{Date: mpiDate("2020-03-30"), Category: EventCategoryCommunity},
+		},
+		want: false,
+	}, {
+		name: "requirements split across months",
+		attendance: []MPIAttendance{
+			{Date: mpiDate("2020-04-01"), Category: EventCategoryDirectAction},
+			{Date: mpiDate("2020-05-01"), Category: EventCategoryCommunity},
+		},
+		want: false,
+	}, {
+		name: "only direct actions",
+		attendance: []MPIAttendance{
+			{Date: mpiDate("2020-05-01"), Category: EventCategoryDirectAction},
+			{Date: mpiDate("2020-05-02"), Category: EventCategoryDirectAction},
+		},
+		want: false,
+	}, {
+		name: "events that don't count",
+		attendance: []MPIAttendance{
+			{Date: mpiDate("2020-05-01"), Category: EventCategoryOther},
+			{Date: mpiDate("2020-05-02"), Category: EventCategoryOther},
+		},
+		want: false,
+	}}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			require.Equal(t, test.want, rules.IsMPI(now, test.attendance))
+		})
+	}
+}
+
+func TestMPIRules_communityWaiver(t *testing.T) {
+	rules := DefaultMPIRules
+	attendance := []MPIAttendance{{Date: mpiDate("2020-02-03"), Category: EventCategoryDirectAction}}
+
+	require.True(t, rules.IsMPI(mpiDate("2020-02-20"), attendance))
+	require.True(t, rules.IsMPI(mpiDate("2020-03-20"), attendance))
+	require.False(t, rules.IsMPI(mpiDate("2020-04-20"), attendance))
+
+	// Outside of the waiver, a direct action isn't enough.
+	attendance = []MPIAttendance{{Date: mpiDate("2020-03-03"), Category: EventCategoryDirectAction}}
+	require.False(t, rules.IsMPI(mpiDate("2020-03-20"), attendance))
+
+	month := rules.Month(mpiDate("2020-01-31"), nil)
+	require.Equal(t, MPIMonth{CommunityWaived: true}, month)
+	require.False(t, month.Met())
+}
+
+func TestMPIRules_months(t *testing.T) {
+	rules := DefaultMPIRules
+	rules.Months = 3
+	attendance := []MPIAttendance{
+		{Date: mpiDate("2019-11-01"), Category: EventCategoryDirectAction},
+		{Date: mpiDate("2019-11-02"), Category: EventCategoryCommunity},
+	}
+
+	// The window crosses the start of the year.
+	require.True(t, rules.IsMPI(mpiDate("2020-01-31"), attendance))
+	require.False(t, rules.IsMPI(mpiDate("2020-02-01"), attendance))
+	require.Equal(t, mpiDate("2019-11-01"), rules.windowStart(mpiDate("2020-01-31")))
+}
+
+func TestSetMPIRules(t *testing.T) {
+	defer SetMPIRules(DefaultMPIRules)
+
+	rules := DefaultMPIRules
+	rules.CommunityWaivers = []string{"January"}
+	require.Error(t, SetMPIRules(rules))
+
+	rules = DefaultMPIRules
+	rules.Months = 0
+	require.Error(t, SetMPIRules(rules))
+
+	rules = DefaultMPIRules
+	rules.Months = 3
+	require.NoError(t, SetMPIRules(rules))
+	require.Equal(t, rules, GetMPIRules())
+}
+
+func TestLoadMPIRules(t *testing.T) {
+	defer SetMPIRules(DefaultMPIRules)
+
+	dir, err := ioutil.TempDir("", "mpi")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "rules.json")
+
+	require.NoError(t, ioutil.WriteFile(path, []byte(`{"community_waivers": [], "months": 1}`), 0600))
+	require.NoError(t, LoadMPIRules(path))
+	want := DefaultMPIRules
+	want.CommunityWaivers = []string{}
+	want.Months = 1
+	require.Equal(t, want, GetMPIRules())
+
+	require.NoError(t, ioutil.WriteFile(path, []byte(`{"months": "two"}`), 0600))
+	require.Error(t, LoadMPIRules(path))
+	require.Equal(t, want, GetMPIRules())
+}
+
+func TestUpdateActivistMPI(t *testing.T) {
+	db := newTestDB()
+	defer db.Close()
+
+	a1, err := GetOrCreateActivist(db, "Test Activist", ADBUser{})
+	require.NoError(t, err)
+	a2, err := GetOrCreateActivist(db, "Other Activist", ADBUser{})
+	require.NoError(t, err)
+
+	now := time.Now()
+	_, err = InsertUpdateEvent(db, Event{
+		EventName:      "Protest",
+		EventDate:      now,
+		EventType:      "Action",
+		AddedAttendees: []Activist{a1, a2},
+	})
+	require.NoError(t, err)
+	circleID, err := InsertUpdateEvent(db, Event{
+		EventName:      "Circle",
+		EventDate:      now,
+		EventType:      "Circle",
+		AddedAttendees: []Activist{a1},
+	})
+	require.NoError(t, err)
+
+	a, err := GetActivistJSON(db, GetActivistOptions{ID: a1.ID}, testViewer)
+	require.NoError(t, err)
+	require.True(t, a.MPI)
+	require.Equal(t, "Fulfilling requirements", a.MPPRequirements)
+	a, err = GetActivistJSON(db, GetActivistOptions{ID: a2.ID}, testViewer)
+	require.NoError(t, err)
+	require.False(t, a.MPI)
+	require.Equal(t, "Missing Community event", a.MPPRequirements)
+
+	// Saving an activist doesn't change their MPI.
+	extra, err := GetActivistsExtra(db, GetActivistOptions{ID: a2.ID})
+	require.NoError(t, err)
+	require.Len(t, extra, 1)
+	extra[0].MPI = true
+	_, err = UpdateActivistData(db, extra[0], ADBUser{})
+	require.NoError(t, err)
+	a, err = GetActivistJSON(db, GetActivistOptions{ID: a2.ID}, testViewer)
+	require.NoError(t, err)
+	require.False(t, a.MPI)
+
+	// Removing the community event removes a1 from the MPI.
+	require.NoError(t, DeleteEvent(db, circleID, ADBUser{}))
+	a, err = GetActivistJSON(db, GetActivistOptions{ID: a1.ID}, testViewer)
+	require.NoError(t, err)
+	require.False(t, a.MPI)
+
+	// Changing the rules takes effect on the next rebuild.
+	defer SetMPIRules(DefaultMPIRules)
+	rules := DefaultMPIRules
+	rules.CommunityWaivers = []string{now.Format(MPIMonthLayout)}
+	require.NoError(t, SetMPIRules(rules))
+	require.NoError(t, RebuildActivistMetrics(db))
+	a, err = GetActivistJSON(db, GetActivistOptions{ID: a2.ID}, testViewer)
+	require.NoError(t, err)
+	require.True(t, a.MPI)
+	require.Equal(t, "Fulfilling requirements", a.MPPRequirements)
+}
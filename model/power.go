@@ -1,15 +1,34 @@
 package model
 
 import (
+	"strings"
+	"time"
+
 	"github.com/jmoiron/sqlx"
+	"github.com/pkg/errors"
 )
 
+/** Constant and Variable Definitions */
+
+// PowerHistMonthLayout is the format of the months that bound a
+// power history query.
+const PowerHistMonthLayout string = "2006-01"
+
 /** Type Definitions */
 
 type PowerHist struct {
-	Month int
-	Year  int
-	Power int
+	Month                int              `db:"month" json:"month"`
+	Year                 int              `db:"year" json:"year"`
+	Power                int              `db:"power" json:"power"`
+	ActiveChapterMembers int              `db:"active_chapter_members" json:"active_chapter_members"`
+	Levels               []PowerHistLevel `json:"levels"`
+}
+
+// PowerHistLevel counts the visible activists at one activist level.
+type PowerHistLevel struct {
+	ActivistLevel string `db:"activist_level" json:"activist_level"`
+	Activists     int    `db:"activists" json:"activists"`
+	MPI           int    `db:"mpi" json:"mpi"`
 }
 
 /** Functions and Methods */
@@ -41,3 +60,118 @@ and activist_level in ('chapter member','organizer','senior organizer')
 	}
 	return members, nil
 }
+
+// RecordPowerSnapshot saves the current power and membership as the
+// snapshot for the month of now. Taking another snapshot in the same
+// month replaces it, so once a month is over its snapshot is from the
+// end of the month.
+func RecordPowerSnapshot(db *sqlx.DB, now time.Time) error {
+	year, month := now.Year(), int(now.Month())
+
+	tx, err := db.Beginx()
+	if err != nil {
+		return errors.Wrap(err, "failed to create transaction")
+	}
+	_, err = tx.Exec(`
+INSERT INTO power_history (year, month, power, active_chapter_members, recorded_at)
+SELECT
+  ?, ?,
+  IFNULL(SUM(mpi = 1), 0),
+  IFNULL(SUM(mpi = 1 AND activist_level IN ('chapter member', 'organizer', 'senior organizer')), 0),
+  ?
+FROM activists
+ON DUPLICATE KEY UPDATE
+  power = VALUES(power),
+  active_chapter_members = VALUES(active_chapter_members),
+  recorded_at = VALUES(recorded_at)`, year, month, now)
+	if err != nil {
+		tx.Rollback()
+		return errors.Wrapf(err, "failed to record power for %d-%02d", year, month)
+	}
+
+	// Levels that no longer have anyone shouldn't keep an old count.
+	_, err = tx.Exec(`DELETE FROM power_history_levels WHERE year = ? AND month = ?`, year, month)
+	if err != nil {
+		tx.Rollback()
+		return errors.Wrapf(err, "failed to clear power levels for %d-%02d", year, month)
+	}
+	_, err = tx.Exec(`
+INSERT INTO power_history_levels (year, month, activist_level, activists, mpi)
+SELECT ?, ?, activist_level, COUNT(*), SUM(mpi = 1)
+FROM activists
+WHERE hidden = 0
+GROUP BY activist_level`, year, month)
+	if err != nil {
+		tx.Rollback()
+		return errors.Wrapf(err, "failed to record power levels for %d-%02d", year, month)
+	}
+
+	if err := tx.Commit(); err != nil {
+		tx.Rollback()
+		return errors.Wrap(err, "failed to commit power snapshot")
+	}
+	return nil
+}
+
+// GetPowerHistory returns the monthly power snapshots between from and
+// to, which are months formatted as PowerHistMonthLayout. Either may
+// be empty to leave that end of the range open.
+func GetPowerHistory(db *sqlx.DB, from, to string) ([]PowerHist, error) {
+	var where []string
+	var args []interface{}
+	for _, bound := range []struct {
+		month string
+		op    string
+	}{{from, ">="}, {to, "<="}} {
+		if bound.month == "" {
+			continue
+		}
+		t, err := time.Parse(PowerHistMonthLayout, bound.month)
+		if err != nil {
+			return nil, errors.Wrapf(err, "invalid month %s", bound.month)
+		}
+		where = append(where, "(year * 12 + month) "+bound.op+" ?")
+		args = append(args, t.Year()*12+int(t.Month()))
+	}
+	whereClause := ""
+	if len(where) > 0 {
+		whereClause = " WHERE " + strings.Join(where, " AND ")
+	}
+
+	var history []PowerHist
+	err := db.Select(&history, `
+SELECT year, month, power, active_chapter_members
+FROM power_history`+whereClause+`
+ORDER BY year, month`, args...)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to select power history")
+	}
+
+	var levels []struct {
+		Year  int `db:"year"`
+		Month int `db:"month"`
+		PowerHistLevel
+	}
+	err = db.Select(&levels, `
+SELECT year, month, activist_level, activists, mpi
+FROM power_history_levels`+whereClause+`
+ORDER BY activist_level`, args...)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to select power history levels")
+	}
+
+	index := map[[2]int]int{}
+	for i := range history {
+		history[i].Levels = []PowerHistLevel{}
+		index[[2]int{history[i].Year, history[i].Month}] = i
+	}
+	for _, l := range levels {
+		if i, ok := index[[2]int{l.Year, l.Month}]; ok {
+			history[i].Levels = append(history[i].Levels, l.PowerHistLevel)
+		}
+	}
+	if history == nil {
+		history = []PowerHist{}
+	}
+	return history, nil
+}
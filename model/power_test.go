@@ -0,0 +1,57 @@
+package model
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestPowerHistory(t *testing.T) {
+	db := newTestDB()
+	defer db.Close()
+
+	for _, a := range []ActivistExtra{
+		{Activist: Activist{Name: "A"}, ActivistMembershipData: ActivistMembershipData{ActivistLevel: "Organizer"}},
+		{Activist: Activist{Name: "B"}, ActivistMembershipData: ActivistMembershipData{ActivistLevel: "Supporter"}},
+		{Activist: Activist{Name: "C"}, ActivistMembershipData: ActivistMembershipData{ActivistLevel: "Supporter"}},
+	} {
+		_, err := CreateActivist(db, a, ADBUser{})
+		require.NoError(t, err)
+	}
+	_, err := db.Exec(`UPDATE activists SET mpi = 1 WHERE name IN ('A', 'B')`)
+	require.NoError(t, err)
+
+	january := time.Date(2020, time.January, 31, 0, 0, 0, 0, time.UTC)
+	require.NoError(t, RecordPowerSnapshot(db, january))
+
+	_, err = db.Exec(`UPDATE activists SET mpi = 1 WHERE name = 'C'`)
+	require.NoError(t, err)
+	february := time.Date(2020, time.February, 1, 0, 0, 0, 0, time.UTC)
+	require.NoError(t, RecordPowerSnapshot(db, february))
+	// Retaking a month's snapshot replaces it.
+	require.NoError(t, RecordPowerSnapshot(db, february))
+
+	history, err := GetPowerHistory(db, "", "")
+	require.NoError(t, err)
+	require.Len(t, history, 2)
+	require.Equal(t, PowerHist{
+		Year:                 2020,
+		Month:                1,
+		Power:                2,
+		ActiveChapterMembers: 1,
+		Levels: []PowerHistLevel{
+			{ActivistLevel: "Organizer", Activists: 1, MPI: 1},
+			{ActivistLevel: "Supporter", Activists: 2, MPI: 1},
+		},
+	}, history[0])
+	require.Equal(t, 3, history[1].Power)
+
+	history, err = GetPowerHistory(db, "2020-02", "2020-12")
+	require.NoError(t, err)
+	require.Len(t, history, 1)
+	require.Equal(t, 2, history[0].Month)
+
+	_, err = GetPowerHistory(db, "February", "")
+	require.Error(t, err)
+}
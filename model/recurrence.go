@@ -0,0 +1,246 @@
+package model
+
+import (
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+/** Constant and Variable Definitions */
+
+const (
+	RecurrenceDaily   = "DAILY"
+	RecurrenceWeekly  = "WEEKLY"
+	RecurrenceMonthly = "MONTHLY"
+)
+
+// recurrenceWeekdays are the names of the days of the week in
+// recurrence rules, indexed by time.Weekday.
+var recurrenceWeekdays = [7]string{"SU", "MO", "TU", "WE", "TH", "FR", "SA"}
+
+/** Type Definitions */
+
+// Recurrence is the subset of iCalendar recurrence rules (RFC 5545)
+// that event series use: FREQ of DAILY, WEEKLY or MONTHLY, with
+// optional INTERVAL, BYDAY and BYMONTHDAY. For example,
+// "FREQ=WEEKLY;BYDAY=TU,TH" is every Tuesday and Thursday, and
+// "FREQ=MONTHLY;BYDAY=-1SA" is the last Saturday of every month.
+//
+// Without BYDAY or BYMONTHDAY, weekly and monthly series recur on the
+// weekday or day of the month of their start date.
+type Recurrence struct {
+	Frequency string
+	Interval  int
+	Days      []RecurrenceDay
+	MonthDays []int
+}
+
+// RecurrenceDay is a day of the week, and for monthly recurrences
+// which one in the month it is. Ordinal 1 is the first in the month,
+// -1 is the last, and 0 is every one.
+type RecurrenceDay struct {
+	Ordinal int
+	Weekday time.Weekday
+}
+
+/** Functions and Methods */
+
+func ParseRecurrence(rule string) (Recurrence, error) {
+	r := Recurrence{Interval: 1}
+	for _, part := range strings.Split(strings.ToUpper(strings.TrimSpace(rule)), ";") {
+		if part == "" {
+			continue
+		}
+		kv := strings.SplitN(part, "=", 2)
+		if len(kv) != 2 {
+			return Recurrence{}, errors.Errorf("Invalid recurrence rule part: %s", part)
+		}
+		key, value := kv[0], kv[1]
+		switch key {
+		case "FREQ":
+			if value != RecurrenceDaily && value != RecurrenceWeekly && value != RecurrenceMonthly {
+				return Recurrence{}, errors.Errorf("Recurrence frequency must be DAILY, WEEKLY or MONTHLY, not %s", value)
+			}
+			r.Frequency = value
+		case "INTERVAL":
+			n, err := strconv.Atoi(value)
+			if err != nil || n < 1 {
+				return Recurrence{}, errors.Errorf("Invalid recurrence interval: %s", value)
+			}
+			r.Interval = n
+		case "BYDAY":
+			for _, d := range strings.Split(value, ",") {
+				day, err := parseRecurrenceDay(d)
+				if err != nil {
+					return Recurrence{}, err
+				}
+				r.Days = append(r.Days, day)
+			}
+		case "BYMONTHDAY":
+			for _, d := range strings.Split(value, ",") {
+				n, err := strconv.Atoi(d)
+				if err != nil || n == 0 || n < -31 || n > 31 {
+					return Recurrence{}, errors.Errorf("Invalid recurrence day of the month: %s", d)
+				}
+				r.MonthDays = append(r.MonthDays, n)
+			}
+		default:
+			return Recurrence{}, errors.Errorf("Unsupported recurrence rule part: %s", key)
+		}
+	}
+
+	if r.Frequency == "" {
+		return Recurrence{}, errors.New("Recurrence rule must have a FREQ")
+	}
+	if len(r.Days) > 0 && len(r.MonthDays) > 0 {
+		return Recurrence{}, errors.New("Recurrence rule can't have both BYDAY and BYMONTHDAY")
+	}
+	if r.Frequency == RecurrenceDaily && (len(r.Days) > 0 || len(r.MonthDays) > 0) {
+		return Recurrence{}, errors.New("Daily recurrence rules can't have BYDAY or BYMONTHDAY")
+	}
+	if r.Frequency == RecurrenceWeekly && len(r.MonthDays) > 0 {
+		return Recurrence{}, errors.New("Weekly recurrence rules can't have BYMONTHDAY")
+	}
+	for _, d := range r.Days {
+		if d.Ordinal != 0 && r.Frequency != RecurrenceMonthly {
+			return Recurrence{}, errors.New("Only monthly recurrence rules can number their days")
+		}
+	}
+	return r, nil
+}
+
+func parseRecurrenceDay(s string) (RecurrenceDay, error) {
+	if len(s) < 2 {
+		return RecurrenceDay{}, errors.Errorf("Invalid recurrence day: %s", s)
+	}
+	day := RecurrenceDay{Weekday: -1}
+	for weekday, name := range recurrenceWeekdays {
+		if name == s[len(s)-2:] {
+			day.Weekday = time.Weekday(weekday)
+		}
+	}
+	if day.Weekday < 0 {
+		return RecurrenceDay{}, errors.Errorf("Invalid recurrence day: %s", s)
+	}
+	if ordinal := s[:len(s)-2]; ordinal != "" {
+		n, err := strconv.Atoi(ordinal)
+		if err != nil || n == 0 || n < -5 || n > 5 {
+			return RecurrenceDay{}, errors.Errorf("Invalid recurrence day: %s", s)
+		}
+		day.Ordinal = n
+	}
+	return day, nil
+}
+
+// String formats r as a recurrence rule.
+func (r Recurrence) String() string {
+	parts := []string{"FREQ=" + r.Frequency}
+	if r.Interval > 1 {
+		parts = append(parts, "INTERVAL="+strconv.Itoa(r.Interval))
+	}
+	if len(r.Days) > 0 {
+		var days []string
+		for _, d := range r.Days {
+			day := ""
+			if d.Ordinal != 0 {
+				day = strconv.Itoa(d.Ordinal)
+			}
+			days = append(days, day+recurrenceWeekdays[d.Weekday])
+		}
+		parts = append(parts, "BYDAY="+strings.Join(days, ","))
+	}
+	if len(r.MonthDays) > 0 {
+		var days []string
+		for _, d := range r.MonthDays {
+			days = append(days, strconv.Itoa(d))
+		}
+		parts = append(parts, "BYMONTHDAY="+strings.Join(days, ","))
+	}
+	return strings.Join(parts, ";")
+}
+
+// Occurrences returns the dates from from through to, inclusive, on
+// which a series that started on start recurs.
+func (r Recurrence) Occurrences(start, from, to time.Time) []time.Time {
+	start, from, to = recurrenceDate(start), recurrenceDate(from), recurrenceDate(to)
+	if from.Before(start) {
+		from = start
+	}
+	var dates []time.Time
+	for day := from; !day.After(to); day = day.AddDate(0, 0, 1) {
+		if r.matches(start, day) {
+			dates = append(dates, day)
+		}
+	}
+	return dates
+}
+
+func (r Recurrence) matches(start, day time.Time) bool {
+	interval := r.Interval
+	if interval < 1 {
+		interval = 1
+	}
+	switch r.Frequency {
+	case RecurrenceDaily:
+		return daysBetween(start, day)%interval == 0
+	case RecurrenceWeekly:
+		// Weeks start on Monday, as they do in iCalendar by default.
+		if daysBetween(startOfWeek(start), startOfWeek(day))/7%interval != 0 {
+			return false
+		}
+		if len(r.Days) == 0 {
+			return day.Weekday() == start.Weekday()
+		}
+		for _, d := range r.Days {
+			if d.Weekday == day.Weekday() {
+				return true
+			}
+		}
+		return false
+	case RecurrenceMonthly:
+		months := (day.Year()-start.Year())*12 + int(day.Month()) - int(start.Month())
+		if months%interval != 0 {
+			return false
+		}
+		daysInMonth := time.Date(day.Year(), day.Month()+1, 0, 0, 0, 0, 0, time.UTC).Day()
+		if len(r.MonthDays) > 0 {
+			for _, d := range r.MonthDays {
+				if d == day.Day() || d < 0 && daysInMonth+d+1 == day.Day() {
+					return true
+				}
+			}
+			return false
+		}
+		if len(r.Days) > 0 {
+			for _, d := range r.Days {
+				if d.Weekday != day.Weekday() {
+					continue
+				}
+				if d.Ordinal == 0 ||
+					d.Ordinal > 0 && (day.Day()-1)/7+1 == d.Ordinal ||
+					d.Ordinal < 0 && (daysInMonth-day.Day())/7+1 == -d.Ordinal {
+					return true
+				}
+			}
+			return false
+		}
+		return day.Day() == start.Day()
+	}
+	return false
+}
+
+// recurrenceDate drops the time of day from t, so that dates can be
+// compared and subtracted.
+func recurrenceDate(t time.Time) time.Time {
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+}
+
+func daysBetween(a, b time.Time) int {
+	return int(b.Sub(a).Hours() / 24)
+}
+
+func startOfWeek(t time.Time) time.Time {
+	return t.AddDate(0, 0, -((int(t.Weekday()) + 6) % 7))
+}
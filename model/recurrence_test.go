@@ -0,0 +1,109 @@
+package model
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseRecurrence(t *testing.T) {
+	for rule, want := range map[string]string{
+		"FREQ=DAILY":                      "FREQ=DAILY",
+		"freq=weekly;interval=1":          "FREQ=WEEKLY",
+		" FREQ=WEEKLY;BYDAY=TU,TH; ":      "FREQ=WEEKLY;BYDAY=TU,TH",
+		"FREQ=MONTHLY;BYDAY=-1SA":         "FREQ=MONTHLY;BYDAY=-1SA",
+		"FREQ=MONTHLY;BYMONTHDAY=1,-1":    "FREQ=MONTHLY;BYMONTHDAY=1,-1",
+		"INTERVAL=2;FREQ=WEEKLY;BYDAY=MO": "FREQ=WEEKLY;INTERVAL=2;BYDAY=MO",
+	} {
+		r, err := ParseRecurrence(rule)
+		require.NoError(t, err, rule)
+		require.Equal(t, want, r.String(), rule)
+	}
+
+	for _, rule := range []string{
+		"",
+		"BYDAY=TU",
+		"FREQ=YEARLY",
+		"FREQ=WEEKLY;INTERVAL=0",
+		"FREQ=WEEKLY;BYDAY=XX",
+		"FREQ=WEEKLY;BYDAY=1TU",
+		"FREQ=DAILY;BYDAY=TU",
+		"FREQ=WEEKLY;BYMONTHDAY=1",
+		"FREQ=MONTHLY;BYDAY=TU;BYMONTHDAY=1",
+		"FREQ=MONTHLY;BYMONTHDAY=32",
+		"FREQ=WEEKLY;COUNT=3",
+	} {
+		_, err := ParseRecurrence(rule)
+		require.Error(t, err, rule)
+	}
+}
+
+func TestRecurrence_Occurrences(t *testing.T) {
+	tests := []struct {
+		rule      string
+		start     string
+		from, to  string
+		wantDates []string
+	}{{
+		rule:      "FREQ=DAILY;INTERVAL=3",
+		start:     "2020-05-01",
+		from:      "2020-05-01",
+		to:        "2020-05-10",
+		wantDates: []string{"2020-05-01", "2020-05-04", "2020-05-07", "2020-05-10"},
+	}, {
+		// Without BYDAY, a weekly series recurs on its start date's weekday.
+		rule:      "FREQ=WEEKLY",
+		start:     "2020-05-05", // Tuesday
+		from:      "2020-05-01",
+		to:        "2020-05-20",
+		wantDates: []string{"2020-05-05", "2020-05-12", "2020-05-19"},
+	}, {
+		rule:      "FREQ=WEEKLY;BYDAY=TU,TH",
+		start:     "2020-05-01",
+		from:      "2020-05-10",
+		to:        "2020-05-17",
+		wantDates: []string{"2020-05-12", "2020-05-14"},
+	}, {
+		// Weeks start on Monday, so a Sunday start is in the week
+		// before the following Monday.
+		rule:      "FREQ=WEEKLY;INTERVAL=2;BYDAY=MO,SU",
+		start:     "2020-05-03", // Sunday
+		from:      "2020-05-01",
+		to:        "2020-05-31",
+		wantDates: []string{"2020-05-03", "2020-05-11", "2020-05-17", "2020-05-25", "2020-05-31"},
+	}, {
+		rule:      "FREQ=MONTHLY;BYDAY=-1SA",
+		start:     "2020-01-01",
+		from:      "2020-04-01",
+		to:        "2020-06-30",
+		wantDates: []string{"2020-04-25", "2020-05-30", "2020-06-27"},
+	}, {
+		rule:      "FREQ=MONTHLY;BYDAY=1MO",
+		start:     "2020-01-01",
+		from:      "2020-06-01",
+		to:        "2020-07-31",
+		wantDates: []string{"2020-06-01", "2020-07-06"},
+	}, {
+		rule:      "FREQ=MONTHLY;INTERVAL=2;BYMONTHDAY=-1",
+		start:     "2020-01-15",
+		from:      "2020-01-01",
+		to:        "2020-06-30",
+		wantDates: []string{"2020-01-31", "2020-03-31", "2020-05-31"},
+	}, {
+		rule:      "FREQ=MONTHLY",
+		start:     "2020-01-15",
+		from:      "2020-03-01",
+		to:        "2020-04-30",
+		wantDates: []string{"2020-03-15", "2020-04-15"},
+	}}
+
+	for _, test := range tests {
+		r, err := ParseRecurrence(test.rule)
+		require.NoError(t, err, test.rule)
+		var got []string
+		for _, d := range r.Occurrences(mpiDate(test.start), mpiDate(test.from), mpiDate(test.to)) {
+			got = append(got, d.Format(EventDateLayout))
+		}
+		require.Equal(t, test.wantDates, got, test.rule)
+	}
+}
@@ -0,0 +1,293 @@
+package model
+
+import (
+	"encoding/json"
+	"io"
+	"regexp"
+	"strings"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/pkg/errors"
+)
+
+/** Constant and Variable Definitions */
+
+// Permissions that roles can be given. Routes check for these, rather
+// than for particular roles.
+const (
+	PermissionActivistRead         = "activist.read"
+	PermissionActivistReadLed      = "activist.read_led"
+	PermissionActivistReadContact  = "activist.read_contact"
+	PermissionActivistReadPrivate  = "activist.read_private"
+	PermissionActivistWrite        = "activist.write"
+	PermissionActivistWriteContact = "activist.write_contact"
+	PermissionActivistMerge        = "activist.merge"
+	PermissionConnectionRead       = "connection.read"
+	PermissionConnectionWrite      = "connection.write"
+	PermissionDataImport           = "data.import"
+	PermissionEventRead            = "event.read"
+	PermissionEventWrite           = "event.write"
+	PermissionEventDelete          = "event.delete"
+	PermissionEventPurge           = "event.purge"
+	PermissionEventSeriesWrite     = "event_series.write"
+	PermissionEventTypeManage      = "event_type.manage"
+	PermissionGroupRead            = "group.read"
+	PermissionGroupWrite           = "group.write"
+	PermissionGroupWriteLed        = "group.write_led"
+	PermissionUserManage           = "user.manage"
+	PermissionWallboardRead        = "wallboard.read"
+)
+
+// Permissions lists every permission, in the order they're shown to
+// admins.
+var Permissions = []PermissionJSON{
+	{PermissionActivistRead, "See activists, their history and the activist reports"},
+	{PermissionActivistReadLed, "See the members of the working groups and circles they lead, including contact details"},
+	{PermissionActivistReadContact, "See activists' full email, phone, location and Facebook, instead of masked ones"},
+	{PermissionActivistReadPrivate, "See activists' date of birth and notes. Every access is logged"},
+	{PermissionActivistWrite, "Edit and hide activists"},
+	{PermissionActivistWriteContact, "Edit activists' email, phone, location and Facebook"},
+	{PermissionActivistMerge, "Merge activists and review possible duplicates"},
+	{PermissionConnectionRead, "See maintenance connections"},
+	{PermissionConnectionWrite, "Add and edit maintenance connections"},
+	{PermissionDataImport, "Import activists and events from CSV"},
+	{PermissionEventRead, "See events, event types and activist names"},
+	{PermissionEventWrite, "Add and edit events and approve self check-ins"},
+	{PermissionEventDelete, "Move events to the trash"},
+	{PermissionEventPurge, "Restore and permanently delete events in the trash"},
+	{PermissionEventSeriesWrite, "Add and edit recurring event series"},
+	{PermissionEventTypeManage, "Add and edit event types"},
+	{PermissionGroupRead, "See working groups and circles"},
+	{PermissionGroupWrite, "Add and edit working groups and circles"},
+	{PermissionGroupWriteLed, "Edit the working groups and circles they lead"},
+	{PermissionUserManage, "Manage users, roles, API keys and sessions"},
+	{PermissionWallboardRead, "See the MPI and chapter member wallboards"},
+}
+
+// AdminRole always has every permission, so it can't be edited or
+// deleted.
+const AdminRole = "admin"
+
+var roleNameRegexp = regexp.MustCompile(`^[a-z][a-z0-9_]{0,44}$`)
+
+/** Type Definitions */
+
+type PermissionJSON struct {
+	Name        string `json:"name"`
+	Description string `json:"description"`
+}
+
+type Role struct {
+	Name        string `db:"name"`
+	Description string `db:"description"`
+}
+
+type RoleJSON struct {
+	Name        string   `json:"name"`
+	Description string   `json:"description"`
+	Permissions []string `json:"permissions"`
+}
+
+type rolePermission struct {
+	Role       string `db:"role"`
+	Permission string `db:"permission"`
+}
+
+/** Functions and Methods */
+
+// HasPermission reports whether any of the user's roles grants
+// permission.
+func (u ADBUser) HasPermission(permission string) bool {
+	return u.Permissions[permission]
+}
+
+// HasAnyPermission reports whether any of the user's roles grants any
+// of permissions.
+func (u ADBUser) HasAnyPermission(permissions ...string) bool {
+	for _, p := range permissions {
+		if u.HasPermission(p) {
+			return true
+		}
+	}
+	return false
+}
+
+func isPermission(permission string) bool {
+	for _, p := range Permissions {
+		if p.Name == permission {
+			return true
+		}
+	}
+	return false
+}
+
+func allPermissions() []string {
+	var permissions []string
+	for _, p := range Permissions {
+		permissions = append(permissions, p.Name)
+	}
+	return permissions
+}
+
+// GetPermissions returns the permissions granted by any of roles.
+func GetPermissions(db *sqlx.DB, roles []string) (map[string]bool, error) {
+	permissions := map[string]bool{}
+	if len(roles) == 0 {
+		return permissions, nil
+	}
+	for _, role := range roles {
+		if role == AdminRole {
+			for _, p := range allPermissions() {
+				permissions[p] = true
+			}
+			return permissions, nil
+		}
+	}
+
+	query, args, err := sqlx.In(`
+SELECT DISTINCT permission FROM role_permissions WHERE role IN (?)`, roles)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to build permissions query")
+	}
+	var granted []string
+	if err := db.Select(&granted, db.Rebind(query), args...); err != nil {
+		return nil, errors.Wrap(err, "failed to select permissions")
+	}
+	for _, p := range granted {
+		permissions[p] = true
+	}
+	return permissions, nil
+}
+
+func GetRolesJSON(db *sqlx.DB) ([]RoleJSON, error) {
+	var roles []Role
+	if err := db.Select(&roles, `SELECT name, description FROM roles ORDER BY name`); err != nil {
+		return nil, errors.Wrap(err, "failed to select roles")
+	}
+	var rolePermissions []rolePermission
+	if err := db.Select(&rolePermissions, `SELECT role, permission FROM role_permissions`); err != nil {
+		return nil, errors.Wrap(err, "failed to select role permissions")
+	}
+
+	granted := map[string]map[string]bool{}
+	for _, rp := range rolePermissions {
+		if granted[rp.Role] == nil {
+			granted[rp.Role] = map[string]bool{}
+		}
+		granted[rp.Role][rp.Permission] = true
+	}
+
+	rolesJSON := []RoleJSON{}
+	for _, r := range roles {
+		roleJSON := RoleJSON{
+			Name:        r.Name,
+			Description: r.Description,
+			Permissions: []string{},
+		}
+		// Keep permissions in the same order as Permissions.
+		for _, p := range Permissions {
+			if r.Name == AdminRole || granted[r.Name][p.Name] {
+				roleJSON.Permissions = append(roleJSON.Permissions, p.Name)
+			}
+		}
+		rolesJSON = append(rolesJSON, roleJSON)
+	}
+	return rolesJSON, nil
+}
+
+func CleanRoleData(body io.Reader) (RoleJSON, error) {
+	var role RoleJSON
+	if err := json.NewDecoder(body).Decode(&role); err != nil {
+		return RoleJSON{}, err
+	}
+	role.Name = strings.TrimSpace(role.Name)
+	role.Description = strings.TrimSpace(role.Description)
+	if !roleNameRegexp.MatchString(role.Name) {
+		return RoleJSON{}, errors.New("Role names must be lowercase letters, numbers and underscores, and start with a letter")
+	}
+	if len(role.Description) > 255 {
+		return RoleJSON{}, errors.New("Role description is too long")
+	}
+	for _, p := range role.Permissions {
+		if !isPermission(p) {
+			return RoleJSON{}, errors.Errorf("Not a valid permission: %s", p)
+		}
+	}
+	return role, nil
+}
+
+// SaveRole creates a role, or replaces the description and
+// permissions of an existing one.
+func SaveRole(db *sqlx.DB, role RoleJSON) error {
+	if role.Name == AdminRole {
+		return errors.New("The admin role always has every permission and can't be changed")
+	}
+
+	tx, err := db.Beginx()
+	if err != nil {
+		return errors.Wrap(err, "failed to create transaction")
+	}
+	_, err = tx.Exec(`
+INSERT INTO roles (name, description) VALUES (?, ?)
+ON DUPLICATE KEY UPDATE description = VALUES(description)`, role.Name, role.Description)
+	if err != nil {
+		tx.Rollback()
+		return errors.Wrapf(err, "failed to save role %s", role.Name)
+	}
+	if _, err := tx.Exec(`DELETE FROM role_permissions WHERE role = ?`, role.Name); err != nil {
+		tx.Rollback()
+		return errors.Wrapf(err, "failed to clear permissions for role %s", role.Name)
+	}
+	for _, p := range role.Permissions {
+		_, err := tx.Exec(`INSERT IGNORE INTO role_permissions (role, permission) VALUES (?, ?)`, role.Name, p)
+		if err != nil {
+			tx.Rollback()
+			return errors.Wrapf(err, "failed to add permission %s to role %s", p, role.Name)
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		tx.Rollback()
+		return errors.Wrapf(err, "failed to commit role %s", role.Name)
+	}
+	return nil
+}
+
+// DeleteRole deletes a role that no user or API key has.
+func DeleteRole(db *sqlx.DB, name string) error {
+	if name == AdminRole {
+		return errors.New("The admin role can't be deleted")
+	}
+
+	var inUse int
+	err := db.Get(&inUse, `
+SELECT
+  (SELECT COUNT(*) FROM users_roles WHERE role = ?)
+  + (SELECT COUNT(*) FROM api_keys WHERE role = ? AND revoked_at IS NULL)`, name, name)
+	if err != nil {
+		return errors.Wrapf(err, "failed to check if role %s is in use", name)
+	}
+	if inUse != 0 {
+		return errors.Errorf("Role %s is still given to users or API keys", name)
+	}
+
+	res, err := db.Exec(`DELETE FROM roles WHERE name = ?`, name)
+	if err != nil {
+		return errors.Wrapf(err, "failed to delete role %s", name)
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return errors.Wrapf(err, "failed to delete role %s", name)
+	}
+	if n == 0 {
+		return errors.Errorf("Role %s does not exist", name)
+	}
+	return nil
+}
+
+func roleExists(db sqlx.Queryer, name string) (bool, error) {
+	var count int
+	if err := sqlx.Get(db, &count, `SELECT COUNT(*) FROM roles WHERE name = ?`, name); err != nil {
+		return false, errors.Wrapf(err, "failed to look up role %s", name)
+	}
+	return count != 0, nil
+}
@@ -0,0 +1,81 @@
+package model
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestCleanRoleData(t *testing.T) {
+	role, err := CleanRoleData(strings.NewReader(`{
+  "name": " working_group_lead ",
+  "description": " Leads a working group ",
+  "permissions": ["activist.read", "group.write"]
+}`))
+	require.NoError(t, err)
+	require.Equal(t, RoleJSON{
+		Name:        "working_group_lead",
+		Description: "Leads a working group",
+		Permissions: []string{"activist.read", "group.write"},
+	}, role)
+
+	for _, body := range []string{
+		`{"name": ""}`,
+		`{"name": "Working Group Lead"}`,
+		`{"name": "1st_role"}`,
+		`{"name": "lead", "permissions": ["activist.delete_everything"]}`,
+	} {
+		_, err := CleanRoleData(strings.NewReader(body))
+		require.Error(t, err, body)
+	}
+}
+
+func TestRoles(t *testing.T) {
+	db := newTestDB()
+	defer db.Close()
+
+	// admin has every permission, including ones it was never
+	// given explicitly.
+	permissions, err := GetPermissions(db, []string{"attendance", AdminRole})
+	require.NoError(t, err)
+	require.Len(t, permissions, len(Permissions))
+
+	permissions, err = GetPermissions(db, []string{"attendance"})
+	require.NoError(t, err)
+	require.True(t, permissions[PermissionEventWrite])
+	require.False(t, permissions[PermissionActivistRead])
+
+	require.Error(t, SaveRole(db, RoleJSON{Name: AdminRole}))
+	require.NoError(t, SaveRole(db, RoleJSON{
+		Name:        "working_group_lead",
+		Permissions: []string{PermissionActivistRead, PermissionGroupWrite},
+	}))
+
+	userID, err := CreateUser(db, ADBUser{Email: "lead@example.com", Name: "Lead"})
+	require.NoError(t, err)
+	_, err = CreateUserRole(db, UserRole{UserID: userID, Role: "superuser"})
+	require.Error(t, err)
+	_, err = CreateUserRole(db, UserRole{UserID: userID, Role: "working_group_lead"})
+	require.NoError(t, err)
+
+	user, err := GetADBUser(db, userID, "")
+	require.NoError(t, err)
+	require.True(t, user.HasPermission(PermissionGroupWrite))
+	require.False(t, user.HasPermission(PermissionActivistWrite))
+
+	// Roles that someone has can't be deleted.
+	require.Error(t, DeleteRole(db, "working_group_lead"))
+	_, err = RemoveUserRole(db, UserRole{UserID: userID, Role: "working_group_lead"})
+	require.NoError(t, err)
+	require.NoError(t, DeleteRole(db, "working_group_lead"))
+	require.Error(t, DeleteRole(db, AdminRole))
+
+	roles, err := GetRolesJSON(db)
+	require.NoError(t, err)
+	var names []string
+	for _, r := range roles {
+		names = append(names, r.Name)
+	}
+	require.Equal(t, []string{"admin", "attendance", "organizer"}, names)
+}
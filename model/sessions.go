@@ -0,0 +1,218 @@
+package model
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/base64"
+	"encoding/hex"
+	"net"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/pkg/errors"
+)
+
+/** Constant and Variable Definitions */
+
+// SessionDuration is how long a sign-in lasts.
+const SessionDuration = 30 * 24 * time.Hour
+
+// sessionLastSeenInterval is how stale a session's last_seen_at can
+// get before a request updates it, so most requests don't write.
+const sessionLastSeenInterval = 5 * time.Minute
+
+const selectSessionBaseQuery string = `
+SELECT
+  s.id,
+  s.user_id,
+  IFNULL(u.name, '') AS user_name,
+  IFNULL(u.email, '') AS user_email,
+  s.created_at,
+  s.last_seen_at,
+  s.expires_at,
+  s.ip,
+  s.user_agent
+FROM sessions s
+LEFT JOIN adb_users u ON u.id = s.user_id
+`
+
+/** Type Definitions */
+
+type Session struct {
+	ID         int       `db:"id"`
+	UserID     int       `db:"user_id"`
+	UserName   string    `db:"user_name"`
+	UserEmail  string    `db:"user_email"`
+	CreatedAt  time.Time `db:"created_at"`
+	LastSeenAt time.Time `db:"last_seen_at"`
+	ExpiresAt  time.Time `db:"expires_at"`
+	IP         string    `db:"ip"`
+	UserAgent  string    `db:"user_agent"`
+}
+
+type SessionJSON struct {
+	ID         int    `json:"id"`
+	UserID     int    `json:"user_id"`
+	UserName   string `json:"user_name"`
+	UserEmail  string `json:"user_email"`
+	CreatedAt  string `json:"created_at"`
+	LastSeenAt string `json:"last_seen_at"`
+	ExpiresAt  string `json:"expires_at"`
+	IP         string `json:"ip"`
+	UserAgent  string `json:"user_agent"`
+}
+
+/** Functions and Methods */
+
+// CreateSession signs a user in, and returns the token to put in their
+// session cookie. Only a hash of the token is stored.
+func CreateSession(db *sqlx.DB, userID int, ip, userAgent string) (string, error) {
+	if userID == 0 {
+		return "", errors.New("User ID cannot be 0")
+	}
+	ip = sessionIP(ip)
+	if len(userAgent) > 255 {
+		userAgent = userAgent[:255]
+	}
+
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", errors.Wrap(err, "failed to generate session token")
+	}
+	token := base64.RawURLEncoding.EncodeToString(b)
+
+	tx, err := db.Beginx()
+	if err != nil {
+		return "", errors.Wrap(err, "failed to create transaction")
+	}
+	// Clean up the user's expired sessions while we're here.
+	_, err = tx.Exec(`DELETE FROM sessions WHERE user_id = ? AND expires_at <= NOW()`, userID)
+	if err != nil {
+		tx.Rollback()
+		return "", errors.Wrapf(err, "failed to delete expired sessions for user %d", userID)
+	}
+	_, err = tx.Exec(`
+INSERT INTO sessions (token_hash, user_id, expires_at, ip, user_agent)
+VALUES (?, ?, ?, ?, ?)`, hashSessionToken(token), userID, time.Now().Add(SessionDuration), ip, userAgent)
+	if err != nil {
+		tx.Rollback()
+		return "", errors.Wrapf(err, "failed to create session for user %d", userID)
+	}
+	if err := tx.Commit(); err != nil {
+		tx.Rollback()
+		return "", errors.Wrapf(err, "failed to commit session for user %d", userID)
+	}
+	return token, nil
+}
+
+// AuthenticateSession returns the session matching token, or an error
+// if there isn't one or it has expired. It also records that the
+// session was seen from ip.
+func AuthenticateSession(db *sqlx.DB, token, ip string) (Session, error) {
+	if token == "" {
+		return Session{}, errors.New("Invalid session")
+	}
+
+	var session Session
+	err := db.Get(&session, selectSessionBaseQuery+`
+WHERE s.token_hash = ? AND s.expires_at > NOW()`, hashSessionToken(token))
+	if err == sql.ErrNoRows {
+		return Session{}, errors.New("Invalid session")
+	}
+	if err != nil {
+		return Session{}, errors.Wrap(err, "failed to select session")
+	}
+
+	ip = sessionIP(ip)
+	if time.Since(session.LastSeenAt) > sessionLastSeenInterval || session.IP != ip {
+		_, err := db.Exec(`
+UPDATE sessions SET last_seen_at = NOW(), ip = ? WHERE id = ?`, ip, session.ID)
+		if err != nil {
+			return Session{}, errors.Wrapf(err, "failed to update session %d", session.ID)
+		}
+	}
+	return session, nil
+}
+
+// GetActiveSessionsJSON returns every session that hasn't expired,
+// most recently seen first.
+func GetActiveSessionsJSON(db *sqlx.DB) ([]SessionJSON, error) {
+	var sessions []Session
+	err := db.Select(&sessions, selectSessionBaseQuery+`
+WHERE s.expires_at > NOW()
+ORDER BY s.last_seen_at DESC`)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to select sessions")
+	}
+
+	sessionsJSON := []SessionJSON{}
+	for _, s := range sessions {
+		sessionsJSON = append(sessionsJSON, SessionJSON{
+			ID:         s.ID,
+			UserID:     s.UserID,
+			UserName:   s.UserName,
+			UserEmail:  s.UserEmail,
+			CreatedAt:  s.CreatedAt.Format(time.RFC3339),
+			LastSeenAt: s.LastSeenAt.Format(time.RFC3339),
+			ExpiresAt:  s.ExpiresAt.Format(time.RFC3339),
+			IP:         s.IP,
+			UserAgent:  s.UserAgent,
+		})
+	}
+	return sessionsJSON, nil
+}
+
+// RevokeSession signs a session out. Its cookie stops working on the
+// next request.
+func RevokeSession(db *sqlx.DB, id int) error {
+	res, err := db.Exec(`DELETE FROM sessions WHERE id = ?`, id)
+	if err != nil {
+		return errors.Wrapf(err, "failed to revoke session %d", id)
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return errors.Wrapf(err, "failed to revoke session %d", id)
+	}
+	if n == 0 {
+		return errors.Errorf("Session %d does not exist", id)
+	}
+	return nil
+}
+
+// RevokeSessionToken signs out the session matching token, if there is
+// one.
+func RevokeSessionToken(db *sqlx.DB, token string) error {
+	_, err := db.Exec(`DELETE FROM sessions WHERE token_hash = ?`, hashSessionToken(token))
+	if err != nil {
+		return errors.Wrap(err, "failed to revoke session")
+	}
+	return nil
+}
+
+// revokeUserSessions signs a user out everywhere.
+func revokeUserSessions(tx *sqlx.Tx, userID int) error {
+	_, err := tx.Exec(`DELETE FROM sessions WHERE user_id = ?`, userID)
+	if err != nil {
+		return errors.Wrapf(err, "failed to revoke sessions for user %d", userID)
+	}
+	return nil
+}
+
+func hashSessionToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// sessionIP returns the IP address of remoteAddr, which is usually a
+// host:port, as it's stored in sessions.
+func sessionIP(remoteAddr string) string {
+	ip := remoteAddr
+	if host, _, err := net.SplitHostPort(remoteAddr); err == nil {
+		ip = host
+	}
+	if len(ip) > 64 {
+		ip = ip[:64]
+	}
+	return ip
+}
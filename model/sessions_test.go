@@ -0,0 +1,51 @@
+package model
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestSessions(t *testing.T) {
+	db := newTestDB()
+	defer db.Close()
+
+	userID, err := CreateUser(db, ADBUser{Email: "organizer@example.com", Name: "Organizer"})
+	require.NoError(t, err)
+
+	token, err := CreateSession(db, userID, "10.0.0.1:1234", "Firefox")
+	require.NoError(t, err)
+
+	session, err := AuthenticateSession(db, token, "10.0.0.2:1234")
+	require.NoError(t, err)
+	require.Equal(t, userID, session.UserID)
+	_, err = AuthenticateSession(db, token+"x", "")
+	require.Error(t, err)
+
+	sessions, err := GetActiveSessionsJSON(db)
+	require.NoError(t, err)
+	require.Len(t, sessions, 1)
+	require.Equal(t, "Organizer", sessions[0].UserName)
+	require.Equal(t, "10.0.0.2", sessions[0].IP)
+	require.Equal(t, "Firefox", sessions[0].UserAgent)
+
+	require.NoError(t, RevokeSession(db, session.ID))
+	require.Error(t, RevokeSession(db, session.ID))
+	_, err = AuthenticateSession(db, token, "")
+	require.Error(t, err)
+
+	// Disabling a user signs them out everywhere.
+	token, err = CreateSession(db, userID, "", "")
+	require.NoError(t, err)
+	_, err = UpdateUser(db, ADBUser{ID: userID, Email: "organizer@example.com", Name: "Organizer", Disabled: true})
+	require.NoError(t, err)
+	_, err = AuthenticateSession(db, token, "")
+	require.Error(t, err)
+}
+
+func TestSessionIP(t *testing.T) {
+	require.Equal(t, "10.0.0.1", sessionIP("10.0.0.1:1234"))
+	require.Equal(t, "::1", sessionIP("[::1]:1234"))
+	require.Equal(t, "10.0.0.1", sessionIP("10.0.0.1"))
+	require.Equal(t, "", sessionIP(""))
+}
@@ -282,6 +282,48 @@ WHERE id = ?`, workingGroupID)
 	return nil
 }
 
+// LeadsWorkingGroup reports whether the activist is a point person of
+// the working group.
+func LeadsWorkingGroup(db *sqlx.DB, activistID, workingGroupID int) (bool, error) {
+	if activistID == 0 || workingGroupID == 0 {
+		return false, nil
+	}
+	var count int
+	err := db.Get(&count, `
+SELECT COUNT(*)
+FROM working_group_members
+WHERE working_group_id = ? AND activist_id = ? AND point_person = 1`, workingGroupID, activistID)
+	if err != nil {
+		return false, errors.Wrapf(err, "failed to check if activist %d leads working group %d", activistID, workingGroupID)
+	}
+	return count != 0, nil
+}
+
+// CheckWorkingGroupMembersNotAdded returns an error if workingGroup has members
+// or point people that the stored working group doesn't. Users who can only
+// edit the groups they lead may remove members, but adding them would
+// let them see more activists.
+func CheckWorkingGroupMembersNotAdded(db *sqlx.DB, workingGroup WorkingGroup) error {
+	stored, err := GetWorkingGroup(db, WorkingGroupQueryOptions{GroupID: workingGroup.ID})
+	if err != nil {
+		return err
+	}
+	pointPeople := map[int]bool{}
+	for _, m := range stored.Members {
+		pointPeople[m.ActivistID] = m.PointPerson
+	}
+	for _, m := range workingGroup.Members {
+		pointPerson, ok := pointPeople[m.ActivistID]
+		if !ok {
+			return errors.Errorf("You can't add %s to the working group", m.ActivistName)
+		}
+		if m.PointPerson && !pointPerson {
+			return errors.Errorf("You can't make %s a point person of the working group", m.ActivistName)
+		}
+	}
+	return nil
+}
+
 func GetWorkingGroupJSON(db *sqlx.DB, workingGroupID int) (WorkingGroupJSON, error) {
 	wgs, err := getWorkingGroupsJSON(db, WorkingGroupQueryOptions{
 		GroupID: workingGroupID,
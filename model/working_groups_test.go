@@ -198,6 +198,72 @@ func TestUpdateWorkingGroup_updateMultipleGroups(t *testing.T) {
 
 }
 
+func TestLeadsWorkingGroup(t *testing.T) {
+	db := newTestDB()
+	defer db.Close()
+
+	members := insertActivists(t, db, []string{"Point Person", "Member", "Outsider"})
+	members[0].PointPerson = true
+	id, err := CreateWorkingGroup(db, WorkingGroup{
+		Name:    "Outreach",
+		Type:    working_group_db_value,
+		Members: members[:2],
+	})
+	require.NoError(t, err)
+
+	leads, err := LeadsWorkingGroup(db, members[0].ActivistID, id)
+	require.NoError(t, err)
+	require.True(t, leads)
+	leads, err = LeadsWorkingGroup(db, members[1].ActivistID, id)
+	require.NoError(t, err)
+	require.False(t, leads)
+	// Users who aren't linked to an activist don't lead anything.
+	leads, err = LeadsWorkingGroup(db, 0, id)
+	require.NoError(t, err)
+	require.False(t, leads)
+
+	// The point person can only list the members of their group.
+	activists, err := GetActivistsJSON(db, GetActivistOptions{LedBy: members[0].ActivistID}, testViewer)
+	require.NoError(t, err)
+	var names []string
+	for _, a := range activists {
+		names = append(names, a.Name)
+	}
+	require.ElementsMatch(t, []string{"Point Person", "Member"}, names)
+}
+
+func TestCheckWorkingGroupMembersNotAdded(t *testing.T) {
+	db := newTestDB()
+	defer db.Close()
+
+	members := insertActivists(t, db, []string{"Point Person", "Member", "Outsider"})
+	members[0].PointPerson = true
+	wg := WorkingGroup{
+		Name:    "Outreach",
+		Type:    working_group_db_value,
+		Members: members[:2],
+	}
+	id, err := CreateWorkingGroup(db, wg)
+	require.NoError(t, err)
+	wg.ID = id
+
+	require.NoError(t, CheckWorkingGroupMembersNotAdded(db, wg))
+
+	// Removing members is fine.
+	removed := wg
+	removed.Members = members[:1]
+	require.NoError(t, CheckWorkingGroupMembersNotAdded(db, removed))
+
+	added := wg
+	added.Members = members
+	require.Error(t, CheckWorkingGroupMembersNotAdded(db, added))
+
+	promoted := wg
+	promoted.Members = []WorkingGroupMember{members[0], members[1]}
+	promoted.Members[1].PointPerson = true
+	require.Error(t, CheckWorkingGroupMembersNotAdded(db, promoted))
+}
+
 func validateReturnedWorkingGroup(t *testing.T, inserted WorkingGroup, returned WorkingGroup) {
 	require.Equal(t, inserted.ID, returned.ID)
 	require.Equal(t, inserted.Name, returned.Name)
@@ -221,7 +287,7 @@ func validateReturnedWorkingGroup(t *testing.T, inserted WorkingGroup, returned
 func insertActivists(t *testing.T, db *sqlx.DB, names []string) []WorkingGroupMember {
 	members := make([]WorkingGroupMember, len(names))
 	for idx, a := range names {
-		activist, err := GetOrCreateActivist(db, a)
+		activist, err := GetOrCreateActivist(db, a, ADBUser{})
 		require.NoError(t, err)
 		members[idx] = WorkingGroupMember{
 			ActivistName: activist.Name,
@@ -0,0 +1,30 @@
+// Package scheduler runs background jobs on an interval.
+package scheduler
+
+import (
+	"log"
+	"time"
+)
+
+// Every runs job right away, and again interval after each run
+// finishes, forever. Errors and panics are logged rather than stopping
+// the schedule. Should be run in a goroutine.
+func Every(name string, interval time.Duration, job func() error) {
+	for {
+		run(name, job)
+		time.Sleep(interval)
+	}
+}
+
+// run runs job once, logging its error or panic.
+func run(name string, job func() error) {
+	defer func() {
+		if r := recover(); r != nil {
+			log.Printf("Recovered from panic in %s: %v", name, r)
+		}
+	}()
+
+	if err := job(); err != nil {
+		log.Printf("Failed to %s: %v", name, err)
+	}
+}
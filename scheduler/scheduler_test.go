@@ -0,0 +1,23 @@
+package scheduler
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestRun(t *testing.T) {
+	runs := 0
+	run("count runs", func() error {
+		runs++
+		return nil
+	})
+	require.Equal(t, 1, runs)
+
+	// Neither an error nor a panic escapes.
+	run("fail", func() error { return errors.New("failed") })
+	require.NotPanics(t, func() {
+		run("panic", func() error { panic("oops") })
+	})
+}
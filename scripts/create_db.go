@@ -33,12 +33,12 @@ func createCurrentDateString(day int) string {
 func createEventsDevDB() string {
 	days := []int{15, 16, 17, 18, 19, 13}
 	eventFormatStrings := []string{
-		"(1, 'Event One', '%s', 'Working Group', '0'),",
-		"(2, 'Event Two', '%s', 'Protest', '0'),",
+		"(1, 'Event One', '%s', 'Meeting', '0'),",
+		"(2, 'Event Two', '%s', 'Action', '0'),",
 		"(3, 'Event Three', '%s', 'Community', '0'),",
 		"(4, 'Event Four', '%s', 'Outreach', '0'),",
-		"(5, 'Event Five', '%s', 'Key Event', '0'),",
-		"(6, 'Event Six', '%s', 'Key Event', '0');"}
+		"(5, 'Event Five', '%s', 'Training', '0'),",
+		"(6, 'Event Six', '%s', 'Circle', '0');"}
 
 	//assert
 	if len(days) != len(eventFormatStrings) {
@@ -76,7 +76,7 @@ INSERT INTO activists
   (107, 'lll', 'test.test.test@gmail.com', '', 'United States', 'Supporter'),
   (108, 'mmm', 'test@gmail.com', '', 'United States', 'Supporter');
 
-INSERT INTO events VALUES
+INSERT INTO events (id, name, date, event_type, survey_sent) VALUES
   %s
 
 
@@ -98,6 +98,9 @@ SELECT id, 'admin' FROM adb_users WHERE id IN(1, 2, 3, 4, 5, 6);
 	if !noFakeData {
 		// Insert sample data
 		db.MustExec(insertStatement)
+		if err := model.RebuildActivistMetrics(db); err != nil {
+			panic(err)
+		}
 	}
 }
 
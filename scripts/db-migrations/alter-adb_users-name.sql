@@ -1,2 +0,0 @@
-ALTER TABLE adb_users
-ADD COLUMN `name` VARCHAR(150) NOT NULL DEFAULT '' AFTER `email`;
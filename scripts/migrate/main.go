@@ -0,0 +1,90 @@
+// Brings a database schema up (or down) to a migration version.
+//
+// Usage:
+//
+//	go run ./scripts/migrate               # migrate the dev/prod db to the latest version
+//	go run ./scripts/migrate -test         # migrate the test db instead
+//	go run ./scripts/migrate -status       # list migrations and whether they're applied
+//	go run ./scripts/migrate -to=3         # migrate up or down to version 3
+//	go run ./scripts/migrate -baseline=2   # mark 1-2 as applied without running them
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+
+	"github.com/dxe/adb/config"
+	"github.com/dxe/adb/migrations"
+	"github.com/dxe/adb/model"
+	"github.com/jmoiron/sqlx"
+)
+
+var (
+	useTestDB bool
+	status    bool
+	target    int
+	baseline  int
+	allowDown bool
+)
+
+func init() {
+	flag.BoolVar(&useTestDB, "test", false, "Migrate the test database instead of the dev/prod database")
+	flag.BoolVar(&status, "status", false, "Print the status of every migration and exit")
+	flag.IntVar(&target, "to", -1, "The version to migrate to; defaults to the latest version")
+	flag.IntVar(&baseline, "baseline", 0, "Record migrations up to this version as applied without running them, for databases created before migrations existed")
+	flag.BoolVar(&allowDown, "allow-down", false, "Allow reverting migrations in prod")
+	flag.Parse()
+}
+
+func printStatus(db *sqlx.DB) {
+	statuses, err := migrations.Status(db)
+	if err != nil {
+		log.Fatal(err)
+	}
+	for _, s := range statuses {
+		applied := "pending"
+		if s.Applied {
+			applied = "applied " + s.AppliedAt.Format("2006-01-02 15:04:05")
+		}
+		fmt.Printf("%4d  %-40s  %s\n", s.Version, s.Name, applied)
+	}
+}
+
+func main() {
+	dataSource := config.DBDataSource()
+	if useTestDB {
+		dataSource = config.DBTestDataSource()
+	}
+	db := model.NewDB(dataSource)
+	defer db.Close()
+
+	if status {
+		printStatus(db)
+		return
+	}
+
+	if baseline != 0 {
+		if err := migrations.Baseline(db, baseline); err != nil {
+			log.Fatal(err)
+		}
+		fmt.Println("Baselined database at version", baseline)
+		return
+	}
+
+	current, err := migrations.Version(db)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if target == -1 {
+		target = migrations.Latest()
+	}
+	if target < current && config.IsProd && !allowDown {
+		log.Fatalf("Refusing to revert prod from version %d to %d without -allow-down", current, target)
+	}
+
+	if err := migrations.MigrateTo(db, target); err != nil {
+		log.Fatal(err)
+	}
+	fmt.Printf("Migrated database from version %d to %d\n", current, target)
+}
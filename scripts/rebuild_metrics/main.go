@@ -0,0 +1,43 @@
+// Recomputes the activist_metrics table and the MPI flag from scratch,
+// using the rules in MPI_RULES_FILE if it's set.
+//
+// Usage:
+//
+//	go run ./scripts/rebuild_metrics         # rebuild the dev/prod db
+//	go run ./scripts/rebuild_metrics -test   # rebuild the test db instead
+package main
+
+import (
+	"flag"
+	"log"
+
+	"github.com/dxe/adb/config"
+	"github.com/dxe/adb/model"
+)
+
+var useTestDB bool
+
+func init() {
+	flag.BoolVar(&useTestDB, "test", false, "Rebuild the test database instead of the dev/prod database")
+	flag.Parse()
+}
+
+func main() {
+	dataSource := config.DBDataSource()
+	if useTestDB {
+		dataSource = config.DBTestDataSource()
+	}
+	db := model.NewDB(dataSource)
+	defer db.Close()
+
+	if config.MPIRulesFile != "" {
+		if err := model.LoadMPIRules(config.MPIRulesFile); err != nil {
+			log.Fatalf("%+v", err)
+		}
+	}
+
+	if err := model.RebuildActivistMetrics(db); err != nil {
+		log.Fatalf("%+v", err)
+	}
+	log.Println("Rebuilt activist metrics")
+}
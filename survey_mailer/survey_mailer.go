@@ -14,6 +14,12 @@ import (
 	"github.com/sourcegraph/go-ses"
 )
 
+// In BodyText and BodyHtml, LINK_PARAM is replaced with the event's
+// name or date, depending on LinkParam, and these are replaced with
+// the event's details, or left empty if they aren't known:
+//
+//	EVENT_NAME, EVENT_DATE, EVENT_TIME, EVENT_LOCATION,
+//	EVENT_ORGANIZER, EVENT_GROUP
 type SurveyOptions struct {
 	SurveyType     string
 	QueryDate      string
@@ -129,10 +135,11 @@ func survey(db *sqlx.DB, surveyOptions SurveyOptions) {
 		if surveyOptions.LinkParam == "date" {
 			linkParam = event.EventDate.Format("2006-01-02")
 		}
-		// build body by replacing LINK_PARAM with the actual link param
-		bodyText := strings.Replace(surveyOptions.BodyText, "LINK_PARAM", linkParam, -1)
+		// build body by replacing LINK_PARAM with the actual link
+		// param, and the event placeholders with the event's details
+		bodyText := eventReplacer(event, linkParam, false).Replace(surveyOptions.BodyText)
 		// TODO: Look into better ways for escaping this to prevent XSS attacks
-		bodyHtml := strings.Replace(surveyOptions.BodyHtml, "LINK_PARAM", html.EscapeString(linkParam), -1)
+		bodyHtml := eventReplacer(event, linkParam, true).Replace(surveyOptions.BodyHtml)
 
 		log.Println("Sending", surveyOptions.SurveyType, "survey for event:", event.EventName)
 
@@ -144,6 +151,47 @@ func survey(db *sqlx.DB, surveyOptions SurveyOptions) {
 	}
 }
 
+// eventReplacer replaces the placeholders in survey bodies with the
+// event's details, escaping them for HTML bodies.
+func eventReplacer(event model.Event, linkParam string, escapeHTML bool) *strings.Replacer {
+	group := event.WorkingGroupName
+	if group == "" {
+		group = event.CircleName
+	}
+	values := []string{
+		"LINK_PARAM", linkParam,
+		"EVENT_NAME", event.EventName,
+		"EVENT_DATE", event.EventDate.Format("Monday, January 2"),
+		"EVENT_TIME", formatEventTime(event),
+		"EVENT_LOCATION", event.Location,
+		"EVENT_ORGANIZER", event.LeadOrganizerName,
+		"EVENT_GROUP", group,
+	}
+	if escapeHTML {
+		for i := 1; i < len(values); i += 2 {
+			values[i] = html.EscapeString(values[i])
+		}
+	}
+	return strings.NewReplacer(values...)
+}
+
+// formatEventTime formats an event's start and end time like
+// "7:00 PM - 9:00 PM".
+func formatEventTime(event model.Event) string {
+	var times []string
+	for _, t := range []string{event.StartTime, event.EndTime} {
+		if t == "" {
+			continue
+		}
+		parsed, err := time.Parse(model.EventTimeLayout, t)
+		if err != nil {
+			continue
+		}
+		times = append(times, parsed.Format("3:04 PM"))
+	}
+	return strings.Join(times, " - ")
+}
+
 func surveyMailerWrapper(db *sqlx.DB) {
 	defer func() {
 		if r := recover(); r != nil {